@@ -0,0 +1,158 @@
+package app
+
+import (
+  "fmt"
+  "strings"
+  "net/url"
+  "net/http"
+  "runtime/debug"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
+)
+
+type errorText struct {
+  Title string
+  Message string
+}
+
+var errorTexts = map[int]errorText{
+  http.StatusBadRequest: {"Bad request", "The request was missing information or contained invalid values."},
+  http.StatusUnauthorized: {"Not signed in", "You need to sign in to see this page."},
+  http.StatusForbidden: {"Access denied", "You do not have access to this page."},
+  http.StatusNotFound: {"Page not found", "The page you are looking for does not exist or has been moved."},
+  http.StatusMethodNotAllowed: {"Method not allowed", "The page does not support this kind of request."},
+  http.StatusConflict: {"Conflict", "The request conflicts with the current state of the item."},
+  http.StatusTooManyRequests: {"Too many requests", "You have made too many requests. Please wait a moment and try again."},
+  http.StatusInternalServerError: {"Something went wrong", "An unexpected error occurred. It has been logged, please try again."},
+  http.StatusBadGateway: {"Service unavailable", "A service meui depends on failed to answer. Please try again in a moment."},
+  http.StatusServiceUnavailable: {"Service unavailable", "meui is unable to handle the request right now. Please try again in a moment."},
+  http.StatusGatewayTimeout: {"Service timed out", "A service meui depends on took too long to answer. Please try again in a moment."},
+}
+
+// Hold back the headers of responses aborted with an error status and no body, so ErrorPages can render one.
+type errorWriter struct {
+  gin.ResponseWriter
+  held bool
+}
+
+func (w *errorWriter) WriteHeaderNow() {
+  if !w.Written() && w.Status() >= http.StatusBadRequest {
+    w.held = true
+    return
+  }
+  w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *errorWriter) Write(data []byte) (int, error) {
+  w.held = false
+  return w.ResponseWriter.Write(data)
+}
+
+func (w *errorWriter) WriteString(s string) (int, error) {
+  w.held = false
+  return w.ResponseWriter.WriteString(s)
+}
+
+// AbortWithError aborts with status and a message for the user. ErrorPages shows it, translated, instead of the generic explanation for the status.
+func AbortWithError(c *gin.Context, status int, message string) {
+  c.Error(fmt.Errorf("%s", message)).SetType(gin.ErrorTypePublic)
+  c.AbortWithStatus(status)
+}
+
+// ErrorPages renders a branded error page for responses aborted with an error status and no body, eg. c.AbortWithStatus(http.StatusBadGateway).
+// Ajax and api callers, and clients asking for json, get a json error instead. Panics are recovered and rendered as 500.
+// Must be used after the sessions middleware as error pages are rendered with the shared page context.
+func ErrorPages() gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    w := &errorWriter{ResponseWriter: c.Writer}
+    c.Writer = w
+
+    defer func() {
+      if r := recover(); r != nil {
+        log := c.MustGet(environment.LogKey).(*logrus.Entry)
+        log.WithFields(logrus.Fields{
+          "panic": fmt.Sprintf("%v", r),
+        }).Error(string(debug.Stack()))
+
+        c.Writer = w.ResponseWriter
+        if !c.Writer.Written() {
+          c.Abort()
+          renderError(c, http.StatusInternalServerError)
+        }
+      }
+    }()
+
+    c.Next()
+
+    // Also catch handlers that only set an error status, eg. c.Status(http.StatusNotFound)
+    c.Writer = w.ResponseWriter
+    if w.held || (!c.Writer.Written() && c.Writer.Status() >= http.StatusBadRequest) {
+      renderError(c, c.Writer.Status())
+    }
+  }
+  return gin.HandlerFunc(fn)
+}
+
+func renderError(c *gin.Context, status int) {
+  text, exists := errorTexts[status]
+  if !exists {
+    text = errorTexts[http.StatusInternalServerError]
+    if status < http.StatusInternalServerError {
+      text = errorTexts[http.StatusBadRequest]
+    }
+  }
+
+  lang := i18n.Language(c)
+  title := i18n.T(lang, text.Title)
+  message := i18n.T(lang, text.Message)
+  if e := c.Errors.ByType(gin.ErrorTypePublic).Last(); e != nil {
+    message = i18n.T(lang, e.Error())
+  }
+
+  requestId := c.GetString(environment.RequestIdKey)
+
+  if wantsJson(c) {
+    c.JSON(status, gin.H{
+      "status": status,
+      "error": title,
+      "message": message,
+      "request_id": requestId,
+    })
+    return
+  }
+
+  // Only offer to retry what is safe to repeat and might succeed a moment later
+  var retryUrl string
+  if c.Request.Method == http.MethodGet && (status >= http.StatusInternalServerError || status == http.StatusTooManyRequests) {
+    retryUrl = c.Request.URL.RequestURI()
+  }
+
+  Render(c, status, "error.html", gin.H{
+    "title": title,
+    "status": status,
+    "message": message,
+    "retryUrl": retryUrl,
+    "backUrl": BackUrl(c),
+  })
+}
+
+func wantsJson(c *gin.Context) bool {
+  path := c.Request.URL.Path
+  if strings.HasPrefix(path, "/ajax/") || strings.HasPrefix(path, "/api/") {
+    return true
+  }
+  return c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON
+}
+
+// BackUrl is where the user came from, but never off site
+func BackUrl(c *gin.Context) string {
+  referer, err := url.Parse(c.Request.Referer())
+  if err != nil || referer.Host != c.Request.Host || referer.RequestURI() == c.Request.URL.RequestURI() {
+    return "/"
+  }
+  return referer.RequestURI()
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+  "strings"
+  "context"
   "net/url"
   "net/http"
   "crypto/rand"
@@ -40,11 +42,11 @@ func RequireIdentity(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig, accessToken)
+    idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig(), accessToken)
 
     // Look up profile information for user.
     identityRequest := []idp.ReadHumansRequest{ {Id: idToken.Subject} }
-    status, responses, err := idp.ReadHumans(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.humans.collection"), identityRequest)
+    status, responses, err := idp.ReadHumans(idpClient, config.Get().Idp.Humans, identityRequest)
     if err != nil {
       log.WithFields(logrus.Fields{"error": err}).Debug("Unable to call idp.ReadHumans")
       c.AbortWithStatus(http.StatusInternalServerError)
@@ -76,6 +78,72 @@ func RequireIdentity(env *environment.State) gin.HandlerFunc {
   return gin.HandlerFunc(fn)
 }
 
+// RequireBearerIdentity is RequireIdentity for api requests. The access token in the Authorization header is checked with
+// the userinfo endpoint of hydra and used for every idp and aap call of the request, there is no session nor redirect to login.
+func RequireBearerIdentity(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "RequireBearerIdentity",
+    })
+
+    accessToken := BearerToken(c.Request)
+    if accessToken == nil {
+      c.Header("WWW-Authenticate", `Bearer realm="meui"`)
+      c.AbortWithStatus(http.StatusUnauthorized)
+      return
+    }
+
+    userInfo, err := env.Provider.UserInfo(context.Background(), oauth2.StaticTokenSource(accessToken))
+    if err != nil {
+      log.Debug(err.Error())
+      c.Header("WWW-Authenticate", `Bearer realm="meui", error="invalid_token"`)
+      c.AbortWithStatus(http.StatusUnauthorized)
+      return
+    }
+
+    idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig(), accessToken)
+
+    identityRequest := []idp.ReadHumansRequest{ {Id: userInfo.Subject} }
+    status, responses, err := idp.ReadHumans(idpClient, config.Get().Idp.Humans, identityRequest)
+    if err != nil {
+      log.WithFields(logrus.Fields{"error": err}).Debug("Unable to call idp.ReadHumans")
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
+
+    if status == http.StatusOK {
+      var resp idp.ReadHumansResponse
+      reqStatus, reqErrors := bulky.Unmarshal(0, responses, &resp)
+      if len(reqErrors) == 0 && reqStatus == http.StatusOK && len(resp) > 0 {
+        c.Set(environment.AccessTokenKey, accessToken)
+        c.Set("identity", resp[0])
+        c.Next()
+        return
+      }
+    }
+
+    // Deny by default, eg. tokens of clients are not humans
+    log.WithFields(logrus.Fields{"status": status, "sub": userInfo.Subject}).Debug("No human found for token")
+    c.AbortWithStatus(http.StatusForbidden)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// BearerToken reads the access token from the Authorization header
+func BearerToken(r *http.Request) (*oauth2.Token) {
+  split := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+  if len(split) != 2 || !strings.EqualFold(split[0], "bearer") || strings.TrimSpace(split[1]) == "" {
+    return nil
+  }
+
+  return &oauth2.Token{
+    AccessToken: strings.TrimSpace(split[1]),
+    TokenType: "Bearer",
+  }
+}
+
 func GetIdentity(c *gin.Context) *idp.Human {
   identity, exists := c.Get("identity")
   if exists == true {
@@ -85,7 +153,17 @@ func GetIdentity(c *gin.Context) *idp.Human {
   return nil
 }
 
+// Nonce that inline scripts must carry to pass the Content-Security-Policy
+func CspNonce(c *gin.Context) string {
+  return c.GetString(environment.CspNonceKey)
+}
+
 func AccessToken(c *gin.Context) (*oauth2.Token) {
+  // Api requests bring their own, see RequireBearerIdentity
+  if t, exists := c.Get(environment.AccessTokenKey); exists {
+    return t.(*oauth2.Token)
+  }
+
   session := sessions.Default(c)
   t := session.Get(environment.SessionTokenKey)
   if t != nil {
@@ -113,31 +191,27 @@ func IdTokenRaw(c *gin.Context) (string) {
 }
 
 func IdpClientUsingAuthorizationCode(env *environment.State, c *gin.Context) (*idp.IdpClient) {
-  session := sessions.Default(c)
-  t := session.Get(environment.SessionTokenKey)
-  if t != nil {
-    accessToken := t.(*oauth2.Token)
-    return idp.NewIdpClientWithUserAccessToken(env.HydraConfig, accessToken)
+  accessToken := AccessToken(c)
+  if accessToken != nil {
+    return idp.NewIdpClientWithUserAccessToken(env.HydraConfig(), accessToken)
   }
   return nil
 }
 
 func IdpClientUsingClientCredentials(env *environment.State, c *gin.Context) (*idp.IdpClient) {
-  return idp.NewIdpClient(env.IdpApiConfig)
+  return idp.NewIdpClient(env.IdpApiConfig())
 }
 
 func AapClientUsingAuthorizationCode(env *environment.State, c *gin.Context) (*aap.AapClient) {
-  session := sessions.Default(c)
-  t := session.Get(environment.SessionTokenKey)
-  if t != nil {
-    accessToken := t.(*oauth2.Token)
-    return aap.NewAapClientWithUserAccessToken(env.HydraConfig, accessToken)
+  accessToken := AccessToken(c)
+  if accessToken != nil {
+    return aap.NewAapClientWithUserAccessToken(env.HydraConfig(), accessToken)
   }
   return nil
 }
 
 func AapClientUsingClientCredentials(env *environment.State, c *gin.Context) (*aap.AapClient) {
-  return aap.NewAapClient(env.AapApiConfig)
+  return aap.NewAapClient(env.AapApiConfig())
 }
 
 func CreateRandomStringWithNumberOfBytes(numberOfBytes int) (string, error) {
@@ -185,14 +259,14 @@ func StartAuthenticationSession(env *environment.State, c *gin.Context, log *log
     "state": state,
   })
   logSession.Debug("Started session")
-  authUrl := env.HydraConfig.AuthCodeURL(state)
+  authUrl := env.HydraConfig().AuthCodeURL(state)
   u, err := url.Parse(authUrl)
   return u, err
 }
 
 func StartLogout(idToken string, postLogoutRedirectUrl *url.URL) (redirectTo *url.URL, state string, err error) {
 
-  logoutUrl, err := url.Parse(config.GetString("hydra.public.url") + config.GetString("hydra.public.endpoints.logout"))
+  logoutUrl, err := url.Parse(config.Get().Hydra.Logout)
   if err != nil {
     return nil, "", err
   }
@@ -218,7 +292,7 @@ func StartLogout(idToken string, postLogoutRedirectUrl *url.URL) (redirectTo *ur
 func FetchInvite(idpClient *idp.IdpClient, id string) (*idp.Invite, error) {
 
   inviteRequest := []idp.ReadInvitesRequest{ {Id: id} }
-  status, responses, err := idp.ReadInvites(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.invites.collection"), inviteRequest)
+  status, responses, err := idp.ReadInvites(idpClient, config.Get().Idp.Invites, inviteRequest)
   if err != nil {
     return nil, err
   }
@@ -0,0 +1,34 @@
+package app
+
+import (
+  "net/http"
+  "github.com/gin-gonic/gin"
+  idp "github.com/opensentry/idp/client"
+
+  "github.com/opensentry/meui/config"
+)
+
+// IsOperator tells if the identity is listed in operators.identities, operators see pages about meui itself like the webhook delivery log
+func IsOperator(identity *idp.Human) bool {
+  if identity == nil {
+    return false
+  }
+  for _, id := range config.Get().Operators.Identities {
+    if id == identity.Id {
+      return true
+    }
+  }
+  return false
+}
+
+// RequireOperator denies everyone but operators with 403 Forbidden. Use it after RequireIdentity.
+func RequireOperator() gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+    if !IsOperator(GetIdentity(c)) {
+      AbortWithError(c, http.StatusForbidden, "Only operators may see this page")
+      return
+    }
+    c.Next()
+  }
+  return gin.HandlerFunc(fn)
+}
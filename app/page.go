@@ -0,0 +1,188 @@
+package app
+
+import (
+  "strings"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  "github.com/gin-contrib/sessions"
+  "github.com/gorilla/csrf"
+
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
+)
+
+const FlashMessagesKey = "flash.messages"
+
+// Flash levels map to fomantic message classes
+const (
+  FlashInfo string = "info"
+  FlashSuccess string = "positive"
+  FlashWarning string = "warning"
+  FlashError string = "negative"
+)
+
+// Flash is a message shown once on the next rendered page. Must be registered with gob to be stored in the session.
+type Flash struct {
+  Level string
+  Message string
+}
+
+type Branding struct {
+  Name string
+  Logo string
+  Stylesheet string
+}
+
+type NavItem struct {
+  Title string
+  Href string
+  Icon string
+  Detail string
+  Active bool
+  Operator bool // Only shown to operators, see IsOperator
+}
+
+type NavSection struct {
+  Title string
+  Items []NavItem
+}
+
+// Navigation of the dashboard. Href is matched against the request path to mark the active item.
+var navigation = []NavSection{
+  {
+    Title: "Identity",
+    Items: []NavItem{
+      {Title: "Profile", Href: "/", Icon: "user"},
+      {Title: "Invites", Href: "/invites", Icon: "paper plane"},
+      {Title: "Clients", Href: "/clients", Icon: "code"},
+      {Title: "Resource Servers", Href: "/resourceservers", Icon: "server"},
+      {Title: "Roles", Href: "/roles", Icon: "theater masks"},
+    },
+  },
+  {
+    Title: "Access & Authorization",
+    Items: []NavItem{
+      {Title: "Grants", Href: "/access/grant", Icon: "unlock"},
+      {Title: "Consents", Href: "/consents", Icon: "book"},
+      {Title: "Shadows", Href: "/shadows", Icon: "user outline"},
+    },
+  },
+  {
+    Title: "Operations",
+    Items: []NavItem{
+      {Title: "Webhooks", Href: "/webhooks", Icon: "satellite dish", Operator: true},
+    },
+  },
+}
+
+// AddFlash queues a message for the next page rendered with Render
+func AddFlash(c *gin.Context, level string, message string) {
+  session := sessions.Default(c)
+  session.AddFlash(Flash{Level: level, Message: message}, FlashMessagesKey)
+  err := session.Save()
+  if err != nil {
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log.Debug(err.Error())
+  }
+}
+
+// Render a page with the context every page shares. Controllers only pass the page specific data, which takes precedence.
+// A string title is translated, so controllers pass it in english.
+func Render(c *gin.Context, status int, template string, data gin.H) {
+  page := PageContext(c)
+  for k, v := range data {
+    page[k] = v
+  }
+  if title, ok := page["title"].(string); ok {
+    page["title"] = i18n.T(i18n.Language(c), title)
+  }
+  c.HTML(status, template, page)
+}
+
+// PageContext builds the data shared by all pages: branding, identity, navigation, language, timezone, flash messages, kept form input, csrf field, request id and csp nonce.
+func PageContext(c *gin.Context) gin.H {
+  lang := i18n.Language(c)
+
+  branding := Branding{
+    Name: config.Get().Provider.Name,
+    Logo: config.Get().Branding.Logo,
+    Stylesheet: config.Get().Branding.Stylesheet,
+  }
+
+  links := []map[string]string{
+    {"href": "/public/css/dashboard.css"},
+  }
+  if branding.Stylesheet != "" {
+    links = append(links, map[string]string{"href": branding.Stylesheet})
+  }
+
+  page := gin.H{
+    "links": links,
+    "branding": branding,
+    "provider": branding.Name,
+    "lang": lang,
+    "locales": i18n.Locales(),
+    "tz": i18n.Location(c),
+    "requestId": c.GetString(environment.RequestIdKey),
+    "cspNonce": CspNonce(c),
+    "flashes": consumeFlashes(c),
+    "input": consumeFormInput(c),
+    csrf.TemplateTag: csrf.TemplateField(c.Request),
+  }
+
+  identity := GetIdentity(c)
+  if identity != nil {
+    page["identity"] = identity
+    page["id"] = identity.Id
+    page["user"] = identity.Username
+    page["name"] = identity.Name
+    page["navigation"] = buildNavigation(lang, c.Request.URL.Path, identity.Name, IsOperator(identity))
+  }
+
+  return page
+}
+
+func buildNavigation(lang string, path string, name string, operator bool) []NavSection {
+  var sections []NavSection
+  for _, section := range navigation {
+    s := NavSection{Title: i18n.T(lang, section.Title)}
+    for _, item := range section.Items {
+      if item.Operator && !operator {
+        continue
+      }
+      item.Title = i18n.T(lang, item.Title)
+      if item.Href == "/" {
+        item.Active = path == "/" || strings.HasPrefix(path, "/profile")
+        item.Detail = name
+      } else {
+        item.Active = strings.HasPrefix(path, item.Href)
+      }
+      s.Items = append(s.Items, item)
+    }
+    if len(s.Items) > 0 {
+      sections = append(sections, s)
+    }
+  }
+  return sections
+}
+
+func consumeFlashes(c *gin.Context) []Flash {
+  session := sessions.Default(c)
+
+  var flashes []Flash
+  for _, f := range session.Flashes(FlashMessagesKey) {
+    if flash, ok := f.(Flash); ok {
+      flashes = append(flashes, flash)
+    }
+  }
+
+  if len(flashes) > 0 {
+    err := session.Save() // Remove flashes read
+    if err != nil {
+      log := c.MustGet(environment.LogKey).(*logrus.Entry)
+      log.Debug(err.Error())
+    }
+  }
+  return flashes
+}
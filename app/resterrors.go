@@ -0,0 +1,187 @@
+package app
+
+import (
+  "regexp"
+  "net/http"
+  "strings"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  "github.com/gin-contrib/sessions"
+
+  bulky "github.com/charmixer/bulky/client"
+
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/forms"
+  "github.com/opensentry/meui/i18n"
+)
+
+const FormInputKey = "form.input"
+
+// RestError is a bulk error response tied to the request item and form field that caused it
+type RestError struct {
+  Index int `json:"index"`
+  Item string `json:"item,omitempty"`
+  Field string `json:"field,omitempty"`
+  Code int `json:"code"`
+  Message string `json:"message"`
+}
+
+// Bulky servers report failed input validation as validator errors, eg. "Key: 'CreateRolesRequest.Name' Error:Field validation for 'Name' failed on the 'required' tag"
+var validationFailed = regexp.MustCompile(`Field validation for '([^']+)' failed on the '([^']+)' tag`)
+
+// FlashRestErrors turns the errors idp or aap returned for the request item at index into flash messages shown on the next rendered page.
+// item names the request item for the user, eg. the scope of a grant, and may be empty when the request has a single item.
+// fields maps request fields to the form labels the user knows them by, errors on other fields are shown without a field.
+func FlashRestErrors(c *gin.Context, index int, item string, restErr []bulky.ErrorResponse, fields map[string]string) (errors []RestError) {
+  log := c.MustGet(environment.LogKey).(*logrus.Entry)
+
+  lang := i18n.Language(c)
+  for _, e := range restErr {
+    field, message := readableRestError(lang, e)
+
+    label, exists := fields[field]
+    if !exists {
+      field = ""
+    }
+
+    log.WithFields(logrus.Fields{
+      "index": index,
+      "item": item,
+      "field": field,
+      "code": e.Code,
+    }).Debug("Rest error: " + e.Error)
+
+    var prefix []string
+    if item != "" {
+      prefix = append(prefix, item)
+    }
+    if label != "" {
+      prefix = append(prefix, i18n.T(lang, label))
+    }
+    if len(prefix) > 0 {
+      message = strings.Join(prefix, ", ") + ": " + message
+    }
+
+    AddFlash(c, FlashError, message)
+    errors = append(errors, RestError{Index: index, Item: item, Field: field, Code: e.Code, Message: message})
+  }
+  return errors
+}
+
+// RestErrorMessages returns readable reasons for bulk errors, for responses that cannot show flash messages like ajax
+func RestErrorMessages(lang string, restErr []bulky.ErrorResponse) (messages []string) {
+  for _, e := range restErr {
+    _, message := readableRestError(lang, e)
+    messages = append(messages, message)
+  }
+  return messages
+}
+
+// AbortWithRestErrors answers api requests with the errors idp or aap returned for the request item at index, see AbortWithErrors
+func AbortWithRestErrors(c *gin.Context, status int, index int, restErr []bulky.ErrorResponse) {
+  log := c.MustGet(environment.LogKey).(*logrus.Entry)
+
+  lang := i18n.Language(c)
+  errors := []RestError{}
+  for _, e := range restErr {
+    log.WithFields(logrus.Fields{"index": index, "code": e.Code}).Debug("Rest error: " + e.Error)
+
+    field, message := readableRestError(lang, e)
+    errors = append(errors, RestError{Index: index, Field: field, Code: e.Code, Message: message})
+  }
+
+  AbortWithErrors(c, status, errors)
+}
+
+// AbortWithErrors answers api requests with status in the json error format of ErrorPages, with a readable reason per error
+func AbortWithErrors(c *gin.Context, status int, errors []RestError) {
+  text, exists := errorTexts[status]
+  if !exists {
+    text = errorTexts[http.StatusBadRequest]
+  }
+
+  lang := i18n.Language(c)
+  message := i18n.T(lang, text.Message)
+  if len(errors) > 0 {
+    var messages []string
+    for _, e := range errors {
+      if e.Field != "" {
+        messages = append(messages, e.Field + ": " + e.Message)
+      } else {
+        messages = append(messages, e.Message)
+      }
+    }
+    message = strings.Join(messages, ", ")
+  }
+
+  c.AbortWithStatusJSON(status, gin.H{
+    "status": status,
+    "error": i18n.T(lang, text.Title),
+    "message": message,
+    "errors": errors,
+    "request_id": c.GetString(environment.RequestIdKey),
+  })
+}
+
+func readableRestError(lang string, e bulky.ErrorResponse) (field string, message string) {
+  match := validationFailed.FindStringSubmatch(e.Error)
+  if match == nil {
+    return "", e.Error
+  }
+
+  return match[1], forms.Translate(lang, match[2], "")
+}
+
+// Never kept by KeepFormInput, the session cookie is signed but not encrypted
+var secretFields = map[string]bool{
+  "gorilla.csrf.Token": true,
+  "password": true,
+  "Password": true,
+  "secret": true,
+  "Secret": true,
+  "client_secret": true,
+  "ClientSecret": true,
+}
+
+// KeepFormInput saves the given fields of the posted form so the form page can show them again after a redirect. Keep
+// only the fields the page shows, the csrf token and secrets are never kept. Read it from the page context as .input
+func KeepFormInput(c *gin.Context, fields ...string) {
+  input := make(map[string][]string)
+  for _, field := range fields {
+    if values, exists := c.Request.PostForm[field]; exists && !secretFields[field] {
+      input[field] = values
+    }
+  }
+
+  session := sessions.Default(c)
+  session.AddFlash(input, FormInputKey)
+  err := session.Save()
+  if err != nil {
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log.Debug(err.Error())
+  }
+}
+
+func consumeFormInput(c *gin.Context) map[string]string {
+  session := sessions.Default(c)
+
+  input := make(map[string]string)
+  for _, f := range session.Flashes(FormInputKey) {
+    if values, ok := f.(map[string][]string); ok {
+      for k, v := range values {
+        if len(v) > 0 {
+          input[k] = v[0]
+        }
+      }
+    }
+  }
+
+  if len(input) > 0 {
+    err := session.Save() // Remove input read
+    if err != nil {
+      log := c.MustGet(environment.LogKey).(*logrus.Entry)
+      log.Debug(err.Error())
+    }
+  }
+  return input
+}
@@ -0,0 +1,18 @@
+package app
+
+import (
+  "github.com/gin-gonic/gin"
+
+  "github.com/opensentry/meui/environment"
+)
+
+// EmitEvent tells the webhook endpoints about a change made by the identity of the request, see webhooks.Dispatcher.Emit.
+// Call it once idp or aap confirmed the change.
+func EmitEvent(env *environment.State, c *gin.Context, eventType string, data interface{}) {
+  var actor string
+  identity := GetIdentity(c)
+  if identity != nil {
+    actor = identity.Id
+  }
+  env.Webhooks.Emit(eventType, actor, data)
+}
@@ -0,0 +1,179 @@
+package assets
+
+import (
+  "io"
+  "os"
+  "sync"
+  "strings"
+  "io/fs"
+  "net/http"
+  "crypto/sha256"
+  "encoding/hex"
+  "html/template"
+  "github.com/gin-gonic/gin"
+  "github.com/gin-gonic/gin/render"
+)
+
+const (
+  PublicPrefix string = "/public/"
+  viewsGlob string = "views/*"
+  publicDir string = "public"
+  hashQuery string = "v"
+)
+
+// Assets holds the templates and public files meui serves. They are embedded in the binary and may be overridden from disk.
+type Assets struct {
+  fs fs.FS
+  reload bool
+
+  mu sync.RWMutex
+  hashes map[string]string
+  templates *template.Template
+  funcs template.FuncMap
+}
+
+// New creates assets from the embedded tree. If overridePath is set, files below it (views/..., public/...) take precedence.
+// With reload set, templates are parsed and assets hashed on every use, which is meant for development only.
+func New(embedded fs.FS, overridePath string, reload bool) *Assets {
+  o := overlayFS{embedded: embedded}
+  if overridePath != "" {
+    o.disk = os.DirFS(overridePath)
+  }
+
+  return &Assets{
+    fs: o,
+    reload: reload,
+    hashes: make(map[string]string),
+  }
+}
+
+// FuncMap returns the template functions assets provide, eg. {{ asset "/public/css/dashboard.css" }}
+func (a *Assets) FuncMap() template.FuncMap {
+  return template.FuncMap{
+    "asset": a.Url,
+  }
+}
+
+// Url returns a content hashed url for a public asset, so it can be cached forever. Other urls are returned unchanged.
+func (a *Assets) Url(path string) string {
+  if !strings.HasPrefix(path, PublicPrefix) {
+    return path
+  }
+
+  hash, err := a.hash(path)
+  if err != nil {
+    return path
+  }
+  return path + "?" + hashQuery + "=" + hash
+}
+
+func (a *Assets) hash(path string) (string, error) {
+  if !a.reload {
+    a.mu.RLock()
+    hash, exists := a.hashes[path]
+    a.mu.RUnlock()
+    if exists {
+      return hash, nil
+    }
+  }
+
+  f, err := a.fs.Open(strings.TrimPrefix(path, "/"))
+  if err != nil {
+    return "", err
+  }
+  defer f.Close()
+
+  h := sha256.New()
+  _, err = io.Copy(h, f)
+  if err != nil {
+    return "", err
+  }
+  hash := hex.EncodeToString(h.Sum(nil))[:12]
+
+  a.mu.Lock()
+  a.hashes[path] = hash
+  a.mu.Unlock()
+  return hash, nil
+}
+
+// LoadTemplates parses all views using the given template functions together with the asset functions.
+func (a *Assets) LoadTemplates(funcs template.FuncMap) error {
+  all := template.FuncMap{}
+  for k, v := range funcs {
+    all[k] = v
+  }
+  for k, v := range a.FuncMap() {
+    all[k] = v
+  }
+
+  t, err := a.parse(all)
+  if err != nil {
+    return err
+  }
+
+  a.mu.Lock()
+  a.templates = t
+  a.funcs = all
+  a.mu.Unlock()
+  return nil
+}
+
+func (a *Assets) parse(funcs template.FuncMap) (*template.Template, error) {
+  return template.New("").Funcs(funcs).ParseFS(a.fs, viewsGlob)
+}
+
+// Instance implements gin's render.HTMLRender, use it with engine.HTMLRender = assets
+func (a *Assets) Instance(name string, data interface{}) render.Render {
+  a.mu.RLock()
+  t := a.templates
+  funcs := a.funcs
+  a.mu.RUnlock()
+
+  if a.reload {
+    reloaded, err := a.parse(funcs)
+    if err == nil {
+      t = reloaded
+    }
+  }
+
+  return render.HTML{
+    Template: t,
+    Name: name,
+    Data: data,
+  }
+}
+
+// Serve public assets. Requests carrying the current content hash are cached for a year, everything else must revalidate.
+func (a *Assets) Serve() gin.HandlerFunc {
+  public, err := fs.Sub(a.fs, publicDir)
+  if err != nil {
+    panic(err)
+  }
+  fileServer := http.StripPrefix(strings.TrimSuffix(PublicPrefix, "/"), http.FileServer(http.FS(public)))
+
+  fn := func(c *gin.Context) {
+    path := c.Request.URL.Path
+
+    // Do not list directories
+    fi, err := fs.Stat(public, strings.TrimPrefix(path, PublicPrefix))
+    if err != nil || fi.IsDir() {
+      c.AbortWithStatus(http.StatusNotFound)
+      return
+    }
+
+    // Embedded files have no modification time, so revalidation relies on the content hash as etag.
+    current, err := a.hash(path)
+    if err == nil {
+      c.Header("ETag", "\"" + current + "\"")
+    }
+
+    if err == nil && !a.reload && c.Query(hashQuery) == current {
+      c.Header("Cache-Control", "public, max-age=31536000, immutable")
+    } else {
+      c.Header("Cache-Control", "no-cache")
+    }
+
+    fileServer.ServeHTTP(c.Writer, c.Request)
+  }
+  return gin.HandlerFunc(fn)
+}
@@ -0,0 +1,59 @@
+package assets
+
+import (
+  "sort"
+  "io/fs"
+  "errors"
+)
+
+// overlayFS serves files from disk when they exist there and falls back to the files embedded in the binary.
+// This allows overriding single templates or assets, eg. for custom branding, without copying the whole tree.
+type overlayFS struct {
+  disk fs.FS
+  embedded fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+  if o.disk != nil {
+    f, err := o.disk.Open(name)
+    if err == nil {
+      return f, nil
+    }
+  }
+  return o.embedded.Open(name)
+}
+
+// ReadDir merges the entries of both layers, entries on disk win.
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+  merged := make(map[string]fs.DirEntry)
+
+  embeddedEntries, embeddedErr := fs.ReadDir(o.embedded, name)
+  for _, e := range embeddedEntries {
+    merged[e.Name()] = e
+  }
+
+  var diskErr error = fs.ErrNotExist
+  if o.disk != nil {
+    var diskEntries []fs.DirEntry
+    diskEntries, diskErr = fs.ReadDir(o.disk, name)
+    for _, e := range diskEntries {
+      merged[e.Name()] = e
+    }
+  }
+
+  if embeddedErr != nil && diskErr != nil {
+    if errors.Is(embeddedErr, fs.ErrNotExist) {
+      return nil, diskErr
+    }
+    return nil, embeddedErr
+  }
+
+  var entries []fs.DirEntry
+  for _, e := range merged {
+    entries = append(entries, e)
+  }
+  sort.Slice(entries, func(i, j int) bool {
+    return entries[i].Name() < entries[j].Name()
+  })
+  return entries, nil
+}
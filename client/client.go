@@ -0,0 +1,114 @@
+// Package client calls the json api of meui, /api/v1, see /api/openapi.json for the contract.
+// The request types are the ones meui validates, answers are the types of idp and aap that meui passes on.
+package client
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "net/http"
+  "strings"
+  "golang.org/x/oauth2"
+  "github.com/go-playground/form"
+)
+
+// MeuiClient calls meui at Url, eg. https://me.example.com/api/v1, with an http client that adds the bearer token of the user
+type MeuiClient struct {
+  *http.Client
+  Url string
+}
+
+// RestError is the reason an item or field of the request was rejected
+type RestError struct {
+  Index int `json:"index"`
+  Item string `json:"item,omitempty"`
+  Field string `json:"field,omitempty"`
+  Code int `json:"code"`
+  Message string `json:"message"`
+}
+
+// Error is the answer of meui to a failed request
+type Error struct {
+  Status int `json:"status"`
+  Title string `json:"error"`
+  Message string `json:"message"`
+  Errors []RestError `json:"errors,omitempty"`
+  RequestId string `json:"request_id"`
+}
+
+func (e *Error) Error() string {
+  if e.Message == "" {
+    return fmt.Sprintf("meui: %d %s", e.Status, e.Title)
+  }
+  return fmt.Sprintf("meui: %d %s: %s", e.Status, e.Title, e.Message)
+}
+
+var queryEncoder = form.NewEncoder()
+
+func NewMeuiClient(client *http.Client, url string) *MeuiClient {
+  return &MeuiClient{Client: client, Url: strings.TrimSuffix(url, "/")}
+}
+
+func NewMeuiClientWithUserAccessToken(config *oauth2.Config, token *oauth2.Token, url string) *MeuiClient {
+  return NewMeuiClient(config.Client(context.Background(), token), url)
+}
+
+// Calls path with request as query for GET and DELETE or as json body otherwise, and reads the answer into response unless it is nil.
+// Answers other than 2xx are returned as *Error.
+func (client *MeuiClient) call(ctx context.Context, method string, path string, request interface{}, response interface{}) error {
+  endpoint := client.Url + path
+
+  var body io.Reader
+  if request != nil {
+    if method == http.MethodGet || method == http.MethodDelete {
+      values, err := queryEncoder.Encode(request)
+      if err != nil {
+        return err
+      }
+      endpoint = endpoint + "?" + values.Encode()
+    } else {
+      data, err := json.Marshal(request)
+      if err != nil {
+        return err
+      }
+      body = bytes.NewReader(data)
+    }
+  }
+
+  req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+  if err != nil {
+    return err
+  }
+  req.Header.Set("Accept", "application/json")
+  if body != nil {
+    req.Header.Set("Content-Type", "application/json")
+  }
+
+  res, err := client.Do(req)
+  if err != nil {
+    return err
+  }
+  defer res.Body.Close()
+
+  data, err := ioutil.ReadAll(res.Body)
+  if err != nil {
+    return err
+  }
+
+  if res.StatusCode < 200 || res.StatusCode > 299 {
+    e := &Error{Status: res.StatusCode}
+    if json.Unmarshal(data, e) != nil || e.Status == 0 {
+      e.Status = res.StatusCode
+      e.Title = http.StatusText(res.StatusCode)
+    }
+    return e
+  }
+
+  if response == nil || res.StatusCode == http.StatusNoContent {
+    return nil
+  }
+  return json.Unmarshal(data, response)
+}
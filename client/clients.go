@@ -0,0 +1,39 @@
+package client
+
+import (
+  "context"
+  "net/http"
+  "net/url"
+  idp "github.com/opensentry/idp/client"
+)
+
+type CreateClientsRequest struct {
+  Name string `json:"name" validate:"required,notblank"`
+  Description string `json:"description" validate:"required,notblank"`
+  IsPublic bool `json:"is_public"`
+  GrantTypes []string `json:"grant_types,omitempty"`
+  ResponseTypes []string `json:"response_types,omitempty"`
+  RedirectUris []string `json:"redirect_uris,omitempty" validate:"dive,redirecturi"`
+  PostLogoutRedirectUris []string `json:"post_logout_redirect_uris,omitempty" validate:"dive,redirecturi"`
+  TokenEndpointAuthMethod string `json:"token_endpoint_auth_method,omitempty"`
+}
+
+func (client *MeuiClient) ReadClients(ctx context.Context) (clients []idp.Client, err error) {
+  err = client.call(ctx, http.MethodGet, "/clients", nil, &clients)
+  return clients, err
+}
+
+func (client *MeuiClient) ReadClient(ctx context.Context, id string) (c idp.Client, err error) {
+  err = client.call(ctx, http.MethodGet, "/clients/" + url.PathEscape(id), nil, &c)
+  return c, err
+}
+
+// CreateClient returns the secret of the client, it is not returned again
+func (client *MeuiClient) CreateClient(ctx context.Context, request CreateClientsRequest) (c idp.Client, err error) {
+  err = client.call(ctx, http.MethodPost, "/clients", request, &c)
+  return c, err
+}
+
+func (client *MeuiClient) DeleteClient(ctx context.Context, id string) error {
+  return client.call(ctx, http.MethodDelete, "/clients/" + url.PathEscape(id), nil, nil)
+}
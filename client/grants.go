@@ -0,0 +1,44 @@
+package client
+
+import (
+  "context"
+  "net/http"
+  aap "github.com/opensentry/aap/client"
+)
+
+type ReadGrantsRequest struct {
+  Identity string `form:"identity_id" json:"identity_id" validate:"required,identityid"`
+  Publisher string `form:"publisher_id,omitempty" json:"publisher_id,omitempty" validate:"omitempty,identityid"`
+}
+
+// nbf and exp are unix timestamps, exp 0 never expires. on_behalf_of_id defaults to the publisher.
+type CreateGrantsRequest struct {
+  Identity string `json:"identity_id" validate:"required,identityid"`
+  Scope string `json:"scope" validate:"required,scope"`
+  Publisher string `json:"publisher_id" validate:"required,identityid"`
+  OnBehalfOf string `json:"on_behalf_of_id,omitempty" validate:"omitempty,identityid"`
+  NotBefore int64 `json:"nbf" validate:"gte=0"`
+  Expire int64 `json:"exp" validate:"omitempty,gtfield=NotBefore"`
+}
+
+// on_behalf_of_id defaults to the publisher
+type DeleteGrantsRequest struct {
+  Identity string `form:"identity_id" json:"identity_id" validate:"required,identityid"`
+  Scope string `form:"scope" json:"scope" validate:"required,scope"`
+  Publisher string `form:"publisher_id" json:"publisher_id" validate:"required,identityid"`
+  OnBehalfOf string `form:"on_behalf_of_id,omitempty" json:"on_behalf_of_id,omitempty" validate:"omitempty,identityid"`
+}
+
+func (client *MeuiClient) ReadGrants(ctx context.Context, request ReadGrantsRequest) (grants []aap.Grant, err error) {
+  err = client.call(ctx, http.MethodGet, "/grants", request, &grants)
+  return grants, err
+}
+
+func (client *MeuiClient) CreateGrant(ctx context.Context, request CreateGrantsRequest) (grant aap.Grant, err error) {
+  err = client.call(ctx, http.MethodPost, "/grants", request, &grant)
+  return grant, err
+}
+
+func (client *MeuiClient) DeleteGrant(ctx context.Context, request DeleteGrantsRequest) error {
+  return client.call(ctx, http.MethodDelete, "/grants", request, nil)
+}
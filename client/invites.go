@@ -0,0 +1,36 @@
+package client
+
+import (
+  "context"
+  "net/http"
+  "net/url"
+  idp "github.com/opensentry/idp/client"
+)
+
+// exp is a unix timestamp and optional
+type CreateInvitesRequest struct {
+  Email string `json:"email" validate:"required,email"`
+  Username string `json:"username,omitempty" validate:"omitempty,username"`
+  ExpiresAt int64 `json:"exp,omitempty" validate:"omitempty,gt=0"`
+}
+
+func (client *MeuiClient) ReadInvites(ctx context.Context) (invites []idp.Invite, err error) {
+  err = client.call(ctx, http.MethodGet, "/invites", nil, &invites)
+  return invites, err
+}
+
+func (client *MeuiClient) ReadInvite(ctx context.Context, id string) (invite idp.Invite, err error) {
+  err = client.call(ctx, http.MethodGet, "/invites/" + url.PathEscape(id), nil, &invite)
+  return invite, err
+}
+
+func (client *MeuiClient) CreateInvite(ctx context.Context, request CreateInvitesRequest) (invite idp.Invite, err error) {
+  err = client.call(ctx, http.MethodPost, "/invites", request, &invite)
+  return invite, err
+}
+
+// SendInvite e-mails the invite
+func (client *MeuiClient) SendInvite(ctx context.Context, id string) (invite idp.Invite, err error) {
+  err = client.call(ctx, http.MethodPost, "/invites/" + url.PathEscape(id) + "/send", nil, &invite)
+  return invite, err
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+  "context"
+  "net/http"
+)
+
+// Profile is the human signed in, without password and totp secret
+type Profile struct {
+  Id string `json:"id"`
+  Username string `json:"username"`
+  Name string `json:"name"`
+  Email string `json:"email"`
+  EmailConfirmedAt int64 `json:"email_confirmed_at"`
+  TotpRequired bool `json:"totp_required"`
+}
+
+// Like the edit profile page only the name can be changed
+type UpdateProfileRequest struct {
+  Name string `json:"name" validate:"required,notblank"`
+}
+
+func (client *MeuiClient) ReadProfile(ctx context.Context) (profile Profile, err error) {
+  err = client.call(ctx, http.MethodGet, "/profile", nil, &profile)
+  return profile, err
+}
+
+func (client *MeuiClient) UpdateProfile(ctx context.Context, request UpdateProfileRequest) (profile Profile, err error) {
+  err = client.call(ctx, http.MethodPatch, "/profile", request, &profile)
+  return profile, err
+}
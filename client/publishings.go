@@ -0,0 +1,29 @@
+package client
+
+import (
+  "context"
+  "net/http"
+  aap "github.com/opensentry/aap/client"
+)
+
+// The scopes the resource server publishes
+type ReadPublishingsRequest struct {
+  Publisher string `form:"publisher_id" json:"publisher_id" validate:"required,identityid"`
+}
+
+type CreatePublishingsRequest struct {
+  Publisher string `json:"publisher_id" validate:"required,identityid"`
+  Scope string `json:"scope" validate:"required,scope"`
+  Title string `json:"title" validate:"required,notblank"`
+  Description string `json:"description,omitempty"`
+}
+
+func (client *MeuiClient) ReadPublishings(ctx context.Context, request ReadPublishingsRequest) (publishings []aap.Publish, err error) {
+  err = client.call(ctx, http.MethodGet, "/publishings", request, &publishings)
+  return publishings, err
+}
+
+func (client *MeuiClient) CreatePublishing(ctx context.Context, request CreatePublishingsRequest) (publishing aap.Publish, err error) {
+  err = client.call(ctx, http.MethodPost, "/publishings", request, &publishing)
+  return publishing, err
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+  "context"
+  "net/http"
+  "net/url"
+  idp "github.com/opensentry/idp/client"
+)
+
+type CreateResourceServersRequest struct {
+  Name string `json:"name" validate:"required,notblank"`
+  Description string `json:"description" validate:"required,notblank"`
+  Audience string `json:"aud" validate:"required,audience"`
+}
+
+func (client *MeuiClient) ReadResourceServers(ctx context.Context) (resourceServers []idp.ResourceServer, err error) {
+  err = client.call(ctx, http.MethodGet, "/resourceservers", nil, &resourceServers)
+  return resourceServers, err
+}
+
+func (client *MeuiClient) ReadResourceServer(ctx context.Context, id string) (resourceServer idp.ResourceServer, err error) {
+  err = client.call(ctx, http.MethodGet, "/resourceservers/" + url.PathEscape(id), nil, &resourceServer)
+  return resourceServer, err
+}
+
+func (client *MeuiClient) CreateResourceServer(ctx context.Context, request CreateResourceServersRequest) (resourceServer idp.ResourceServer, err error) {
+  err = client.call(ctx, http.MethodPost, "/resourceservers", request, &resourceServer)
+  return resourceServer, err
+}
+
+func (client *MeuiClient) DeleteResourceServer(ctx context.Context, id string) error {
+  return client.call(ctx, http.MethodDelete, "/resourceservers/" + url.PathEscape(id), nil, nil)
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+  "context"
+  "net/http"
+  "net/url"
+  idp "github.com/opensentry/idp/client"
+)
+
+type CreateRolesRequest struct {
+  Name string `json:"name" validate:"required,notblank"`
+  Description string `json:"description" validate:"required,notblank"`
+}
+
+func (client *MeuiClient) ReadRoles(ctx context.Context) (roles []idp.Role, err error) {
+  err = client.call(ctx, http.MethodGet, "/roles", nil, &roles)
+  return roles, err
+}
+
+func (client *MeuiClient) ReadRole(ctx context.Context, id string) (role idp.Role, err error) {
+  err = client.call(ctx, http.MethodGet, "/roles/" + url.PathEscape(id), nil, &role)
+  return role, err
+}
+
+func (client *MeuiClient) CreateRole(ctx context.Context, request CreateRolesRequest) (role idp.Role, err error) {
+  err = client.call(ctx, http.MethodPost, "/roles", request, &role)
+  return role, err
+}
+
+func (client *MeuiClient) DeleteRole(ctx context.Context, id string) error {
+  return client.call(ctx, http.MethodDelete, "/roles/" + url.PathEscape(id), nil, nil)
+}
@@ -0,0 +1,21 @@
+package client
+
+import (
+  "context"
+  "net/http"
+  aap "github.com/opensentry/aap/client"
+)
+
+type CreateScopesRequest struct {
+  Scope string `json:"scope" validate:"required,scope"`
+}
+
+func (client *MeuiClient) ReadScopes(ctx context.Context) (scopes []aap.Scope, err error) {
+  err = client.call(ctx, http.MethodGet, "/scopes", nil, &scopes)
+  return scopes, err
+}
+
+func (client *MeuiClient) CreateScope(ctx context.Context, request CreateScopesRequest) (scope aap.Scope, err error) {
+  err = client.call(ctx, http.MethodPost, "/scopes", request, &scope)
+  return scope, err
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+  "context"
+  "net/http"
+  aap "github.com/opensentry/aap/client"
+)
+
+// Either or both, eg. shadow_id lists the identities shadowing a role
+type ReadShadowsRequest struct {
+  Identity string `form:"identity_id,omitempty" json:"identity_id,omitempty" validate:"required_without=Shadow,omitempty,identityid"`
+  Shadow string `form:"shadow_id,omitempty" json:"shadow_id,omitempty" validate:"required_without=Identity,omitempty,identityid"`
+}
+
+// The identity gets the grants of the shadow from nbf until exp, unix timestamps where exp 0 never expires
+type CreateShadowsRequest struct {
+  Identity string `json:"identity_id" validate:"required,identityid"`
+  Shadow string `json:"shadow_id" validate:"required,identityid"`
+  NotBefore int64 `json:"nbf" validate:"gte=0"`
+  Expire int64 `json:"exp" validate:"omitempty,gtfield=NotBefore"`
+}
+
+type DeleteShadowsRequest struct {
+  Identity string `form:"identity_id" json:"identity_id" validate:"required,identityid"`
+  Shadow string `form:"shadow_id" json:"shadow_id" validate:"required,identityid"`
+}
+
+func (client *MeuiClient) ReadShadows(ctx context.Context, request ReadShadowsRequest) (shadows []aap.Shadow, err error) {
+  err = client.call(ctx, http.MethodGet, "/shadows", request, &shadows)
+  return shadows, err
+}
+
+func (client *MeuiClient) CreateShadow(ctx context.Context, request CreateShadowsRequest) (shadow aap.Shadow, err error) {
+  err = client.call(ctx, http.MethodPost, "/shadows", request, &shadow)
+  return shadow, err
+}
+
+func (client *MeuiClient) DeleteShadow(ctx context.Context, request DeleteShadowsRequest) error {
+  return client.call(ctx, http.MethodDelete, "/shadows", request, nil)
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+  "context"
+  "net/http"
+  aap "github.com/opensentry/aap/client"
+)
+
+type ReadSubscriptionsRequest struct {
+  Subscriber string `form:"subscriber_id" json:"subscriber_id" validate:"required,identityid"`
+}
+
+// Identifies a subscription, posted as json and deleted by query
+type CreateSubscriptionsRequest struct {
+  Subscriber string `form:"subscriber_id" json:"subscriber_id" validate:"required,identityid"`
+  Publisher string `form:"publisher_id" json:"publisher_id" validate:"required,identityid"`
+  Scope string `form:"scope" json:"scope" validate:"required,scope"`
+}
+
+type DeleteSubscriptionsRequest CreateSubscriptionsRequest
+
+func (client *MeuiClient) ReadSubscriptions(ctx context.Context, request ReadSubscriptionsRequest) (subscriptions []aap.Subscription, err error) {
+  err = client.call(ctx, http.MethodGet, "/subscriptions", request, &subscriptions)
+  return subscriptions, err
+}
+
+func (client *MeuiClient) CreateSubscription(ctx context.Context, request CreateSubscriptionsRequest) (subscription aap.Subscription, err error) {
+  err = client.call(ctx, http.MethodPost, "/subscriptions", request, &subscription)
+  return subscription, err
+}
+
+func (client *MeuiClient) DeleteSubscription(ctx context.Context, request DeleteSubscriptionsRequest) error {
+  return client.call(ctx, http.MethodDelete, "/subscriptions", request, nil)
+}
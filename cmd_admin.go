@@ -0,0 +1,492 @@
+package main
+
+import (
+  "io"
+  "os"
+  "fmt"
+  "sort"
+  "bytes"
+  "time"
+  "strings"
+  "net/http"
+  "text/tabwriter"
+  "encoding/json"
+  "golang.org/x/net/context"
+  "golang.org/x/oauth2"
+  "gopkg.in/yaml.v2"
+  "github.com/pborman/getopt"
+  oidc "github.com/coreos/go-oidc/v3/oidc"
+  bulky "github.com/charmixer/bulky/client"
+  idp "github.com/opensentry/idp/client"
+  aap "github.com/opensentry/aap/client"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/forms"
+  "github.com/opensentry/meui/invitestore"
+  "github.com/opensentry/meui/webhooks"
+)
+
+const adminUsage = `Usage: meui <resource> <command> [options] [arguments]
+
+Commands:
+  clients list
+  clients create --name NAME --description TEXT [--public] [--grant-type TYPE]... [--response-type TYPE]...
+                 [--redirect-uri URI]... [--post-logout-redirect-uri URI]... [--token-endpoint-auth-method METHOD]
+  clients delete ID...
+  roles list
+  roles create --name NAME --description TEXT
+  roles delete ID...
+  grants list --identity ID [--publisher ID]
+  grants grant --identity ID --scope SCOPE --publisher ID [--on-behalf-of ID] [--nbf UNIX] [--exp UNIX]
+  grants revoke --identity ID --scope SCOPE --publisher ID [--on-behalf-of ID]
+  invites list
+  invites create --email EMAIL [--username NAME] [--exp UNIX]
+  invites send ID...
+  invites import FILE [--send] [--dry-run] [--skip-invalid] [--results FILE]
+  scopes list
+  scopes create SCOPE...
+  apply FILE [--dry-run] [--yes] [--prune]
+  export [--identity ID]...
+  snapshot export [FILE]
+  snapshot import FILE [--dry-run] [--map OLD=NEW]...
+
+Options:
+  -o, --output FORMAT  table, json or yaml, table by default
+  --token TOKEN        Call idp and aap as the human owning the access token, MEUI_TOKEN is read if not given.
+                       Without a token the login of meui login is used, and without a login meui calls them
+                       as itself, with the client credentials of app.yml.
+
+Exit codes:
+  0  Success
+  1  idp or aap failed or could not be reached, or the configuration is invalid
+  2  Wrong usage or invalid input, the request was not sent or idp or aap rejected it
+  3  Not found
+  4  Access denied
+
+Commands acting on several items, eg. clients delete, go on after a failed item and exit with the code of the first failure.
+
+apply makes idp and aap match an access configuration, a yaml or json file of resource servers, roles, scopes,
+publishes, subscriptions, shadows and grants like export writes. It prints the plan of creates and deletes and asks
+before applying it, --dry-run only prints the plan and --yes applies it without asking. Resource servers and roles
+are matched by name and referred to by name in the file, other identities by id. The publishes, subscriptions,
+shadows and grants of the resource servers and roles in the file, and of every identity the file gives any, are the
+ones in the file, the others are deleted. --prune deletes the resource servers and roles missing from the file.
+aap offers no way to delete scopes and publishes or to change publishes, apply warns about those.
+
+export writes the access configuration of the resource servers, roles and clients, in yaml unless -o json is given.
+--identity adds the shadows and grants of a human.
+
+snapshot export writes everything meui can read to a versioned json archive, gzipped if FILE ends in .gz, or to
+stdout: clients, resource servers, roles, scopes, publishes, subscriptions, shadows, grants, pending invites and the
+emails of the humans. snapshot import recreates it, for backups or to clone an environment. Clients, resource servers
+and roles are found by name and humans by email, or mapped by --map to a new id, and those not found are created with
+new ids, except humans. What exists is kept, nothing is deleted. The report tells the outcome of every item and why
+it was skipped, eg. grants of humans not found, and the secrets of the created clients. --dry-run only reports what
+would be created.
+
+invites import creates invites from a csv file of email, username and expires, in that order or named by a header
+row, and sends them with --send. Every row is validated first and nothing is created if any is invalid, unless
+--skip-invalid is given. Invites are created and sent in batches of invites.import.batchSize. --dry-run only
+validates, --results writes the outcome of every row as csv.
+
+invites send refuses revoked, expired and accepted invites and those sent less than invites.resend.interval ago, with
+exit code 2, like the web ui and the api do.
+`
+
+// Exit codes of the admin commands
+const (
+  exitOk = 0
+  exitFailed = 1
+  exitUsage = 2
+  exitNotFound = 3
+  exitDenied = 4
+)
+
+// An admin command registers its options on set and returns the function running it with the arguments left
+type adminCommand func(set *getopt.Set) func(a *admin, args []string) int
+
+// isAdminCommand tells if the first argument names a resource or a command of the admin commands
+func isAdminCommand(resource string) bool {
+  for name, _ := range adminCommands {
+    if name == resource || strings.HasPrefix(name, resource + " ") {
+      return true
+    }
+  }
+  return false
+}
+
+// State of an admin command
+type admin struct {
+  env *environment.State
+  tokens oauth2.TokenSource // Tokens of a human, nil calls idp and aap with the client credentials of meui
+  actor string // Sent as actor of webhook events
+  output string
+  stdout io.Writer // The clients of idp and aap print debug output to os.Stdout, output goes here instead
+  code int // Exit code of the first failure
+}
+
+// meui <resource> <command>. Returns the exit code, see adminUsage.
+func adminCommandLine(args []string) int {
+  // Commands acting on several resources, eg. apply, are named by one word
+  name := args[0]
+  command, exists := adminCommands[name]
+  if !exists {
+    if len(args) < 2 {
+      fmt.Fprint(os.Stderr, adminUsage)
+      return exitUsage
+    }
+    name = args[0] + " " + args[1]
+    command, exists = adminCommands[name]
+  }
+  if !exists {
+    fmt.Fprint(os.Stderr, adminUsage)
+    return exitUsage
+  }
+
+  set := getopt.New()
+  output := set.EnumLong("output", 'o', []string{"table", "json", "yaml"}, "table", "FORMAT")
+  token := set.StringLong("token", 0, os.Getenv("MEUI_TOKEN"), "TOKEN")
+  run := command(set)
+
+  arguments, err := parseOptions(set, "meui " + name, args[len(strings.Fields(name)):])
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err.Error())
+    fmt.Fprint(os.Stderr, adminUsage)
+    return exitUsage
+  }
+
+  a, code := newAdmin(*output, *token)
+  if code != exitOk {
+    return code
+  }
+
+  // The clients of idp and aap print every request and response to os.Stdout. Keep it out of the output scripts read,
+  // on stderr when log.debug is set.
+  stdout := os.Stdout
+  a.stdout = stdout
+  os.Stdout = os.Stderr
+  if !config.Get().Log.Debug {
+    devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+    if err == nil {
+      os.Stdout = devNull
+      defer devNull.Close()
+    }
+  }
+  defer func() { os.Stdout = stdout }()
+
+  code = run(a, arguments)
+
+  // Events are delivered by this process, give the endpoints their first attempt before it ends
+  pending := a.env.Webhooks.Wait(config.Get().Webhooks.Timeout + time.Second)
+  if pending > 0 {
+    fmt.Fprintf(os.Stderr, "Warning: %d webhook deliveries were not delivered before meui exited\n", pending)
+  }
+  return code
+}
+
+// Parses the options wherever they are among the arguments, getopt alone stops at the first argument
+func parseOptions(set *getopt.Set, program string, args []string) (arguments []string, err error) {
+  for {
+    err = set.Getopt(append([]string{program}, args...), nil)
+    if err != nil {
+      return nil, err
+    }
+
+    args = set.Args()
+    if len(args) == 0 {
+      return arguments, nil
+    }
+    arguments = append(arguments, args[0])
+    args = args[1:]
+  }
+}
+
+// Reads the configuration and discovers hydra like --serve does
+func newAdmin(output string, token string) (*admin, int) {
+  initConfigurations() // Exits with 1 if the configuration is invalid
+  cfg := config.Get()
+
+  provider, err := oidc.NewProvider(context.Background(), cfg.Hydra.Url + "/")
+  if err != nil {
+    fmt.Fprintln(os.Stderr, "Unable to discover hydra: " + err.Error())
+    return nil, exitFailed
+  }
+
+  // Shared with the web ui, invites sent here count against the resend interval there and the other way round
+  inviteStore, err := invitestore.Open(cfg.Invites.StorePath)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, "Unable to open the invite store: " + err.Error())
+    return nil, exitFailed
+  }
+
+  env := &environment.State{
+    SessionKeys: &sessionKeys,
+    Provider: provider,
+    Webhooks: webhooks.NewDispatcher(log.WithFields(appFields)),
+    Invites: inviteStore,
+  }
+  setOAuth2Configs(env)
+  env.Webhooks.Start(4)
+
+  a := &admin{env: env, output: output, actor: cfg.OAuth2.ClientId}
+
+  if token != "" {
+    a.tokens = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token, TokenType: "Bearer"})
+
+    // Tells the identity of the token and refuses expired or revoked tokens before anything is sent
+    userInfo, err := provider.UserInfo(context.Background(), a.tokens)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, "The access token was rejected: " + err.Error())
+      return nil, exitDenied
+    }
+    a.actor = userInfo.Subject
+    return a, exitOk
+  }
+
+  // The login of meui login, refreshed if it expired
+  cached, err := loadCredentials(cfg.Hydra.Url)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err.Error())
+    return nil, exitFailed
+  }
+  if cached != nil {
+    oauth2Config, err := cliOAuth2Config(provider, cfg.Cli.LoopbackPort)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err.Error())
+      return nil, exitFailed
+    }
+    oauth2Config.ClientID = cached.ClientId
+
+    refreshed, err := oauth2Config.TokenSource(context.Background(), cached.Token).Token()
+    if err != nil {
+      fmt.Fprintln(os.Stderr, "The login expired, run meui login again: " + err.Error())
+      return nil, exitDenied
+    }
+    if refreshed.AccessToken != cached.Token.AccessToken {
+      cached.Token = refreshed
+      err = saveCredentials(cfg.Hydra.Url, cached)
+      if err != nil {
+        fmt.Fprintln(os.Stderr, "Warning: unable to cache the refreshed tokens: " + err.Error())
+      }
+    }
+
+    a.tokens = oauth2Config.TokenSource(context.Background(), refreshed)
+    a.actor = cached.Subject
+  }
+
+  return a, exitOk
+}
+
+func (a *admin) idpClient() *idp.IdpClient {
+  if a.tokens != nil {
+    return &idp.IdpClient{Client: oauth2.NewClient(context.Background(), a.tokens)}
+  }
+  return idp.NewIdpClient(a.env.IdpApiConfig())
+}
+
+func (a *admin) aapClient() *aap.AapClient {
+  if a.tokens != nil {
+    return &aap.AapClient{Client: oauth2.NewClient(context.Background(), a.tokens)}
+  }
+  return aap.NewAapClient(a.env.AapApiConfig())
+}
+
+// Records the exit code of a failure, the first one is kept
+func (a *admin) fail(code int) {
+  if a.code == exitOk {
+    a.code = code
+  }
+}
+
+// Validates input like the api does, see forms.ValidateJson. Prints the invalid fields.
+func (a *admin) validate(input interface{}) bool {
+  messages, err := forms.ValidateJson("en", input)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err.Error())
+    a.fail(exitFailed)
+    return false
+  }
+
+  if len(messages) == 0 {
+    return true
+  }
+
+  var fields []string
+  for field, _ := range messages {
+    fields = append(fields, field)
+  }
+  sort.Strings(fields)
+  for _, field := range fields {
+    for _, message := range messages[field] {
+      fmt.Fprintf(os.Stderr, "%s: %s\n", field, message)
+    }
+  }
+  a.fail(exitUsage)
+  return false
+}
+
+// Checks the outcome of a bulk call to idp or aap, see api.checkResponse
+func (a *admin) check(url string, status int, err error) bool {
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "Unable to call %s: %s\n", url, err.Error())
+    a.fail(exitFailed)
+    return false
+  }
+
+  if status == http.StatusOK {
+    return true
+  }
+
+  fmt.Fprintf(os.Stderr, "Got status %d from %s\n", status, url)
+  a.fail(exitCode(status))
+  return false
+}
+
+// Reads item i of a bulk response into v. Prints the errors idp or aap returned for it, prefixed by name if given.
+// A list not found is empty when list is true.
+func (a *admin) read(i int, name string, responses bulky.Responses, v interface{}, list bool) bool {
+  var status int
+  var restErr []bulky.ErrorResponse
+  for _, r := range responses {
+    if r.Index == i { // bulky.Unmarshal panics if the index is missing
+      status, restErr = bulky.Unmarshal(i, responses, v)
+    }
+  }
+
+  if status == http.StatusOK && len(restErr) == 0 {
+    return true
+  }
+  if list && (status == http.StatusNotFound || len(responses) == 0) {
+    return true
+  }
+
+  prefix := ""
+  if name != "" {
+    prefix = name + ": "
+  }
+
+  messages := app.RestErrorMessages("en", restErr)
+  if len(messages) == 0 {
+    messages = []string{fmt.Sprintf("Got status %d", status)}
+  }
+  for _, message := range messages {
+    fmt.Fprintln(os.Stderr, prefix + message)
+  }
+
+  a.fail(exitCode(status))
+  return false
+}
+
+func exitCode(status int) int {
+  switch status {
+  case http.StatusBadRequest:
+    return exitUsage
+  case http.StatusNotFound:
+    return exitNotFound
+  case http.StatusUnauthorized, http.StatusForbidden:
+    return exitDenied
+  }
+  return exitFailed
+}
+
+func (a *admin) emit(eventType string, data interface{}) {
+  a.env.Webhooks.Emit(eventType, a.actor, data)
+}
+
+// Rows printed by the table output, json and yaml print the items themselves
+type table struct {
+  header []string
+  rows [][]string
+}
+
+// Prints v in the output format and returns the exit code
+func (a *admin) print(v interface{}, t table) int {
+  switch a.output {
+  case "json":
+    fmt.Fprintln(a.stdout, jsonIndent(v))
+  case "yaml":
+    out, err := yamlValue(v)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err.Error())
+      a.fail(exitFailed)
+      break
+    }
+    fmt.Fprint(a.stdout, out)
+  default:
+    if len(t.rows) == 0 {
+      break
+    }
+    w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, strings.ToUpper(strings.Join(t.header, "\t")))
+    for _, row := range t.rows {
+      fmt.Fprintln(w, strings.Join(row, "\t"))
+    }
+    w.Flush()
+  }
+  return a.code
+}
+
+func jsonIndent(v interface{}) string {
+  var b bytes.Buffer
+  enc := json.NewEncoder(&b)
+  enc.SetEscapeHTML(false)
+  enc.SetIndent("", "  ")
+  err := enc.Encode(v)
+  if err != nil {
+    return jsonValue(v)
+  }
+  return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Yaml of v named by its json tags, the idp and aap types have no yaml tags
+func yamlValue(v interface{}) (string, error) {
+  data, err := json.Marshal(v)
+  if err != nil {
+    return "", err
+  }
+
+  dec := json.NewDecoder(bytes.NewReader(data))
+  dec.UseNumber()
+  var value interface{}
+  err = dec.Decode(&value)
+  if err != nil {
+    return "", err
+  }
+
+  out, err := yaml.Marshal(numbers(value))
+  if err != nil {
+    return "", err
+  }
+  return string(out), nil
+}
+
+// Turns json numbers into ints where possible, so timestamps are not printed as floats like 1.6e+09
+func numbers(v interface{}) interface{} {
+  switch value := v.(type) {
+  case json.Number:
+    if i, err := value.Int64(); err == nil {
+      return i
+    }
+    f, _ := value.Float64()
+    return f
+  case map[string]interface{}:
+    for k, item := range value {
+      value[k] = numbers(item)
+    }
+  case []interface{}:
+    for i, item := range value {
+      value[i] = numbers(item)
+    }
+  }
+  return v
+}
+
+// Unix timestamps in tables, empty for 0
+func unixTime(unix int64) string {
+  if unix == 0 {
+    return ""
+  }
+  return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "time"
+  "strings"
+  "github.com/pborman/getopt"
+  idp "github.com/opensentry/idp/client"
+  aap "github.com/opensentry/aap/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/invitestore"
+  "github.com/opensentry/meui/webhooks"
+)
+
+var adminCommands = map[string]adminCommand{
+  "clients list": clientsList,
+  "clients create": clientsCreate,
+  "clients delete": clientsDelete,
+  "roles list": rolesList,
+  "roles create": rolesCreate,
+  "roles delete": rolesDelete,
+  "grants list": grantsList,
+  "grants grant": grantsGrant,
+  "grants revoke": grantsRevoke,
+  "invites list": invitesList,
+  "invites create": invitesCreate,
+  "invites send": invitesSend,
+  "invites import": invitesImport,
+  "scopes list": scopesList,
+  "scopes create": scopesCreate,
+  "apply": accessApply,
+  "export": accessExport,
+  "snapshot export": snapshotExport,
+  "snapshot import": snapshotImport,
+}
+
+// Commands taking no arguments but options refuse arguments, they are likely options missing their dashes
+func noArguments(args []string) bool {
+  if len(args) > 0 {
+    fmt.Fprintf(os.Stderr, "Unexpected arguments: %s\n", strings.Join(args, " "))
+    fmt.Fprint(os.Stderr, adminUsage)
+    return false
+  }
+  return true
+}
+
+func someArguments(args []string) bool {
+  if len(args) == 0 {
+    fmt.Fprint(os.Stderr, adminUsage)
+    return false
+  }
+  return true
+}
+
+func clientsTable(clients []idp.Client) table {
+  t := table{header: []string{"Id", "Name", "Grant types", "Redirect uris", "Description"}}
+  for _, client := range clients {
+    t.rows = append(t.rows, []string{client.Id, client.Name, strings.Join(client.GrantTypes, ","), strings.Join(client.RedirectUris, ","), client.Description})
+  }
+  return t
+}
+
+func clientsList(set *getopt.Set) func(a *admin, args []string) int {
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    url := config.Get().Idp.Clients
+    status, responses, err := idp.ReadClients(a.idpClient(), url, nil)
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    clients := idp.ReadClientsResponse{}
+    if !a.read(0, "", responses, &clients, true) {
+      return a.code
+    }
+    return a.print(clients, clientsTable(clients))
+  }
+}
+
+func clientsCreate(set *getopt.Set) func(a *admin, args []string) int {
+  name := set.StringLong("name", 0, "", "NAME")
+  description := set.StringLong("description", 0, "", "TEXT")
+  public := set.BoolLong("public", 0)
+  grantTypes := set.ListLong("grant-type", 0, "TYPE")
+  responseTypes := set.ListLong("response-type", 0, "TYPE")
+  redirectUris := set.ListLong("redirect-uri", 0, "URI")
+  postLogoutRedirectUris := set.ListLong("post-logout-redirect-uri", 0, "URI")
+  tokenEndpointAuthMethod := set.StringLong("token-endpoint-auth-method", 0, "", "METHOD")
+
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    input := meui.CreateClientsRequest{
+      Name: *name,
+      Description: *description,
+      IsPublic: *public,
+      GrantTypes: *grantTypes,
+      ResponseTypes: *responseTypes,
+      RedirectUris: *redirectUris,
+      PostLogoutRedirectUris: *postLogoutRedirectUris,
+      TokenEndpointAuthMethod: *tokenEndpointAuthMethod,
+    }
+    if !a.validate(input) {
+      return a.code
+    }
+
+    url := config.Get().Idp.Clients
+    status, responses, err := idp.CreateClients(a.idpClient(), url, []idp.CreateClientsRequest{
+      {
+        Name:                    input.Name,
+        Description:             input.Description,
+        IsPublic:                input.IsPublic,
+        GrantTypes:              input.GrantTypes,
+        ResponseTypes:           input.ResponseTypes,
+        RedirectUris:            input.RedirectUris,
+        PostLogoutRedirectUris:  input.PostLogoutRedirectUris,
+        TokenEndpointAuthMethod: input.TokenEndpointAuthMethod,
+      },
+    })
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    var client idp.CreateClientsResponse
+    if !a.read(0, "", responses, &client, false) {
+      return a.code
+    }
+
+    a.emit(webhooks.ClientCreated, webhooks.ClientData(idp.Client(client)))
+
+    // The secret is only returned here, the table shows it as well
+    t := clientsTable([]idp.Client{ idp.Client(client) })
+    t.header = append(t.header, "Secret")
+    t.rows[0] = append(t.rows[0], client.Secret)
+    return a.print(client, t)
+  }
+}
+
+func clientsDelete(set *getopt.Set) func(a *admin, args []string) int {
+  return func(a *admin, args []string) int {
+    if !someArguments(args) {
+      return exitUsage
+    }
+
+    var requests []idp.DeleteClientsRequest
+    for _, id := range args {
+      requests = append(requests, idp.DeleteClientsRequest{Id: id})
+    }
+
+    url := config.Get().Idp.Clients
+    status, responses, err := idp.DeleteClients(a.idpClient(), url, requests)
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    deleted := []idp.DeleteClientsResponse{}
+    t := table{header: []string{"Id"}}
+    for i, request := range requests {
+      var client idp.DeleteClientsResponse
+      if !a.read(i, request.Id, responses, &client, false) {
+        continue
+      }
+      a.emit(webhooks.ClientDeleted, client)
+      deleted = append(deleted, client)
+      t.rows = append(t.rows, []string{client.Id})
+    }
+    return a.print(deleted, t)
+  }
+}
+
+func rolesTable(roles []idp.Role) table {
+  t := table{header: []string{"Id", "Name", "Description"}}
+  for _, role := range roles {
+    t.rows = append(t.rows, []string{role.Id, role.Name, role.Description})
+  }
+  return t
+}
+
+func rolesList(set *getopt.Set) func(a *admin, args []string) int {
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    url := config.Get().Idp.Roles
+    status, responses, err := idp.ReadRoles(a.idpClient(), url, nil)
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    roles := idp.ReadRolesResponse{}
+    if !a.read(0, "", responses, &roles, true) {
+      return a.code
+    }
+    return a.print(roles, rolesTable(roles))
+  }
+}
+
+func rolesCreate(set *getopt.Set) func(a *admin, args []string) int {
+  name := set.StringLong("name", 0, "", "NAME")
+  description := set.StringLong("description", 0, "", "TEXT")
+
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    input := meui.CreateRolesRequest{Name: *name, Description: *description}
+    if !a.validate(input) {
+      return a.code
+    }
+
+    url := config.Get().Idp.Roles
+    status, responses, err := idp.CreateRoles(a.idpClient(), url, []idp.CreateRolesRequest{
+      {Name: input.Name, Description: input.Description},
+    })
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    var role idp.CreateRolesResponse
+    if !a.read(0, "", responses, &role, false) {
+      return a.code
+    }
+
+    a.emit(webhooks.RoleCreated, role)
+    return a.print(role, rolesTable([]idp.Role{ idp.Role(role) }))
+  }
+}
+
+func rolesDelete(set *getopt.Set) func(a *admin, args []string) int {
+  return func(a *admin, args []string) int {
+    if !someArguments(args) {
+      return exitUsage
+    }
+
+    var requests []idp.DeleteRolesRequest
+    for _, id := range args {
+      requests = append(requests, idp.DeleteRolesRequest{Id: id})
+    }
+
+    url := config.Get().Idp.Roles
+    status, responses, err := idp.DeleteRoles(a.idpClient(), url, requests)
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    deleted := []idp.DeleteRolesResponse{}
+    t := table{header: []string{"Id"}}
+    for i, request := range requests {
+      var role idp.DeleteRolesResponse
+      if !a.read(i, request.Id, responses, &role, false) {
+        continue
+      }
+      a.emit(webhooks.RoleDeleted, role)
+      deleted = append(deleted, role)
+      t.rows = append(t.rows, []string{role.Id})
+    }
+    return a.print(deleted, t)
+  }
+}
+
+func grantsTable(grants []aap.Grant) table {
+  t := table{header: []string{"Identity", "Scope", "Publisher", "On behalf of", "Not before", "Expires"}}
+  for _, grant := range grants {
+    t.rows = append(t.rows, []string{grant.Identity, grant.Scope, grant.Publisher, grant.OnBehalfOf, unixTime(grant.NotBefore), unixTime(grant.Expire)})
+  }
+  return t
+}
+
+func grantsList(set *getopt.Set) func(a *admin, args []string) int {
+  identity := set.StringLong("identity", 0, "", "ID")
+  publisher := set.StringLong("publisher", 0, "", "ID")
+
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    query := meui.ReadGrantsRequest{Identity: *identity, Publisher: *publisher}
+    if !a.validate(query) {
+      return a.code
+    }
+
+    url := config.Get().Aap.Grants
+    status, responses, err := aap.ReadGrants(a.aapClient(), url, []aap.ReadGrantsRequest{ {Identity: query.Identity, Publisher: query.Publisher} })
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    grants := aap.ReadGrantsResponse{}
+    if !a.read(0, "", responses, &grants, true) {
+      return a.code
+    }
+    return a.print(grants, grantsTable(grants))
+  }
+}
+
+func grantsGrant(set *getopt.Set) func(a *admin, args []string) int {
+  identity := set.StringLong("identity", 0, "", "ID")
+  scope := set.StringLong("scope", 0, "", "SCOPE")
+  publisher := set.StringLong("publisher", 0, "", "ID")
+  onBehalfOf := set.StringLong("on-behalf-of", 0, "", "ID")
+  notBefore := set.Int64Long("nbf", 0, 0, "UNIX")
+  expire := set.Int64Long("exp", 0, 0, "UNIX")
+
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    input := meui.CreateGrantsRequest{Identity: *identity, Scope: *scope, Publisher: *publisher, OnBehalfOf: *onBehalfOf, NotBefore: *notBefore, Expire: *expire}
+    if !a.validate(input) {
+      return a.code
+    }
+    if input.OnBehalfOf == "" {
+      input.OnBehalfOf = input.Publisher
+    }
+
+    url := config.Get().Aap.Grants
+    status, responses, err := aap.CreateGrants(a.aapClient(), url, []aap.CreateGrantsRequest{
+      {
+        Identity: input.Identity,
+        Scope: input.Scope,
+        Publisher: input.Publisher,
+        OnBehalfOf: input.OnBehalfOf,
+        NotBefore: input.NotBefore,
+        Expire: input.Expire,
+      },
+    })
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    var grant aap.CreateGrantsResponse
+    if !a.read(0, "", responses, &grant, false) {
+      return a.code
+    }
+
+    a.emit(webhooks.GrantCreated, grant)
+    return a.print(grant, grantsTable([]aap.Grant{ aap.Grant(grant) }))
+  }
+}
+
+func grantsRevoke(set *getopt.Set) func(a *admin, args []string) int {
+  identity := set.StringLong("identity", 0, "", "ID")
+  scope := set.StringLong("scope", 0, "", "SCOPE")
+  publisher := set.StringLong("publisher", 0, "", "ID")
+  onBehalfOf := set.StringLong("on-behalf-of", 0, "", "ID")
+
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    input := meui.DeleteGrantsRequest{Identity: *identity, Scope: *scope, Publisher: *publisher, OnBehalfOf: *onBehalfOf}
+    if !a.validate(input) {
+      return a.code
+    }
+    if input.OnBehalfOf == "" {
+      input.OnBehalfOf = input.Publisher
+    }
+
+    url := config.Get().Aap.Grants
+    request := aap.DeleteGrantsRequest{Identity: input.Identity, Scope: input.Scope, Publisher: input.Publisher, OnBehalfOf: input.OnBehalfOf}
+    status, responses, err := aap.DeleteGrants(a.aapClient(), url, []aap.DeleteGrantsRequest{request})
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    var deleted aap.DeleteGrantsResponse
+    if !a.read(0, "", responses, &deleted, false) {
+      return a.code
+    }
+
+    a.emit(webhooks.GrantDeleted, request)
+
+    // aap answers nothing for a revoked grant, the request tells which it was
+    t := table{header: []string{"Identity", "Scope", "Publisher", "On behalf of"}}
+    t.rows = append(t.rows, []string{request.Identity, request.Scope, request.Publisher, request.OnBehalfOf})
+    return a.print(request, t)
+  }
+}
+
+func invitesTable(invites []idp.Invite) table {
+  t := table{header: []string{"Id", "Email", "Username", "Issued", "Expires", "Sent"}}
+  for _, invite := range invites {
+    t.rows = append(t.rows, []string{invite.Id, invite.Email, invite.Username, unixTime(invite.IssuedAt), unixTime(invite.ExpiresAt), unixTime(invite.SentAt)})
+  }
+  return t
+}
+
+func invitesList(set *getopt.Set) func(a *admin, args []string) int {
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    url := config.Get().Idp.Invites
+    status, responses, err := idp.ReadInvites(a.idpClient(), url, nil)
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    invites := idp.ReadInvitesResponse{}
+    if !a.read(0, "", responses, &invites, true) {
+      return a.code
+    }
+    return a.print(invites, invitesTable(invites))
+  }
+}
+
+func invitesCreate(set *getopt.Set) func(a *admin, args []string) int {
+  email := set.StringLong("email", 0, "", "EMAIL")
+  username := set.StringLong("username", 0, "", "NAME")
+  expiresAt := set.Int64Long("exp", 0, 0, "UNIX")
+
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    input := meui.CreateInvitesRequest{Email: *email, Username: *username, ExpiresAt: *expiresAt}
+    if !a.validate(input) {
+      return a.code
+    }
+
+    url := config.Get().Idp.Invites
+    status, responses, err := idp.CreateInvites(a.idpClient(), url, []idp.CreateInvitesRequest{
+      {Email: input.Email, Username: input.Username, ExpiresAt: input.ExpiresAt},
+    })
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    var invite idp.CreateInvitesResponse
+    if !a.read(0, "", responses, &invite, false) {
+      return a.code
+    }
+
+    a.emit(webhooks.InviteCreated, invite)
+    return a.print(invite, invitesTable([]idp.Invite{ idp.Invite(invite) }))
+  }
+}
+
+func invitesSend(set *getopt.Set) func(a *admin, args []string) int {
+  return func(a *admin, args []string) int {
+    if !someArguments(args) {
+      return exitUsage
+    }
+
+    cfg := config.Get()
+    client := a.idpClient()
+
+    // Revoked, expired and accepted invites are not sent, nor those sent a moment ago, like the api refuses them
+    var readRequests []idp.ReadInvitesRequest
+    for _, id := range args {
+      readRequests = append(readRequests, idp.ReadInvitesRequest{Id: id})
+    }
+    status, invites, err := a.env.Invites.Read(client, cfg.Idp.Invites, cfg.Idp.Humans, readRequests)
+    if !a.check(cfg.Idp.Invites, status, err) {
+      return a.code
+    }
+
+    found := map[string]invitestore.Invite{}
+    for _, invite := range invites {
+      found[invite.Id] = invite
+    }
+
+    now := time.Now()
+    var requests []idp.CreateInvitesSendRequest
+    for _, id := range args {
+      invite, exists := found[id]
+      if !exists {
+        fmt.Fprintf(os.Stderr, "%s: Not found\n", id)
+        a.fail(exitNotFound)
+        continue
+      }
+      if err := invite.CanSend(now, cfg.Invites.ResendInterval); err != nil {
+        fmt.Fprintf(os.Stderr, "%s: %s\n", id, err.Error())
+        a.fail(exitUsage)
+        continue
+      }
+      requests = append(requests, idp.CreateInvitesSendRequest{Id: id})
+    }
+
+    if len(requests) == 0 {
+      return a.code
+    }
+
+    url := cfg.Idp.InvitesSend
+    status, responses, err := idp.CreateInvitesSend(client, url, requests)
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    sent := []idp.Invite{}
+    for i, request := range requests {
+      var invite idp.CreateInvitesSendResponse
+      if !a.read(i, request.Id, responses, &invite, false) {
+        continue
+      }
+      err = a.env.Invites.RecordSend(invite.Id, time.Now())
+      if err != nil {
+        fmt.Fprintf(os.Stderr, "Warning: %s: unable to record the send: %s\n", invite.Id, err.Error())
+      }
+      a.emit(webhooks.InviteSent, invite)
+      sent = append(sent, idp.Invite(invite))
+    }
+    return a.print(sent, invitesTable(sent))
+  }
+}
+
+func scopesTable(scopes []aap.Scope) table {
+  t := table{header: []string{"Scope"}}
+  for _, scope := range scopes {
+    t.rows = append(t.rows, []string{scope.Scope})
+  }
+  return t
+}
+
+func scopesList(set *getopt.Set) func(a *admin, args []string) int {
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    url := config.Get().Aap.Scopes
+    status, responses, err := aap.ReadScopes(a.aapClient(), url, nil)
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    scopes := aap.ReadScopesResponse{}
+    if !a.read(0, "", responses, &scopes, true) {
+      return a.code
+    }
+    return a.print(scopes, scopesTable(scopes))
+  }
+}
+
+func scopesCreate(set *getopt.Set) func(a *admin, args []string) int {
+  return func(a *admin, args []string) int {
+    if !someArguments(args) {
+      return exitUsage
+    }
+
+    var requests []aap.CreateScopesRequest
+    for _, scope := range args {
+      if !a.validate(meui.CreateScopesRequest{Scope: scope}) {
+        return a.code
+      }
+      requests = append(requests, aap.CreateScopesRequest{Scope: scope})
+    }
+
+    url := config.Get().Aap.Scopes
+    status, responses, err := aap.CreateScopes(a.aapClient(), url, requests)
+    if !a.check(url, status, err) {
+      return a.code
+    }
+
+    created := []aap.Scope{}
+    for i, request := range requests {
+      var scope aap.CreateScopesResponse
+      if !a.read(i, request.Scope, responses, &scope, false) {
+        continue
+      }
+      created = append(created, aap.Scope(scope))
+    }
+    return a.print(created, scopesTable(created))
+  }
+}
@@ -0,0 +1,898 @@
+package main
+
+import (
+  "os"
+  "fmt"
+  "sort"
+  "bufio"
+  "strings"
+  "io/ioutil"
+  "gopkg.in/yaml.v2"
+  "github.com/pborman/getopt"
+  idp "github.com/opensentry/idp/client"
+  aap "github.com/opensentry/aap/client"
+
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// Access configuration of meui apply and meui export. Resource servers and roles are referred to by name in the
+// rest of the file, other identities by id.
+type accessConfig struct {
+  ResourceServers []accessResourceServer `json:"resource_servers,omitempty" yaml:"resource_servers,omitempty"`
+  Roles []accessRole `json:"roles,omitempty" yaml:"roles,omitempty"`
+  Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+  Publishes []accessPublish `json:"publishes,omitempty" yaml:"publishes,omitempty"`
+  Subscriptions []accessSubscription `json:"subscriptions,omitempty" yaml:"subscriptions,omitempty"`
+  Shadows []accessShadow `json:"shadows,omitempty" yaml:"shadows,omitempty"`
+  Grants []accessGrant `json:"grants,omitempty" yaml:"grants,omitempty"`
+}
+
+type accessResourceServer struct {
+  Name string `json:"name" yaml:"name"`
+  Description string `json:"description" yaml:"description"`
+  Audience string `json:"audience" yaml:"audience"`
+}
+
+type accessRole struct {
+  Name string `json:"name" yaml:"name"`
+  Description string `json:"description" yaml:"description"`
+}
+
+type accessPublish struct {
+  Publisher string `json:"publisher" yaml:"publisher"`
+  Scope string `json:"scope" yaml:"scope"`
+  Title string `json:"title" yaml:"title"`
+  Description string `json:"description" yaml:"description"`
+  MayGrantScopes []string `json:"may_grant_scopes,omitempty" yaml:"may_grant_scopes,omitempty"`
+}
+
+type accessSubscription struct {
+  Subscriber string `json:"subscriber" yaml:"subscriber"`
+  Publisher string `json:"publisher" yaml:"publisher"`
+  Scope string `json:"scope" yaml:"scope"`
+}
+
+type accessShadow struct {
+  Identity string `json:"identity" yaml:"identity"`
+  Shadow string `json:"shadow" yaml:"shadow"`
+  NotBefore int64 `json:"nbf,omitempty" yaml:"nbf,omitempty"`
+  Expire int64 `json:"exp,omitempty" yaml:"exp,omitempty"`
+}
+
+type accessGrant struct {
+  Identity string `json:"identity" yaml:"identity"`
+  Scope string `json:"scope" yaml:"scope"`
+  Publisher string `json:"publisher" yaml:"publisher"`
+  OnBehalfOf string `json:"on_behalf_of,omitempty" yaml:"on_behalf_of,omitempty"` // The publisher if empty
+  NotBefore int64 `json:"nbf,omitempty" yaml:"nbf,omitempty"`
+  Expire int64 `json:"exp,omitempty" yaml:"exp,omitempty"`
+}
+
+// Reads an access configuration, json is read as the yaml it also is. - reads stdin.
+func readAccessConfig(path string) (*accessConfig, error) {
+  var data []byte
+  var err error
+  if path == "-" {
+    data, err = ioutil.ReadAll(os.Stdin)
+  } else {
+    data, err = ioutil.ReadFile(path)
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  var file accessConfig
+  err = yaml.UnmarshalStrict(data, &file)
+  if err != nil {
+    return nil, fmt.Errorf("Unable to parse %s: %s", path, err.Error())
+  }
+  return &file, nil
+}
+
+// Returns the problems of an access configuration, idp and aap would reject the items or apply could not tell them apart
+func (file *accessConfig) problems() (problems []string) {
+  add := func(format string, args ...interface{}) {
+    problems = append(problems, fmt.Sprintf(format, args...))
+  }
+
+  names := map[string]bool{}
+  for i, rs := range file.ResourceServers {
+    if rs.Name == "" || rs.Description == "" || rs.Audience == "" {
+      add("resource_servers[%d]: name, description and audience are required", i)
+    }
+    if names[rs.Name] {
+      add("resource_servers[%d]: the name %s is used twice", i, rs.Name)
+    }
+    names[rs.Name] = true
+  }
+  for i, role := range file.Roles {
+    if role.Name == "" || role.Description == "" {
+      add("roles[%d]: name and description are required", i)
+    }
+    if names[role.Name] {
+      add("roles[%d]: the name %s is used twice", i, role.Name)
+    }
+    names[role.Name] = true
+  }
+
+  keys := map[string]bool{}
+  unique := func(section string, i int, key ...interface{}) {
+    k := fmt.Sprint(append([]interface{}{section}, key...)...)
+    if keys[k] {
+      add("%s[%d]: listed twice", section, i)
+    }
+    keys[k] = true
+  }
+
+  for i, scope := range file.Scopes {
+    if scope == "" || strings.ContainsAny(scope, " \t\n") {
+      add("scopes[%d]: a scope is required and must not contain spaces", i)
+    }
+    unique("scopes", i, scope)
+  }
+  for i, p := range file.Publishes {
+    if p.Publisher == "" || p.Scope == "" || p.Title == "" || p.Description == "" {
+      add("publishes[%d]: publisher, scope, title and description are required", i)
+    }
+    unique("publishes", i, p.Publisher, " ", p.Scope)
+  }
+  for i, s := range file.Subscriptions {
+    if s.Subscriber == "" || s.Publisher == "" || s.Scope == "" {
+      add("subscriptions[%d]: subscriber, publisher and scope are required", i)
+    }
+    unique("subscriptions", i, s.Subscriber, " ", s.Publisher, " ", s.Scope)
+  }
+  for i, s := range file.Shadows {
+    if s.Identity == "" || s.Shadow == "" {
+      add("shadows[%d]: identity and shadow are required", i)
+    }
+    if s.NotBefore < 0 || (s.Expire != 0 && s.Expire < s.NotBefore) {
+      add("shadows[%d]: exp must be 0 or after nbf", i)
+    }
+    unique("shadows", i, s.Identity, " ", s.Shadow)
+  }
+  for i, g := range file.Grants {
+    if g.Identity == "" || g.Scope == "" || g.Publisher == "" {
+      add("grants[%d]: identity, scope and publisher are required", i)
+    }
+    if g.NotBefore < 0 || (g.Expire != 0 && g.Expire < g.NotBefore) {
+      add("grants[%d]: exp must be 0 or after nbf", i)
+    }
+    unique("grants", i, g.Identity, " ", g.Scope, " ", g.Publisher, " ", g.onBehalfOf())
+  }
+  return problems
+}
+
+func (g accessGrant) onBehalfOf() string {
+  if g.OnBehalfOf == "" {
+    return g.Publisher
+  }
+  return g.OnBehalfOf
+}
+
+// A change of the plan of meui apply
+type change struct {
+  Action string `json:"action"`
+  Kind string `json:"kind"`
+  Item string `json:"item"`
+  run func() bool
+}
+
+// Plans and applies the changes making idp and aap match an access configuration
+type reconciler struct {
+  a *admin
+  file *accessConfig
+  declared map[string]bool // Names of the resource servers and roles of the file
+  ids map[string]string // Ids of the declared resource servers and roles by name, missing until they are created
+  names map[string]string // Names of the live resource servers and roles by id, printed instead of their ids
+  warnings []string
+}
+
+func newReconciler(a *admin, file *accessConfig) *reconciler {
+  r := &reconciler{a: a, file: file, declared: map[string]bool{}, ids: map[string]string{}, names: map[string]string{}}
+  for _, rs := range file.ResourceServers {
+    r.declared[rs.Name] = true
+  }
+  for _, role := range file.Roles {
+    r.declared[role.Name] = true
+  }
+  return r
+}
+
+// Id of a reference of the file, empty if it names a resource server or role not created yet
+func (r *reconciler) id(ref string) string {
+  if r.declared[ref] {
+    return r.ids[ref]
+  }
+  return ref
+}
+
+// Name of a live identity if it is a resource server or role
+func (r *reconciler) name(id string) string {
+  if name, exists := r.names[id]; exists {
+    return name
+  }
+  return id
+}
+
+func (r *reconciler) warn(format string, args ...interface{}) {
+  r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+}
+
+// Ids of the identities a section of the file is authoritative for, the declared ones existing and those given by refs
+func (r *reconciler) owners(refs []string) (ids []string) {
+  seen := map[string]bool{}
+  add := func(id string) {
+    if id != "" && !seen[id] {
+      seen[id] = true
+      ids = append(ids, id)
+    }
+  }
+  for _, rs := range r.file.ResourceServers {
+    add(r.id(rs.Name))
+  }
+  for _, role := range r.file.Roles {
+    add(r.id(role.Name))
+  }
+  for _, ref := range refs {
+    add(r.id(ref))
+  }
+  return ids
+}
+
+// Resolves a reference when a change runs, resource servers and roles of the file may have been created by then
+func (r *reconciler) resolve(ref string) (string, bool) {
+  id := r.id(ref)
+  if id == "" {
+    fmt.Fprintf(os.Stderr, "%s: was not created\n", ref)
+    r.a.fail(exitFailed)
+    return "", false
+  }
+  return id, true
+}
+
+// Reads the live state of idp and aap and returns the changes making it match the file
+func (r *reconciler) plan(prune bool) (changes []change, ok bool) {
+  var creates, deletes, grantCreates, prunes []change
+  cfg := config.Get()
+
+  // Resource servers and roles have no update, a changed one is only warned about as recreating it changes its id
+
+  url := cfg.Idp.ResourceServers
+  status, responses, err := idp.ReadResourceServers(r.a.idpClient(), url, nil)
+  if !r.a.check(url, status, err) {
+    return nil, false
+  }
+  resourceServers := idp.ReadResourceServersResponse{}
+  if !r.a.read(0, "", responses, &resourceServers, true) {
+    return nil, false
+  }
+  liveResourceServers := map[string]idp.ResourceServer{}
+  for _, rs := range resourceServers {
+    liveResourceServers[rs.Name] = rs
+    r.names[rs.Id] = rs.Name
+  }
+  for _, rs := range r.file.ResourceServers {
+    live, exists := liveResourceServers[rs.Name]
+    if !exists {
+      creates = append(creates, r.createResourceServer(rs))
+      continue
+    }
+    r.ids[rs.Name] = live.Id
+    if live.Description != rs.Description || live.Audience != rs.Audience {
+      r.warn("resource server %s: description or audience differs from the file and cannot be updated", rs.Name)
+    }
+  }
+  if prune {
+    for _, rs := range resourceServers {
+      if !r.declared[rs.Name] {
+        prunes = append(prunes, r.deleteResourceServer(rs))
+      }
+    }
+  }
+
+  url = cfg.Idp.Roles
+  status, responses, err = idp.ReadRoles(r.a.idpClient(), url, nil)
+  if !r.a.check(url, status, err) {
+    return nil, false
+  }
+  roles := idp.ReadRolesResponse{}
+  if !r.a.read(0, "", responses, &roles, true) {
+    return nil, false
+  }
+  liveRoles := map[string]idp.Role{}
+  for _, role := range roles {
+    liveRoles[role.Name] = role
+    r.names[role.Id] = role.Name
+  }
+  for _, role := range r.file.Roles {
+    live, exists := liveRoles[role.Name]
+    if !exists {
+      creates = append(creates, r.createRole(role))
+      continue
+    }
+    r.ids[role.Name] = live.Id
+    if live.Description != role.Description {
+      r.warn("role %s: description differs from the file and cannot be updated", role.Name)
+    }
+  }
+  if prune {
+    for _, role := range roles {
+      if !r.declared[role.Name] {
+        prunes = append(prunes, r.deleteRole(role))
+      }
+    }
+  }
+
+  url = cfg.Aap.Scopes
+  status, responses, err = aap.ReadScopes(r.a.aapClient(), url, nil)
+  if !r.a.check(url, status, err) {
+    return nil, false
+  }
+  scopes := aap.ReadScopesResponse{}
+  if !r.a.read(0, "", responses, &scopes, true) {
+    return nil, false
+  }
+  liveScopes := map[string]bool{}
+  for _, scope := range scopes {
+    liveScopes[scope.Scope] = true
+  }
+  for _, scope := range r.file.Scopes {
+    if !liveScopes[scope] {
+      creates = append(creates, r.createScope(scope))
+    }
+  }
+
+  // Publishes, subscriptions, shadows and grants are read for the identities the file is authoritative for
+
+  var refs []string
+  for _, p := range r.file.Publishes {
+    refs = append(refs, p.Publisher)
+  }
+  publishers := r.owners(refs)
+  livePublishes := map[string]aap.Publish{}
+  if len(publishers) > 0 {
+    var requests []aap.ReadPublishesRequest
+    for _, id := range publishers {
+      requests = append(requests, aap.ReadPublishesRequest{Publisher: id})
+    }
+    url = cfg.Aap.Publishes
+    status, responses, err = aap.ReadPublishes(r.a.aapClient(), url, requests)
+    if !r.a.check(url, status, err) {
+      return nil, false
+    }
+    for i, request := range requests {
+      publishes := aap.ReadPublishesResponse{}
+      if !r.a.read(i, r.name(request.Publisher), responses, &publishes, true) {
+        return nil, false
+      }
+      for _, p := range publishes {
+        livePublishes[p.Publisher + " " + p.Scope] = p
+      }
+    }
+  }
+  wanted := map[string]bool{}
+  for _, p := range r.file.Publishes {
+    key := r.id(p.Publisher) + " " + p.Scope
+    wanted[key] = true
+    live, exists := livePublishes[key]
+    if !exists || r.id(p.Publisher) == "" {
+      creates = append(creates, r.createPublish(p))
+      if len(p.MayGrantScopes) > 0 {
+        r.warn("publish %s %s: may_grant_scopes cannot be set through aap and are left to it", p.Publisher, p.Scope)
+      }
+      continue
+    }
+    if live.Title != p.Title || live.Description != p.Description || !sameStrings(live.MayGrantScopes, p.MayGrantScopes) {
+      r.warn("publish %s %s: title, description or may_grant_scopes differ from the file and cannot be updated", p.Publisher, p.Scope)
+    }
+  }
+  for key, p := range livePublishes {
+    if !wanted[key] {
+      r.warn("publish %s %s: is not in the file and cannot be deleted", r.name(p.Publisher), p.Scope)
+    }
+  }
+
+  refs = nil
+  for _, s := range r.file.Subscriptions {
+    refs = append(refs, s.Subscriber)
+  }
+  subscribers := r.owners(refs)
+  liveSubscriptions := map[string]aap.Subscription{}
+  if len(subscribers) > 0 {
+    var requests []aap.ReadSubscriptionsRequest
+    for _, id := range subscribers {
+      requests = append(requests, aap.ReadSubscriptionsRequest{Subscriber: id})
+    }
+    url = cfg.Aap.Subscriptions
+    status, responses, err = aap.ReadSubscriptions(r.a.aapClient(), url, requests)
+    if !r.a.check(url, status, err) {
+      return nil, false
+    }
+    for i, request := range requests {
+      subscriptions := aap.ReadSubscriptionsResponse{}
+      if !r.a.read(i, r.name(request.Subscriber), responses, &subscriptions, true) {
+        return nil, false
+      }
+      for _, s := range subscriptions {
+        liveSubscriptions[s.Subscriber + " " + s.Publisher + " " + s.Scope] = s
+      }
+    }
+  }
+  wanted = map[string]bool{}
+  for _, s := range r.file.Subscriptions {
+    subscriber, publisher := r.id(s.Subscriber), r.id(s.Publisher)
+    key := subscriber + " " + publisher + " " + s.Scope
+    wanted[key] = true
+    if _, exists := liveSubscriptions[key]; !exists || subscriber == "" || publisher == "" {
+      grantCreates = append(grantCreates, r.createSubscription(s))
+    }
+  }
+  for _, key := range sortedKeys(liveSubscriptions) {
+    if !wanted[key] {
+      deletes = append(deletes, r.deleteSubscription(liveSubscriptions[key]))
+    }
+  }
+
+  refs = nil
+  for _, s := range r.file.Shadows {
+    refs = append(refs, s.Identity)
+  }
+  identities := r.owners(refs)
+  liveShadows := map[string]aap.Shadow{}
+  if len(identities) > 0 {
+    var requests []aap.ReadShadowsRequest
+    for _, id := range identities {
+      requests = append(requests, aap.ReadShadowsRequest{Identity: id})
+    }
+    url = cfg.Aap.Shadows
+    status, responses, err = aap.ReadShadows(r.a.aapClient(), url, requests)
+    if !r.a.check(url, status, err) {
+      return nil, false
+    }
+    for i, request := range requests {
+      shadows := aap.ReadShadowsResponse{}
+      if !r.a.read(i, r.name(request.Identity), responses, &shadows, true) {
+        return nil, false
+      }
+      for _, s := range shadows {
+        liveShadows[fmt.Sprintf("%s %s %d %d", s.Identity, s.Shadow, s.NotBefore, s.Expire)] = s
+      }
+    }
+  }
+  wanted = map[string]bool{}
+  for _, s := range r.file.Shadows {
+    identity, shadow := r.id(s.Identity), r.id(s.Shadow)
+    key := fmt.Sprintf("%s %s %d %d", identity, shadow, s.NotBefore, s.Expire)
+    wanted[key] = true
+    if _, exists := liveShadows[key]; !exists || identity == "" || shadow == "" {
+      grantCreates = append(grantCreates, r.createShadow(s))
+    }
+  }
+  for _, key := range sortedKeys(liveShadows) {
+    if !wanted[key] {
+      deletes = append(deletes, r.deleteShadow(liveShadows[key]))
+    }
+  }
+
+  refs = nil
+  for _, g := range r.file.Grants {
+    refs = append(refs, g.Identity)
+  }
+  identities = r.owners(refs)
+  liveGrants := map[string]aap.Grant{}
+  if len(identities) > 0 {
+    var requests []aap.ReadGrantsRequest
+    for _, id := range identities {
+      requests = append(requests, aap.ReadGrantsRequest{Identity: id})
+    }
+    url = cfg.Aap.Grants
+    status, responses, err = aap.ReadGrants(r.a.aapClient(), url, requests)
+    if !r.a.check(url, status, err) {
+      return nil, false
+    }
+    for i, request := range requests {
+      grants := aap.ReadGrantsResponse{}
+      if !r.a.read(i, r.name(request.Identity), responses, &grants, true) {
+        return nil, false
+      }
+      for _, g := range grants {
+        liveGrants[grantKey(g.Identity, g.Scope, g.Publisher, g.OnBehalfOf, g.NotBefore, g.Expire)] = g
+      }
+    }
+  }
+  wanted = map[string]bool{}
+  for _, g := range r.file.Grants {
+    identity, publisher, onBehalfOf := r.id(g.Identity), r.id(g.Publisher), r.id(g.onBehalfOf())
+    key := grantKey(identity, g.Scope, publisher, onBehalfOf, g.NotBefore, g.Expire)
+    wanted[key] = true
+    if _, exists := liveGrants[key]; !exists || identity == "" || publisher == "" || onBehalfOf == "" {
+      grantCreates = append(grantCreates, r.createGrant(g))
+    }
+  }
+  for _, key := range sortedKeys(liveGrants) {
+    if !wanted[key] {
+      deletes = append(deletes, r.deleteGrant(liveGrants[key]))
+    }
+  }
+
+  // Identities and scopes come first as the rest refers to them. A changed shadow or grant is deleted before it is
+  // created again, and pruned resource servers and roles go last.
+  changes = append(changes, creates...)
+  changes = append(changes, deletes...)
+  changes = append(changes, grantCreates...)
+  changes = append(changes, prunes...)
+  return changes, true
+}
+
+func grantKey(identity string, scope string, publisher string, onBehalfOf string, notBefore int64, expire int64) string {
+  return fmt.Sprintf("%s %s %s %s %d %d", identity, scope, publisher, onBehalfOf, notBefore, expire)
+}
+
+func sortedKeys(m interface{}) (keys []string) {
+  switch items := m.(type) {
+  case map[string]aap.Subscription:
+    for key, _ := range items {
+      keys = append(keys, key)
+    }
+  case map[string]aap.Shadow:
+    for key, _ := range items {
+      keys = append(keys, key)
+    }
+  case map[string]aap.Grant:
+    for key, _ := range items {
+      keys = append(keys, key)
+    }
+  }
+  sort.Strings(keys)
+  return keys
+}
+
+func sameStrings(a []string, b []string) bool {
+  if len(a) != len(b) {
+    return false
+  }
+  a = append([]string{}, a...)
+  b = append([]string{}, b...)
+  sort.Strings(a)
+  sort.Strings(b)
+  for i, _ := range a {
+    if a[i] != b[i] {
+      return false
+    }
+  }
+  return true
+}
+
+// Validity of shadows and grants in the plan, empty when they are always valid
+func validity(notBefore int64, expire int64) (s string) {
+  if notBefore != 0 {
+    s = s + " from " + unixTime(notBefore)
+  }
+  if expire != 0 {
+    s = s + " until " + unixTime(expire)
+  }
+  return s
+}
+
+func (r *reconciler) createResourceServer(rs accessResourceServer) change {
+  return change{Action: "create", Kind: "resource server", Item: rs.Name + " " + rs.Audience, run: func() bool {
+    url := config.Get().Idp.ResourceServers
+    status, responses, err := idp.CreateResourceServers(r.a.idpClient(), url, []idp.CreateResourceServersRequest{
+      {Name: rs.Name, Description: rs.Description, Audience: rs.Audience},
+    })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var created idp.CreateResourceServersResponse
+    if !r.a.read(0, rs.Name, responses, &created, false) {
+      return false
+    }
+    r.ids[rs.Name] = created.Id
+    return true
+  }}
+}
+
+func (r *reconciler) deleteResourceServer(rs idp.ResourceServer) change {
+  return change{Action: "delete", Kind: "resource server", Item: rs.Name + " " + rs.Audience, run: func() bool {
+    url := config.Get().Idp.ResourceServers
+    status, responses, err := idp.DeleteResourceServers(r.a.idpClient(), url, []idp.DeleteResourceServersRequest{ {Id: rs.Id} })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var deleted idp.DeleteResourceServersResponse
+    return r.a.read(0, rs.Name, responses, &deleted, false)
+  }}
+}
+
+func (r *reconciler) createRole(role accessRole) change {
+  return change{Action: "create", Kind: "role", Item: role.Name, run: func() bool {
+    url := config.Get().Idp.Roles
+    status, responses, err := idp.CreateRoles(r.a.idpClient(), url, []idp.CreateRolesRequest{
+      {Name: role.Name, Description: role.Description},
+    })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var created idp.CreateRolesResponse
+    if !r.a.read(0, role.Name, responses, &created, false) {
+      return false
+    }
+    r.ids[role.Name] = created.Id
+    r.a.emit(webhooks.RoleCreated, created)
+    return true
+  }}
+}
+
+func (r *reconciler) deleteRole(role idp.Role) change {
+  return change{Action: "delete", Kind: "role", Item: role.Name, run: func() bool {
+    url := config.Get().Idp.Roles
+    status, responses, err := idp.DeleteRoles(r.a.idpClient(), url, []idp.DeleteRolesRequest{ {Id: role.Id} })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var deleted idp.DeleteRolesResponse
+    if !r.a.read(0, role.Name, responses, &deleted, false) {
+      return false
+    }
+    r.a.emit(webhooks.RoleDeleted, deleted)
+    return true
+  }}
+}
+
+func (r *reconciler) createScope(scope string) change {
+  return change{Action: "create", Kind: "scope", Item: scope, run: func() bool {
+    url := config.Get().Aap.Scopes
+    status, responses, err := aap.CreateScopes(r.a.aapClient(), url, []aap.CreateScopesRequest{ {Scope: scope} })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var created aap.CreateScopesResponse
+    return r.a.read(0, scope, responses, &created, false)
+  }}
+}
+
+func (r *reconciler) createPublish(p accessPublish) change {
+  return change{Action: "create", Kind: "publish", Item: p.Publisher + " " + p.Scope, run: func() bool {
+    publisher, ok := r.resolve(p.Publisher)
+    if !ok {
+      return false
+    }
+    url := config.Get().Aap.Publishes
+    status, responses, err := aap.CreatePublishes(r.a.aapClient(), url, []aap.CreatePublishesRequest{
+      {Publisher: publisher, Scope: p.Scope, Title: p.Title, Description: p.Description},
+    })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var created aap.CreatePublishesResponse
+    return r.a.read(0, p.Publisher + " " + p.Scope, responses, &created, false)
+  }}
+}
+
+func (r *reconciler) createSubscription(s accessSubscription) change {
+  item := s.Subscriber + " " + s.Publisher + " " + s.Scope
+  return change{Action: "create", Kind: "subscription", Item: item, run: func() bool {
+    subscriber, ok := r.resolve(s.Subscriber)
+    if !ok {
+      return false
+    }
+    publisher, ok := r.resolve(s.Publisher)
+    if !ok {
+      return false
+    }
+    url := config.Get().Aap.Subscriptions
+    status, responses, err := aap.CreateSubscriptions(r.a.aapClient(), url, []aap.CreateSubscriptionsRequest{
+      {Subscriber: subscriber, Publisher: publisher, Scope: s.Scope},
+    })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var created aap.CreateSubscriptionsResponse
+    if !r.a.read(0, item, responses, &created, false) {
+      return false
+    }
+    r.a.emit(webhooks.SubscriptionCreated, created)
+    return true
+  }}
+}
+
+func (r *reconciler) deleteSubscription(s aap.Subscription) change {
+  item := r.name(s.Subscriber) + " " + r.name(s.Publisher) + " " + s.Scope
+  return change{Action: "delete", Kind: "subscription", Item: item, run: func() bool {
+    request := aap.DeleteSubscriptionsRequest{Subscriber: s.Subscriber, Publisher: s.Publisher, Scope: s.Scope}
+    url := config.Get().Aap.Subscriptions
+    status, responses, err := aap.DeleteSubscriptions(r.a.aapClient(), url, []aap.DeleteSubscriptionsRequest{request})
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var deleted aap.DeleteSubscriptionsResponse
+    if !r.a.read(0, item, responses, &deleted, false) {
+      return false
+    }
+    r.a.emit(webhooks.SubscriptionDeleted, request)
+    return true
+  }}
+}
+
+func (r *reconciler) createShadow(s accessShadow) change {
+  item := s.Identity + " " + s.Shadow + validity(s.NotBefore, s.Expire)
+  return change{Action: "create", Kind: "shadow", Item: item, run: func() bool {
+    identity, ok := r.resolve(s.Identity)
+    if !ok {
+      return false
+    }
+    shadow, ok := r.resolve(s.Shadow)
+    if !ok {
+      return false
+    }
+    url := config.Get().Aap.Shadows
+    status, responses, err := aap.CreateShadows(r.a.aapClient(), url, []aap.CreateShadowsRequest{
+      {Identity: identity, Shadow: shadow, NotBefore: s.NotBefore, Expire: s.Expire},
+    })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var created aap.CreateShadowsResponse
+    if !r.a.read(0, item, responses, &created, false) {
+      return false
+    }
+    r.a.emit(webhooks.ShadowCreated, created)
+    return true
+  }}
+}
+
+func (r *reconciler) deleteShadow(s aap.Shadow) change {
+  item := r.name(s.Identity) + " " + r.name(s.Shadow) + validity(s.NotBefore, s.Expire)
+  return change{Action: "delete", Kind: "shadow", Item: item, run: func() bool {
+    url := config.Get().Aap.Shadows
+    status, responses, err := aap.DeleteShadows(r.a.aapClient(), url, []aap.DeleteShadowsRequest{
+      {Identity: s.Identity, Shadow: s.Shadow},
+    })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var deleted aap.DeleteShadowsResponse
+    if !r.a.read(0, item, responses, &deleted, false) {
+      return false
+    }
+    r.a.emit(webhooks.ShadowDeleted, deleted)
+    return true
+  }}
+}
+
+func (r *reconciler) createGrant(g accessGrant) change {
+  item := g.Identity + " " + g.Scope + " " + g.Publisher
+  if g.onBehalfOf() != g.Publisher {
+    item = item + " on behalf of " + g.onBehalfOf()
+  }
+  item = item + validity(g.NotBefore, g.Expire)
+  return change{Action: "create", Kind: "grant", Item: item, run: func() bool {
+    identity, ok := r.resolve(g.Identity)
+    if !ok {
+      return false
+    }
+    publisher, ok := r.resolve(g.Publisher)
+    if !ok {
+      return false
+    }
+    onBehalfOf, ok := r.resolve(g.onBehalfOf())
+    if !ok {
+      return false
+    }
+    url := config.Get().Aap.Grants
+    status, responses, err := aap.CreateGrants(r.a.aapClient(), url, []aap.CreateGrantsRequest{
+      {Identity: identity, Scope: g.Scope, Publisher: publisher, OnBehalfOf: onBehalfOf, NotBefore: g.NotBefore, Expire: g.Expire},
+    })
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var created aap.CreateGrantsResponse
+    if !r.a.read(0, item, responses, &created, false) {
+      return false
+    }
+    r.a.emit(webhooks.GrantCreated, created)
+    return true
+  }}
+}
+
+func (r *reconciler) deleteGrant(g aap.Grant) change {
+  item := r.name(g.Identity) + " " + g.Scope + " " + r.name(g.Publisher)
+  if g.OnBehalfOf != g.Publisher {
+    item = item + " on behalf of " + r.name(g.OnBehalfOf)
+  }
+  item = item + validity(g.NotBefore, g.Expire)
+  return change{Action: "delete", Kind: "grant", Item: item, run: func() bool {
+    request := aap.DeleteGrantsRequest{Identity: g.Identity, Scope: g.Scope, Publisher: g.Publisher, OnBehalfOf: g.OnBehalfOf}
+    url := config.Get().Aap.Grants
+    status, responses, err := aap.DeleteGrants(r.a.aapClient(), url, []aap.DeleteGrantsRequest{request})
+    if !r.a.check(url, status, err) {
+      return false
+    }
+    var deleted aap.DeleteGrantsResponse
+    if !r.a.read(0, item, responses, &deleted, false) {
+      return false
+    }
+    r.a.emit(webhooks.GrantDeleted, request)
+    return true
+  }}
+}
+
+func changesTable(changes []change) table {
+  t := table{header: []string{"Action", "Kind", "Item"}}
+  for _, c := range changes {
+    t.rows = append(t.rows, []string{c.Action, c.Kind, c.Item})
+  }
+  return t
+}
+
+// Asks on the terminal before changing anything, without a terminal --yes is required
+func confirm(question string) bool {
+  info, err := os.Stdin.Stat()
+  if err != nil || info.Mode() & os.ModeCharDevice == 0 {
+    fmt.Fprintln(os.Stderr, "Not asking without a terminal, give --yes to apply the plan")
+    return false
+  }
+
+  fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+  answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+  answer = strings.ToLower(strings.TrimSpace(answer))
+  return answer == "y" || answer == "yes"
+}
+
+func accessApply(set *getopt.Set) func(a *admin, args []string) int {
+  dryRun := set.BoolLong("dry-run", 0)
+  yes := set.BoolLong("yes", 'y')
+  prune := set.BoolLong("prune", 0)
+
+  return func(a *admin, args []string) int {
+    if len(args) != 1 {
+      fmt.Fprint(os.Stderr, adminUsage)
+      return exitUsage
+    }
+
+    file, err := readAccessConfig(args[0])
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err.Error())
+      return exitUsage
+    }
+    problems := file.problems()
+    if len(problems) > 0 {
+      for _, problem := range problems {
+        fmt.Fprintln(os.Stderr, problem)
+      }
+      return exitUsage
+    }
+
+    r := newReconciler(a, file)
+    changes, ok := r.plan(*prune)
+    if !ok {
+      return a.code
+    }
+    for _, warning := range r.warnings {
+      fmt.Fprintln(os.Stderr, "Warning: " + warning)
+    }
+
+    if len(changes) == 0 {
+      fmt.Fprintln(os.Stderr, "No changes, idp and aap match the file")
+      return a.print([]change{}, changesTable(nil))
+    }
+    a.print(changes, changesTable(changes))
+    if *dryRun {
+      return a.code
+    }
+    if !*yes && !confirm(fmt.Sprintf("Apply %d changes?", len(changes))) {
+      return exitUsage
+    }
+
+    applied := 0
+    for _, c := range changes {
+      if c.run() {
+        applied++
+      }
+    }
+    fmt.Fprintf(os.Stderr, "Applied %d of %d changes\n", applied, len(changes))
+    return a.code
+  }
+}
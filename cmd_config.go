@@ -0,0 +1,127 @@
+package main
+
+import (
+  "os"
+  "fmt"
+  "sort"
+  "strings"
+  "text/tabwriter"
+  "encoding/json"
+
+  "github.com/opensentry/meui/config"
+)
+
+const configUsage = `Usage: meui config <command>
+
+Commands:
+  validate  Read app.yml and discovery.yml like --serve does and report every missing or invalid key
+  print     Print the effective configuration, environment overrides included, with secrets redacted
+  keys      List every key meui reads with its default and description, (restart) marks keys not reloaded when the files change
+
+Set CONFIG_APP_PATH and CONFIG_DISCOVERY_PATH to use other files than ./app.yml and ./discovery.yml
+
+Secret keys take file:///path or env://NAME references instead of the secret itself, or a file named by
+the key as environment variable with _FILE appended, eg. OAUTH2_CLIENT_SECRET_FILE=/run/secrets/meui
+`
+
+// meui config validate|print|keys. Returns the exit code, 1 if the configuration is invalid and 2 on wrong usage.
+func configCommand(args []string) int {
+  if len(args) != 1 {
+    fmt.Fprint(os.Stderr, configUsage)
+    return 2
+  }
+
+  switch args[0] {
+  case "validate":
+    return configValidate()
+  case "print":
+    return configPrint()
+  case "keys":
+    return configKeys()
+  }
+
+  fmt.Fprint(os.Stderr, configUsage)
+  return 2
+}
+
+func configValidate() int {
+  err := config.ReadConfigurations()
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err.Error())
+    return 1
+  }
+
+  // Unknown keys are often typos of keys reported missing below, but they do not stop meui from starting
+  _, unknown := config.Settings()
+  sort.Strings(unknown)
+  for _, name := range unknown {
+    fmt.Fprintf(os.Stderr, "Warning: %s is not a key meui reads\n", name)
+  }
+
+  _, err = config.Load()
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err.Error())
+    return 1
+  }
+
+  fmt.Println("Configuration is valid")
+  return 0
+}
+
+func configPrint() int {
+  err := config.ReadConfigurations()
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err.Error())
+    return 1
+  }
+
+  settings, _ := config.Settings()
+
+  var names []string
+  for name, _ := range settings {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  // One key per line as yaml, values in json which yaml also reads
+  for _, name := range names {
+    fmt.Printf("%s: %s\n", name, jsonValue(settings[name]))
+  }
+  return 0
+}
+
+func configKeys() int {
+  w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+  fmt.Fprintln(w, "KEY\tDEFAULT\tDESCRIPTION")
+  for _, k := range config.Keys {
+    def := "-"
+    if k.Default != nil {
+      def = jsonValue(k.Default)
+      if len(def) > 40 {
+        def = "(see config print)" // eg. csp.directives
+      }
+    }
+
+    description := k.Description
+    if k.Secret {
+      description = strings.TrimSuffix(description, ".") + " (secret)"
+    }
+    if config.NeedsRestart(k.Name) {
+      description = strings.TrimSuffix(description, ".") + " (restart)"
+    }
+    fmt.Fprintf(w, "%s\t%s\t%s\n", k.Name, def, description)
+  }
+  w.Flush()
+  return 0
+}
+
+func jsonValue(v interface{}) string {
+  var b strings.Builder
+  enc := json.NewEncoder(&b)
+  enc.SetEscapeHTML(false)
+  err := enc.Encode(v)
+  if err != nil {
+    return fmt.Sprintf("%q", fmt.Sprint(v))
+  }
+  return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+  "os"
+  "fmt"
+  "sort"
+  "gopkg.in/yaml.v2"
+  "github.com/pborman/getopt"
+  idp "github.com/opensentry/idp/client"
+  aap "github.com/opensentry/aap/client"
+
+  "github.com/opensentry/meui/config"
+)
+
+// Reads the access configuration of the resource servers, roles and clients and of the humans given by --identity
+type exporter struct {
+  a *admin
+  names map[string]string // Names of the resource servers and roles by id, the file refers to them by name
+}
+
+// Reference of an identity in the file
+func (e *exporter) ref(id string) string {
+  if name, exists := e.names[id]; exists {
+    return name
+  }
+  return id
+}
+
+func (e *exporter) export(humans []string) (*accessConfig, bool) {
+  a := e.a
+  cfg := config.Get()
+  file := &accessConfig{}
+
+  url := cfg.Idp.ResourceServers
+  status, responses, err := idp.ReadResourceServers(a.idpClient(), url, nil)
+  if !a.check(url, status, err) {
+    return nil, false
+  }
+  resourceServers := idp.ReadResourceServersResponse{}
+  if !a.read(0, "", responses, &resourceServers, true) {
+    return nil, false
+  }
+
+  url = cfg.Idp.Roles
+  status, responses, err = idp.ReadRoles(a.idpClient(), url, nil)
+  if !a.check(url, status, err) {
+    return nil, false
+  }
+  roles := idp.ReadRolesResponse{}
+  if !a.read(0, "", responses, &roles, true) {
+    return nil, false
+  }
+
+  url = cfg.Idp.Clients
+  status, responses, err = idp.ReadClients(a.idpClient(), url, nil)
+  if !a.check(url, status, err) {
+    return nil, false
+  }
+  clients := idp.ReadClientsResponse{}
+  if !a.read(0, "", responses, &clients, true) {
+    return nil, false
+  }
+
+  url = cfg.Aap.Scopes
+  status, responses, err = aap.ReadScopes(a.aapClient(), url, nil)
+  if !a.check(url, status, err) {
+    return nil, false
+  }
+  scopes := aap.ReadScopesResponse{}
+  if !a.read(0, "", responses, &scopes, true) {
+    return nil, false
+  }
+
+  // apply matches resource servers and roles by name, one sharing its name with another is referred to by id
+  count := map[string]int{}
+  for _, rs := range resourceServers {
+    count[rs.Name]++
+  }
+  for _, role := range roles {
+    count[role.Name]++
+  }
+  var publishers, subscribers, identities []string
+  for _, rs := range resourceServers {
+    if count[rs.Name] > 1 {
+      fmt.Fprintf(os.Stderr, "Warning: the name %s is used by several resource servers and roles, resource server %s is left out\n", rs.Name, rs.Id)
+      continue
+    }
+    e.names[rs.Id] = rs.Name
+    file.ResourceServers = append(file.ResourceServers, accessResourceServer{Name: rs.Name, Description: rs.Description, Audience: rs.Audience})
+    publishers = append(publishers, rs.Id)
+    subscribers = append(subscribers, rs.Id)
+    identities = append(identities, rs.Id)
+  }
+  for _, role := range roles {
+    if count[role.Name] > 1 {
+      fmt.Fprintf(os.Stderr, "Warning: the name %s is used by several resource servers and roles, role %s is left out\n", role.Name, role.Id)
+      continue
+    }
+    e.names[role.Id] = role.Name
+    file.Roles = append(file.Roles, accessRole{Name: role.Name, Description: role.Description})
+    publishers = append(publishers, role.Id)
+    identities = append(identities, role.Id)
+  }
+  for _, client := range clients {
+    subscribers = append(subscribers, client.Id)
+    identities = append(identities, client.Id)
+  }
+  identities = append(identities, humans...)
+
+  for _, scope := range scopes {
+    file.Scopes = append(file.Scopes, scope.Scope)
+  }
+
+  if len(publishers) > 0 {
+    var requests []aap.ReadPublishesRequest
+    for _, id := range publishers {
+      requests = append(requests, aap.ReadPublishesRequest{Publisher: id})
+    }
+    url = cfg.Aap.Publishes
+    status, responses, err = aap.ReadPublishes(a.aapClient(), url, requests)
+    if !a.check(url, status, err) {
+      return nil, false
+    }
+    for i, request := range requests {
+      publishes := aap.ReadPublishesResponse{}
+      if !a.read(i, e.ref(request.Publisher), responses, &publishes, true) {
+        return nil, false
+      }
+      for _, p := range publishes {
+        file.Publishes = append(file.Publishes, accessPublish{Publisher: e.ref(p.Publisher), Scope: p.Scope, Title: p.Title, Description: p.Description, MayGrantScopes: p.MayGrantScopes})
+      }
+    }
+  }
+
+  if len(subscribers) > 0 {
+    var requests []aap.ReadSubscriptionsRequest
+    for _, id := range subscribers {
+      requests = append(requests, aap.ReadSubscriptionsRequest{Subscriber: id})
+    }
+    url = cfg.Aap.Subscriptions
+    status, responses, err = aap.ReadSubscriptions(a.aapClient(), url, requests)
+    if !a.check(url, status, err) {
+      return nil, false
+    }
+    for i, request := range requests {
+      subscriptions := aap.ReadSubscriptionsResponse{}
+      if !a.read(i, e.ref(request.Subscriber), responses, &subscriptions, true) {
+        return nil, false
+      }
+      for _, s := range subscriptions {
+        file.Subscriptions = append(file.Subscriptions, accessSubscription{Subscriber: e.ref(s.Subscriber), Publisher: e.ref(s.Publisher), Scope: s.Scope})
+      }
+    }
+  }
+
+  if len(identities) > 0 {
+    var shadowRequests []aap.ReadShadowsRequest
+    var grantRequests []aap.ReadGrantsRequest
+    for _, id := range identities {
+      shadowRequests = append(shadowRequests, aap.ReadShadowsRequest{Identity: id})
+      grantRequests = append(grantRequests, aap.ReadGrantsRequest{Identity: id})
+    }
+
+    url = cfg.Aap.Shadows
+    status, responses, err = aap.ReadShadows(a.aapClient(), url, shadowRequests)
+    if !a.check(url, status, err) {
+      return nil, false
+    }
+    for i, request := range shadowRequests {
+      shadows := aap.ReadShadowsResponse{}
+      if !a.read(i, e.ref(request.Identity), responses, &shadows, true) {
+        return nil, false
+      }
+      for _, s := range shadows {
+        file.Shadows = append(file.Shadows, accessShadow{Identity: e.ref(s.Identity), Shadow: e.ref(s.Shadow), NotBefore: s.NotBefore, Expire: s.Expire})
+      }
+    }
+
+    url = cfg.Aap.Grants
+    status, responses, err = aap.ReadGrants(a.aapClient(), url, grantRequests)
+    if !a.check(url, status, err) {
+      return nil, false
+    }
+    for i, request := range grantRequests {
+      grants := aap.ReadGrantsResponse{}
+      if !a.read(i, e.ref(request.Identity), responses, &grants, true) {
+        return nil, false
+      }
+      for _, g := range grants {
+        grant := accessGrant{Identity: e.ref(g.Identity), Scope: g.Scope, Publisher: e.ref(g.Publisher), NotBefore: g.NotBefore, Expire: g.Expire}
+        if g.OnBehalfOf != g.Publisher {
+          grant.OnBehalfOf = e.ref(g.OnBehalfOf)
+        }
+        file.Grants = append(file.Grants, grant)
+      }
+    }
+  }
+
+  file.sort()
+  return file, true
+}
+
+// Sorts the items so exports of the same state are the same and diff well
+func (file *accessConfig) sort() {
+  sort.Slice(file.ResourceServers, func(i, j int) bool { return file.ResourceServers[i].Name < file.ResourceServers[j].Name })
+  sort.Slice(file.Roles, func(i, j int) bool { return file.Roles[i].Name < file.Roles[j].Name })
+  sort.Strings(file.Scopes)
+  sort.Slice(file.Publishes, func(i, j int) bool {
+    a, b := file.Publishes[i], file.Publishes[j]
+    return a.Publisher + " " + a.Scope < b.Publisher + " " + b.Scope
+  })
+  sort.Slice(file.Subscriptions, func(i, j int) bool {
+    a, b := file.Subscriptions[i], file.Subscriptions[j]
+    return a.Subscriber + " " + a.Publisher + " " + a.Scope < b.Subscriber + " " + b.Publisher + " " + b.Scope
+  })
+  sort.Slice(file.Shadows, func(i, j int) bool {
+    a, b := file.Shadows[i], file.Shadows[j]
+    return a.Identity + " " + a.Shadow < b.Identity + " " + b.Shadow
+  })
+  sort.Slice(file.Grants, func(i, j int) bool {
+    a, b := file.Grants[i], file.Grants[j]
+    return grantKey(a.Identity, a.Scope, a.Publisher, a.onBehalfOf(), a.NotBefore, a.Expire) < grantKey(b.Identity, b.Scope, b.Publisher, b.onBehalfOf(), b.NotBefore, b.Expire)
+  })
+}
+
+func accessExport(set *getopt.Set) func(a *admin, args []string) int {
+  humans := set.ListLong("identity", 0, "ID")
+
+  return func(a *admin, args []string) int {
+    if !noArguments(args) {
+      return exitUsage
+    }
+
+    e := &exporter{a: a, names: map[string]string{}}
+    file, ok := e.export(*humans)
+    if !ok {
+      return a.code
+    }
+
+    if a.output == "json" {
+      fmt.Fprintln(a.stdout, jsonIndent(file))
+      return a.code
+    }
+
+    // The file keeps the order of the fields, yamlValue would sort them
+    out, err := yaml.Marshal(file)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err.Error())
+      return exitFailed
+    }
+    fmt.Fprint(a.stdout, string(out))
+    return a.code
+  }
+}
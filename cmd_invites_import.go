@@ -0,0 +1,88 @@
+package main
+
+import (
+  "os"
+  "io"
+  "fmt"
+  "strings"
+  "github.com/pborman/getopt"
+
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/invitecsv"
+)
+
+func importRowsTable(rows []invitecsv.Row) table {
+  t := table{header: []string{"Line", "Email", "Username", "Expires", "Status", "Id", "Errors"}}
+  for _, row := range rows {
+    t.rows = append(t.rows, []string{fmt.Sprint(row.Line), row.Email, row.Username, row.Expires, row.Status, row.Id, strings.Join(row.Errors, "; ")})
+  }
+  return t
+}
+
+func invitesImport(set *getopt.Set) func(a *admin, args []string) int {
+  send := set.BoolLong("send", 0)
+  dryRun := set.BoolLong("dry-run", 0)
+  skipInvalid := set.BoolLong("skip-invalid", 0)
+  results := set.StringLong("results", 0, "", "FILE")
+
+  return func(a *admin, args []string) int {
+    if len(args) != 1 {
+      fmt.Fprint(os.Stderr, adminUsage)
+      return exitUsage
+    }
+
+    var in io.Reader = os.Stdin
+    if args[0] != "-" {
+      f, err := os.Open(args[0])
+      if err != nil {
+        fmt.Fprintln(os.Stderr, err.Error())
+        return exitUsage
+      }
+      defer f.Close()
+      in = f
+    }
+
+    cfg := config.Get()
+    rows, err := invitecsv.Parse(in, "en", cfg.Invites.ImportMaxRows)
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "Unable to parse %s: %s\n", args[0], err.Error())
+      return exitUsage
+    }
+
+    // Nothing is created from a file with invalid rows unless asked to, they are likely to be fixed and imported again
+    invalid := invitecsv.Count(rows)[invitecsv.Invalid]
+    if invalid > 0 && !*skipInvalid {
+      fmt.Fprintf(os.Stderr, "%d of %d rows are invalid, fix them or import the others with --skip-invalid\n", invalid, len(rows))
+      a.fail(exitUsage)
+      return a.print(rows, importRowsTable(rows))
+    }
+    if *dryRun {
+      return a.print(rows, importRowsTable(rows))
+    }
+
+    invitecsv.Create(a.idpClient(), cfg.Idp.Invites, rows, cfg.Invites.ImportBatchSize, a.emit)
+    if *send {
+      invitecsv.Send(a.idpClient(), cfg.Idp.InvitesSend, rows, cfg.Invites.ImportBatchSize, -1, "", a.emit)
+    }
+
+    counts := invitecsv.Count(rows)
+    if counts[invitecsv.Failed] > 0 || counts[invitecsv.NotSent] > 0 {
+      a.fail(exitFailed)
+    }
+
+    if *results != "" {
+      f, err := os.OpenFile(*results, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+      if err == nil {
+        err = invitecsv.WriteResults(f, rows)
+        if cerr := f.Close(); err == nil {
+          err = cerr
+        }
+      }
+      if err != nil {
+        fmt.Fprintf(os.Stderr, "Unable to write %s: %s\n", *results, err.Error())
+        a.fail(exitFailed)
+      }
+    }
+    return a.print(rows, importRowsTable(rows))
+  }
+}
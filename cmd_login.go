@@ -0,0 +1,486 @@
+package main
+
+import (
+  "os"
+  "fmt"
+  "net"
+  "time"
+  "errors"
+  "strings"
+  "net/url"
+  "net/http"
+  "io/ioutil"
+  "path/filepath"
+  "crypto/rand"
+  "crypto/sha256"
+  "encoding/json"
+  "encoding/base64"
+  "golang.org/x/net/context"
+  "golang.org/x/oauth2"
+  "github.com/pborman/getopt"
+  oidc "github.com/coreos/go-oidc/v3/oidc"
+
+  "github.com/opensentry/meui/config"
+)
+
+const loginUsage = `Usage: meui login [--device | --loopback] [--port PORT]
+       meui logout
+
+Log in as a human for the admin commands, eg. meui clients list. The tokens are cached in cli.credentials and refreshed
+when they expire, logout revokes them at hydra and removes them from the cache.
+
+Options:
+  --device     Use the device authorization grant, open the url printed on any device and enter the code. The default
+               when hydra supports it.
+  --loopback   Use a redirect to http://127.0.0.1:PORT/callback with PKCE, open the url printed in a browser on this machine
+  --port PORT  Port of the loopback redirect, cli.loopback.port by default
+
+cli.client.id names the public client of hydra meui logs in with.
+`
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// How long a login waits for the human to finish it in the browser
+const loginTimeout = 5 * time.Minute
+
+// Tokens of a login, cached by the url of hydra they were issued by
+type credentials struct {
+  ClientId string `json:"client_id"`
+  Subject string `json:"subject"`
+  Token *oauth2.Token `json:"token"`
+}
+
+// Endpoints of the discovery document the oidc provider does not expose
+type providerClaims struct {
+  DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+  RevocationEndpoint string `json:"revocation_endpoint"`
+}
+
+// meui login. Returns the exit code, see adminUsage.
+func loginCommand(args []string) int {
+  set := getopt.New()
+  device := set.BoolLong("device", 0)
+  loopback := set.BoolLong("loopback", 0)
+  port := set.IntLong("port", 0, -1, "PORT")
+
+  arguments, err := parseOptions(set, "meui login", args)
+  if err != nil || len(arguments) > 0 || (*device && *loopback) {
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err.Error())
+    }
+    fmt.Fprint(os.Stderr, loginUsage)
+    return exitUsage
+  }
+
+  initConfigurations()
+  cfg := config.Get()
+
+  ctx := context.Background()
+  provider, err := oidc.NewProvider(ctx, cfg.Hydra.Url + "/")
+  if err != nil {
+    fmt.Fprintln(os.Stderr, "Unable to discover hydra: " + err.Error())
+    return exitFailed
+  }
+
+  var claims providerClaims
+  provider.Claims(&claims)
+
+  if *port < 0 {
+    *port = cfg.Cli.LoopbackPort
+  }
+  oauth2Config, err := cliOAuth2Config(provider, *port)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err.Error())
+    return exitFailed
+  }
+
+  if *device && claims.DeviceAuthorizationEndpoint == "" {
+    fmt.Fprintln(os.Stderr, "Hydra does not support the device authorization grant, use --loopback")
+    return exitFailed
+  }
+
+  var token *oauth2.Token
+  if claims.DeviceAuthorizationEndpoint != "" && !*loopback {
+    token, err = deviceLogin(ctx, oauth2Config, claims.DeviceAuthorizationEndpoint)
+  } else {
+    token, err = loopbackLogin(ctx, oauth2Config, *port)
+  }
+  if err != nil {
+    fmt.Fprintln(os.Stderr, "Login failed: " + err.Error())
+    return exitDenied
+  }
+
+  userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+  if err != nil {
+    fmt.Fprintln(os.Stderr, "The access token was rejected: " + err.Error())
+    return exitDenied
+  }
+
+  if token.RefreshToken == "" {
+    fmt.Fprintln(os.Stderr, "Warning: hydra issued no refresh token, log in again when the access token expires. Is offline_access among cli.scopes?")
+  }
+
+  err = saveCredentials(cfg.Hydra.Url, &credentials{ClientId: oauth2Config.ClientID, Subject: userInfo.Subject, Token: token})
+  if err != nil {
+    fmt.Fprintln(os.Stderr, "Unable to cache the tokens: " + err.Error())
+    return exitFailed
+  }
+
+  fmt.Println("Logged in as " + userInfo.Subject)
+  return exitOk
+}
+
+// meui logout. Returns the exit code, see adminUsage.
+func logoutCommand(args []string) int {
+  if len(args) > 0 {
+    fmt.Fprint(os.Stderr, loginUsage)
+    return exitUsage
+  }
+
+  initConfigurations()
+  cfg := config.Get()
+
+  cached, err := loadCredentials(cfg.Hydra.Url)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err.Error())
+    return exitFailed
+  }
+  if cached == nil {
+    fmt.Println("Not logged in")
+    return exitOk
+  }
+
+  code := exitOk
+
+  provider, err := oidc.NewProvider(context.Background(), cfg.Hydra.Url + "/")
+  if err != nil {
+    fmt.Fprintln(os.Stderr, "Unable to discover hydra, the tokens are not revoked: " + err.Error())
+    code = exitFailed
+  } else {
+    var claims providerClaims
+    provider.Claims(&claims)
+
+    // Revoking the refresh token revokes the access tokens issued with it, the access token is revoked for logins without one
+    for _, t := range [][2]string{ {cached.Token.RefreshToken, "refresh_token"}, {cached.Token.AccessToken, "access_token"} } {
+      if t[0] == "" {
+        continue
+      }
+      err = revokeToken(claims.RevocationEndpoint, cached.ClientId, t[0], t[1])
+      if err != nil {
+        fmt.Fprintf(os.Stderr, "Unable to revoke the %s: %s\n", strings.Replace(t[1], "_", " ", 1), err.Error())
+        code = exitFailed
+      }
+    }
+  }
+
+  // Removed even if revoking failed, the tokens are of no use to meui once the human logged out
+  err = saveCredentials(cfg.Hydra.Url, nil)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, "Unable to remove the cached tokens: " + err.Error())
+    return exitFailed
+  }
+
+  fmt.Println("Logged out " + cached.Subject)
+  return code
+}
+
+// The oauth2 configuration of the public client logins use
+func cliOAuth2Config(provider *oidc.Provider, port int) (*oauth2.Config, error) {
+  cfg := config.Get()
+  if cfg.Cli.ClientId == "" {
+    return nil, errors.New("cli.client.id is not set, register a public client in hydra for meui login")
+  }
+
+  endpoint := provider.Endpoint()
+  endpoint.AuthStyle = oauth2.AuthStyleInParams // Public client, no secret
+
+  scopes := cfg.Cli.Scopes
+  if len(scopes) == 0 {
+    scopes = append([]string{}, cfg.OAuth2.Scopes...)
+    if !containsString(scopes, "offline_access") {
+      scopes = append(scopes, "offline_access")
+    }
+  }
+
+  return &oauth2.Config{
+    ClientID: cfg.Cli.ClientId,
+    Endpoint: endpoint,
+    Scopes: scopes,
+    RedirectURL: fmt.Sprintf("http://127.0.0.1:%d/callback", port),
+  }, nil
+}
+
+// Device authorization grant, RFC 8628
+func deviceLogin(ctx context.Context, oauth2Config *oauth2.Config, endpoint string) (*oauth2.Token, error) {
+  var authorization struct {
+    DeviceCode string `json:"device_code"`
+    UserCode string `json:"user_code"`
+    VerificationUri string `json:"verification_uri"`
+    VerificationUriComplete string `json:"verification_uri_complete"`
+    ExpiresIn int `json:"expires_in"`
+    Interval int `json:"interval"`
+  }
+  err := postForm(endpoint, url.Values{"client_id": {oauth2Config.ClientID}, "scope": {strings.Join(oauth2Config.Scopes, " ")}}, &authorization)
+  if err != nil {
+    return nil, err
+  }
+
+  fmt.Fprintf(os.Stderr, "Open %s and enter the code %s\n", authorization.VerificationUri, authorization.UserCode)
+  if authorization.VerificationUriComplete != "" {
+    fmt.Fprintf(os.Stderr, "or open %s\n", authorization.VerificationUriComplete)
+  }
+
+  interval := time.Duration(authorization.Interval) * time.Second
+  if interval <= 0 {
+    interval = 5 * time.Second
+  }
+  expires := time.Now().Add(loginTimeout)
+  if authorization.ExpiresIn > 0 {
+    expires = time.Now().Add(time.Duration(authorization.ExpiresIn) * time.Second)
+  }
+
+  for time.Now().Before(expires) {
+    time.Sleep(interval)
+
+    var answer struct {
+      AccessToken string `json:"access_token"`
+      TokenType string `json:"token_type"`
+      RefreshToken string `json:"refresh_token"`
+      ExpiresIn int64 `json:"expires_in"`
+      Error string `json:"error"`
+      ErrorDescription string `json:"error_description"`
+    }
+    err = postForm(oauth2Config.Endpoint.TokenURL, url.Values{
+      "grant_type": {deviceCodeGrantType},
+      "device_code": {authorization.DeviceCode},
+      "client_id": {oauth2Config.ClientID},
+    }, &answer)
+    if err != nil && answer.Error == "" {
+      return nil, err
+    }
+
+    switch answer.Error {
+    case "":
+      token := &oauth2.Token{AccessToken: answer.AccessToken, TokenType: answer.TokenType, RefreshToken: answer.RefreshToken}
+      if answer.ExpiresIn > 0 {
+        token.Expiry = time.Now().Add(time.Duration(answer.ExpiresIn) * time.Second)
+      }
+      return token, nil
+    case "authorization_pending":
+      continue
+    case "slow_down":
+      interval += 5 * time.Second
+      continue
+    case "access_denied":
+      return nil, errors.New("the login was denied")
+    case "expired_token":
+      return nil, errors.New("the code expired, run meui login again")
+    }
+    return nil, fmt.Errorf("%s %s", answer.Error, answer.ErrorDescription)
+  }
+  return nil, errors.New("the code expired, run meui login again")
+}
+
+// Authorization code grant with PKCE and a loopback redirect, RFC 8252
+func loopbackLogin(ctx context.Context, oauth2Config *oauth2.Config, port int) (*oauth2.Token, error) {
+  listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+  if err != nil {
+    return nil, err
+  }
+  defer listener.Close()
+  oauth2Config.RedirectURL = fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port)
+
+  state, err := randomUrlString(16)
+  if err != nil {
+    return nil, err
+  }
+  verifier, err := randomUrlString(32)
+  if err != nil {
+    return nil, err
+  }
+  challenge := sha256.Sum256([]byte(verifier))
+
+  authUrl := oauth2Config.AuthCodeURL(state,
+    oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+    oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+  )
+  fmt.Fprintf(os.Stderr, "Open this url in a browser on this machine to log in:\n%s\n", authUrl)
+
+  type result struct {
+    code string
+    err error
+  }
+  results := make(chan result, 1)
+
+  server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != "/callback" {
+      http.NotFound(w, r)
+      return
+    }
+
+    q := r.URL.Query()
+    var res result
+    switch {
+    case q.Get("state") != state:
+      res.err = errors.New("the state of the redirect does not match, try again")
+    case q.Get("error") != "":
+      res.err = fmt.Errorf("%s %s", q.Get("error"), q.Get("error_description"))
+    default:
+      res.code = q.Get("code")
+    }
+
+    if res.err != nil {
+      http.Error(w, "Login failed, see the terminal.", http.StatusBadRequest)
+    } else {
+      fmt.Fprintln(w, "Logged in, you may close this window.")
+    }
+
+    select {
+    case results <- res:
+    default:
+    }
+  })}
+  go server.Serve(listener)
+  defer server.Close()
+
+  select {
+  case res := <-results:
+    if res.err != nil {
+      return nil, res.err
+    }
+    return oauth2Config.Exchange(ctx, res.code, oauth2.SetAuthURLParam("code_verifier", verifier))
+  case <-time.After(loginTimeout):
+    return nil, errors.New("no redirect within " + loginTimeout.String() + ", run meui login again")
+  }
+}
+
+// Revokes a token, RFC 7009
+func revokeToken(endpoint string, clientId string, token string, hint string) error {
+  if endpoint == "" {
+    return errors.New("hydra announces no revocation endpoint")
+  }
+  return postForm(endpoint, url.Values{"token": {token}, "token_type_hint": {hint}, "client_id": {clientId}}, nil)
+}
+
+// Posts a form to hydra and reads the json answer into v, also the error answer. Errors on status other than 200.
+func postForm(endpoint string, form url.Values, v interface{}) error {
+  client := &http.Client{Timeout: 30 * time.Second}
+  res, err := client.PostForm(endpoint, form)
+  if err != nil {
+    return err
+  }
+  defer res.Body.Close()
+
+  body, err := ioutil.ReadAll(res.Body)
+  if err != nil {
+    return err
+  }
+
+  if v != nil && len(body) > 0 {
+    json.Unmarshal(body, v)
+  }
+
+  if res.StatusCode != http.StatusOK {
+    return fmt.Errorf("%s answered %s", endpoint, res.Status)
+  }
+  return nil
+}
+
+func randomUrlString(numberOfBytes int) (string, error) {
+  b := make([]byte, numberOfBytes)
+  _, err := rand.Read(b)
+  if err != nil {
+    return "", err
+  }
+  return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func containsString(values []string, value string) bool {
+  for _, v := range values {
+    if v == value {
+      return true
+    }
+  }
+  return false
+}
+
+// cli.credentials, or credentials.json in the meui directory of the user config directory, eg. ~/.config/meui
+func credentialsPath() (string, error) {
+  if config.Get().Cli.Credentials != "" {
+    return config.Get().Cli.Credentials, nil
+  }
+
+  dir, err := os.UserConfigDir()
+  if err != nil {
+    return "", err
+  }
+  return filepath.Join(dir, "meui", "credentials.json"), nil
+}
+
+func readCredentialsFile() (string, map[string]*credentials, error) {
+  path, err := credentialsPath()
+  if err != nil {
+    return "", nil, err
+  }
+
+  all := make(map[string]*credentials)
+  data, err := ioutil.ReadFile(path)
+  if os.IsNotExist(err) {
+    return path, all, nil
+  }
+  if err != nil {
+    return "", nil, err
+  }
+
+  err = json.Unmarshal(data, &all)
+  if err != nil {
+    return "", nil, fmt.Errorf("%s is not valid json: %s", path, err.Error())
+  }
+  return path, all, nil
+}
+
+// The credentials of the login to hydra, nil if not logged in
+func loadCredentials(hydra string) (*credentials, error) {
+  _, all, err := readCredentialsFile()
+  if err != nil {
+    return nil, err
+  }
+
+  cached := all[hydra]
+  if cached == nil || cached.Token == nil {
+    return nil, nil
+  }
+  return cached, nil
+}
+
+// Saves the credentials of the login to hydra, nil removes them. Only the user may read the file.
+func saveCredentials(hydra string, cached *credentials) error {
+  path, all, err := readCredentialsFile()
+  if err != nil {
+    return err
+  }
+
+  if cached == nil {
+    delete(all, hydra)
+  } else {
+    all[hydra] = cached
+  }
+
+  data, err := json.MarshalIndent(all, "", "  ")
+  if err != nil {
+    return err
+  }
+
+  err = os.MkdirAll(filepath.Dir(path), 0700)
+  if err != nil {
+    return err
+  }
+
+  // Written next to the file and renamed, so an interrupted write does not lose the other logins
+  tmp := path + ".tmp"
+  err = ioutil.WriteFile(tmp, append(data, '\n'), 0600)
+  if err != nil {
+    return err
+  }
+  return os.Rename(tmp, path)
+}
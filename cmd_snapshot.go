@@ -0,0 +1,810 @@
+package main
+
+import (
+  "io"
+  "os"
+  "fmt"
+  "time"
+  "strings"
+  "io/ioutil"
+  "compress/gzip"
+  "encoding/json"
+  "github.com/pborman/getopt"
+  bulky "github.com/charmixer/bulky/client"
+  idp "github.com/opensentry/idp/client"
+  aap "github.com/opensentry/aap/client"
+
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// Version of the snapshot format, import refuses snapshots of other versions
+const snapshotVersion = 1
+
+// Everything meui can read of an environment, written by meui snapshot export. Unlike the access configuration of
+// meui export it keeps the ids, import maps them to the ids of the identities it finds or creates.
+type snapshot struct {
+  Version int `json:"version"`
+  CreatedAt int64 `json:"created_at"`
+  Hydra string `json:"hydra"` // Url of hydra of the environment
+  Clients []idp.Client `json:"clients"`
+  ResourceServers []idp.ResourceServer `json:"resource_servers"`
+  Roles []idp.Role `json:"roles"`
+  Humans []snapshotHuman `json:"humans"`
+  Scopes []aap.Scope `json:"scopes"`
+  Publishes []aap.Publish `json:"publishes"`
+  Subscriptions []aap.Subscription `json:"subscriptions"`
+  Shadows []aap.Shadow `json:"shadows"`
+  Grants []aap.Grant `json:"grants"`
+  Invites []idp.Invite `json:"invites"` // Pending ones only
+}
+
+// Humans are not recreated, import finds them by email to map the ids of their shadows and grants
+type snapshotHuman struct {
+  Id string `json:"id"`
+  Email string `json:"email"`
+  Username string `json:"username"`
+}
+
+// Reads a whole collection of idp or aap into v
+func (a *admin) readAll(url string, call func(url string) (int, bulky.Responses, error), v interface{}) bool {
+  status, responses, err := call(url)
+  if !a.check(url, status, err) {
+    return false
+  }
+  return a.read(0, "", responses, v, true)
+}
+
+func (a *admin) takeSnapshot() (*snapshot, bool) {
+  cfg := config.Get()
+  s := &snapshot{Version: snapshotVersion, CreatedAt: time.Now().Unix(), Hydra: cfg.Hydra.Url}
+
+  clients := idp.ReadClientsResponse{}
+  resourceServers := idp.ReadResourceServersResponse{}
+  roles := idp.ReadRolesResponse{}
+  humans := idp.ReadHumansResponse{}
+  scopes := aap.ReadScopesResponse{}
+  invites := idp.ReadInvitesResponse{}
+  ok := a.readAll(cfg.Idp.Clients, func(url string) (int, bulky.Responses, error) { return idp.ReadClients(a.idpClient(), url, nil) }, &clients) &&
+    a.readAll(cfg.Idp.ResourceServers, func(url string) (int, bulky.Responses, error) { return idp.ReadResourceServers(a.idpClient(), url, nil) }, &resourceServers) &&
+    a.readAll(cfg.Idp.Roles, func(url string) (int, bulky.Responses, error) { return idp.ReadRoles(a.idpClient(), url, nil) }, &roles) &&
+    a.readAll(cfg.Idp.Humans, func(url string) (int, bulky.Responses, error) { return idp.ReadHumans(a.idpClient(), url, nil) }, &humans) &&
+    a.readAll(cfg.Aap.Scopes, func(url string) (int, bulky.Responses, error) { return aap.ReadScopes(a.aapClient(), url, nil) }, &scopes) &&
+    a.readAll(cfg.Idp.Invites, func(url string) (int, bulky.Responses, error) { return idp.ReadInvites(a.idpClient(), url, nil) }, &invites)
+  if !ok {
+    return nil, false
+  }
+
+  s.Clients = clients
+  s.ResourceServers = resourceServers
+  s.Roles = roles
+  s.Scopes = scopes
+
+  var publishers, subscribers, identities []string
+  for _, rs := range resourceServers {
+    publishers = append(publishers, rs.Id)
+    subscribers = append(subscribers, rs.Id)
+    identities = append(identities, rs.Id)
+  }
+  for _, role := range roles {
+    publishers = append(publishers, role.Id)
+    identities = append(identities, role.Id)
+  }
+  for _, client := range clients {
+    subscribers = append(subscribers, client.Id)
+    identities = append(identities, client.Id)
+  }
+  for _, human := range humans {
+    s.Humans = append(s.Humans, snapshotHuman{Id: human.Id, Email: human.Email, Username: human.Username})
+    identities = append(identities, human.Id)
+  }
+
+  now := time.Now().Unix()
+  for _, invite := range invites {
+    if invite.ExpiresAt == 0 || invite.ExpiresAt > now {
+      s.Invites = append(s.Invites, invite)
+    }
+  }
+
+  s.Publishes, ok = a.readPublishes(publishers)
+  if !ok {
+    return nil, false
+  }
+  s.Subscriptions, ok = a.readSubscriptions(subscribers)
+  if !ok {
+    return nil, false
+  }
+  s.Shadows, ok = a.readShadows(identities)
+  if !ok {
+    return nil, false
+  }
+  s.Grants, ok = a.readGrants(identities)
+  if !ok {
+    return nil, false
+  }
+  return s, true
+}
+
+func (a *admin) readPublishes(publishers []string) (all []aap.Publish, ok bool) {
+  if len(publishers) == 0 {
+    return nil, true
+  }
+  var requests []aap.ReadPublishesRequest
+  for _, id := range publishers {
+    requests = append(requests, aap.ReadPublishesRequest{Publisher: id})
+  }
+  url := config.Get().Aap.Publishes
+  status, responses, err := aap.ReadPublishes(a.aapClient(), url, requests)
+  if !a.check(url, status, err) {
+    return nil, false
+  }
+  for i, request := range requests {
+    publishes := aap.ReadPublishesResponse{}
+    if !a.read(i, request.Publisher, responses, &publishes, true) {
+      return nil, false
+    }
+    all = append(all, publishes...)
+  }
+  return all, true
+}
+
+func (a *admin) readSubscriptions(subscribers []string) (all []aap.Subscription, ok bool) {
+  if len(subscribers) == 0 {
+    return nil, true
+  }
+  var requests []aap.ReadSubscriptionsRequest
+  for _, id := range subscribers {
+    requests = append(requests, aap.ReadSubscriptionsRequest{Subscriber: id})
+  }
+  url := config.Get().Aap.Subscriptions
+  status, responses, err := aap.ReadSubscriptions(a.aapClient(), url, requests)
+  if !a.check(url, status, err) {
+    return nil, false
+  }
+  for i, request := range requests {
+    subscriptions := aap.ReadSubscriptionsResponse{}
+    if !a.read(i, request.Subscriber, responses, &subscriptions, true) {
+      return nil, false
+    }
+    all = append(all, subscriptions...)
+  }
+  return all, true
+}
+
+func (a *admin) readShadows(identities []string) (all []aap.Shadow, ok bool) {
+  if len(identities) == 0 {
+    return nil, true
+  }
+  var requests []aap.ReadShadowsRequest
+  for _, id := range identities {
+    requests = append(requests, aap.ReadShadowsRequest{Identity: id})
+  }
+  url := config.Get().Aap.Shadows
+  status, responses, err := aap.ReadShadows(a.aapClient(), url, requests)
+  if !a.check(url, status, err) {
+    return nil, false
+  }
+  for i, request := range requests {
+    shadows := aap.ReadShadowsResponse{}
+    if !a.read(i, request.Identity, responses, &shadows, true) {
+      return nil, false
+    }
+    all = append(all, shadows...)
+  }
+  return all, true
+}
+
+func (a *admin) readGrants(identities []string) (all []aap.Grant, ok bool) {
+  if len(identities) == 0 {
+    return nil, true
+  }
+  var requests []aap.ReadGrantsRequest
+  for _, id := range identities {
+    requests = append(requests, aap.ReadGrantsRequest{Identity: id})
+  }
+  url := config.Get().Aap.Grants
+  status, responses, err := aap.ReadGrants(a.aapClient(), url, requests)
+  if !a.check(url, status, err) {
+    return nil, false
+  }
+  for i, request := range requests {
+    grants := aap.ReadGrantsResponse{}
+    if !a.read(i, request.Identity, responses, &grants, true) {
+      return nil, false
+    }
+    all = append(all, grants...)
+  }
+  return all, true
+}
+
+// Writes a snapshot to path, gzipped if it ends in .gz. - or no path writes to stdout.
+func (a *admin) writeSnapshot(path string, s *snapshot) error {
+  data, err := json.MarshalIndent(s, "", "  ")
+  if err != nil {
+    return err
+  }
+  data = append(data, '\n')
+
+  var w io.Writer = a.stdout
+  if path != "" && path != "-" {
+    // Snapshots hold the emails of humans and invites
+    f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+    if err != nil {
+      return err
+    }
+    defer f.Close()
+    w = f
+  }
+
+  if strings.HasSuffix(path, ".gz") {
+    gz := gzip.NewWriter(w)
+    _, err = gz.Write(data)
+    if err != nil {
+      return err
+    }
+    return gz.Close()
+  }
+  _, err = w.Write(data)
+  return err
+}
+
+func readSnapshot(path string) (*snapshot, error) {
+  var r io.Reader = os.Stdin
+  if path != "-" {
+    f, err := os.Open(path)
+    if err != nil {
+      return nil, err
+    }
+    defer f.Close()
+    r = f
+  }
+
+  if strings.HasSuffix(path, ".gz") {
+    gz, err := gzip.NewReader(r)
+    if err != nil {
+      return nil, err
+    }
+    defer gz.Close()
+    r = gz
+  }
+
+  data, err := ioutil.ReadAll(r)
+  if err != nil {
+    return nil, err
+  }
+
+  var s snapshot
+  err = json.Unmarshal(data, &s)
+  if err != nil {
+    return nil, fmt.Errorf("Unable to parse %s: %s", path, err.Error())
+  }
+  if s.Version != snapshotVersion {
+    return nil, fmt.Errorf("%s is a snapshot of version %d, this meui imports version %d", path, s.Version, snapshotVersion)
+  }
+  return &s, nil
+}
+
+// Outcome of an item of a snapshot import
+type outcome struct {
+  Kind string `json:"kind"`
+  Item string `json:"item"`
+  Outcome string `json:"outcome"` // created, exists, mapped, skipped or failed, "would create" in a dry run
+  Detail string `json:"detail,omitempty"`
+}
+
+// Outcomes of an import
+const (
+  outcomeCreated = "created"
+  outcomeWouldCreate = "would create"
+  outcomeExists = "exists"
+  outcomeMapped = "mapped"
+  outcomeSkipped = "skipped"
+  outcomeFailed = "failed"
+)
+
+// Recreates a snapshot in the environment of meui, mapping the ids of the snapshot to the ids of the environment
+type importer struct {
+  a *admin
+  s *snapshot
+  dryRun bool
+  ids map[string]string // New ids by id of the snapshot, empty for identities a dry run would create
+  missing map[string]string // Why an identity of the snapshot has no new id, by id of the snapshot
+  names map[string]string // Names of the identities of the snapshot by id, printed in the report
+  report []outcome
+}
+
+// New id of an identity of the snapshot, identities it does not list keep their id
+func (im *importer) id(old string) (string, bool) {
+  if _, missing := im.missing[old]; missing {
+    return "", false
+  }
+  if id, mapped := im.ids[old]; mapped {
+    return id, true
+  }
+  return old, true
+}
+
+// Ids of a change to an item, the first identity missing tells why it cannot be recreated
+func (im *importer) resolve(olds ...string) (ids []string, reason string) {
+  for _, old := range olds {
+    id, ok := im.id(old)
+    if !ok {
+      return nil, im.name(old) + " " + im.missing[old]
+    }
+    ids = append(ids, id)
+  }
+  return ids, ""
+}
+
+func (im *importer) name(id string) string {
+  if name, exists := im.names[id]; exists {
+    return name
+  }
+  return id
+}
+
+func (im *importer) record(kind string, item string, result string, detail string) {
+  im.report = append(im.report, outcome{Kind: kind, Item: item, Outcome: result, Detail: detail})
+  if result == outcomeFailed {
+    im.a.fail(exitFailed)
+  }
+}
+
+// Creates an item unless it is a dry run, create tells if it succeeded and may replace the detail of the report.
+// Errors are printed prefixed by item.
+func (im *importer) create(kind string, item string, detail string, create func(detail *string) bool) bool {
+  if im.dryRun {
+    im.record(kind, item, outcomeWouldCreate, detail)
+    return true
+  }
+  if !create(&detail) {
+    im.record(kind, item, outcomeFailed, detail)
+    return false
+  }
+  im.record(kind, item, outcomeCreated, detail)
+  return true
+}
+
+func (im *importer) run() bool {
+  a := im.a
+  cfg := config.Get()
+  s := im.s
+
+  for _, client := range s.Clients {
+    im.names[client.Id] = client.Name
+  }
+  for _, rs := range s.ResourceServers {
+    im.names[rs.Id] = rs.Name
+  }
+  for _, role := range s.Roles {
+    im.names[role.Id] = role.Name
+  }
+  for _, human := range s.Humans {
+    im.names[human.Id] = human.Email
+  }
+
+  clients := idp.ReadClientsResponse{}
+  resourceServers := idp.ReadResourceServersResponse{}
+  roles := idp.ReadRolesResponse{}
+  humans := idp.ReadHumansResponse{}
+  scopes := aap.ReadScopesResponse{}
+  invites := idp.ReadInvitesResponse{}
+  ok := a.readAll(cfg.Idp.Clients, func(url string) (int, bulky.Responses, error) { return idp.ReadClients(a.idpClient(), url, nil) }, &clients) &&
+    a.readAll(cfg.Idp.ResourceServers, func(url string) (int, bulky.Responses, error) { return idp.ReadResourceServers(a.idpClient(), url, nil) }, &resourceServers) &&
+    a.readAll(cfg.Idp.Roles, func(url string) (int, bulky.Responses, error) { return idp.ReadRoles(a.idpClient(), url, nil) }, &roles) &&
+    a.readAll(cfg.Idp.Humans, func(url string) (int, bulky.Responses, error) { return idp.ReadHumans(a.idpClient(), url, nil) }, &humans) &&
+    a.readAll(cfg.Aap.Scopes, func(url string) (int, bulky.Responses, error) { return aap.ReadScopes(a.aapClient(), url, nil) }, &scopes) &&
+    a.readAll(cfg.Idp.Invites, func(url string) (int, bulky.Responses, error) { return idp.ReadInvites(a.idpClient(), url, nil) }, &invites)
+  if !ok {
+    return false
+  }
+
+  // Identities are found by name, humans by email, unless --map gave their new id. Those not found are created,
+  // except humans who are left to sign up or be invited.
+
+  liveClients := map[string]string{}
+  for _, client := range clients {
+    liveClients[client.Name] = client.Id
+  }
+  for _, client := range s.Clients {
+    client := client
+    if im.mapped("client", client.Id, client.Name, liveClients[client.Name]) {
+      continue
+    }
+    if im.dryRun {
+      im.ids[client.Id] = ""
+    }
+    im.create("client", client.Name, "", func(detail *string) bool {
+      url := cfg.Idp.Clients
+      status, responses, err := idp.CreateClients(a.idpClient(), url, []idp.CreateClientsRequest{
+        {
+          Name: client.Name,
+          Description: client.Description,
+          IsPublic: client.TokenEndpointAuthMethod == "none",
+          GrantTypes: client.GrantTypes,
+          ResponseTypes: client.ResponseTypes,
+          RedirectUris: client.RedirectUris,
+          PostLogoutRedirectUris: client.PostLogoutRedirectUris,
+          TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+        },
+      })
+      if !a.check(url, status, err) {
+        im.missing[client.Id] = "was not created"
+        return false
+      }
+      var created idp.CreateClientsResponse
+      if !a.read(0, client.Name, responses, &created, false) {
+        im.missing[client.Id] = "was not created"
+        return false
+      }
+      im.ids[client.Id] = created.Id
+      a.emit(webhooks.ClientCreated, webhooks.ClientData(idp.Client(created)))
+
+      // The new secret is only known here
+      *detail = "id " + created.Id
+      if created.Secret != "" {
+        *detail = *detail + ", secret " + created.Secret
+      }
+      return true
+    })
+  }
+
+  liveResourceServers := map[string]string{}
+  for _, rs := range resourceServers {
+    liveResourceServers[rs.Name] = rs.Id
+  }
+  for _, rs := range s.ResourceServers {
+    rs := rs
+    if im.mapped("resource server", rs.Id, rs.Name, liveResourceServers[rs.Name]) {
+      continue
+    }
+    if im.dryRun {
+      im.ids[rs.Id] = ""
+    }
+    im.create("resource server", rs.Name, "", func(detail *string) bool {
+      url := cfg.Idp.ResourceServers
+      status, responses, err := idp.CreateResourceServers(a.idpClient(), url, []idp.CreateResourceServersRequest{
+        {Name: rs.Name, Description: rs.Description, Audience: rs.Audience},
+      })
+      var created idp.CreateResourceServersResponse
+      if !a.check(url, status, err) || !a.read(0, rs.Name, responses, &created, false) {
+        im.missing[rs.Id] = "was not created"
+        return false
+      }
+      im.ids[rs.Id] = created.Id
+      *detail = "id " + created.Id
+      return true
+    })
+  }
+
+  liveRoles := map[string]string{}
+  for _, role := range roles {
+    liveRoles[role.Name] = role.Id
+  }
+  for _, role := range s.Roles {
+    role := role
+    if im.mapped("role", role.Id, role.Name, liveRoles[role.Name]) {
+      continue
+    }
+    if im.dryRun {
+      im.ids[role.Id] = ""
+    }
+    im.create("role", role.Name, "", func(detail *string) bool {
+      url := cfg.Idp.Roles
+      status, responses, err := idp.CreateRoles(a.idpClient(), url, []idp.CreateRolesRequest{
+        {Name: role.Name, Description: role.Description},
+      })
+      var created idp.CreateRolesResponse
+      if !a.check(url, status, err) || !a.read(0, role.Name, responses, &created, false) {
+        im.missing[role.Id] = "was not created"
+        return false
+      }
+      im.ids[role.Id] = created.Id
+      *detail = "id " + created.Id
+      a.emit(webhooks.RoleCreated, created)
+      return true
+    })
+  }
+
+  liveHumans := map[string]string{}
+  for _, human := range humans {
+    liveHumans[strings.ToLower(human.Email)] = human.Id
+  }
+  for _, human := range s.Humans {
+    if im.mapped("human", human.Id, human.Email, liveHumans[strings.ToLower(human.Email)]) {
+      continue
+    }
+    im.missing[human.Id] = "has no human with the same email"
+    im.record("human", human.Email, outcomeSkipped, "no human with this email, their shadows and grants are skipped")
+  }
+
+  liveScopes := map[string]bool{}
+  for _, scope := range scopes {
+    liveScopes[scope.Scope] = true
+  }
+  for _, scope := range s.Scopes {
+    scope := scope
+    if liveScopes[scope.Scope] {
+      im.record("scope", scope.Scope, outcomeExists, "")
+      continue
+    }
+    im.create("scope", scope.Scope, "", func(detail *string) bool {
+      url := cfg.Aap.Scopes
+      status, responses, err := aap.CreateScopes(a.aapClient(), url, []aap.CreateScopesRequest{ {Scope: scope.Scope} })
+      var created aap.CreateScopesResponse
+      return a.check(url, status, err) && a.read(0, scope.Scope, responses, &created, false)
+    })
+  }
+
+  // The access of identities found in the environment is read so what they already have is not created again
+
+  var publishers, subscribers, identities []string
+  for _, id := range im.ids {
+    if id != "" {
+      publishers = append(publishers, id)
+      subscribers = append(subscribers, id)
+      identities = append(identities, id)
+    }
+  }
+
+  livePublishes, ok := a.readPublishes(publishers)
+  if !ok {
+    return false
+  }
+  existing := map[string]bool{}
+  for _, p := range livePublishes {
+    existing[p.Publisher + " " + p.Scope] = true
+  }
+  for _, p := range s.Publishes {
+    p := p
+    item := im.name(p.Publisher) + " " + p.Scope
+    ids, reason := im.resolve(p.Publisher)
+    if reason != "" {
+      im.record("publish", item, outcomeSkipped, reason)
+      continue
+    }
+    if existing[ids[0] + " " + p.Scope] {
+      im.record("publish", item, outcomeExists, "")
+      continue
+    }
+    detail := ""
+    if len(p.MayGrantScopes) > 0 {
+      detail = "may_grant_scopes cannot be set through aap: " + strings.Join(p.MayGrantScopes, " ")
+    }
+    im.create("publish", item, detail, func(detail *string) bool {
+      url := cfg.Aap.Publishes
+      status, responses, err := aap.CreatePublishes(a.aapClient(), url, []aap.CreatePublishesRequest{
+        {Publisher: ids[0], Scope: p.Scope, Title: p.Title, Description: p.Description},
+      })
+      var created aap.CreatePublishesResponse
+      return a.check(url, status, err) && a.read(0, item, responses, &created, false)
+    })
+  }
+
+  liveSubscriptions, ok := a.readSubscriptions(subscribers)
+  if !ok {
+    return false
+  }
+  existing = map[string]bool{}
+  for _, sub := range liveSubscriptions {
+    existing[sub.Subscriber + " " + sub.Publisher + " " + sub.Scope] = true
+  }
+  for _, sub := range s.Subscriptions {
+    sub := sub
+    item := im.name(sub.Subscriber) + " " + im.name(sub.Publisher) + " " + sub.Scope
+    ids, reason := im.resolve(sub.Subscriber, sub.Publisher)
+    if reason != "" {
+      im.record("subscription", item, outcomeSkipped, reason)
+      continue
+    }
+    if existing[ids[0] + " " + ids[1] + " " + sub.Scope] {
+      im.record("subscription", item, outcomeExists, "")
+      continue
+    }
+    im.create("subscription", item, "", func(detail *string) bool {
+      url := cfg.Aap.Subscriptions
+      status, responses, err := aap.CreateSubscriptions(a.aapClient(), url, []aap.CreateSubscriptionsRequest{
+        {Subscriber: ids[0], Publisher: ids[1], Scope: sub.Scope},
+      })
+      var created aap.CreateSubscriptionsResponse
+      if !a.check(url, status, err) || !a.read(0, item, responses, &created, false) {
+        return false
+      }
+      a.emit(webhooks.SubscriptionCreated, created)
+      return true
+    })
+  }
+
+  liveShadows, ok := a.readShadows(identities)
+  if !ok {
+    return false
+  }
+  existing = map[string]bool{}
+  for _, shadow := range liveShadows {
+    existing[shadow.Identity + " " + shadow.Shadow] = true
+  }
+  for _, shadow := range s.Shadows {
+    shadow := shadow
+    item := im.name(shadow.Identity) + " " + im.name(shadow.Shadow) + validity(shadow.NotBefore, shadow.Expire)
+    ids, reason := im.resolve(shadow.Identity, shadow.Shadow)
+    if reason != "" {
+      im.record("shadow", item, outcomeSkipped, reason)
+      continue
+    }
+    if existing[ids[0] + " " + ids[1]] {
+      im.record("shadow", item, outcomeExists, "")
+      continue
+    }
+    im.create("shadow", item, "", func(detail *string) bool {
+      url := cfg.Aap.Shadows
+      status, responses, err := aap.CreateShadows(a.aapClient(), url, []aap.CreateShadowsRequest{
+        {Identity: ids[0], Shadow: ids[1], NotBefore: shadow.NotBefore, Expire: shadow.Expire},
+      })
+      var created aap.CreateShadowsResponse
+      if !a.check(url, status, err) || !a.read(0, item, responses, &created, false) {
+        return false
+      }
+      a.emit(webhooks.ShadowCreated, created)
+      return true
+    })
+  }
+
+  liveGrants, ok := a.readGrants(identities)
+  if !ok {
+    return false
+  }
+  existing = map[string]bool{}
+  for _, g := range liveGrants {
+    existing[g.Identity + " " + g.Scope + " " + g.Publisher + " " + g.OnBehalfOf] = true
+  }
+  for _, g := range s.Grants {
+    g := g
+    item := im.name(g.Identity) + " " + g.Scope + " " + im.name(g.Publisher)
+    if g.OnBehalfOf != g.Publisher {
+      item = item + " on behalf of " + im.name(g.OnBehalfOf)
+    }
+    item = item + validity(g.NotBefore, g.Expire)
+    ids, reason := im.resolve(g.Identity, g.Publisher, g.OnBehalfOf)
+    if reason != "" {
+      im.record("grant", item, outcomeSkipped, reason)
+      continue
+    }
+    if existing[ids[0] + " " + g.Scope + " " + ids[1] + " " + ids[2]] {
+      im.record("grant", item, outcomeExists, "")
+      continue
+    }
+    detail := ""
+    if len(g.MayGrantScopes) > 0 {
+      detail = "may_grant_scopes cannot be set through aap: " + strings.Join(g.MayGrantScopes, " ")
+    }
+    im.create("grant", item, detail, func(detail *string) bool {
+      url := cfg.Aap.Grants
+      status, responses, err := aap.CreateGrants(a.aapClient(), url, []aap.CreateGrantsRequest{
+        {Identity: ids[0], Scope: g.Scope, Publisher: ids[1], OnBehalfOf: ids[2], NotBefore: g.NotBefore, Expire: g.Expire},
+      })
+      var created aap.CreateGrantsResponse
+      if !a.check(url, status, err) || !a.read(0, item, responses, &created, false) {
+        return false
+      }
+      a.emit(webhooks.GrantCreated, created)
+      return true
+    })
+  }
+
+  // Invites are recreated for emails without a human or an invite, they are not sent
+  invited := map[string]bool{}
+  for _, invite := range invites {
+    invited[strings.ToLower(invite.Email)] = true
+  }
+  now := time.Now().Unix()
+  for _, invite := range s.Invites {
+    invite := invite
+    email := strings.ToLower(invite.Email)
+    if invited[email] || liveHumans[email] != "" {
+      im.record("invite", invite.Email, outcomeExists, "")
+      continue
+    }
+    if invite.ExpiresAt != 0 && invite.ExpiresAt <= now {
+      im.record("invite", invite.Email, outcomeSkipped, "expired " + unixTime(invite.ExpiresAt))
+      continue
+    }
+    im.create("invite", invite.Email, "not sent", func(detail *string) bool {
+      url := cfg.Idp.Invites
+      status, responses, err := idp.CreateInvites(a.idpClient(), url, []idp.CreateInvitesRequest{
+        {Email: invite.Email, Username: invite.Username, ExpiresAt: invite.ExpiresAt},
+      })
+      var created idp.CreateInvitesResponse
+      if !a.check(url, status, err) || !a.read(0, invite.Email, responses, &created, false) {
+        return false
+      }
+      a.emit(webhooks.InviteCreated, created)
+      return true
+    })
+  }
+
+  return true
+}
+
+// Maps an identity of the snapshot given by --map or found in the environment, found is its id there or empty
+func (im *importer) mapped(kind string, old string, item string, found string) bool {
+  if id, exists := im.ids[old]; exists {
+    im.record(kind, item, outcomeMapped, "by --map to " + id)
+    return true
+  }
+  if found != "" {
+    im.ids[old] = found
+    im.record(kind, item, outcomeExists, "id " + found)
+    return true
+  }
+  return false
+}
+
+func outcomesTable(report []outcome) table {
+  t := table{header: []string{"Kind", "Item", "Outcome", "Detail"}}
+  for _, o := range report {
+    t.rows = append(t.rows, []string{o.Kind, o.Item, o.Outcome, o.Detail})
+  }
+  return t
+}
+
+func snapshotExport(set *getopt.Set) func(a *admin, args []string) int {
+  return func(a *admin, args []string) int {
+    if len(args) > 1 {
+      fmt.Fprint(os.Stderr, adminUsage)
+      return exitUsage
+    }
+    path := ""
+    if len(args) == 1 {
+      path = args[0]
+    }
+
+    s, ok := a.takeSnapshot()
+    if !ok {
+      return a.code
+    }
+
+    err := a.writeSnapshot(path, s)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, "Unable to write the snapshot: " + err.Error())
+      return exitFailed
+    }
+    if path != "" && path != "-" {
+      fmt.Fprintf(os.Stderr, "Wrote %d clients, %d resource servers, %d roles, %d scopes, %d publishes, %d subscriptions, %d shadows, %d grants and %d invites to %s\n",
+        len(s.Clients), len(s.ResourceServers), len(s.Roles), len(s.Scopes), len(s.Publishes), len(s.Subscriptions), len(s.Shadows), len(s.Grants), len(s.Invites), path)
+    }
+    return a.code
+  }
+}
+
+func snapshotImport(set *getopt.Set) func(a *admin, args []string) int {
+  dryRun := set.BoolLong("dry-run", 0)
+  maps := set.ListLong("map", 0, "OLD=NEW")
+
+  return func(a *admin, args []string) int {
+    if len(args) != 1 {
+      fmt.Fprint(os.Stderr, adminUsage)
+      return exitUsage
+    }
+
+    s, err := readSnapshot(args[0])
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err.Error())
+      return exitUsage
+    }
+
+    im := &importer{a: a, s: s, dryRun: *dryRun, ids: map[string]string{}, missing: map[string]string{}, names: map[string]string{}}
+    for _, m := range *maps {
+      parts := strings.SplitN(m, "=", 2)
+      if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+        fmt.Fprintf(os.Stderr, "--map %s: expected OLD=NEW\n", m)
+        return exitUsage
+      }
+      im.ids[parts[0]] = parts[1]
+    }
+
+    if !im.run() {
+      return a.code
+    }
+    return a.print(im.report, outcomesTable(im.report))
+  }
+}
@@ -3,57 +3,151 @@ package config
 import (
   "github.com/spf13/viper"
   "strings"
+  "sync/atomic"
 )
 
-func setDefaults() {
-  viper.SetDefault("config.app.path", "./app.yml")
-  viper.SetDefault("config.discovery.path", "./discovery.yml")
-}
+var (
+  // Holds *Config, replaced as a whole when secrets are rotated or the files are reloaded
+  current atomic.Value
 
-func GetInt(key string) int {
-  return viper.GetInt(key)
-}
+  // Holds the *viper.Viper the current configuration was loaded from
+  values atomic.Value
+)
 
-func GetIntStrict(key string) int {
-  return viper.GetInt(key)
+// Get returns the configuration loaded by InitConfigurations
+func Get() *Config {
+  cfg, _ := current.Load().(*Config)
+  return cfg
 }
 
-func GetString(key string) string {
-  return viper.GetString(key)
+// Set replaces the current configuration, eg. by tests of packages reading it
+func Set(cfg *Config) {
+  current.Store(cfg)
 }
 
-func GetStringStrict(key string) string {
-  return viper.GetString(key)
+func source() *viper.Viper {
+  v, _ := values.Load().(*viper.Viper)
+  if v == nil {
+    return viper.GetViper()
+  }
+  return v
 }
 
-func GetStringSlice(key string) []string {
-  return viper.GetStringSlice(key)
+func setDefaults(v *viper.Viper) {
+  for _, k := range Keys {
+    if k.Default != nil {
+      v.SetDefault(k.Name, k.Default)
+    }
+  }
 }
 
-func InitConfigurations() (error) {
+// Reads discovery.yml and app.yml into a new viper, so a broken file never replaces the values in use
+func readConfigurations() (*viper.Viper, error) {
   var err error
+  v := viper.New()
 
   // lets environment variable override config file
-  viper.AutomaticEnv()
-  viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+  v.AutomaticEnv()
+  v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
-  setDefaults()
+  setDefaults(v)
 
   // Load discovery configurations
 
-  viper.SetConfigFile(viper.GetString("config.discovery.path"))
-  err = viper.ReadInConfig() // Find and read the config file
+  v.SetConfigFile(v.GetString("config.discovery.path"))
+  err = v.ReadInConfig() // Find and read the config file
   if err != nil { // Handle errors reading the config file
-    return err
+    return nil, err
   }
 
   // Load app specific configurations
 
-  viper.SetConfigFile(viper.GetString("config.app.path"))
-  err = viper.MergeInConfig() // Find and read the config file
+  v.SetConfigFile(v.GetString("config.app.path"))
+  err = v.MergeInConfig() // Find and read the config file
   if err != nil { // Handle errors reading the config file
+    return nil, err
+  }
+
+  return v, nil
+}
+
+// ReadConfigurations reads discovery.yml and app.yml, environment variables override both, eg. SERVE_PUBLIC_PORT overrides serve.public.port.
+// Nothing is validated, see Load.
+func ReadConfigurations() (error) {
+  v, err := readConfigurations()
+  if err != nil {
+    return err
+  }
+  values.Store(v)
+  return nil
+}
+
+// InitConfigurations reads the configuration files and loads the typed configuration, see ReadConfigurations and Load.
+func InitConfigurations() (error) {
+  err := ReadConfigurations()
+  if err != nil {
     return err
   }
 
+  // Every problem is reported at once, so a broken configuration is fixed in one go and never served
+  cfg, err := Load()
+  if err != nil {
+    return err
+  }
+  current.Store(cfg)
+  started = source()
+
   return nil
 }
+
+// Settings returns the effective value of every key set, by defaults, files or environment, with the values of secret keys redacted.
+// Unknown lists the keys set that meui does not read, usually typos.
+func Settings() (settings map[string]interface{}, unknown []string) {
+  v := source()
+  settings = make(map[string]interface{})
+
+  // Keys only set by environment variables are not among the keys viper knows
+  seen := make(map[string]bool)
+  names := v.AllKeys()
+  for _, k := range Keys {
+    if v.Get(k.Name) != nil || (k.Secret && secretReference(v, k.Name) != "") {
+      names = append(names, strings.ToLower(k.Name))
+    }
+  }
+
+  for _, name := range names {
+    if seen[name] {
+      continue
+    }
+    seen[name] = true
+
+    key, known := Lookup(name)
+    if !known {
+      unknown = append(unknown, name)
+    }
+
+    value := v.Get(name)
+    if key.Secret {
+      value = redact(v, name)
+    }
+
+    // viper lowercases keys, show them as documented
+    if strings.EqualFold(key.Name, name) {
+      name = key.Name
+    }
+    settings[name] = value
+  }
+  return settings, unknown
+}
+
+// References to secrets are shown as they are, secrets never
+func redact(v *viper.Viper, key string) string {
+  reference := secretReference(v, key)
+  if strings.HasPrefix(reference, FileReferencePrefix) || strings.HasPrefix(reference, EnvReferencePrefix) {
+    return reference
+  }
+  if reference == "" {
+    return ""
+  }
+  return "<redacted>"
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+  "strings"
+)
+
+// Key is a configuration key meui reads. Default is nil for keys without a default, they are usually set in discovery.yml or app.yml.
+// Values of secret keys are never printed. They can be read from files, see Secret.
+type Key struct {
+  Name string
+  Default interface{}
+  Secret bool
+  Description string
+}
+
+// Keys lists every key meui reads, Load reads nothing else
+var Keys = []Key{
+  {"config.app.path", "./app.yml", false, "Path of app.yml, the meui specific configuration"},
+  {"config.discovery.path", "./discovery.yml", false, "Path of discovery.yml, the urls and endpoints of all services"},
+
+  {"log.debug", false, false, "Log debug messages"},
+  {"log.format", "", false, "Log format, default or json"},
+
+  {"serve.listen.mode", "tls", false, "Serve tls, plain http or on a unix socket"},
+  {"serve.listen.socket", "", false, "Path of the unix socket, required when serve.listen.mode is unix"},
+  {"serve.public.port", nil, false, "Port to listen on, required unless serve.listen.mode is unix"},
+  {"serve.tls.cert.path", nil, false, "Path of the tls certificate, required when serve.listen.mode is tls"},
+  {"serve.tls.key.path", nil, false, "Path of the tls key, required when serve.listen.mode is tls"},
+  {"serve.tls.reload.interval", 10, false, "Seconds between checks for a rotated certificate and key"},
+  {"serve.shutdown.timeout", 30, false, "Seconds to drain in-flight requests on SIGTERM"},
+  {"serve.debug.vars", false, false, "Expose expvar metrics on /debug/vars"},
+  {"secrets.reload.interval", 60, false, "Seconds between reading secret files again to pick up rotated secrets, 0 disables"},
+
+  {"cookie.secure", true, false, "Set the Secure flag on cookies, only turn it off when browsers talk plain http to meui"},
+  {"cookie.samesite", "lax", false, "SameSite of cookies, default, lax, strict or none"},
+  {"cookie.domain", "", false, "Domain of cookies, empty for the host of the request"},
+
+  {"session.authKey", nil, true, "Key signing the session cookie, at least 32 characters. Rotation needs a restart"},
+  {"csrf.authKey", nil, true, "Key signing the csrf cookie, at least 32 characters. Rotation needs a restart"},
+
+  {"oauth2.client.id", nil, false, "Client id of meui in hydra"},
+  {"oauth2.client.secret", nil, true, "Client secret of meui in hydra, rotated without a restart"},
+  {"oauth2.callback", nil, false, "Url hydra redirects to after login, must be registered for the client"},
+  {"oauth2.defaultRedirect", nil, false, "Url users are sent to after login when they did not ask for a page"},
+  {"oauth2.scopes.required", nil, false, "Scopes meui requests"},
+
+  {"ratelimit.invites.perMinute", 10, false, "Invites created per minute per identity and client ip"},
+  {"ratelimit.invites.burst", 5, false, "Invites created in a burst"},
+  {"ratelimit.clients.perMinute", 10, false, "Clients created or deleted per minute per identity and client ip"},
+  {"ratelimit.clients.burst", 5, false, "Clients created or deleted in a burst"},
+  {"ratelimit.ajax.perMinute", 120, false, "Ajax requests per minute per identity and client ip"},
+  {"ratelimit.ajax.burst", 20, false, "Ajax requests in a burst"},
+  {"ratelimit.api.perMinute", 120, false, "Api requests per minute per identity and client ip, writes also count against the limit of the page doing the same"},
+  {"ratelimit.api.burst", 20, false, "Api requests in a burst"},
+  {"ratelimit.default.perMinute", 30, false, "Requests per minute to every other POST endpoint"},
+  {"ratelimit.default.burst", 10, false, "Requests in a burst to every other POST endpoint"},
+  {"ratelimit.invites.send.daily", 50, false, "Invite e-mails a user may send per UTC day"},
+  {"ratelimit.trustedProxies", nil, false, "CIDRs of the proxies whose X-Forwarded-For tells the client ip, eg. 10.0.0.0/8. Other peers are limited by their own address"},
+
+  {"csp.directives", map[string][]string{
+    "default-src": {"'self'"},
+    "script-src": {"'self'", "'nonce-{nonce}'"},
+    "style-src": {"'self'", "'unsafe-inline'"}, // style attributes are used throughout the views
+    "img-src": {"'self'", "data:"},
+    "font-src": {"'self'", "data:"},
+    "connect-src": {"'self'"},
+    "object-src": {"'none'"},
+    "base-uri": {"'self'"},
+    "frame-ancestors": {"'none'"},
+  }, false, "Content-Security-Policy directives, {nonce} is replaced with a fresh nonce on every request"},
+  {"csp.reportOnly", false, false, "Only report violations, do not enforce the policy"},
+  {"csp.report.enabled", true, false, "Browsers post violations to /csp-report"},
+
+  {"headers.referrerPolicy", "strict-origin-when-cross-origin", false, "Referrer-Policy header"},
+  {"headers.hsts.maxAge", 31536000, false, "Seconds of Strict-Transport-Security, 0 disables it"},
+  {"headers.hsts.includeSubDomains", false, false, "Include subdomains in Strict-Transport-Security"},
+
+  {"assets.path", "", false, "Directory with views/ and public/ overriding the embedded files"},
+  {"assets.reload", false, false, "Re-read templates and assets on every request, development only"},
+
+  {"provider.name", "", false, "Name of the provider shown on every page"},
+  {"branding.logo", "/public/images/fingerprint.svg", false, "Logo shown on every page"},
+  {"branding.stylesheet", "", false, "Extra stylesheet loaded after dashboard.css, eg. /public/css/brand.css"},
+
+  {"validation.redirectUris.allowLocalhost", false, false, "Accept http://localhost redirect uris for native and development clients"},
+
+  {"operators.identities", nil, false, "Identity ids allowed on operator pages, eg. the webhook delivery log"},
+
+  {"invites.import.batchSize", 50, false, "Invites created or sent per request to idp by the invite import"},
+  {"invites.import.maxRows", 1000, false, "Rows a csv file of the invite import may have"},
+  {"invites.store.path", "./invites.json", false, "File keeping the revocations, expiry changes and resends of invites, which idp has no place for"},
+  {"invites.resend.interval", 600, false, "Seconds before an invite may be sent again"},
+  {"invites.provision.interval", 60, false, "Seconds between looking for accepted invites whose bundle is to be provisioned, 0 turns it off"},
+
+  {"webhooks.endpoints.*.secret", nil, true, "Key signing the payloads sent to the webhook endpoint, read on every delivery so rotation needs no restart"},
+  {"webhooks.endpoints", nil, false, "Webhook endpoints by name, each with url, events and secret. Events are names like grant.created, patterns like grant.* or * for all"},
+  {"webhooks.retries", 5, false, "Retries of a failed delivery before it goes to the dead letters"},
+  {"webhooks.backoff", 10, false, "Seconds before the first retry, doubled on every retry"},
+  {"webhooks.timeout", 10, false, "Seconds to wait for a webhook endpoint to answer"},
+  {"webhooks.log.size", 500, false, "Deliveries kept in the delivery log and in the dead letters"},
+
+  {"cli.client.id", "", false, "Public client of hydra used by meui login, allowed the device code grant or http://127.0.0.1:{cli.loopback.port}/callback as redirect uri"},
+  {"cli.scopes", nil, false, "Scopes meui login requests, oauth2.scopes.required and offline_access if not set"},
+  {"cli.loopback.port", 8085, false, "Port on 127.0.0.1 receiving the redirect of meui login --loopback, 0 picks a free port for hydra versions accepting any loopback port"},
+  {"cli.credentials", "", false, "File caching the tokens of meui login, credentials.json in the meui directory of the user config directory if not set"},
+
+  {"hydra.public.url", nil, false, "Public url of hydra"},
+  {"hydra.public.endpoints.logout", nil, false, "Logout endpoint of hydra"},
+
+  {"idp.public.url", nil, false, "Public url of idp"},
+  {"idp.public.endpoints.clients.collection", nil, false, "Clients endpoint of idp"},
+  {"idp.public.endpoints.humans.collection", nil, false, "Humans endpoint of idp"},
+  {"idp.public.endpoints.identities.collection", nil, false, "Identities endpoint of idp"},
+  {"idp.public.endpoints.invites.collection", nil, false, "Invites endpoint of idp"},
+  {"idp.public.endpoints.invites.send", nil, false, "Invite e-mail endpoint of idp"},
+  {"idp.public.endpoints.resourceservers.collection", nil, false, "Resource servers endpoint of idp"},
+  {"idp.public.endpoints.roles.collection", nil, false, "Roles endpoint of idp"},
+
+  {"idpui.public.url", nil, false, "Public url of idpui"},
+  {"idpui.public.endpoints.profile", nil, false, "Public profile page of idpui"},
+  {"idpui.public.endpoints.password", nil, false, "Change password page of idpui"},
+  {"idpui.public.endpoints.emailchange", nil, false, "Change e-mail page of idpui"},
+  {"idpui.public.endpoints.totp", nil, false, "Two-factor authentication page of idpui"},
+  {"idpui.public.endpoints.delete", nil, false, "Delete profile page of idpui"},
+
+  {"aap.public.url", nil, false, "Public url of aap"},
+  {"aap.public.endpoints.consents.collection", nil, false, "Consents endpoint of aap"},
+  {"aap.public.endpoints.entities.judge", nil, false, "Judge endpoint of aap, authorizes every request to meui"},
+  {"aap.public.endpoints.grants", nil, false, "Grants endpoint of aap"},
+  {"aap.public.endpoints.publishes", nil, false, "Publishes endpoint of aap"},
+  {"aap.public.endpoints.scopes", nil, false, "Scopes endpoint of aap"},
+  {"aap.public.endpoints.shadows.collection", nil, false, "Shadows endpoint of aap"},
+  {"aap.public.endpoints.subscriptions.collection", nil, false, "Subscriptions endpoint of aap"},
+
+  {"aapui.public.url", nil, false, "Public url of aapui"},
+
+  {"meui.public.url", nil, false, "Public url of meui"},
+  {"meui.public.endpoints.access.grant", nil, false, "Grants page of meui"},
+  {"meui.public.endpoints.client", nil, false, "Create client page of meui"},
+  {"meui.public.endpoints.clients.collection", nil, false, "Clients page of meui"},
+  {"meui.public.endpoints.clients.delete", nil, false, "Delete client page of meui"},
+  {"meui.public.endpoints.edit", nil, false, "Edit profile page of meui"},
+  {"meui.public.endpoints.invites.collection", nil, false, "Invites page of meui"},
+  {"meui.public.endpoints.invites.send", nil, false, "Send invite page of meui"},
+  {"meui.public.endpoints.logout", nil, false, "Logout page of meui"},
+  {"meui.public.endpoints.publishings.collection", nil, false, "Publishings page of meui"},
+  {"meui.public.endpoints.resourceserver", nil, false, "Create resource server page of meui"},
+  {"meui.public.endpoints.resourceservers.collection", nil, false, "Resource servers page of meui"},
+  {"meui.public.endpoints.resourceservers.delete", nil, false, "Delete resource server page of meui"},
+  {"meui.public.endpoints.roles.delete", nil, false, "Delete role page of meui"},
+  {"meui.public.endpoints.seeyoulater", nil, false, "Page shown after logout"},
+  {"meui.public.endpoints.shadow", nil, false, "Create shadow page of meui"},
+  {"meui.public.endpoints.shadows.collection", nil, false, "Shadows page of meui"},
+  {"meui.public.endpoints.shadows.delete", nil, false, "Delete shadow page of meui"},
+  {"meui.public.endpoints.subscriptions.collection", nil, false, "Subscriptions page of meui"},
+}
+
+// WebhookEvents are the events meui sends to webhook endpoints, see package webhooks
+var WebhookEvents = []string{
+  "grant.created", "grant.deleted",
+  "subscription.created", "subscription.deleted",
+  "shadow.created", "shadow.deleted",
+  "client.created", "client.deleted",
+  "role.created", "role.deleted",
+  "invite.created", "invite.sent", "invite.revoked", "invite.updated", "invite.provisioned",
+}
+
+// Lookup finds the key name, or the key holding it, eg. csp.directives holds csp.directives.script-src.
+// A * in a key matches any one part of the name, eg. webhooks.endpoints.*.secret matches webhooks.endpoints.siem.secret.
+func Lookup(name string) (Key, bool) {
+  parts := strings.Split(strings.ToLower(name), ".")
+  for _, k := range Keys {
+    if matchKey(strings.Split(strings.ToLower(k.Name), "."), parts) {
+      return k, true
+    }
+  }
+  return Key{}, false
+}
+
+func matchKey(key []string, name []string) bool {
+  if len(name) < len(key) {
+    return false
+  }
+  for i, part := range key {
+    if part != "*" && part != name[i] {
+      return false
+    }
+  }
+  return true
+}
@@ -0,0 +1,428 @@
+package config
+
+import (
+  "fmt"
+  "time"
+  "sort"
+  "strings"
+  "strconv"
+  "net"
+  "net/url"
+  "net/http"
+  "github.com/spf13/viper"
+
+  "github.com/opensentry/meui/utils"
+)
+
+// Minimum length of the keys signing session and csrf cookies
+const MinAuthKeyLength = 32
+
+// Problems lists everything wrong with the configuration, one line per key
+type Problems []string
+
+func (p Problems) Error() string {
+  return "Invalid configuration:\n  " + strings.Join(p, "\n  ")
+}
+
+// Load reads the typed configuration from viper. Required keys, urls, ports and key lengths are validated
+// and every problem found is returned at once as Problems.
+func Load() (*Config, error) {
+  return load(source())
+}
+
+func load(v *viper.Viper) (*Config, error) {
+  l := &loader{v: v}
+  cfg := &Config{}
+
+  cfg.Log = LogConfig{
+    Debug: l.bool("log.debug"),
+    Format: l.oneOf("log.format", "", "default", "json"),
+  }
+
+  cfg.Serve = ServeConfig{
+    Mode: l.oneOf("serve.listen.mode", "tls", "http", "unix"),
+    Socket: v.GetString("serve.listen.socket"),
+    CertReloadInterval: l.seconds("serve.tls.reload.interval"),
+    ShutdownTimeout: l.seconds("serve.shutdown.timeout"),
+    DebugVars: l.bool("serve.debug.vars"),
+  }
+  switch cfg.Serve.Mode {
+  case "unix":
+    cfg.Serve.Socket = l.required("serve.listen.socket")
+  case "tls":
+    cfg.Serve.Port = l.port("serve.public.port")
+    cfg.Serve.CertPath = l.required("serve.tls.cert.path")
+    cfg.Serve.KeyPath = l.required("serve.tls.key.path")
+  default:
+    cfg.Serve.Port = l.port("serve.public.port")
+  }
+
+  sameSite, err := utils.ParseSameSite(v.GetString("cookie.samesite"))
+  if err != nil {
+    l.problem("cookie.samesite", "must be one of default, lax, strict or none")
+  }
+  cfg.Cookie = CookieConfig{
+    Secure: l.bool("cookie.secure"),
+    SameSite: sameSite,
+    Domain: v.GetString("cookie.domain"),
+  }
+  if sameSite == http.SameSiteNoneMode && !cfg.Cookie.Secure {
+    l.problem("cookie.samesite", "none requires cookie.secure=true, browsers reject the cookies otherwise")
+  }
+
+  cfg.Secrets = SecretsConfig{ReloadInterval: l.seconds("secrets.reload.interval")}
+  cfg.Session = SessionConfig{AuthKey: l.authKey("session.authKey")}
+  cfg.Csrf = CsrfConfig{AuthKey: l.authKey("csrf.authKey")}
+
+  cfg.OAuth2 = OAuth2Config{
+    ClientId: l.required("oauth2.client.id"),
+    ClientSecret: l.secret("oauth2.client.secret"),
+    Callback: l.url("oauth2.callback"),
+    DefaultRedirect: l.url("oauth2.defaultRedirect"),
+    Scopes: l.requiredSlice("oauth2.scopes.required"),
+  }
+
+  cfg.Csp = CspConfig{
+    Directives: v.GetStringMapStringSlice("csp.directives"),
+    ReportOnly: l.bool("csp.reportOnly"),
+    ReportEnabled: l.bool("csp.report.enabled"),
+  }
+
+  cfg.Headers = HeadersConfig{
+    ReferrerPolicy: l.oneOf("headers.referrerPolicy", "", "no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin", "same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url"),
+    HstsMaxAge: l.int("headers.hsts.maxAge", 0, -1),
+    HstsIncludeSubDomains: l.bool("headers.hsts.includeSubDomains"),
+  }
+
+  cfg.Assets = AssetsConfig{
+    Path: v.GetString("assets.path"),
+    Reload: l.bool("assets.reload"),
+  }
+
+  cfg.Provider = ProviderConfig{Name: v.GetString("provider.name")}
+
+  cfg.Branding = BrandingConfig{
+    Logo: v.GetString("branding.logo"),
+    Stylesheet: v.GetString("branding.stylesheet"),
+  }
+
+  cfg.Validation = ValidationConfig{
+    RedirectUrisAllowLocalhost: l.bool("validation.redirectUris.allowLocalhost"),
+  }
+
+  cfg.RateLimit = RateLimitConfig{
+    Invites: l.rate("ratelimit.invites"),
+    Clients: l.rate("ratelimit.clients"),
+    Ajax: l.rate("ratelimit.ajax"),
+    Api: l.rate("ratelimit.api"),
+    Default: l.rate("ratelimit.default"),
+    InvitesSendDaily: l.int("ratelimit.invites.send.daily", 1, -1),
+    TrustedProxies: l.cidrs("ratelimit.trustedProxies"),
+  }
+
+  cfg.Operators = OperatorsConfig{Identities: v.GetStringSlice("operators.identities")}
+
+  cfg.Invites = InvitesConfig{
+    ImportBatchSize: l.int("invites.import.batchSize", 1, -1),
+    ImportMaxRows: l.int("invites.import.maxRows", 1, -1),
+    StorePath: v.GetString("invites.store.path"),
+    ResendInterval: l.seconds("invites.resend.interval"),
+    ProvisionInterval: l.seconds("invites.provision.interval"),
+  }
+
+  cfg.Webhooks = WebhooksConfig{
+    Endpoints: l.webhookEndpoints("webhooks.endpoints"),
+    Retries: l.int("webhooks.retries", 0, -1),
+    Backoff: l.seconds("webhooks.backoff"),
+    Timeout: time.Duration(l.int("webhooks.timeout", 1, -1)) * time.Second,
+    LogSize: l.int("webhooks.log.size", 1, -1),
+  }
+
+  cfg.Cli = CliConfig{
+    ClientId: v.GetString("cli.client.id"),
+    Scopes: v.GetStringSlice("cli.scopes"),
+    LoopbackPort: l.int("cli.loopback.port", 0, 65535),
+    Credentials: v.GetString("cli.credentials"),
+  }
+
+  hydra := l.url("hydra.public.url")
+  cfg.Hydra = HydraConfig{
+    Url: hydra,
+    Logout: l.endpoint(hydra, "hydra.public.endpoints.logout"),
+  }
+
+  idp := l.url("idp.public.url")
+  cfg.Idp = IdpConfig{
+    Url: idp,
+    Clients: l.endpoint(idp, "idp.public.endpoints.clients.collection"),
+    Humans: l.endpoint(idp, "idp.public.endpoints.humans.collection"),
+    Identities: l.endpoint(idp, "idp.public.endpoints.identities.collection"),
+    Invites: l.endpoint(idp, "idp.public.endpoints.invites.collection"),
+    InvitesSend: l.endpoint(idp, "idp.public.endpoints.invites.send"),
+    ResourceServers: l.endpoint(idp, "idp.public.endpoints.resourceservers.collection"),
+    Roles: l.endpoint(idp, "idp.public.endpoints.roles.collection"),
+  }
+
+  idpui := l.url("idpui.public.url")
+  cfg.IdpUi = IdpUiConfig{
+    Url: idpui,
+    Profile: l.endpoint(idpui, "idpui.public.endpoints.profile"),
+    Password: l.endpoint(idpui, "idpui.public.endpoints.password"),
+    EmailChange: l.endpoint(idpui, "idpui.public.endpoints.emailchange"),
+    Totp: l.endpoint(idpui, "idpui.public.endpoints.totp"),
+    Delete: l.endpoint(idpui, "idpui.public.endpoints.delete"),
+  }
+
+  aap := l.url("aap.public.url")
+  cfg.Aap = AapConfig{
+    Url: aap,
+    Consents: l.endpoint(aap, "aap.public.endpoints.consents.collection"),
+    EntitiesJudge: l.endpoint(aap, "aap.public.endpoints.entities.judge"),
+    Grants: l.endpoint(aap, "aap.public.endpoints.grants"),
+    Publishes: l.endpoint(aap, "aap.public.endpoints.publishes"),
+    Scopes: l.endpoint(aap, "aap.public.endpoints.scopes"),
+    Shadows: l.endpoint(aap, "aap.public.endpoints.shadows.collection"),
+    Subscriptions: l.endpoint(aap, "aap.public.endpoints.subscriptions.collection"),
+  }
+
+  cfg.AapUi = AapUiConfig{Url: l.url("aapui.public.url")}
+
+  meui := l.url("meui.public.url")
+  cfg.Meui = MeuiConfig{
+    Url: meui,
+    AccessGrant: l.endpoint(meui, "meui.public.endpoints.access.grant"),
+    Client: l.endpoint(meui, "meui.public.endpoints.client"),
+    Clients: l.endpoint(meui, "meui.public.endpoints.clients.collection"),
+    ClientsDelete: l.endpoint(meui, "meui.public.endpoints.clients.delete"),
+    Edit: l.endpoint(meui, "meui.public.endpoints.edit"),
+    Invites: l.endpoint(meui, "meui.public.endpoints.invites.collection"),
+    InvitesSend: l.endpoint(meui, "meui.public.endpoints.invites.send"),
+    Logout: l.endpoint(meui, "meui.public.endpoints.logout"),
+    Publishings: l.endpoint(meui, "meui.public.endpoints.publishings.collection"),
+    ResourceServer: l.endpoint(meui, "meui.public.endpoints.resourceserver"),
+    ResourceServers: l.endpoint(meui, "meui.public.endpoints.resourceservers.collection"),
+    ResourceServersDelete: l.endpoint(meui, "meui.public.endpoints.resourceservers.delete"),
+    RolesDelete: l.endpoint(meui, "meui.public.endpoints.roles.delete"),
+    SeeYouLater: l.endpoint(meui, "meui.public.endpoints.seeyoulater"),
+    Shadow: l.endpoint(meui, "meui.public.endpoints.shadow"),
+    Shadows: l.endpoint(meui, "meui.public.endpoints.shadows.collection"),
+    ShadowsDelete: l.endpoint(meui, "meui.public.endpoints.shadows.delete"),
+    Subscriptions: l.endpoint(meui, "meui.public.endpoints.subscriptions.collection"),
+  }
+
+  if len(l.problems) > 0 {
+    return nil, l.problems
+  }
+  return cfg, nil
+}
+
+// Reads keys from viper and collects the problems found instead of stopping at the first
+type loader struct {
+  v *viper.Viper
+  problems Problems
+}
+
+func (l *loader) problem(key string, format string, args ...interface{}) {
+  l.problems = append(l.problems, key + ": " + fmt.Sprintf(format, args...))
+}
+
+func (l *loader) required(key string) string {
+  value := strings.TrimSpace(l.v.GetString(key))
+  if value == "" {
+    l.problem(key, "is required")
+  }
+  return value
+}
+
+func (l *loader) requiredSlice(key string) []string {
+  values := l.v.GetStringSlice(key)
+  if len(values) == 0 {
+    l.problem(key, "is required")
+  }
+  return values
+}
+
+func (l *loader) bool(key string) bool {
+  value := l.v.GetString(key)
+  if value == "" {
+    return false
+  }
+
+  b, err := strconv.ParseBool(value)
+  if err != nil {
+    l.problem(key, "must be true or false, got %q", value)
+  }
+  return b
+}
+
+// Integer from min to max, max below zero means no upper bound
+func (l *loader) int(key string, min int, max int) int {
+  value := l.v.GetString(key)
+  if value == "" {
+    l.problem(key, "is required")
+    return 0
+  }
+
+  i, err := strconv.Atoi(value)
+  if err != nil {
+    l.problem(key, "must be a whole number, got %q", value)
+    return 0
+  }
+
+  if i < min || (max >= 0 && i > max) {
+    if max < 0 {
+      l.problem(key, "must be at least %d, got %d", min, i)
+    } else {
+      l.problem(key, "must be between %d and %d, got %d", min, max, i)
+    }
+  }
+  return i
+}
+
+func (l *loader) seconds(key string) time.Duration {
+  return time.Duration(l.int(key, 0, -1)) * time.Second
+}
+
+func (l *loader) port(key string) int {
+  return l.int(key, 1, 65535)
+}
+
+func (l *loader) rate(prefix string) Rate {
+  return Rate{
+    PerMinute: l.int(prefix + ".perMinute", 1, -1),
+    Burst: l.int(prefix + ".burst", 1, -1),
+  }
+}
+
+func (l *loader) cidrs(key string) []*net.IPNet {
+  var nets []*net.IPNet
+  for _, value := range l.v.GetStringSlice(key) {
+    _, ipNet, err := net.ParseCIDR(value)
+    if err != nil {
+      l.problem(key, "must be a list of cidrs like 10.0.0.0/8, got %q", value)
+      continue
+    }
+    nets = append(nets, ipNet)
+  }
+  return nets
+}
+
+func (l *loader) oneOf(key string, allowed ...string) string {
+  value := l.v.GetString(key)
+  for _, a := range allowed {
+    if value == a {
+      return value
+    }
+  }
+
+  var shown []string
+  for _, a := range allowed {
+    if a != "" {
+      shown = append(shown, a)
+    }
+  }
+  l.problem(key, "must be one of %s, got %q", strings.Join(shown, ", "), value)
+  return value
+}
+
+// Secret read by Secret, following file:// and env:// references
+func (l *loader) secret(key string) string {
+  value, err := readSecret(l.v, key)
+  if err != nil {
+    l.problem(key, "%s", err.Error())
+    return ""
+  }
+
+  if strings.TrimSpace(value) == "" {
+    l.problem(key, "is required")
+  }
+  return value
+}
+
+func (l *loader) authKey(key string) []byte {
+  value := l.secret(key)
+  if value != "" && len(value) < MinAuthKeyLength {
+    l.problem(key, "must be at least %d characters, got %d", MinAuthKeyLength, len(value))
+  }
+  return []byte(value)
+}
+
+// Absolute http or https url
+func (l *loader) url(key string) string {
+  value := l.required(key)
+  if value == "" {
+    return ""
+  }
+
+  u, err := url.Parse(value)
+  if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+    l.problem(key, "must be an absolute http or https url, got %q", value)
+    return ""
+  }
+  return value
+}
+
+// Path resolved against the url of its service, eg. https://idp/api + /clients. An unusable base url is reported by its own key.
+func (l *loader) endpoint(base string, key string) string {
+  path := l.required(key)
+  if path == "" {
+    return ""
+  }
+
+  if !strings.HasPrefix(path, "/") {
+    l.problem(key, "must be a path starting with /, got %q", path)
+    return ""
+  }
+
+  if base == "" {
+    return ""
+  }
+
+  endpoint := strings.TrimSuffix(base, "/") + path
+  _, err := url.Parse(endpoint)
+  if err != nil {
+    l.problem(key, "does not resolve to a valid url: %s", err.Error())
+    return ""
+  }
+  return endpoint
+}
+
+// Endpoints by name, in name order so problems are reported in a stable order
+func (l *loader) webhookEndpoints(key string) []WebhookEndpoint {
+  var names []string
+  for name, _ := range l.v.GetStringMap(key) {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  var endpoints []WebhookEndpoint
+  for _, name := range names {
+    prefix := key + "." + name
+    endpoint := WebhookEndpoint{
+      Name: name,
+      Url: l.url(prefix + ".url"),
+      Events: l.requiredSlice(prefix + ".events"),
+      Secret: l.secret(prefix + ".secret"),
+    }
+
+    for _, event := range endpoint.Events {
+      if !knownWebhookEvent(event) {
+        l.problem(prefix + ".events", "unknown event %q, must be * or one of %s, or a pattern like grant.*", event, strings.Join(WebhookEvents, ", "))
+      }
+    }
+    endpoints = append(endpoints, endpoint)
+  }
+  return endpoints
+}
+
+func knownWebhookEvent(event string) bool {
+  if event == "*" {
+    return true
+  }
+  for _, e := range WebhookEvents {
+    if e == event || (strings.HasSuffix(event, ".*") && strings.HasPrefix(e, strings.TrimSuffix(event, "*"))) {
+      return true
+    }
+  }
+  return false
+}
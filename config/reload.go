@@ -0,0 +1,112 @@
+package config
+
+import (
+  "sync"
+  "strings"
+  "reflect"
+  "github.com/spf13/viper"
+)
+
+// Keys read once at startup, eg. by the listener, the cookie store or the oidc provider. Changes are reported, not applied.
+var restartKeys = []string{
+  "config.",
+  "serve.",
+  "cookie.",
+  "session.",
+  "csrf.",
+  "csp.",
+  "headers.",
+  "assets.",
+  "secrets.",
+  "hydra.public.url",
+}
+
+var (
+  // Serializes Reload and RefreshSecrets, both replace the current configuration
+  reloadMu sync.Mutex
+
+  // Values meui started with, keys needing a restart are compared to these
+  started *viper.Viper
+)
+
+// Changes lists the keys that differ after Reload
+type Changes struct {
+  Applied []string // Used from the next request on
+  Restart []string // Differ from the values meui started with, used after a restart
+}
+
+// NeedsRestart tells if changes of key only take effect after a restart
+func NeedsRestart(key string) bool {
+  key = strings.ToLower(key)
+  for _, prefix := range restartKeys {
+    prefix = strings.ToLower(prefix)
+    if key == strings.TrimSuffix(prefix, ".") || strings.HasPrefix(key, prefix) {
+      return true
+    }
+  }
+  return false
+}
+
+// Reload reads the configuration files again and replaces the current configuration if the new one is valid.
+// An invalid configuration is rejected with every problem as Problems and the current configuration is kept.
+// Keys that need a restart keep the values meui started with, so Get always describes what is running.
+func Reload() (Changes, error) {
+  reloadMu.Lock()
+  defer reloadMu.Unlock()
+
+  v, err := readConfigurations()
+  if err != nil {
+    return Changes{}, err
+  }
+
+  cfg, err := load(v)
+  if err != nil {
+    return Changes{}, err
+  }
+
+  running := Get()
+  if running != nil {
+    cfg.Serve = running.Serve
+    cfg.Cookie = running.Cookie
+    cfg.Session = running.Session
+    cfg.Csrf = running.Csrf
+    cfg.Csp = running.Csp
+    cfg.Headers = running.Headers
+    cfg.Assets = running.Assets
+    cfg.Secrets = running.Secrets
+    cfg.Hydra.Url = running.Hydra.Url
+  }
+
+  var changes Changes
+  previous := source()
+  for _, k := range Keys {
+    if NeedsRestart(k.Name) {
+      if started != nil && !reflect.DeepEqual(started.Get(k.Name), v.Get(k.Name)) {
+        changes.Restart = append(changes.Restart, k.Name)
+      }
+      continue
+    }
+
+    if !reflect.DeepEqual(previous.Get(k.Name), v.Get(k.Name)) {
+      changes.Applied = append(changes.Applied, k.Name)
+    }
+  }
+
+  // A rotated secret file is read by load as well, report it like RefreshSecrets does
+  if running != nil && cfg.OAuth2.ClientSecret != running.OAuth2.ClientSecret && !contains(changes.Applied, "oauth2.client.secret") {
+    changes.Applied = append(changes.Applied, "oauth2.client.secret")
+  }
+
+  values.Store(v)
+  current.Store(cfg)
+  return changes, nil
+}
+
+func contains(keys []string, key string) bool {
+  for _, k := range keys {
+    if k == key {
+      return true
+    }
+  }
+  return false
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+  "os"
+  "fmt"
+  "strings"
+  "io/ioutil"
+  "github.com/spf13/viper"
+)
+
+// Prefixes of values referencing a secret instead of holding it, eg. file:///run/secrets/meui_client_secret or env://MEUI_CLIENT_SECRET
+const (
+  FileReferencePrefix = "file://"
+  EnvReferencePrefix = "env://"
+)
+
+// Environment variable naming the file holding the secret of key, eg. OAUTH2_CLIENT_SECRET_FILE for oauth2.client.secret
+func fileEnv(key string) string {
+  return strings.ToUpper(strings.ReplaceAll(key, ".", "_")) + "_FILE"
+}
+
+// Where the value of the secret key comes from. A file named by the _FILE environment variable wins over the configured value,
+// which is either a file:// or env:// reference or the secret itself.
+func secretReference(v *viper.Viper, key string) string {
+  if path := os.Getenv(fileEnv(key)); path != "" {
+    return FileReferencePrefix + path
+  }
+  return v.GetString(key)
+}
+
+// Secret reads the value of the secret key, following references. Files are read on every call, so rotated secrets are picked up.
+func Secret(key string) (string, error) {
+  return readSecret(source(), key)
+}
+
+func readSecret(v *viper.Viper, key string) (string, error) {
+  reference := secretReference(v, key)
+
+  if strings.HasPrefix(reference, FileReferencePrefix) {
+    path := strings.TrimPrefix(reference, FileReferencePrefix)
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+      return "", fmt.Errorf("unable to read secret file %s: %s", path, err.Error())
+    }
+    return strings.TrimRight(string(data), "\r\n"), nil // Secret files usually end with a newline
+  }
+
+  if strings.HasPrefix(reference, EnvReferencePrefix) {
+    name := strings.TrimPrefix(reference, EnvReferencePrefix)
+    value, exists := os.LookupEnv(name)
+    if !exists {
+      return "", fmt.Errorf("environment variable %s is not set", name)
+    }
+    return value, nil
+  }
+
+  return reference, nil
+}
+
+// Secrets changed since startup that wait for a restart, reported once by RefreshSecrets
+var pendingRestart = make(map[string]bool)
+
+// RefreshSecrets reads the secret keys again and, if the client secret changed, replaces the current configuration with one holding it.
+// Returns the keys that changed, keys needing a restart are returned once. An unreadable or invalid secret keeps the current configuration.
+func RefreshSecrets() ([]string, error) {
+  reloadMu.Lock()
+  defer reloadMu.Unlock()
+
+  old := Get()
+  if old == nil {
+    return nil, nil
+  }
+
+  l := &loader{v: source()}
+  clientSecret := l.secret("oauth2.client.secret")
+  authKeys := map[string][]byte{
+    "session.authKey": l.authKey("session.authKey"),
+    "csrf.authKey": l.authKey("csrf.authKey"),
+  }
+  if len(l.problems) > 0 {
+    return nil, l.problems
+  }
+
+  var changed []string
+  if clientSecret != old.OAuth2.ClientSecret {
+    cfg := *old
+    cfg.OAuth2.ClientSecret = clientSecret
+    current.Store(&cfg)
+    changed = append(changed, "oauth2.client.secret")
+  }
+
+  // The running cookie store and csrf protection keep the keys they started with, see NeedsRestart
+  running := map[string][]byte{
+    "session.authKey": old.Session.AuthKey,
+    "csrf.authKey": old.Csrf.AuthKey,
+  }
+  for _, key := range []string{"session.authKey", "csrf.authKey"} {
+    differs := string(authKeys[key]) != string(running[key])
+    if differs && !pendingRestart[key] {
+      changed = append(changed, key)
+    }
+    pendingRestart[key] = differs
+  }
+
+  return changed, nil
+}
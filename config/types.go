@@ -0,0 +1,222 @@
+package config
+
+import (
+  "time"
+  "net"
+  "net/http"
+)
+
+// Config is the typed configuration of meui. Endpoint urls are resolved against the url of their service, eg. Idp.Clients is idp.public.url + idp.public.endpoints.clients.collection
+type Config struct {
+  Log LogConfig
+  Serve ServeConfig
+  Cookie CookieConfig
+  Secrets SecretsConfig
+  Session SessionConfig
+  Csrf CsrfConfig
+  OAuth2 OAuth2Config
+  Csp CspConfig
+  Headers HeadersConfig
+  Assets AssetsConfig
+  Provider ProviderConfig
+  Branding BrandingConfig
+  Validation ValidationConfig
+  RateLimit RateLimitConfig
+  Operators OperatorsConfig
+  Invites InvitesConfig
+  Webhooks WebhooksConfig
+  Cli CliConfig
+
+  Hydra HydraConfig
+  Idp IdpConfig
+  IdpUi IdpUiConfig
+  Aap AapConfig
+  AapUi AapUiConfig
+  Meui MeuiConfig
+}
+
+type LogConfig struct {
+  Debug bool
+  Format string // default or json
+}
+
+type ServeConfig struct {
+  Mode string // tls, http or unix
+  Port int
+  Socket string
+  CertPath string
+  KeyPath string
+  CertReloadInterval time.Duration
+  ShutdownTimeout time.Duration
+  DebugVars bool
+}
+
+type CookieConfig struct {
+  Secure bool
+  SameSite http.SameSite
+  Domain string
+}
+
+type SecretsConfig struct {
+  ReloadInterval time.Duration
+}
+
+type SessionConfig struct {
+  AuthKey []byte
+}
+
+type CsrfConfig struct {
+  AuthKey []byte
+}
+
+type OAuth2Config struct {
+  ClientId string
+  ClientSecret string
+  Callback string
+  DefaultRedirect string
+  Scopes []string
+}
+
+type CspConfig struct {
+  Directives map[string][]string
+  ReportOnly bool
+  ReportEnabled bool
+}
+
+type HeadersConfig struct {
+  ReferrerPolicy string
+  HstsMaxAge int
+  HstsIncludeSubDomains bool
+}
+
+type AssetsConfig struct {
+  Path string
+  Reload bool
+}
+
+type ProviderConfig struct {
+  Name string
+}
+
+type BrandingConfig struct {
+  Logo string
+  Stylesheet string
+}
+
+type ValidationConfig struct {
+  RedirectUrisAllowLocalhost bool
+}
+
+type RateLimitConfig struct {
+  Invites Rate
+  Clients Rate
+  Ajax Rate
+  Api Rate
+  Default Rate
+  InvitesSendDaily int
+  TrustedProxies []*net.IPNet
+}
+
+// Rate of a token bucket
+type Rate struct {
+  PerMinute int
+  Burst int
+}
+
+type OperatorsConfig struct {
+  Identities []string
+}
+
+type InvitesConfig struct {
+  ImportBatchSize int
+  ImportMaxRows int
+  StorePath string
+  ResendInterval time.Duration
+  ProvisionInterval time.Duration
+}
+
+type WebhooksConfig struct {
+  Endpoints []WebhookEndpoint
+  Retries int
+  Backoff time.Duration
+  Timeout time.Duration
+  LogSize int
+}
+
+// CliConfig is read by meui login and logout, see cmd_login.go
+type CliConfig struct {
+  ClientId string
+  Scopes []string
+  LoopbackPort int
+  Credentials string
+}
+
+// WebhookEndpoint receives the events matching one of Events, eg. grant.created, grant.* or *
+type WebhookEndpoint struct {
+  Name string
+  Url string
+  Events []string
+  Secret string
+}
+
+type HydraConfig struct {
+  Url string
+  Logout string
+}
+
+type IdpConfig struct {
+  Url string
+  Clients string
+  Humans string
+  Identities string
+  Invites string
+  InvitesSend string
+  ResourceServers string
+  Roles string
+}
+
+type IdpUiConfig struct {
+  Url string
+  Profile string
+  Password string
+  EmailChange string
+  Totp string
+  Delete string
+}
+
+type AapConfig struct {
+  Url string
+  Consents string
+  EntitiesJudge string
+  Grants string
+  Publishes string
+  Scopes string
+  Shadows string
+  Subscriptions string
+}
+
+type AapUiConfig struct {
+  Url string
+}
+
+type MeuiConfig struct {
+  Url string
+  AccessGrant string
+  Client string
+  Clients string
+  ClientsDelete string
+  Edit string
+  Invites string
+  InvitesSend string
+  Logout string
+  Publishings string
+  ResourceServer string
+  ResourceServers string
+  ResourceServersDelete string
+  RolesDelete string
+  SeeYouLater string
+  Shadow string
+  Shadows string
+  ShadowsDelete string
+  Subscriptions string
+}
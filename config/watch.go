@@ -0,0 +1,88 @@
+package config
+
+import (
+  "time"
+  "path/filepath"
+  "github.com/fsnotify/fsnotify"
+)
+
+// Editors and configuration management write files in several steps, reload once they are done
+const watchDelay = 500 * time.Millisecond
+
+// Watch reloads the configuration when app.yml or discovery.yml changes and calls reloaded with the outcome of Reload.
+// Like viper.WatchConfig the directories are watched, so files replaced by a rename or a symlink swap, eg. a kubernetes configmap, are seen.
+func Watch(reloaded func(Changes, error)) error {
+  watcher, err := fsnotify.NewWatcher()
+  if err != nil {
+    return err
+  }
+
+  v := source()
+  realPaths := make(map[string]string)
+  dirs := make(map[string]bool)
+  for _, key := range []string{"config.discovery.path", "config.app.path"} {
+    path, err := filepath.Abs(v.GetString(key))
+    if err != nil {
+      watcher.Close()
+      return err
+    }
+
+    realPaths[path], _ = filepath.EvalSymlinks(path)
+
+    dir := filepath.Dir(path)
+    if !dirs[dir] {
+      err = watcher.Add(dir)
+      if err != nil {
+        watcher.Close()
+        return err
+      }
+      dirs[dir] = true
+    }
+  }
+
+  changed := func(event fsnotify.Event) bool {
+    if event.Op & (fsnotify.Write | fsnotify.Create | fsnotify.Rename | fsnotify.Remove) == 0 {
+      return false
+    }
+
+    name, _ := filepath.Abs(event.Name)
+    result := false
+    for path, realPath := range realPaths {
+      current, _ := filepath.EvalSymlinks(path)
+      if name == path || current != realPath {
+        realPaths[path] = current
+        result = true
+      }
+    }
+    return result
+  }
+
+  go func() {
+    defer watcher.Close()
+
+    var pending <-chan time.Time
+    for {
+      select {
+      case event, ok := <-watcher.Events:
+        if !ok {
+          return
+        }
+        if changed(event) {
+          pending = time.After(watchDelay)
+        }
+
+      case err, ok := <-watcher.Errors:
+        if !ok {
+          return
+        }
+        reloaded(Changes{}, err)
+
+      case <-pending:
+        pending = nil
+        reloaded(Reload())
+      }
+    }
+  }()
+
+  return nil
+}
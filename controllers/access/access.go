@@ -4,7 +4,6 @@ import (
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
   "github.com/gin-contrib/sessions"
   "golang.org/x/oauth2"
   oidc "github.com/coreos/go-oidc/v3/oidc"
@@ -13,14 +12,18 @@ import (
 
   aap "github.com/opensentry/aap/client"
 
+  "github.com/opensentry/meui/app"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/forms"
 )
 
-type newAccessForm struct {
-  Scope       string `form:"scope" binding:"required"`
+type newAccessInput struct {
+  Scope       string `form:"scope" validate:"required,scope"`
 }
 
+var newAccessForm = forms.New("access.new")
+
 func ShowAccess(env *environment.State) gin.HandlerFunc {
   fn := func(c *gin.Context) {
 
@@ -37,36 +40,28 @@ func ShowAccess(env *environment.State) gin.HandlerFunc {
     var idToken *oidc.IDToken
     idToken = session.Get(environment.SessionIdTokenKey).(*oidc.IDToken)
     if idToken == nil {
-      c.HTML(http.StatusNotFound, "access_new.html", gin.H{"error": "Identity not found"})
-      c.Abort()
+      c.AbortWithStatus(http.StatusUnauthorized)
       return
     }
 
     var accessToken *oauth2.Token
     accessToken = session.Get(environment.SessionTokenKey).(*oauth2.Token)
-    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig, accessToken)
+    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig(), accessToken)
 
-    url := config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.scopes")
+    url := config.Get().Aap.Scopes
     _, responses, _ := aap.ReadScopes(aapClient, url, nil)
 
     var ok aap.ReadScopesResponse
     _, restErr := bulky.Unmarshal(0, responses, &ok)
     if restErr != nil {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Println("Rest error: " + e.Error)
-      }
+      app.FlashRestErrors(c, 0, "", restErr, nil)
     }
 
-    c.HTML(200, "access.html", gin.H{
+    app.Render(c, 200, "access.html", gin.H{
       "title": "Access",
       "scopes": ok,
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "idpUiUrl": config.GetString("meui.public.url"),
-      "aapUiUrl": config.GetString("aapui.public.url"),
+      "idpUiUrl": config.Get().Meui.Url,
+      "aapUiUrl": config.Get().AapUi.Url,
     })
   }
   return gin.HandlerFunc(fn)
@@ -81,14 +76,14 @@ func ShowAccessNew(env *environment.State) gin.HandlerFunc {
       "func": "ShowAccessNew",
     })
 
-    c.HTML(200, "access_new.html", gin.H{
+    values, errors := newAccessForm.Populate(c, nil)
+
+    app.Render(c, 200, "access_new.html", gin.H{
       "title": "Create new scope",
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "idpUiUrl": config.GetString("meui.public.url"),
-      "aapUiUrl": config.GetString("aapui.public.url"),
+      "form": values,
+      "errors": errors,
+      "idpUiUrl": config.Get().Meui.Url,
+      "aapUiUrl": config.Get().AapUi.Url,
     })
   }
   return gin.HandlerFunc(fn)
@@ -102,11 +97,16 @@ func SubmitAccessNew(env *environment.State) gin.HandlerFunc {
       "func": "ShowAccess",
     })
 
-    var form newAccessForm
-    err := c.Bind(&form)
+    var input newAccessInput
+    valid, err := newAccessForm.Bind(c, &input)
     if err != nil {
-      c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-      c.Abort()
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
+    }
+
+    if !valid {
+      newAccessForm.RedirectBack(c)
       return
     }
 
@@ -118,41 +118,39 @@ func SubmitAccessNew(env *environment.State) gin.HandlerFunc {
     var idToken *oidc.IDToken
     idToken = session.Get(environment.SessionIdTokenKey).(*oidc.IDToken)
     if idToken == nil {
-      c.HTML(http.StatusNotFound, "access_new.html", gin.H{"error": "Identity not found"})
-      c.Abort()
+      c.AbortWithStatus(http.StatusUnauthorized)
       return
     }
 
     var accessToken *oauth2.Token
     accessToken = session.Get(environment.SessionTokenKey).(*oauth2.Token)
-    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig, accessToken)
+    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig(), accessToken)
 
     var createScopesRequests []aap.CreateScopesRequest
     createScopesRequests = append(createScopesRequests, aap.CreateScopesRequest{
-      Scope:               form.Scope,
+      Scope:               input.Scope,
     })
 
-    url := config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.scopes")
+    url := config.Get().Aap.Scopes
 
-    _, responses, err := aap.CreateScopes(aapClient, url, createScopesRequests)
+    status, responses, err := aap.CreateScopes(aapClient, url, createScopesRequests)
+    if err != nil || status != http.StatusOK {
+      log.Debug("Scope create failed")
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
 
     var ok aap.CreateScopesResponse
     _, restErr := bulky.Unmarshal(0, responses, &ok)
 
     if restErr != nil {
-      c.HTML(http.StatusOK, "access_new.html", gin.H{
-        "title": "Create new scope",
-        "errors": restErr,
-        "links": []map[string]string{
-          {"href": "/public/css/dashboard.css"},
-        },
-        "idpUiUrl": config.GetString("meui.public.url"),
-        "aapUiUrl": config.GetString("aapui.public.url"),
-      })
-      c.Abort()
+      app.FlashRestErrors(c, 0, "", restErr, map[string]string{"Scope": "Scope"})
+      newAccessForm.RedirectBack(c)
       return
     }
 
+    newAccessForm.Clear(c)
+
     c.Redirect(http.StatusFound, "/access")
     c.Abort()
   }
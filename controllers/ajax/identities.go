@@ -1,6 +1,7 @@
 package ajax
 
 import (
+  "strings"
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
@@ -9,8 +10,10 @@ import (
   oidc "github.com/coreos/go-oidc/v3/oidc"
   bulky "github.com/charmixer/bulky/client"
   idp "github.com/opensentry/idp/client"
+  "github.com/opensentry/meui/app"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
   "fmt"
 )
 
@@ -24,7 +27,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
     query, queryExists := c.GetQuery("q")
 
     if !queryExists {
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadRequest)
       log.Debug("Missing query")
       return
     }
@@ -42,7 +45,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
 
     var accessToken *oauth2.Token
     accessToken = session.Get(environment.SessionTokenKey).(*oauth2.Token)
-    idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig, accessToken)
+    idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig(), accessToken)
 
     var url string
     var responses []bulky.Response
@@ -52,7 +55,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
 
     // fetch identities
 
-    url = config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.identities.collection")
+    url = config.Get().Idp.Identities
     status, responses, err = idp.ReadIdentities(idpClient, url, []idp.ReadIdentitiesRequest{ {Search: query} })
 
     if !checkRestResponse(url, status, err, c, log) {
@@ -62,12 +65,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
     var identities idp.ReadIdentitiesResponse
     _, restErr = bulky.Unmarshal(0, responses, &identities)
     if len(restErr) > 0 {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-
-      c.AbortWithStatus(404)
+      abortWithRestErrors(restErr, c, log)
       return
     }
 
@@ -114,7 +112,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
     // fetch humans
 
     if len(readHumansRequests) > 0 {
-      url = config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.humans.collection")
+      url = config.Get().Idp.Humans
       status, responses, err = idp.ReadHumans(idpClient, url, readHumansRequests)
 
       if !checkRestResponse(url, status, err, c, log) {
@@ -125,12 +123,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
         var humans idp.ReadHumansResponse
         _, restErr = bulky.Unmarshal(i, responses, &humans)
         if len(restErr) > 0 {
-          for _,e := range restErr {
-            // TODO show user somehow
-            log.Debug("Rest error: " + e.Error)
-          }
-
-          c.AbortWithStatus(404)
+          abortWithRestErrors(restErr, c, log)
           return
         }
 
@@ -144,7 +137,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
     // fetch clients
 
     if len(readClientsRequests) > 0 {
-      url = config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.clients.collection")
+      url = config.Get().Idp.Clients
       status, responses, err = idp.ReadClients(idpClient, url, readClientsRequests)
 
       if !checkRestResponse(url, status, err, c, log) {
@@ -156,12 +149,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
         var clients idp.ReadClientsResponse
         _, restErr = bulky.Unmarshal(i, responses, &clients)
         if len(restErr) > 0 {
-          for _,e := range restErr {
-            // TODO show user somehow
-            log.Debug("Rest error: " + e.Error)
-          }
-
-          c.AbortWithStatus(404)
+          abortWithRestErrors(restErr, c, log)
           return
         }
 
@@ -175,7 +163,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
     // fetch resource servers
 
     if len(readResourceServersRequests) > 0 {
-      url = config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.resourceservers.collection")
+      url = config.Get().Idp.ResourceServers
       status, responses, err = idp.ReadResourceServers(idpClient, url, readResourceServersRequests)
 
       if !checkRestResponse(url, status, err, c, log) {
@@ -186,12 +174,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
         var resourceServer idp.ReadClientsResponse
         _, restErr = bulky.Unmarshal(i, responses, &resourceServer)
         if len(restErr) > 0 {
-          for _,e := range restErr {
-            // TODO show user somehow
-            log.Debug("Rest error: " + e.Error)
-          }
-
-          c.AbortWithStatus(404)
+          abortWithRestErrors(restErr, c, log)
           return
         }
 
@@ -205,7 +188,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
     // fetch roles
 
     if len(readRolesRequests) > 0 {
-      url = config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.roles.collection")
+      url = config.Get().Idp.Roles
       status, responses, err = idp.ReadRoles(idpClient, url, readRolesRequests)
 
       if !checkRestResponse(url, status, err, c, log) {
@@ -216,12 +199,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
         var roles idp.ReadRolesResponse
         _, restErr = bulky.Unmarshal(i, responses, &roles)
         if len(restErr) > 0 {
-          for _,e := range restErr {
-            // TODO show user somehow
-            log.Debug("Rest error: " + e.Error)
-          }
-
-          c.AbortWithStatus(404)
+          abortWithRestErrors(restErr, c, log)
           return
         }
 
@@ -261,7 +239,7 @@ func GetIdentities(env *environment.State) gin.HandlerFunc {
 
 func checkRestResponse(url string, status int, err error, c *gin.Context, log *logrus.Entry) (bool){
   if err != nil {
-    c.AbortWithStatus(404)
+    c.AbortWithStatus(http.StatusBadGateway)
     log.Debug(err.Error())
     return false
   }
@@ -273,10 +251,22 @@ func checkRestResponse(url string, status int, err error, c *gin.Context, log *l
   }
 
   if status != http.StatusOK {
-    c.AbortWithStatus(404)
+    c.AbortWithStatus(http.StatusBadGateway)
     log.Debug("Unable to get status 200 from "+url)
     return false
   }
 
   return true
 }
+
+// Search dropdowns cannot show flash messages, so answer in the fomantic api format with the reason as message
+func abortWithRestErrors(restErr []bulky.ErrorResponse, c *gin.Context, log *logrus.Entry) {
+  for _,e := range restErr {
+    log.Debug("Rest error: " + e.Error)
+  }
+
+  c.AbortWithStatusJSON(404, gin.H{
+    "success": false,
+    "message": strings.Join(app.RestErrorMessages(i18n.Language(c), restErr), ", "),
+  })
+}
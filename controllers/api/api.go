@@ -0,0 +1,140 @@
+// Package api serves /api/v1, the operations of the html pages as json resources for scripts and other services.
+// Requests are authenticated by bearer token, see app.RequireBearerIdentity, and idp and aap are called with that token
+// like the pages call them with the token of the session. Errors use the json format of app.ErrorPages.
+package api
+
+import (
+  "fmt"
+  "sort"
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+
+  bulky "github.com/charmixer/bulky/client"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/forms"
+  "github.com/opensentry/meui/i18n"
+)
+
+// Reads the json body into input and validates it like the form of the page doing the same, see forms.ValidateJson.
+// Aborts with 400 Bad Request listing every invalid field.
+func bindJson(c *gin.Context, input interface{}) bool {
+  err := c.ShouldBindJSON(input)
+  if err != nil {
+    app.AbortWithError(c, http.StatusBadRequest, "The request body must be a json object")
+    return false
+  }
+  return validate(c, input)
+}
+
+// Like bindJson for the query, parameters are bound by form tag and named by json tag in errors, which are the same
+func bindQuery(c *gin.Context, input interface{}) bool {
+  err := c.ShouldBindQuery(input)
+  if err != nil {
+    app.AbortWithError(c, http.StatusBadRequest, "The request query is invalid")
+    return false
+  }
+  return validate(c, input)
+}
+
+func validate(c *gin.Context, input interface{}) bool {
+  messages, err := forms.ValidateJson(i18n.Language(c), input)
+  if err != nil {
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log.Debug(err.Error())
+    c.AbortWithStatus(http.StatusInternalServerError)
+    return false
+  }
+
+  if len(messages) > 0 {
+    var fields []string
+    for field, _ := range messages {
+      fields = append(fields, field)
+    }
+    sort.Strings(fields)
+
+    var errors []app.RestError
+    for _, field := range fields {
+      for _, message := range messages[field] {
+        errors = append(errors, app.RestError{Field: field, Code: http.StatusBadRequest, Message: message})
+      }
+    }
+    app.AbortWithErrors(c, http.StatusBadRequest, errors)
+    return false
+  }
+
+  return true
+}
+
+// Checks the outcome of a bulk call to idp or aap, aborts with the status to answer if it failed
+func checkResponse(c *gin.Context, log *logrus.Entry, url string, status int, err error) bool {
+  if err != nil {
+    log.Debug(err.Error())
+    c.AbortWithStatus(http.StatusBadGateway)
+    return false
+  }
+
+  switch status {
+  case http.StatusOK:
+    return true
+  case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound:
+    log.Debug(fmt.Sprintf("Got status %d from %s", status, url))
+    c.AbortWithStatus(status)
+    return false
+  case http.StatusUnauthorized:
+    log.Debug("Got status unauthorized from " + url)
+    c.AbortWithStatus(http.StatusForbidden)
+    return false
+  }
+
+  log.Debug(fmt.Sprintf("Unable to get status 200 from %s, got %d", url, status))
+  c.AbortWithStatus(http.StatusBadGateway)
+  return false
+}
+
+// Reads the first item of a bulk response into v, aborts with the errors idp or aap returned for it
+func readItem(c *gin.Context, responses bulky.Responses, v interface{}) bool {
+  if len(responses) == 0 {
+    c.AbortWithStatus(http.StatusNotFound)
+    return false
+  }
+
+  status, restErr := bulky.Unmarshal(0, responses, v)
+  if status == http.StatusOK && len(restErr) == 0 {
+    return true
+  }
+
+  app.AbortWithRestErrors(c, itemStatus(status), 0, restErr)
+  return false
+}
+
+// Like readItem, but an item not found is an empty list
+func readList(c *gin.Context, responses bulky.Responses, v interface{}) bool {
+  if len(responses) == 0 {
+    return true
+  }
+
+  status, restErr := bulky.Unmarshal(0, responses, v)
+  if status == http.StatusNotFound || (status == http.StatusOK && len(restErr) == 0) {
+    return true
+  }
+
+  app.AbortWithRestErrors(c, itemStatus(status), 0, restErr)
+  return false
+}
+
+// Status to answer for a failed request item. Errors of the caller are passed on, everything else is a failure of the service.
+func itemStatus(status int) int {
+  switch status {
+  case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict:
+    return status
+  case http.StatusUnauthorized:
+    return http.StatusForbidden
+  }
+  if status >= 400 && status < 500 {
+    return http.StatusBadRequest
+  }
+  return http.StatusBadGateway
+}
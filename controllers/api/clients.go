@@ -0,0 +1,147 @@
+package api
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  idp "github.com/opensentry/idp/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// GET /api/v1/clients
+func GetClients(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetClients",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Clients
+    status, responses, err := idp.ReadClients(idpClient, url, nil)
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    clients := idp.ReadClientsResponse{}
+    if !readList(c, responses, &clients) {
+      return
+    }
+
+    c.JSON(http.StatusOK, clients)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// GET /api/v1/clients/:id
+func GetClient(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetClient",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Clients
+    status, responses, err := idp.ReadClients(idpClient, url, []idp.ReadClientsRequest{ {Id: c.Param("id")} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var clients idp.ReadClientsResponse
+    if !readItem(c, responses, &clients) {
+      return
+    }
+
+    if len(clients) == 0 {
+      c.AbortWithStatus(http.StatusNotFound)
+      return
+    }
+
+    c.JSON(http.StatusOK, clients[0])
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/clients, the secret of the client is only returned here
+func PostClients(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostClients",
+    })
+
+    var input meui.CreateClientsRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Clients
+    status, responses, err := idp.CreateClients(idpClient, url, []idp.CreateClientsRequest{
+      {
+        Name:                    input.Name,
+        Description:             input.Description,
+        IsPublic:                input.IsPublic,
+        GrantTypes:              input.GrantTypes,
+        ResponseTypes:           input.ResponseTypes,
+        RedirectUris:            input.RedirectUris,
+        PostLogoutRedirectUris:  input.PostLogoutRedirectUris,
+        TokenEndpointAuthMethod: input.TokenEndpointAuthMethod,
+      },
+    })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var client idp.CreateClientsResponse
+    if !readItem(c, responses, &client) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"id": client.Id}).Debug("Client created")
+    app.EmitEvent(env, c, webhooks.ClientCreated, webhooks.ClientData(idp.Client(client)))
+    c.JSON(http.StatusCreated, client)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// DELETE /api/v1/clients/:id
+func DeleteClient(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "DeleteClient",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Clients
+    status, responses, err := idp.DeleteClients(idpClient, url, []idp.DeleteClientsRequest{ {Id: c.Param("id")} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var deleted idp.DeleteClientsResponse
+    if !readItem(c, responses, &deleted) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"id": c.Param("id")}).Debug("Client deleted")
+    app.EmitEvent(env, c, webhooks.ClientDeleted, deleted)
+    c.Status(http.StatusNoContent)
+  }
+  return gin.HandlerFunc(fn)
+}
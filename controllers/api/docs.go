@@ -0,0 +1,145 @@
+package api
+
+import (
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "github.com/gin-gonic/gin"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/environment"
+)
+
+type docsTag struct {
+  Name string
+  Operations []docsOperation
+}
+
+type docsOperation struct {
+  Method string
+  Path string
+  Summary string
+  Parameters []docsProperty
+  Body docsProperty
+  Status int
+  Response docsProperty
+}
+
+// A parameter, property, body or response, Schema is set when the type links to a schema of the page
+type docsProperty struct {
+  Name string
+  In string
+  Type string
+  Schema string
+  Required bool
+}
+
+type docsSchema struct {
+  Name string
+  Properties []docsProperty
+}
+
+// GET /api/docs renders the openapi document as a page, so the api can be read without tools or scripts from elsewhere.
+// Summaries are part of the contract and not translated.
+func ShowDocs(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+    doc := OpenApi()
+
+    var tags []docsTag
+    for _, route := range Routes {
+      p, _ := openApiPath(route.Path)
+      op := doc.Paths[p][strings.ToLower(route.Method)]
+
+      operation := docsOperation{Method: route.Method, Path: Prefix + p, Summary: op.Summary, Status: route.Status}
+      for _, param := range op.Parameters {
+        property := describe(param.Schema)
+        property.Name = param.Name
+        property.In = param.In
+        property.Required = param.Required
+        operation.Parameters = append(operation.Parameters, property)
+      }
+      if op.RequestBody != nil {
+        operation.Body = describe(op.RequestBody.Content["application/json"].Schema)
+      }
+      if content, exists := op.Responses[strconv.Itoa(route.Status)].Content["application/json"]; exists {
+        operation.Response = describe(content.Schema)
+      }
+
+      if len(tags) == 0 || tags[len(tags) - 1].Name != route.Tag {
+        tags = append(tags, docsTag{Name: route.Tag})
+      }
+      tags[len(tags) - 1].Operations = append(tags[len(tags) - 1].Operations, operation)
+    }
+
+    var schemas []docsSchema
+    for name, schema := range doc.Components.Schemas {
+      s := docsSchema{Name: name}
+      for property, propertySchema := range schema.Properties {
+        p := describe(propertySchema)
+        p.Name = property
+        p.Required = contains(schema.Required, property)
+        s.Properties = append(s.Properties, p)
+      }
+      sort.Slice(s.Properties, func(i, j int) bool { return s.Properties[i].Name < s.Properties[j].Name })
+      schemas = append(schemas, s)
+    }
+    sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+
+    app.Render(c, http.StatusOK, "apidocs.html", gin.H{
+      "title": doc.Info.Title + " api " + doc.Info.Version,
+      "description": doc.Info.Description,
+      "server": Prefix,
+      "tags": tags,
+      "schemas": schemas,
+    })
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// Readable type of a schema, eg. array of Client or string (uuid)
+func describe(schema *Schema) docsProperty {
+  if schema == nil {
+    return docsProperty{}
+  }
+
+  if schema.Ref != "" {
+    return docsProperty{Type: schema.Name(), Schema: schema.Name()}
+  }
+
+  if schema.Type == "array" && schema.Items != nil {
+    items := describe(schema.Items)
+    items.Type = "array of " + items.Type
+    return items
+  }
+
+  var details []string
+  if schema.Format != "" {
+    details = append(details, schema.Format)
+  }
+  if len(schema.Enum) > 0 {
+    details = append(details, strings.Join(schema.Enum, " | "))
+  }
+  if schema.Minimum != nil {
+    op := ">= "
+    if schema.ExclusiveMinimum {
+      op = "> "
+    }
+    details = append(details, op + strconv.FormatFloat(*schema.Minimum, 'f', -1, 64))
+  }
+
+  t := schema.Type
+  if len(details) > 0 {
+    t = t + " (" + strings.Join(details, ", ") + ")"
+  }
+  return docsProperty{Type: t}
+}
+
+func contains(values []string, value string) bool {
+  for _, v := range values {
+    if v == value {
+      return true
+    }
+  }
+  return false
+}
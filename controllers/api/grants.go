@@ -0,0 +1,132 @@
+package api
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  aap "github.com/opensentry/aap/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// GET /api/v1/grants?identity_id=&publisher_id=, publisher_id is optional
+func GetGrants(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetGrants",
+    })
+
+    var query meui.ReadGrantsRequest
+    if !bindQuery(c, &query) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Grants
+    status, responses, err := aap.ReadGrants(aapClient, url, []aap.ReadGrantsRequest{ {Identity: query.Identity, Publisher: query.Publisher} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    grants := aap.ReadGrantsResponse{}
+    if !readList(c, responses, &grants) {
+      return
+    }
+
+    c.JSON(http.StatusOK, grants)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/grants. Like the grants page on_behalf_of_id defaults to the publisher.
+func PostGrants(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostGrants",
+    })
+
+    var input meui.CreateGrantsRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    if input.OnBehalfOf == "" {
+      input.OnBehalfOf = input.Publisher
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Grants
+    status, responses, err := aap.CreateGrants(aapClient, url, []aap.CreateGrantsRequest{
+      {
+        Identity: input.Identity,
+        Scope: input.Scope,
+        Publisher: input.Publisher,
+        OnBehalfOf: input.OnBehalfOf,
+        NotBefore: input.NotBefore,
+        Expire: input.Expire,
+      },
+    })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var grant aap.CreateGrantsResponse
+    if !readItem(c, responses, &grant) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"identity_id": grant.Identity, "scope": grant.Scope}).Debug("Grant created")
+    app.EmitEvent(env, c, webhooks.GrantCreated, grant)
+    c.JSON(http.StatusCreated, grant)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// DELETE /api/v1/grants?identity_id=&scope=&publisher_id=&on_behalf_of_id=, on_behalf_of_id defaults to the publisher
+func DeleteGrants(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "DeleteGrants",
+    })
+
+    var query meui.DeleteGrantsRequest
+    if !bindQuery(c, &query) {
+      return
+    }
+
+    if query.OnBehalfOf == "" {
+      query.OnBehalfOf = query.Publisher
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Grants
+    request := aap.DeleteGrantsRequest{Identity: query.Identity, Scope: query.Scope, Publisher: query.Publisher, OnBehalfOf: query.OnBehalfOf}
+    status, responses, err := aap.DeleteGrants(aapClient, url, []aap.DeleteGrantsRequest{request})
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var deleted aap.DeleteGrantsResponse
+    if !readItem(c, responses, &deleted) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"identity_id": query.Identity, "scope": query.Scope}).Debug("Grant deleted")
+    app.EmitEvent(env, c, webhooks.GrantDeleted, request)
+    c.Status(http.StatusNoContent)
+  }
+  return gin.HandlerFunc(fn)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+  "time"
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  idp "github.com/opensentry/idp/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/ratelimit"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// GET /api/v1/invites
+func GetInvites(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetInvites",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Invites
+    status, responses, err := idp.ReadInvites(idpClient, url, nil)
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    invites := idp.ReadInvitesResponse{}
+    if !readList(c, responses, &invites) {
+      return
+    }
+
+    c.JSON(http.StatusOK, invites)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// GET /api/v1/invites/:id
+func GetInvite(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetInvite",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Invites
+    status, responses, err := idp.ReadInvites(idpClient, url, []idp.ReadInvitesRequest{ {Id: c.Param("id")} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var invites idp.ReadInvitesResponse
+    if !readItem(c, responses, &invites) {
+      return
+    }
+
+    if len(invites) == 0 {
+      c.AbortWithStatus(http.StatusNotFound)
+      return
+    }
+
+    c.JSON(http.StatusOK, invites[0])
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/invites, exp is a unix timestamp and optional
+func PostInvites(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostInvites",
+    })
+
+    var input meui.CreateInvitesRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Invites
+    status, responses, err := idp.CreateInvites(idpClient, url, []idp.CreateInvitesRequest{
+      {Email: input.Email, Username: input.Username, ExpiresAt: input.ExpiresAt},
+    })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var invite idp.CreateInvitesResponse
+    if !readItem(c, responses, &invite) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"id": invite.Id}).Debug("Invite created")
+    app.EmitEvent(env, c, webhooks.InviteCreated, invite)
+    c.JSON(http.StatusCreated, invite)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/invites/:id/send e-mails the invite
+func PostInviteSend(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostInviteSend",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+    cfg := config.Get()
+
+    // Revoked, expired and accepted invites are not sent, nor those sent a moment ago
+    status, invites, err := env.Invites.Read(idpClient, cfg.Idp.Invites, cfg.Idp.Humans, []idp.ReadInvitesRequest{ {Id: c.Param("id")} })
+    if !checkResponse(c, log, cfg.Idp.Invites, status, err) {
+      return
+    }
+    if len(invites) == 0 {
+      c.AbortWithStatus(http.StatusNotFound)
+      return
+    }
+    if err := invites[0].CanSend(time.Now(), cfg.Invites.ResendInterval); err != nil {
+      app.AbortWithError(c, http.StatusConflict, err.Error())
+      return
+    }
+
+    url := cfg.Idp.InvitesSend
+    status, responses, err := idp.CreateInvitesSend(idpClient, url, []idp.CreateInvitesSendRequest{ {Id: c.Param("id")} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var invite idp.CreateInvitesSendResponse
+    if !readItem(c, responses, &invite) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"id": invite.Id}).Debug("Invite sent")
+    ratelimit.Spend(c)
+    err = env.Invites.RecordSend(invite.Id, time.Now())
+    if err != nil {
+      log.Debug(err.Error())
+    }
+    app.EmitEvent(env, c, webhooks.InviteSent, invite)
+    c.JSON(http.StatusOK, invite)
+  }
+  return gin.HandlerFunc(fn)
+}
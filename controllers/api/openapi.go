@@ -0,0 +1,360 @@
+package api
+
+import (
+  "path"
+  "net/http"
+  "reflect"
+  "runtime"
+  "strconv"
+  "strings"
+  "sync"
+  "github.com/gin-gonic/gin"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/environment"
+)
+
+// Document is an OpenAPI 3 document, only the parts meui uses
+type Document struct {
+  OpenApi string `json:"openapi"`
+  Info Info `json:"info"`
+  Servers []Server `json:"servers"`
+  Tags []Tag `json:"tags"`
+  Security []map[string][]string `json:"security"`
+  Paths map[string]map[string]*Operation `json:"paths"`
+  Components Components `json:"components"`
+}
+
+type Info struct {
+  Title string `json:"title"`
+  Description string `json:"description"`
+  Version string `json:"version"`
+}
+
+type Server struct {
+  Url string `json:"url"`
+}
+
+type Tag struct {
+  Name string `json:"name"`
+}
+
+type Operation struct {
+  OperationId string `json:"operationId"`
+  Summary string `json:"summary"`
+  Tags []string `json:"tags"`
+  Parameters []Parameter `json:"parameters,omitempty"`
+  RequestBody *RequestBody `json:"requestBody,omitempty"`
+  Responses map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+  Name string `json:"name"`
+  In string `json:"in"`
+  Required bool `json:"required"`
+  Schema *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+  Required bool `json:"required"`
+  Content map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+  Ref string `json:"$ref,omitempty"`
+  Description string `json:"description,omitempty"`
+  Content map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+  Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+  Ref string `json:"$ref,omitempty"`
+  Type string `json:"type,omitempty"`
+  Format string `json:"format,omitempty"`
+  Enum []string `json:"enum,omitempty"`
+  Minimum *float64 `json:"minimum,omitempty"`
+  ExclusiveMinimum bool `json:"exclusiveMinimum,omitempty"`
+  Items *Schema `json:"items,omitempty"`
+  Properties map[string]*Schema `json:"properties,omitempty"`
+  AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+  Required []string `json:"required,omitempty"`
+}
+
+type Components struct {
+  Schemas map[string]*Schema `json:"schemas"`
+  Responses map[string]*Response `json:"responses"`
+  SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+  Type string `json:"type"`
+  Scheme string `json:"scheme"`
+}
+
+// Name of the schema of a type, the type name
+func (schema *Schema) Name() string {
+  return strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+}
+
+var (
+  openApi *Document
+  openApiOnce sync.Once
+)
+
+// OpenApi is the document of Routes. It is generated once, the routes do not change while meui runs.
+func OpenApi() *Document {
+  openApiOnce.Do(func() {
+    openApi = NewDocument(Routes)
+  })
+  return openApi
+}
+
+// NewDocument generates the OpenAPI document of routes from the types in the route table. Validate tags become required fields,
+// formats and enums, so the contract says what the api rejects.
+func NewDocument(routes []Route) *Document {
+  schemas := make(map[string]*Schema)
+  names := make(map[reflect.Type]string)
+
+  doc := &Document{
+    OpenApi: "3.0.3",
+    Info: Info{
+      Title: "meui",
+      Description: "Manage the identity, clients, resource servers and access of the human signed in. Requests are authenticated by the access token of the human as bearer token.",
+      Version: strings.TrimPrefix(Prefix, "/api/"),
+    },
+    Servers: []Server{ {Url: Prefix} },
+    Security: []map[string][]string{ {"bearer": {}} },
+    Paths: make(map[string]map[string]*Operation),
+    Components: Components{
+      Schemas: schemas,
+      Responses: map[string]*Response{
+        "Error": {
+          Description: "The request failed, message says why and errors which fields or items of the request were rejected",
+          Content: map[string]MediaType{"application/json": {Schema: newSchema(reflect.TypeOf(meui.Error{}), schemas, names)}},
+        },
+      },
+      SecuritySchemes: map[string]SecurityScheme{"bearer": {Type: "http", Scheme: "bearer"}},
+    },
+  }
+
+  for _, route := range routes {
+    p, params := openApiPath(route.Path)
+
+    op := &Operation{
+      OperationId: handlerName(route.Handler),
+      Summary: route.Summary,
+      Tags: []string{route.Tag},
+      Parameters: params,
+      Responses: make(map[string]*Response),
+    }
+
+    if route.Query != nil {
+      op.Parameters = append(op.Parameters, queryParameters(reflect.TypeOf(route.Query), schemas, names)...)
+    }
+
+    if route.Body != nil {
+      op.RequestBody = &RequestBody{
+        Required: true,
+        Content: map[string]MediaType{"application/json": {Schema: newSchema(reflect.TypeOf(route.Body), schemas, names)}},
+      }
+    }
+
+    response := &Response{Description: http.StatusText(route.Status)}
+    if route.Response != nil {
+      response.Content = map[string]MediaType{"application/json": {Schema: newSchema(reflect.TypeOf(route.Response), schemas, names)}}
+    }
+    op.Responses[strconv.Itoa(route.Status)] = response
+
+    errors := []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests, http.StatusBadGateway}
+    if route.Query != nil || route.Body != nil {
+      errors = append(errors, http.StatusBadRequest)
+    }
+    errors = append(errors, route.Errors...)
+    for _, status := range errors {
+      op.Responses[strconv.Itoa(status)] = &Response{Ref: "#/components/responses/Error"}
+    }
+
+    if doc.Paths[p] == nil {
+      doc.Paths[p] = make(map[string]*Operation)
+    }
+    doc.Paths[p][strings.ToLower(route.Method)] = op
+
+    if len(doc.Tags) == 0 || doc.Tags[len(doc.Tags) - 1].Name != route.Tag {
+      doc.Tags = append(doc.Tags, Tag{Name: route.Tag})
+    }
+  }
+
+  return doc
+}
+
+// GET /api/openapi.json
+func GetOpenApi(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+    c.JSON(http.StatusOK, OpenApi())
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// Turns gin parameters into openapi ones, eg. /clients/:id into /clients/{id}
+func openApiPath(route string) (string, []Parameter) {
+  var params []Parameter
+  parts := strings.Split(route, "/")
+  for i, part := range parts {
+    if strings.HasPrefix(part, ":") {
+      name := part[1:]
+      parts[i] = "{" + name + "}"
+      params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+    }
+  }
+  return strings.Join(parts, "/"), params
+}
+
+func handlerName(handler interface{}) string {
+  name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+  return name[strings.LastIndex(name, ".") + 1:]
+}
+
+func queryParameters(t reflect.Type, schemas map[string]*Schema, names map[reflect.Type]string) (params []Parameter) {
+  for i := 0; i < t.NumField(); i++ {
+    field := t.Field(i)
+    name := strings.SplitN(field.Tag.Get("form"), ",", 2)[0]
+    if name == "" || name == "-" {
+      continue
+    }
+
+    schema := newSchema(field.Type, schemas, names)
+    required := applyValidation(schema, field.Tag.Get("validate"))
+    params = append(params, Parameter{Name: name, In: "query", Required: required, Schema: schema})
+  }
+  return params
+}
+
+// Schema of t, structs are added to schemas and referenced by name
+func newSchema(t reflect.Type, schemas map[string]*Schema, names map[reflect.Type]string) *Schema {
+  for t.Kind() == reflect.Ptr {
+    t = t.Elem()
+  }
+
+  switch t.Kind() {
+  case reflect.String:
+    return &Schema{Type: "string"}
+  case reflect.Bool:
+    return &Schema{Type: "boolean"}
+  case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+    return &Schema{Type: "integer"}
+  case reflect.Int64, reflect.Uint64:
+    return &Schema{Type: "integer", Format: "int64"}
+  case reflect.Float32, reflect.Float64:
+    return &Schema{Type: "number"}
+  case reflect.Slice, reflect.Array:
+    return &Schema{Type: "array", Items: newSchema(t.Elem(), schemas, names)}
+  case reflect.Map:
+    return &Schema{Type: "object", AdditionalProperties: newSchema(t.Elem(), schemas, names)}
+  case reflect.Struct:
+    return &Schema{Ref: "#/components/schemas/" + structSchema(t, schemas, names)}
+  }
+  return &Schema{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]*Schema, names map[reflect.Type]string) string {
+  if name, exists := names[t]; exists {
+    return name
+  }
+
+  // Types of idp and aap may share names with each other or with meui, the first one gets the plain name
+  name := t.Name()
+  if _, exists := schemas[name]; exists {
+    pkg := path.Dir(t.PkgPath()) // eg. github.com/opensentry/idp of github.com/opensentry/idp/client
+    name = strings.Title(path.Base(pkg)) + name
+  }
+
+  schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+  names[t] = name
+  schemas[name] = schema
+
+  for i := 0; i < t.NumField(); i++ {
+    field := t.Field(i)
+    if field.PkgPath != "" {
+      continue // Unexported
+    }
+
+    name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+    if name == "-" {
+      continue
+    }
+    if name == "" {
+      name = field.Name
+    }
+
+    property := newSchema(field.Type, schemas, names)
+    if applyValidation(property, field.Tag.Get("validate")) {
+      schema.Required = append(schema.Required, name)
+    }
+    schema.Properties[name] = property
+  }
+
+  return name
+}
+
+// Describes the validate tag in schema, what follows dive applies to the items. Returns true if the value is required.
+func applyValidation(schema *Schema, tag string) (required bool) {
+  target := schema
+  for _, rule := range strings.Split(tag, ",") {
+    if rule == "" {
+      continue
+    }
+
+    if rule == "dive" {
+      if target.Items == nil {
+        return required
+      }
+      target = target.Items
+      continue
+    }
+
+    if rule == "required" && target == schema {
+      required = true
+      continue
+    }
+
+    if enum, ok := enumRule(rule); ok && target.Type == "string" {
+      target.Enum = enum
+      continue
+    }
+
+    name, param := rule, ""
+    if i := strings.Index(rule, "="); i >= 0 {
+      name, param = rule[:i], rule[i + 1:]
+    }
+
+    switch name {
+    case "email":
+      target.Format = "email"
+    case "uuid", "identityid":
+      target.Format = "uuid"
+    case "url", "uri", "redirecturi":
+      target.Format = "uri"
+    case "gt", "gte":
+      if n, err := strconv.ParseFloat(param, 64); err == nil && target.Type != "string" && target.Type != "array" {
+        target.Minimum = &n
+        target.ExclusiveMinimum = name == "gt"
+      }
+    }
+  }
+  return required
+}
+
+// eq=code|eq=token is an enum, alternatives of other rules are not described
+func enumRule(rule string) ([]string, bool) {
+  var enum []string
+  for _, alternative := range strings.Split(rule, "|") {
+    if !strings.HasPrefix(alternative, "eq=") {
+      return nil, false
+    }
+    enum = append(enum, strings.TrimPrefix(alternative, "eq="))
+  }
+  return enum, true
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  idp "github.com/opensentry/idp/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+)
+
+func newProfile(human idp.Human) meui.Profile {
+  return meui.Profile{
+    Id: human.Id,
+    Username: human.Username,
+    Name: human.Name,
+    Email: human.Email,
+    EmailConfirmedAt: human.EmailConfirmedAt,
+    TotpRequired: human.TotpRequired,
+  }
+}
+
+// GET /api/v1/profile
+func GetProfile(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    c.JSON(http.StatusOK, newProfile(*identity))
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// PATCH /api/v1/profile, like the edit profile page only the name can be changed
+func PatchProfile(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PatchProfile",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    var input meui.UpdateProfileRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Humans
+    status, responses, err := idp.UpdateHumans(idpClient, url, []idp.UpdateHumansRequest{ {Id: identity.Id, Name: input.Name} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var human idp.UpdateHumansResponse
+    if !readItem(c, responses, &human) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"id": human.Id}).Debug("Human updated")
+    c.JSON(http.StatusOK, newProfile(idp.Human(human)))
+  }
+  return gin.HandlerFunc(fn)
+}
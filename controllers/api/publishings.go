@@ -0,0 +1,80 @@
+package api
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  aap "github.com/opensentry/aap/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+)
+
+// GET /api/v1/publishings?publisher_id=, the scopes the resource server publishes
+func GetPublishings(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetPublishings",
+    })
+
+    var query meui.ReadPublishingsRequest
+    if !bindQuery(c, &query) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Publishes
+    status, responses, err := aap.ReadPublishes(aapClient, url, []aap.ReadPublishesRequest{ {Publisher: query.Publisher} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    publishes := aap.ReadPublishesResponse{}
+    if !readList(c, responses, &publishes) {
+      return
+    }
+
+    c.JSON(http.StatusOK, publishes)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/publishings
+func PostPublishings(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostPublishings",
+    })
+
+    var input meui.CreatePublishingsRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Publishes
+    status, responses, err := aap.CreatePublishes(aapClient, url, []aap.CreatePublishesRequest{
+      {Publisher: input.Publisher, Scope: input.Scope, Title: input.Title, Description: input.Description},
+    })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var publish aap.CreatePublishesResponse
+    if !readItem(c, responses, &publish) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"publisher_id": publish.Publisher, "scope": publish.Scope}).Debug("Scope published")
+    c.JSON(http.StatusCreated, publish)
+  }
+  return gin.HandlerFunc(fn)
+}
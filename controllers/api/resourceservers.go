@@ -0,0 +1,135 @@
+package api
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  idp "github.com/opensentry/idp/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+)
+
+// GET /api/v1/resourceservers
+func GetResourceServers(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetResourceServers",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.ResourceServers
+    status, responses, err := idp.ReadResourceServers(idpClient, url, nil)
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    resourceServers := idp.ReadResourceServersResponse{}
+    if !readList(c, responses, &resourceServers) {
+      return
+    }
+
+    c.JSON(http.StatusOK, resourceServers)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// GET /api/v1/resourceservers/:id
+func GetResourceServer(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetResourceServer",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.ResourceServers
+    status, responses, err := idp.ReadResourceServers(idpClient, url, []idp.ReadResourceServersRequest{ {Id: c.Param("id")} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var resourceServers idp.ReadResourceServersResponse
+    if !readItem(c, responses, &resourceServers) {
+      return
+    }
+
+    if len(resourceServers) == 0 {
+      c.AbortWithStatus(http.StatusNotFound)
+      return
+    }
+
+    c.JSON(http.StatusOK, resourceServers[0])
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/resourceservers
+func PostResourceServers(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostResourceServers",
+    })
+
+    var input meui.CreateResourceServersRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.ResourceServers
+    status, responses, err := idp.CreateResourceServers(idpClient, url, []idp.CreateResourceServersRequest{
+      {Name: input.Name, Description: input.Description, Audience: input.Audience},
+    })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var resourceServer idp.CreateResourceServersResponse
+    if !readItem(c, responses, &resourceServer) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"id": resourceServer.Id}).Debug("Resource server created")
+    c.JSON(http.StatusCreated, resourceServer)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// DELETE /api/v1/resourceservers/:id
+func DeleteResourceServer(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "DeleteResourceServer",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.ResourceServers
+    status, responses, err := idp.DeleteResourceServers(idpClient, url, []idp.DeleteResourceServersRequest{ {Id: c.Param("id")} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var deleted idp.DeleteResourceServersResponse
+    if !readItem(c, responses, &deleted) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"id": c.Param("id")}).Debug("Resource server deleted")
+    c.Status(http.StatusNoContent)
+  }
+  return gin.HandlerFunc(fn)
+}
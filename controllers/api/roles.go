@@ -0,0 +1,138 @@
+package api
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  idp "github.com/opensentry/idp/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// GET /api/v1/roles
+func GetRoles(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetRoles",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Roles
+    status, responses, err := idp.ReadRoles(idpClient, url, nil)
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    roles := idp.ReadRolesResponse{}
+    if !readList(c, responses, &roles) {
+      return
+    }
+
+    c.JSON(http.StatusOK, roles)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// GET /api/v1/roles/:id
+func GetRole(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetRole",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Roles
+    status, responses, err := idp.ReadRoles(idpClient, url, []idp.ReadRolesRequest{ {Id: c.Param("id")} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var roles idp.ReadRolesResponse
+    if !readItem(c, responses, &roles) {
+      return
+    }
+
+    if len(roles) == 0 {
+      c.AbortWithStatus(http.StatusNotFound)
+      return
+    }
+
+    c.JSON(http.StatusOK, roles[0])
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/roles
+func PostRoles(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostRoles",
+    })
+
+    var input meui.CreateRolesRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Roles
+    status, responses, err := idp.CreateRoles(idpClient, url, []idp.CreateRolesRequest{
+      {Name: input.Name, Description: input.Description},
+    })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var role idp.CreateRolesResponse
+    if !readItem(c, responses, &role) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"id": role.Id}).Debug("Role created")
+    app.EmitEvent(env, c, webhooks.RoleCreated, role)
+    c.JSON(http.StatusCreated, role)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// DELETE /api/v1/roles/:id
+func DeleteRole(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "DeleteRole",
+    })
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Idp.Roles
+    status, responses, err := idp.DeleteRoles(idpClient, url, []idp.DeleteRolesRequest{ {Id: c.Param("id")} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var deleted idp.DeleteRolesResponse
+    if !readItem(c, responses, &deleted) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"id": c.Param("id")}).Debug("Role deleted")
+    app.EmitEvent(env, c, webhooks.RoleDeleted, deleted)
+    c.Status(http.StatusNoContent)
+  }
+  return gin.HandlerFunc(fn)
+}
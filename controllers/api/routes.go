@@ -0,0 +1,111 @@
+package api
+
+import (
+  "net/http"
+  "github.com/gin-gonic/gin"
+  aap "github.com/opensentry/aap/client"
+  idp "github.com/opensentry/idp/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/environment"
+)
+
+const (
+  // Prefix is where the routes are served
+  Prefix = "/api/v1"
+
+  LimitDefault = "default"
+  LimitInvites = "invites"
+  LimitInvitesSend = "invites.send"
+  LimitClients = "clients"
+)
+
+// Route is an operation of the api. The route table is what main registers and what the openapi document is generated from,
+// so Query, Body and Response must be the types the handler binds and answers with.
+type Route struct {
+  Method string
+  Path string // Relative to Prefix, gin syntax, eg. /clients/:id
+  Tag string
+  Summary string
+  Query interface{} // Struct bound from the query by form tag
+  Body interface{} // Struct bound from the json body
+  Response interface{} // Answered with Status, nil if there is no body
+  Status int
+  Errors []int // Error statuses the handler answers besides those every route may, eg. 409
+  Limit string // Rate limit of writes, one of the Limit constants. Every route is limited by the api limiter as well.
+  Handler func(env *environment.State) gin.HandlerFunc
+}
+
+var Routes = []Route{
+  {Method: http.MethodGet, Path: "/profile", Tag: "Profile", Summary: "Read the profile of the human signed in",
+    Response: meui.Profile{}, Status: http.StatusOK, Handler: GetProfile},
+  {Method: http.MethodPatch, Path: "/profile", Tag: "Profile", Summary: "Change the name of the human signed in",
+    Body: meui.UpdateProfileRequest{}, Response: meui.Profile{}, Status: http.StatusOK, Limit: LimitDefault, Handler: PatchProfile},
+
+  {Method: http.MethodGet, Path: "/invites", Tag: "Invites", Summary: "List invites",
+    Response: []idp.Invite{}, Status: http.StatusOK, Handler: GetInvites},
+  {Method: http.MethodPost, Path: "/invites", Tag: "Invites", Summary: "Create an invite",
+    Body: meui.CreateInvitesRequest{}, Response: idp.Invite{}, Status: http.StatusCreated, Limit: LimitInvites, Handler: PostInvites},
+  {Method: http.MethodGet, Path: "/invites/:id", Tag: "Invites", Summary: "Read an invite",
+    Response: idp.Invite{}, Status: http.StatusOK, Handler: GetInvite},
+  {Method: http.MethodPost, Path: "/invites/:id/send", Tag: "Invites", Summary: "E-mail an invite unless it is revoked, expired, accepted or was sent within invites.resend.interval",
+    Response: idp.Invite{}, Status: http.StatusOK, Errors: []int{http.StatusConflict}, Limit: LimitInvitesSend, Handler: PostInviteSend},
+
+  {Method: http.MethodGet, Path: "/clients", Tag: "Clients", Summary: "List clients",
+    Response: []idp.Client{}, Status: http.StatusOK, Handler: GetClients},
+  {Method: http.MethodPost, Path: "/clients", Tag: "Clients", Summary: "Create a client, the secret is only returned here",
+    Body: meui.CreateClientsRequest{}, Response: idp.Client{}, Status: http.StatusCreated, Limit: LimitClients, Handler: PostClients},
+  {Method: http.MethodGet, Path: "/clients/:id", Tag: "Clients", Summary: "Read a client",
+    Response: idp.Client{}, Status: http.StatusOK, Handler: GetClient},
+  {Method: http.MethodDelete, Path: "/clients/:id", Tag: "Clients", Summary: "Delete a client",
+    Status: http.StatusNoContent, Limit: LimitClients, Handler: DeleteClient},
+
+  {Method: http.MethodGet, Path: "/resourceservers", Tag: "Resource servers", Summary: "List resource servers",
+    Response: []idp.ResourceServer{}, Status: http.StatusOK, Handler: GetResourceServers},
+  {Method: http.MethodPost, Path: "/resourceservers", Tag: "Resource servers", Summary: "Create a resource server",
+    Body: meui.CreateResourceServersRequest{}, Response: idp.ResourceServer{}, Status: http.StatusCreated, Limit: LimitDefault, Handler: PostResourceServers},
+  {Method: http.MethodGet, Path: "/resourceservers/:id", Tag: "Resource servers", Summary: "Read a resource server",
+    Response: idp.ResourceServer{}, Status: http.StatusOK, Handler: GetResourceServer},
+  {Method: http.MethodDelete, Path: "/resourceservers/:id", Tag: "Resource servers", Summary: "Delete a resource server",
+    Status: http.StatusNoContent, Limit: LimitDefault, Handler: DeleteResourceServer},
+
+  {Method: http.MethodGet, Path: "/scopes", Tag: "Scopes", Summary: "List scopes",
+    Response: []aap.Scope{}, Status: http.StatusOK, Handler: GetScopes},
+  {Method: http.MethodPost, Path: "/scopes", Tag: "Scopes", Summary: "Create a scope",
+    Body: meui.CreateScopesRequest{}, Response: aap.Scope{}, Status: http.StatusCreated, Limit: LimitDefault, Handler: PostScopes},
+
+  {Method: http.MethodGet, Path: "/publishings", Tag: "Publishings", Summary: "List the scopes a resource server publishes",
+    Query: meui.ReadPublishingsRequest{}, Response: []aap.Publish{}, Status: http.StatusOK, Handler: GetPublishings},
+  {Method: http.MethodPost, Path: "/publishings", Tag: "Publishings", Summary: "Publish a scope",
+    Body: meui.CreatePublishingsRequest{}, Response: aap.Publish{}, Status: http.StatusCreated, Limit: LimitDefault, Handler: PostPublishings},
+
+  {Method: http.MethodGet, Path: "/grants", Tag: "Grants", Summary: "List the grants of an identity",
+    Query: meui.ReadGrantsRequest{}, Response: []aap.Grant{}, Status: http.StatusOK, Handler: GetGrants},
+  {Method: http.MethodPost, Path: "/grants", Tag: "Grants", Summary: "Grant a scope",
+    Body: meui.CreateGrantsRequest{}, Response: aap.Grant{}, Status: http.StatusCreated, Limit: LimitDefault, Handler: PostGrants},
+  {Method: http.MethodDelete, Path: "/grants", Tag: "Grants", Summary: "Revoke a grant",
+    Query: meui.DeleteGrantsRequest{}, Status: http.StatusNoContent, Limit: LimitDefault, Handler: DeleteGrants},
+
+  {Method: http.MethodGet, Path: "/subscriptions", Tag: "Subscriptions", Summary: "List the subscriptions of a client",
+    Query: meui.ReadSubscriptionsRequest{}, Response: []aap.Subscription{}, Status: http.StatusOK, Handler: GetSubscriptions},
+  {Method: http.MethodPost, Path: "/subscriptions", Tag: "Subscriptions", Summary: "Subscribe to a scope",
+    Body: meui.CreateSubscriptionsRequest{}, Response: aap.Subscription{}, Status: http.StatusCreated, Limit: LimitDefault, Handler: PostSubscriptions},
+  {Method: http.MethodDelete, Path: "/subscriptions", Tag: "Subscriptions", Summary: "Unsubscribe from a scope",
+    Query: meui.DeleteSubscriptionsRequest{}, Status: http.StatusNoContent, Limit: LimitDefault, Handler: DeleteSubscriptions},
+
+  {Method: http.MethodGet, Path: "/roles", Tag: "Roles", Summary: "List roles",
+    Response: []idp.Role{}, Status: http.StatusOK, Handler: GetRoles},
+  {Method: http.MethodPost, Path: "/roles", Tag: "Roles", Summary: "Create a role",
+    Body: meui.CreateRolesRequest{}, Response: idp.Role{}, Status: http.StatusCreated, Limit: LimitDefault, Handler: PostRoles},
+  {Method: http.MethodGet, Path: "/roles/:id", Tag: "Roles", Summary: "Read a role",
+    Response: idp.Role{}, Status: http.StatusOK, Handler: GetRole},
+  {Method: http.MethodDelete, Path: "/roles/:id", Tag: "Roles", Summary: "Delete a role",
+    Status: http.StatusNoContent, Limit: LimitDefault, Handler: DeleteRole},
+
+  {Method: http.MethodGet, Path: "/shadows", Tag: "Shadows", Summary: "List shadows by identity or by shadow",
+    Query: meui.ReadShadowsRequest{}, Response: []aap.Shadow{}, Status: http.StatusOK, Handler: GetShadows},
+  {Method: http.MethodPost, Path: "/shadows", Tag: "Shadows", Summary: "Let an identity shadow another",
+    Body: meui.CreateShadowsRequest{}, Response: aap.Shadow{}, Status: http.StatusCreated, Limit: LimitDefault, Handler: PostShadows},
+  {Method: http.MethodDelete, Path: "/shadows", Tag: "Shadows", Summary: "Delete a shadow",
+    Query: meui.DeleteShadowsRequest{}, Status: http.StatusNoContent, Limit: LimitDefault, Handler: DeleteShadows},
+}
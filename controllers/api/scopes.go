@@ -0,0 +1,73 @@
+package api
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  aap "github.com/opensentry/aap/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+)
+
+// GET /api/v1/scopes
+func GetScopes(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetScopes",
+    })
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Scopes
+    status, responses, err := aap.ReadScopes(aapClient, url, nil)
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    scopes := aap.ReadScopesResponse{}
+    if !readList(c, responses, &scopes) {
+      return
+    }
+
+    c.JSON(http.StatusOK, scopes)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/scopes
+func PostScopes(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostScopes",
+    })
+
+    var input meui.CreateScopesRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Scopes
+    status, responses, err := aap.CreateScopes(aapClient, url, []aap.CreateScopesRequest{ {Scope: input.Scope} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var scope aap.CreateScopesResponse
+    if !readItem(c, responses, &scope) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"scope": scope.Scope}).Debug("Scope created")
+    c.JSON(http.StatusCreated, scope)
+  }
+  return gin.HandlerFunc(fn)
+}
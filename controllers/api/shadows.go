@@ -0,0 +1,116 @@
+package api
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  aap "github.com/opensentry/aap/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// GET /api/v1/shadows?identity_id=&shadow_id=
+func GetShadows(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetShadows",
+    })
+
+    var query meui.ReadShadowsRequest
+    if !bindQuery(c, &query) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Shadows
+    status, responses, err := aap.ReadShadows(aapClient, url, []aap.ReadShadowsRequest{ {Identity: query.Identity, Shadow: query.Shadow} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    shadows := aap.ReadShadowsResponse{}
+    if !readList(c, responses, &shadows) {
+      return
+    }
+
+    c.JSON(http.StatusOK, shadows)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/shadows, the identity gets the grants of the shadow from nbf until exp
+func PostShadows(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostShadows",
+    })
+
+    var input meui.CreateShadowsRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Shadows
+    status, responses, err := aap.CreateShadows(aapClient, url, []aap.CreateShadowsRequest{
+      {Identity: input.Identity, Shadow: input.Shadow, NotBefore: input.NotBefore, Expire: input.Expire},
+    })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var shadow aap.CreateShadowsResponse
+    if !readItem(c, responses, &shadow) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"identity_id": shadow.Identity, "shadow_id": shadow.Shadow}).Debug("Shadow created")
+    app.EmitEvent(env, c, webhooks.ShadowCreated, shadow)
+    c.JSON(http.StatusCreated, shadow)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// DELETE /api/v1/shadows?identity_id=&shadow_id=
+func DeleteShadows(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "DeleteShadows",
+    })
+
+    var query meui.DeleteShadowsRequest
+    if !bindQuery(c, &query) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Shadows
+    status, responses, err := aap.DeleteShadows(aapClient, url, []aap.DeleteShadowsRequest{ {Identity: query.Identity, Shadow: query.Shadow} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var deleted aap.DeleteShadowsResponse
+    if !readItem(c, responses, &deleted) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"identity_id": query.Identity, "shadow_id": query.Shadow}).Debug("Shadow deleted")
+    app.EmitEvent(env, c, webhooks.ShadowDeleted, deleted)
+    c.Status(http.StatusNoContent)
+  }
+  return gin.HandlerFunc(fn)
+}
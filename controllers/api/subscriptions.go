@@ -0,0 +1,117 @@
+package api
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  aap "github.com/opensentry/aap/client"
+
+  meui "github.com/opensentry/meui/client"
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// GET /api/v1/subscriptions?subscriber_id=
+func GetSubscriptions(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "GetSubscriptions",
+    })
+
+    var query meui.ReadSubscriptionsRequest
+    if !bindQuery(c, &query) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Subscriptions
+    status, responses, err := aap.ReadSubscriptions(aapClient, url, []aap.ReadSubscriptionsRequest{ {Subscriber: query.Subscriber} })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    subscriptions := aap.ReadSubscriptionsResponse{}
+    if !readList(c, responses, &subscriptions) {
+      return
+    }
+
+    c.JSON(http.StatusOK, subscriptions)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// POST /api/v1/subscriptions
+func PostSubscriptions(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "PostSubscriptions",
+    })
+
+    var input meui.CreateSubscriptionsRequest
+    if !bindJson(c, &input) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Subscriptions
+    status, responses, err := aap.CreateSubscriptions(aapClient, url, []aap.CreateSubscriptionsRequest{
+      {Subscriber: input.Subscriber, Publisher: input.Publisher, Scope: input.Scope},
+    })
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var subscription aap.CreateSubscriptionsResponse
+    if !readItem(c, responses, &subscription) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"subscriber_id": subscription.Subscriber, "scope": subscription.Scope}).Debug("Subscription created")
+    app.EmitEvent(env, c, webhooks.SubscriptionCreated, subscription)
+    c.JSON(http.StatusCreated, subscription)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// DELETE /api/v1/subscriptions?subscriber_id=&publisher_id=&scope=
+func DeleteSubscriptions(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "DeleteSubscriptions",
+    })
+
+    var query meui.DeleteSubscriptionsRequest
+    if !bindQuery(c, &query) {
+      return
+    }
+
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    url := config.Get().Aap.Subscriptions
+    request := aap.DeleteSubscriptionsRequest{Subscriber: query.Subscriber, Publisher: query.Publisher, Scope: query.Scope}
+    status, responses, err := aap.DeleteSubscriptions(aapClient, url, []aap.DeleteSubscriptionsRequest{request})
+    if !checkResponse(c, log, url, status, err) {
+      return
+    }
+
+    var deleted aap.DeleteSubscriptionsResponse
+    if !readItem(c, responses, &deleted) {
+      return
+    }
+
+    log.WithFields(logrus.Fields{"subscriber_id": query.Subscriber, "scope": query.Scope}).Debug("Subscription deleted")
+    app.EmitEvent(env, c, webhooks.SubscriptionDeleted, request)
+    c.Status(http.StatusNoContent)
+  }
+  return gin.HandlerFunc(fn)
+}
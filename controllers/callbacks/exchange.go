@@ -63,7 +63,7 @@ func ExchangeAuthorizationCodeCallback(env *environment.State) gin.HandlerFunc {
     }
 
     // Found a code try and exchange it for access token.
-    token, err := env.HydraConfig.Exchange(context.Background(), code)
+    token, err := env.HydraConfig().Exchange(context.Background(), code)
     if err != nil {
       log.WithFields(logrus.Fields{"error": err.Error()}).Debug("Token exchange failed")
       c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
@@ -74,7 +74,7 @@ func ExchangeAuthorizationCodeCallback(env *environment.State) gin.HandlerFunc {
     if token.Valid() == true {
 
       // Look into session for redirect_to using state
-      var redirectTo string = config.GetString("oauth2.defaultRedirect")
+      var redirectTo string = config.Get().OAuth2.DefaultRedirect
       redirect := session.Get(sessionState)
       if redirect != nil {
         redirectTo = redirect.(string)
@@ -88,7 +88,7 @@ func ExchangeAuthorizationCodeCallback(env *environment.State) gin.HandlerFunc {
       }
 
       oidcConfig := &oidc.Config{
-        ClientID: config.GetString("oauth2.client.id"),
+        ClientID: config.Get().OAuth2.ClientId,
       }
       verifier := env.Provider.Verifier(oidcConfig)
 
@@ -1,45 +1,32 @@
 package clients
 
 import (
-  "strings"
   "net/http"
-  "reflect"
-  "gopkg.in/go-playground/validator.v9"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
-  "github.com/gin-contrib/sessions"
   idp "github.com/opensentry/idp/client"
 
-  "github.com/go-playground/form"
-
   bulky "github.com/charmixer/bulky/client"
 
   "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/webhooks"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
-  "github.com/opensentry/meui/utils"
-  "github.com/opensentry/meui/validators"
+  "github.com/opensentry/meui/forms"
 )
 
 type formInput struct {
   Name                    string   `validate:"required,notblank"`
   Description             string   `validate:"required,notblank"`
-  RedirectUri             []string
-  PostLogoutRedirectUri   []string
+  RedirectUri             []string `validate:"dive,omitempty,redirecturi"`
+  PostLogoutRedirectUri   []string `validate:"dive,omitempty,redirecturi"`
   TokenEndpointAuthMethod string
   GrantType               []string
   ResponseType            []string
   IsPublic                []string
 }
 
-const ClientFieldsKey = "client.fields"
-const ClientErrorsKey = "client.errors"
-const RestErrorsKey   = "rest.errors"
-
-const ClientNameKey = "Name"
-const ClientDescriptionKey = "Description"
-const ClientIsPublicKey = "IsPublic"
+var clientForm = forms.New("client")
 
 func ShowClient(env *environment.State) gin.HandlerFunc {
   fn := func(c *gin.Context) {
@@ -56,64 +43,13 @@ func ShowClient(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    session := sessions.Default(c)
-
-    var clientName string
-    var description string
-    rf := session.Flashes(ClientFieldsKey)
-    if len(rf) > 0 {
-      fields := rf[0].(map[string][]string)
-      for k, v := range fields {
-        if k == ClientDescriptionKey && len(v) > 0 {
-          description = strings.Join(v, ", ")
-        }
-        if k == ClientNameKey && len(v) > 0 {
-          clientName = strings.Join(v, ", ")
-        }
-      }
-    }
-
-    errors     := session.Flashes(ClientErrorsKey)
-    restErrors := session.Flashes(RestErrorsKey)
-    err := session.Save() // Remove flashes read, and save submit fields
-    if err != nil {
-      log.Debug(err.Error())
-    }
+    values, errors := clientForm.Populate(c, nil)
 
-    var errorName string
-    var errorDescription string
-
-    if len(errors) > 0 {
-      errorsMap := errors[0].(map[string][]string)
-      for k, v := range errorsMap {
-
-        if k == ClientNameKey && len(v) > 0 {
-          errorName = strings.Join(v, ", ")
-        }
-
-        if k == ClientDescriptionKey && len(v) > 0 {
-          errorDescription = strings.Join(v, ", ")
-        }
-
-      }
-    }
-
-    c.HTML(http.StatusOK, "client.html", gin.H{
+    app.Render(c, http.StatusOK, "client.html", gin.H{
       "title": "Client",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "provider": config.GetString("provider.name"),
-      "id": identity.Id,
-      "user": identity.Username,
-      "name": identity.Name,
-      ClientNameKey: clientName,
-      ClientDescriptionKey: description,
-      "errorName": errorName,
-      "errorDesecription": errorDescription,
-      "restErrors": restErrors,
-      "clientUrl": config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.client"),
+      "form": values,
+      "errors": errors,
+      "clientUrl": config.Get().Meui.Client,
     })
   }
   return gin.HandlerFunc(fn)
@@ -127,16 +63,23 @@ func SubmitClient(env *environment.State) gin.HandlerFunc {
       "func": "SubmitClient",
     })
 
-    var input formInput
-    c.Request.ParseForm()
-
-    decoder := form.NewDecoder()
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
 
-    // must pass a pointer
-    err := decoder.Decode(&input, c.Request.Form)
+    var input formInput
+    valid, err := clientForm.Bind(c, &input)
     if err != nil {
-      log.Panic(err)
-      c.AbortWithStatus(404)
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
+    }
+
+    if !valid {
+      clientForm.RedirectBack(c)
       return
     }
 
@@ -163,91 +106,9 @@ func SubmitClient(env *environment.State) gin.HandlerFunc {
       postLogoutRedirectUris = append(postLogoutRedirectUris, uri)
     }
 
-    identity := app.GetIdentity(c)
-    if identity == nil {
-      log.Debug("Missing Identity")
-      c.AbortWithStatus(http.StatusForbidden)
-      return
-    }
-
-    session := sessions.Default(c)
-
-    // Save values if submit fails
-    fields := make(map[string][]string)
-    fields[ClientNameKey] = append(fields[ClientNameKey], input.Name)
-    fields[ClientDescriptionKey] = append(fields[ClientDescriptionKey], input.Description)
-
-    session.AddFlash(fields, ClientFieldsKey)
-    err = session.Save()
-    if err != nil {
-      log.Debug(err.Error())
-    }
-
-    errors := make(map[string][]string)
-    validate := validator.New()
-    validate.RegisterValidation("notblank", validators.NotBlank)
-    err = validate.Struct(input)
-    if err != nil {
-
-      // Validation syntax is invalid
-      if err,ok := err.(*validator.InvalidValidationError); ok{
-        log.Debug(err.Error())
-        c.AbortWithStatus(http.StatusInternalServerError)
-        return
-      }
-
-      reflected := reflect.ValueOf(input) // Use reflector to reverse engineer struct
-      for _, err := range err.(validator.ValidationErrors){
-
-        // Attempt to find field by name and get json tag name
-        field,_ := reflected.Type().FieldByName(err.StructField())
-        var name string
-
-        // If form tag doesn't exist, use lower case of name
-        if name = field.Tag.Get("form"); name == ""{
-          name = strings.ToLower(err.StructField())
-        }
-
-        switch err.Tag() {
-        case "required":
-            errors[name] = append(errors[name], "Field is required")
-            break
-        case "eqfield":
-            errors[name] = append(errors[name], "Field should be equal to the "+err.Param())
-            break
-        case "notblank":
-          errors[name] = append(errors[name], "Field is not allowed to be blank")
-          break
-        default:
-            errors[name] = append(errors[name], "Field is invalid")
-            break
-        }
-      }
-
-    }
-
-    if len(errors) > 0 {
-      session.AddFlash(errors, ClientErrorsKey)
-      err = session.Save()
-      if err != nil {
-        log.Debug(err.Error())
-      }
-
-      submitUrl, err := utils.FetchSubmitUrlFromRequest(c.Request, nil)
-      if err != nil {
-        log.Debug(err.Error())
-        c.AbortWithStatus(http.StatusInternalServerError)
-        return
-      }
-      log.WithFields(logrus.Fields{"redirect_to": submitUrl}).Debug("Redirecting")
-      c.Redirect(http.StatusFound, submitUrl)
-      c.Abort()
-      return
-    }
-
     idpClient := app.IdpClientUsingAuthorizationCode(env, c)
 
-    status, responses, err := idp.CreateClients(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.clients.collection"), []idp.CreateClientsRequest{
+    status, responses, err := idp.CreateClients(idpClient, config.Get().Idp.Clients, []idp.CreateClientsRequest{
       {
         Name:                    input.Name,
         Description:             input.Description,
@@ -261,7 +122,7 @@ func SubmitClient(env *environment.State) gin.HandlerFunc {
     })
     if err != nil || status != 200 {
       log.Debug("Client create failed")
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -269,16 +130,10 @@ func SubmitClient(env *environment.State) gin.HandlerFunc {
     _, restErr := bulky.Unmarshal(0, responses, &createClientResponse)
 
     if restErr == nil {
-      // Cleanup session
-      session.Delete(ClientFieldsKey)
-      session.Delete(ClientErrorsKey)
-      session.Delete(RestErrorsKey)
-      err = session.Save()
-      if err != nil {
-        log.Debug(err.Error())
-      }
+      app.EmitEvent(env, c, webhooks.ClientCreated, webhooks.ClientData(idp.Client(createClientResponse)))
+      clientForm.Clear(c)
 
-      redirectTo := config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.clients.collection")
+      redirectTo := config.Get().Meui.Clients
       log.WithFields(logrus.Fields{"redirect_to": redirectTo}).Debug("Redirecting")
 
       c.Redirect(http.StatusFound, redirectTo)
@@ -286,16 +141,16 @@ func SubmitClient(env *environment.State) gin.HandlerFunc {
       return
     }
 
+    app.FlashRestErrors(c, 0, "", restErr, map[string]string{
+      "Name": "Name",
+      "Description": "Description",
+      "RedirectUris": "Redirect uris",
+      "PostLogoutRedirectUris": "Post logout redirect uris",
+      "TokenEndpointAuthMethod": "Token endpoint auth method",
+    })
+
     // Deny by default. Failed to fill in the form correctly.
-    submitUrl, err := utils.FetchSubmitUrlFromRequest(c.Request, nil)
-    if err != nil {
-      log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
-    }
-    log.WithFields(logrus.Fields{"redirect_to": submitUrl}).Debug("Redirecting")
-    c.Redirect(http.StatusFound, submitUrl)
-    c.Abort()
+    clientForm.RedirectBack(c)
   }
   return gin.HandlerFunc(fn)
 }
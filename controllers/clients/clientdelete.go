@@ -5,11 +5,11 @@ import (
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
   "github.com/gin-contrib/sessions"
   idp "github.com/opensentry/idp/client"
 
   "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/webhooks"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
   "github.com/opensentry/meui/utils"
@@ -50,10 +50,10 @@ func ShowClientDelete(env *environment.State) gin.HandlerFunc {
     var client *idp.Client
     idpClient := app.IdpClientUsingAuthorizationCode(env, c)
     readRequest := []idp.ReadClientsRequest{ {Id: clientToDeleteId} }
-    status, responses, err := idp.ReadClients(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.clients.collection"), readRequest)
+    status, responses, err := idp.ReadClients(idpClient, config.Get().Idp.Clients, readRequest)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -103,16 +103,10 @@ func ShowClientDelete(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    c.HTML(http.StatusOK, "clientdelete.html", gin.H{
+    app.Render(c, http.StatusOK, "clientdelete.html", gin.H{
       "title": "Delete Client",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "provider": config.GetString("provider.name"),
       "id": client.Id,
       "username": identity.Username,
-      "name": identity.Name,
       "RiskAccepted": riskAccepted,
       "errorRiskAccepted": errorRiskAccepted,
       "submitUrl": submitUrl,
@@ -161,10 +155,10 @@ func SubmitClientDelete(env *environment.State) gin.HandlerFunc {
         idpClient := app.IdpClientUsingAuthorizationCode(env, c)
 
         deleteRequest := []idp.DeleteClientsRequest{ {Id: form.Id} }
-        status, responses, err := idp.DeleteClients(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.clients.collection"), deleteRequest)
+        status, responses, err := idp.DeleteClients(idpClient, config.Get().Idp.Clients, deleteRequest)
         if err != nil {
           log.Debug(err.Error())
-          c.AbortWithStatus(http.StatusInternalServerError)
+          c.AbortWithStatus(http.StatusBadGateway)
           return
         }
 
@@ -173,6 +167,7 @@ func SubmitClientDelete(env *environment.State) gin.HandlerFunc {
           var resp idp.DeleteClientsResponse
           status, _ := bulky.Unmarshal(0, responses, &resp)
           if status == 200 {
+            app.EmitEvent(env, c, webhooks.ClientDeleted, resp)
 
             // Cleanup session
             session.Delete(ClientAcceptRiskKey)
@@ -182,7 +177,7 @@ func SubmitClientDelete(env *environment.State) gin.HandlerFunc {
               log.Debug(err.Error())
             }
 
-            redirectTo := config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.clients.collection")
+            redirectTo := config.Get().Meui.Clients
             log.WithFields(logrus.Fields{"redirect_to": redirectTo}).Debug("Redirecting");
             c.Redirect(http.StatusFound, redirectTo)
             c.Abort()
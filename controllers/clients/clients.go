@@ -44,10 +44,10 @@ func ShowClients(env *environment.State) gin.HandlerFunc {
 
     idpClient := app.IdpClientUsingAuthorizationCode(env, c)
 
-    status, responses, err := idp.ReadClients(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.clients.collection"), nil)
+    status, responses, err := idp.ReadClients(idpClient, config.Get().Idp.Clients, nil)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -57,21 +57,21 @@ func ShowClients(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    grantsUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.access.grant"))
+    grantsUrl, err := url.Parse(config.Get().Meui.AccessGrant)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
       return
     }
 
-    subscriptionsUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.subscriptions.collection"))
+    subscriptionsUrl, err := url.Parse(config.Get().Meui.Subscriptions)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
       return
     }
 
-    deleteUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.clients.delete"))
+    deleteUrl, err := url.Parse(config.Get().Meui.ClientsDelete)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
@@ -121,15 +121,8 @@ func ShowClients(env *environment.State) gin.HandlerFunc {
       return uiCreatedClients[i].Name > uiCreatedClients[j].Name
     })
 
-    c.HTML(http.StatusOK, "clients.html", gin.H{
+    app.Render(c, http.StatusOK, "clients.html", gin.H{
       "title": "Clients",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
-      "id": identity.Id,
-      "user": identity.Username,
-      "name": identity.Name,
       "created": uiCreatedClients,
     })
   }
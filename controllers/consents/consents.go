@@ -34,11 +34,11 @@ func ShowConsents(env *environment.State) gin.HandlerFunc {
 
     // Consents
 
-    callUrl := config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.consents.collection")
+    callUrl := config.Get().Aap.Consents
     status, responses, err := aap.ReadConsents(aapClient, callUrl, nil)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -49,18 +49,14 @@ func ShowConsents(env *environment.State) gin.HandlerFunc {
 
     if status != http.StatusOK {
       log.Debug("Failed to read consents")
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
     var consents aap.ReadConsentsResponse
     status, restErr := bulky.Unmarshal(0, responses, &consents)
     if len(restErr) > 0 {
-      for _,e := range restErr {
-        log.Debug("Rest error: " + e.Error)
-      }
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
+      app.FlashRestErrors(c, 0, "", restErr, nil)
     }
 
     if status == http.StatusForbidden {
@@ -70,7 +66,7 @@ func ShowConsents(env *environment.State) gin.HandlerFunc {
 
     if status != http.StatusOK {
       log.Debug("Failed to unmarshal ReadConsentsResponse")
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -86,11 +82,11 @@ func ShowConsents(env *environment.State) gin.HandlerFunc {
     }
 
     // Read clients
-    callUrl = config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.clients.collection")
+    callUrl = config.Get().Idp.Clients
     status, responses, err = idp.ReadClients(idpClient, callUrl, nil)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -101,18 +97,14 @@ func ShowConsents(env *environment.State) gin.HandlerFunc {
 
     if status != http.StatusOK {
       log.Debug("Failed to read clients")
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
     var clients idp.ReadClientsResponse
     status, restErr = bulky.Unmarshal(0, responses, &clients)
     if len(restErr) > 0 {
-      for _,e := range restErr {
-        log.Debug("Rest error: " + e.Error)
-      }
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
+      app.FlashRestErrors(c, 0, "", restErr, nil)
     }
 
     if status == http.StatusForbidden {
@@ -122,20 +114,13 @@ func ShowConsents(env *environment.State) gin.HandlerFunc {
 
     if status != http.StatusOK {
       log.Debug("Failed to unmarshal ReadClientsResponse")
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
     log.Debug(clients)
 
-    c.HTML(http.StatusOK, "consents.html", gin.H{
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
-      "id": identity.Id,
-      "user": identity.Username,
-      "name": identity.Name,
+    app.Render(c, http.StatusOK, "consents.html", gin.H{
       "title": "Consents",
     })
   }
@@ -0,0 +1,118 @@
+package deliveries
+
+import (
+  "strings"
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/webhooks"
+)
+
+type retryForm struct {
+  Id string `form:"id" binding:"required"`
+}
+
+type endpoint struct {
+  Name string
+  Url string
+  Events string
+}
+
+type delivery struct {
+  Id string
+  EventId string
+  Event string
+  Endpoint string
+  State string
+  Color string
+  Attempts int
+  Status int
+  Error string
+  CreatedAt int64
+  LastAttemptAt int64
+  NextAttemptAt int64
+}
+
+var stateColors = map[string]string{
+  webhooks.Pending: "yellow",
+  webhooks.Delivered: "green",
+  webhooks.Dead: "red",
+}
+
+func newDeliveries(list []webhooks.Delivery) (deliveries []delivery) {
+  for _, d := range list {
+    row := delivery{
+      Id: d.Id,
+      EventId: d.Event.Id,
+      Event: d.Event.Type,
+      Endpoint: d.Endpoint,
+      State: d.State,
+      Color: stateColors[d.State],
+      Attempts: d.Attempts,
+      Status: d.Status,
+      Error: d.Error,
+      CreatedAt: d.Event.CreatedAt,
+    }
+    if !d.LastAttemptAt.IsZero() {
+      row.LastAttemptAt = d.LastAttemptAt.Unix()
+    }
+    if !d.NextAttemptAt.IsZero() {
+      row.NextAttemptAt = d.NextAttemptAt.Unix()
+    }
+    deliveries = append(deliveries, row)
+  }
+  return deliveries
+}
+
+// ShowDeliveries lists the webhook endpoints, the dead letters and the delivery log, newest first
+func ShowDeliveries(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    var endpoints []endpoint
+    for _, e := range config.Get().Webhooks.Endpoints {
+      endpoints = append(endpoints, endpoint{Name: e.Name, Url: e.Url, Events: strings.Join(e.Events, ", ")})
+    }
+
+    app.Render(c, http.StatusOK, "webhooks.html", gin.H{
+      "title": "Webhooks",
+      "endpoints": endpoints,
+      "dead": newDeliveries(env.Webhooks.DeadLetters()),
+      "deliveries": newDeliveries(env.Webhooks.Log()),
+    })
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// SubmitRetry queues a dead letter again
+func SubmitRetry(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitRetry",
+    })
+
+    var form retryForm
+    err := c.Bind(&form)
+    if err != nil {
+      log.Debug(err.Error())
+      app.AbortWithError(c, http.StatusBadRequest, "The delivery is missing.")
+      return
+    }
+
+    if env.Webhooks.Retry(form.Id) {
+      log.WithFields(logrus.Fields{"delivery": form.Id}).Info("Webhook delivery retried")
+      app.AddFlash(c, app.FlashSuccess, "The delivery is queued again.")
+    } else {
+      app.AddFlash(c, app.FlashWarning, "The delivery is no longer among the dead letters.")
+    }
+
+    c.Redirect(http.StatusFound, "/webhooks")
+    c.Abort()
+  }
+  return gin.HandlerFunc(fn)
+}
@@ -5,7 +5,6 @@ import (
   "time"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
   "github.com/gin-contrib/sessions"
   "golang.org/x/oauth2"
   oidc "github.com/coreos/go-oidc/v3/oidc"
@@ -17,27 +16,36 @@ import (
 
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/forms"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/validators"
 
   "github.com/opensentry/meui/app"
-  f "github.com/go-playground/form"
+  "github.com/opensentry/meui/webhooks"
   "fmt"
 )
 
+var grantsForm = forms.New("grants")
+
+type grantInput struct {
+  Scope     string `validate:"required,scope"`
+  Enabled   bool
+  StartDate string `validate:"omitempty,datetime"`
+  EndDate   string `validate:"omitempty,datetime,dateafter=StartDate"`
+}
+
 type formInput struct {
-  Publisher     string
-  Receiver       string
-  Grants        []struct{
-    Scope          string
-    Enabled        bool
-    StartDate      string
-    EndDate        string
-  }
+  Grants []grantInput `validate:"dive"`
 }
 
 type uiGrant struct {
   Nbf string
   Exp string
+  NotBefore int64
+  Expire int64
   Granted bool
+  NbfError string
+  ExpError string
 }
 
 func ShowGrants(env *environment.State) gin.HandlerFunc {
@@ -70,15 +78,14 @@ func ShowGrants(env *environment.State) gin.HandlerFunc {
     var idToken *oidc.IDToken
     idToken = session.Get(environment.SessionIdTokenKey).(*oidc.IDToken)
     if idToken == nil {
-      c.HTML(http.StatusNotFound, "grants.html", gin.H{"error": "Identity not found"})
-      c.Abort()
+      c.AbortWithStatus(http.StatusUnauthorized)
       return
     }
 
     var accessToken *oauth2.Token
     accessToken = session.Get(environment.SessionTokenKey).(*oauth2.Token)
-    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig, accessToken)
-    idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig, accessToken)
+    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig(), accessToken)
+    idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig(), accessToken)
 
     var url string
     var responses []bulky.Response
@@ -90,26 +97,20 @@ func ShowGrants(env *environment.State) gin.HandlerFunc {
     var grantPublishes []aap.Publish
     var mayGrantPublishes []aap.Publish
     if publisherExists {
-      url = config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.publishes")
+      url = config.Get().Aap.Publishes
       _, responses, err = aap.ReadPublishes(aapClient, url, []aap.ReadPublishesRequest{
         {Publisher: publisher},
       })
 
       if err != nil {
-        c.AbortWithStatus(404)
+        c.AbortWithStatus(http.StatusBadGateway)
         log.Debug(err.Error())
         return
       }
 
       _, restErr = bulky.Unmarshal(0, responses, &publishes)
       if len(restErr) > 0 {
-        for _,e := range restErr {
-          // TODO show user somehow
-          log.Debug("Rest error: " + e.Error)
-        }
-
-        c.AbortWithStatus(404)
-        return
+        app.FlashRestErrors(c, 0, "", restErr, nil)
       }
 
       for _,p := range publishes {
@@ -124,13 +125,13 @@ func ShowGrants(env *environment.State) gin.HandlerFunc {
 
     // fetch grants
 
-    url = config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.grants")
+    url = config.Get().Aap.Grants
     _, responses, err = aap.ReadGrants(aapClient, url, []aap.ReadGrantsRequest{
       { Identity: receiver, Publisher: publisher},
     })
 
     if err != nil {
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       log.Debug(err.Error())
       return
     }
@@ -138,42 +139,58 @@ func ShowGrants(env *environment.State) gin.HandlerFunc {
     var grants aap.ReadGrantsResponse
     _, restErr = bulky.Unmarshal(0, responses, &grants)
     if len(restErr) > 0 {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-
-      c.AbortWithStatus(404)
-      return
+      app.FlashRestErrors(c, 0, "", restErr, nil)
     }
 
+    loc := i18n.Location(c)
+
     var hasGrantsMap = make(map[string]uiGrant, len(grants))
     for _,g := range grants {
 
       var nbf string
       if g.NotBefore != 0 {
-        nbf = time.Unix(g.NotBefore, 0).Format("2006-01-02")
+        nbf = time.Unix(g.NotBefore, 0).In(loc).Format(validators.DateTimeLayout)
       }
 
       var exp string
       if g.Expire != 0 {
-        exp = time.Unix(g.Expire, 0).Format("2006-01-02")
+        exp = time.Unix(g.Expire, 0).In(loc).Format(validators.DateTimeLayout)
       }
 
       hasGrantsMap[g.Scope] = uiGrant{
         Nbf: nbf,
         Exp: exp,
+        NotBefore: g.NotBefore,
+        Expire: g.Expire,
         Granted: true,
       }
     }
 
+    // Input kept from a failed submit is shown instead, so the user can correct it. Both tabs number their grants from zero, so match by scope.
+    values, errors := grantsForm.Populate(c, nil)
+    for i := 0; ; i++ {
+      key := fmt.Sprintf("Grants[%d].", i)
+      scope, exists := values[key + "Scope"]
+      if !exists {
+        break
+      }
+
+      g := hasGrantsMap[scope]
+      g.Granted = values[key + "Enabled"] == "true"
+      g.Nbf = values[key + "StartDate"]
+      g.Exp = values[key + "EndDate"]
+      g.NbfError = errors[key + "StartDate"]
+      g.ExpError = errors[key + "EndDate"]
+      hasGrantsMap[scope] = g
+    }
+
     // fetch resourceservers
 
-    url = config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.resourceservers.collection")
+    url = config.Get().Idp.ResourceServers
     _, responses, err = idp.ReadResourceServers(idpClient, url, nil)
 
     if err != nil {
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       log.Debug(err.Error())
       return
     }
@@ -181,24 +198,10 @@ func ShowGrants(env *environment.State) gin.HandlerFunc {
     var resourceservers idp.ReadResourceServersResponse
     _, restErr = bulky.Unmarshal(0, responses, &resourceservers)
     if len(restErr) > 0 {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-
-      c.AbortWithStatus(404)
-      return
+      app.FlashRestErrors(c, 0, "", restErr, nil)
     }
 
-    c.HTML(200, "grants.html", gin.H{
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
-      "id": identity.Id,
-      "user": identity.Username,
-      "name": identity.Name,
+    app.Render(c, 200, "grants.html", gin.H{
       "title": "Grants",
       "hasGrantsMap": hasGrantsMap,
       "grantPublishes": grantPublishes,
@@ -224,8 +227,7 @@ func SubmitGrants(env *environment.State) gin.HandlerFunc {
     var idToken *oidc.IDToken
     idToken = session.Get(environment.SessionIdTokenKey).(*oidc.IDToken)
     if idToken == nil {
-      c.HTML(http.StatusNotFound, "grants.html", gin.H{"error": "Identity not found"})
-      c.Abort()
+      c.AbortWithStatus(http.StatusUnauthorized)
       return
     }
 
@@ -237,53 +239,79 @@ func SubmitGrants(env *environment.State) gin.HandlerFunc {
         "publisher": publisher,
         "receiver": receiver,
       }).Debug("publisher and receiver must exists")
-      c.AbortWithStatus(404)
+      app.AbortWithError(c, http.StatusBadRequest, "The link is missing a publisher or receiver.")
       return
     }
 
     var form formInput
-    c.Request.ParseForm()
-
-    decoder := f.NewDecoder()
-
-    // must pass a pointer
-    err := decoder.Decode(&form, c.Request.Form)
+    valid, err := grantsForm.Bind(c, &form)
     if err != nil {
-      log.Panic(err)
-      c.AbortWithStatus(404)
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
+    }
+
+    if !valid {
+      grantsForm.RedirectBack(c)
       return
     }
 
     var accessToken *oauth2.Token
     accessToken = session.Get(environment.SessionTokenKey).(*oauth2.Token)
-    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig, accessToken)
+    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig(), accessToken)
+
+    url := config.Get().Aap.Grants
+
+    // Only what changes is sent, so webhook events are emitted for grants actually created or deleted
+    status, responses, err := aap.ReadGrants(aapClient, url, []aap.ReadGrantsRequest{
+      {Identity: receiver, Publisher: publisher},
+    })
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
+    if status != http.StatusOK {
+      log.Debug("Failed to get 200 from " + url)
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
+
+    var current aap.ReadGrantsResponse
+    if len(responses) > 0 {
+      bulky.Unmarshal(0, responses, &current) // Not found is no grants
+    }
+    granted := make(map[string]aap.Grant, len(current))
+    for _, g := range current {
+      if g.OnBehalfOf == publisher {
+        granted[g.Scope] = g
+      }
+    }
 
     var createGrantsRequests []aap.CreateGrantsRequest
     var deleteGrantsRequests []aap.DeleteGrantsRequest
+    // Dates are entered in the timezone of the user, they are validated so they parse
+    loc := i18n.Location(c)
     for _,grant := range form.Grants {
-      layout := "2006-01-02"
 
       var nbf int64
       if grant.StartDate != "" {
-        nbfTime, err := time.Parse(layout, grant.StartDate)
-        if err != nil {
-           panic(err)
-        }
-
+        nbfTime, _ := validators.ParseDateTime(grant.StartDate, loc)
         nbf = nbfTime.Unix()
       }
 
       var exp int64
       if grant.EndDate != "" {
-        expTime, err := time.Parse(layout, grant.EndDate)
-        if err != nil {
-           panic(err)
-        }
-
+        expTime, _ := validators.ParseDateTime(grant.EndDate, loc)
         exp = expTime.Unix()
       }
 
+      existing, isGranted := granted[grant.Scope]
+
       if grant.Enabled {
+        if isGranted && existing.NotBefore == nbf && existing.Expire == exp {
+          continue
+        }
         createGrantsRequests = append(createGrantsRequests, aap.CreateGrantsRequest{
           Identity: receiver,
           Scope: grant.Scope,
@@ -295,6 +323,10 @@ func SubmitGrants(env *environment.State) gin.HandlerFunc {
         continue;
       }
 
+      if !isGranted {
+        continue
+      }
+
       // deny by default
       deleteGrantsRequests = append(deleteGrantsRequests, aap.DeleteGrantsRequest{
         Identity: receiver,
@@ -304,15 +336,13 @@ func SubmitGrants(env *environment.State) gin.HandlerFunc {
       })
     }
 
-    url := config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.grants")
-
     var createStatus int
     var createResponses []bulky.Response
     if createGrantsRequests != nil {
       createStatus, createResponses, err = aap.CreateGrants(aapClient, url, createGrantsRequests)
       if err != nil {
         log.Debug(err.Error())
-        c.AbortWithStatus(404)
+        c.AbortWithStatus(http.StatusBadGateway)
         return
       }
     }
@@ -323,45 +353,55 @@ func SubmitGrants(env *environment.State) gin.HandlerFunc {
       deleteStatus, deleteResponses, err = aap.DeleteGrants(aapClient, url, deleteGrantsRequests)
       if err != nil {
         log.Debug(err.Error())
-        c.AbortWithStatus(404)
+        c.AbortWithStatus(http.StatusBadGateway)
         return
       }
     }
 
-    foundErrors := false
+    // Requests are sent in form order, so the response index tells which scope failed
+    grantFields := map[string]string{"NotBefore": "Start date", "Expire": "End date"}
 
     if createStatus == 200 {
-      var createGrants aap.CreateGrantsResponse
-      _, restErr := bulky.Unmarshal(0, createResponses, &createGrants)
-      if restErr != nil {
-        for _,e := range restErr {
-          // TODO show user somehow
-          log.Debug("Rest error: " + e.Error)
+      for _, r := range createResponses {
+        var createGrants aap.CreateGrantsResponse
+        _, restErr := bulky.Unmarshal(r.Index, createResponses, &createGrants)
+        if restErr != nil {
+          var scope string
+          if r.Index < len(createGrantsRequests) {
+            scope = createGrantsRequests[r.Index].Scope
+          }
+          app.FlashRestErrors(c, r.Index, scope, restErr, grantFields)
+          continue
+        }
+        // A grant given again with new dates is not a new grant
+        if _, isGranted := granted[createGrants.Scope]; !isGranted {
+          app.EmitEvent(env, c, webhooks.GrantCreated, createGrants)
         }
-        foundErrors = true
       }
     }
 
     if deleteStatus == 200 {
-      var deleteGrants aap.DeleteGrantsResponse
-      _, restErr := bulky.Unmarshal(0, deleteResponses, &deleteGrants)
-      if restErr != nil {
-        for _,e := range restErr {
-          // TODO show user somehow
-          log.Debug("Rest error: " + e.Error)
+      for _, r := range deleteResponses {
+        var deleteGrants aap.DeleteGrantsResponse
+        _, restErr := bulky.Unmarshal(r.Index, deleteResponses, &deleteGrants)
+        if restErr != nil {
+          var scope string
+          if r.Index < len(deleteGrantsRequests) {
+            scope = deleteGrantsRequests[r.Index].Scope
+          }
+          app.FlashRestErrors(c, r.Index, scope, restErr, grantFields)
+          continue
+        }
+        if r.Index < len(deleteGrantsRequests) {
+          app.EmitEvent(env, c, webhooks.GrantDeleted, deleteGrantsRequests[r.Index])
         }
-        foundErrors = true
       }
-
     }
 
-    if !foundErrors {
-      c.Redirect(http.StatusFound, fmt.Sprintf("/access/grant?receiver=%s&publisher=%s", receiver, publisher))
-      c.Abort()
-      return
-    }
+    grantsForm.Clear(c)
 
-    c.AbortWithStatus(404)
+    c.Redirect(http.StatusFound, fmt.Sprintf("/access/grant?receiver=%s&publisher=%s", receiver, publisher))
+    c.Abort()
   }
   return gin.HandlerFunc(fn)
 }
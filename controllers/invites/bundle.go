@@ -0,0 +1,575 @@
+package invites
+
+import (
+  "fmt"
+  "time"
+  "strings"
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  bulky "github.com/charmixer/bulky/client"
+  aap "github.com/opensentry/aap/client"
+  idp "github.com/opensentry/idp/client"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/forms"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/invitestore"
+  "github.com/opensentry/meui/validators"
+  "github.com/opensentry/meui/webhooks"
+)
+
+var bundleForm = forms.New("invitebundle")
+
+type bundleShadowInput struct {
+  Role      string `validate:"required,uuid"`
+  Enabled   bool
+  StartDate string `validate:"omitempty,datetime"`
+  EndDate   string `validate:"omitempty,datetime,future,dateafter=StartDate"`
+}
+
+type bundleGrantInput struct {
+  Scope     string `validate:"required,scope"`
+  Enabled   bool
+  StartDate string `validate:"omitempty,datetime"`
+  EndDate   string `validate:"omitempty,datetime,future,dateafter=StartDate"`
+}
+
+type bundleInput struct {
+  Id string `form:"id" validate:"required,uuid"`
+  Publisher string `form:"publisher" validate:"omitempty,uuid"`
+  Shadows []bundleShadowInput `validate:"dive"`
+  Grants []bundleGrantInput `validate:"dive"`
+}
+
+// A role or scope that may be put in the bundle, Key is the role id or the scope
+type BundleRowTemplate struct {
+  Key string
+  Name string
+  Description string
+  Enabled bool
+  StartDate string
+  EndDate string
+  StartDateError string
+  EndDateError string
+}
+
+// A shadow or grant of the bundle with the outcome of provisioning it, if provisioned
+type BundleItemTemplate struct {
+  Kind string
+  Name string
+  Publisher string
+  NotBefore int64
+  Expire int64
+  Outcome *invitestore.Outcome
+}
+
+// Names of roles and resource servers by id. Best effort, ids without a name are shown as they are.
+func readNames(env *environment.State, c *gin.Context, log *logrus.Entry) map[string]string {
+  cfg := config.Get()
+  idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+  names := map[string]string{}
+
+  status, responses, err := idp.ReadRoles(idpClient, cfg.Idp.Roles, nil)
+  if err == nil && status == http.StatusOK && len(responses) > 0 {
+    var roles idp.ReadRolesResponse
+    if status, _ := bulky.Unmarshal(0, responses, &roles); status == http.StatusOK {
+      for _, role := range roles {
+        names[role.Id] = role.Name
+      }
+    }
+  } else if err != nil {
+    log.Debug(err.Error())
+  }
+
+  status, responses, err = idp.ReadResourceServers(idpClient, cfg.Idp.ResourceServers, nil)
+  if err == nil && status == http.StatusOK && len(responses) > 0 {
+    var resourceServers idp.ReadResourceServersResponse
+    if status, _ := bulky.Unmarshal(0, responses, &resourceServers); status == http.StatusOK {
+      for _, rs := range resourceServers {
+        names[rs.Id] = rs.Name
+      }
+    }
+  } else if err != nil {
+    log.Debug(err.Error())
+  }
+
+  return names
+}
+
+// The items of the bundle, with their outcomes once provisioned
+func bundleItems(invite invitestore.Invite, names map[string]string) (items []BundleItemTemplate) {
+  name := func(id string) string {
+    if n, exists := names[id]; exists && n != "" {
+      return n
+    }
+    return id
+  }
+
+  p := invite.Record.Provisioning
+  if p != nil && p.Skipped == "" {
+    for i := range p.Outcomes {
+      o := &p.Outcomes[i]
+      switch {
+      case o.Shadow != nil:
+        items = append(items, BundleItemTemplate{Kind: "Role", Name: name(o.Shadow.Role), NotBefore: o.Shadow.NotBefore, Expire: o.Shadow.Expire, Outcome: o})
+      case o.Grant != nil:
+        items = append(items, BundleItemTemplate{Kind: "Grant", Name: o.Grant.Scope, Publisher: name(o.Grant.Publisher), NotBefore: o.Grant.NotBefore, Expire: o.Grant.Expire, Outcome: o})
+      }
+    }
+    return items
+  }
+
+  if invite.Record.Bundle == nil {
+    return nil
+  }
+  for _, s := range invite.Record.Bundle.Shadows {
+    items = append(items, BundleItemTemplate{Kind: "Role", Name: name(s.Role), NotBefore: s.NotBefore, Expire: s.Expire})
+  }
+  for _, g := range invite.Record.Bundle.Grants {
+    items = append(items, BundleItemTemplate{Kind: "Grant", Name: g.Scope, Publisher: name(g.Publisher), NotBefore: g.NotBefore, Expire: g.Expire})
+  }
+  return items
+}
+
+// Names the shadows and grants of bundle the identity may not give, those of previous excepted
+func deniedItems(env *environment.State, c *gin.Context, log *logrus.Entry, identity string, previous *invitestore.Bundle, bundle *invitestore.Bundle) (denied []string, err error) {
+  kept := map[interface{}]bool{}
+  if previous != nil {
+    for _, s := range previous.Shadows {
+      kept[s] = true
+    }
+    for _, g := range previous.Grants {
+      kept[g] = true
+    }
+  }
+
+  changed := &invitestore.Bundle{}
+  for _, s := range bundle.Shadows {
+    if !kept[s] {
+      changed.Shadows = append(changed.Shadows, s)
+    }
+  }
+  for _, g := range bundle.Grants {
+    if !kept[g] {
+      changed.Grants = append(changed.Grants, g)
+    }
+  }
+  if changed.Empty() {
+    return nil, nil
+  }
+
+  may, err := readGivable(app.AapClientUsingAuthorizationCode(env, c), identity, changed)
+  if err != nil {
+    return nil, err
+  }
+
+  var names map[string]string
+  for _, s := range changed.Shadows {
+    if may.Give(invitestore.Outcome{Shadow: &s}) {
+      continue
+    }
+    if names == nil {
+      names = readNames(env, c, log)
+    }
+    name, exists := names[s.Role]
+    if !exists || name == "" {
+      name = s.Role
+    }
+    denied = append(denied, name)
+  }
+  for _, g := range changed.Grants {
+    if !may.Give(invitestore.Outcome{Grant: &g}) {
+      denied = append(denied, g.Scope)
+    }
+  }
+  return denied, nil
+}
+
+// What an identity may give of a bundle: the roles it shadows and the scopes its grants may grant, see
+// aap.Grant.MayGrantScopes
+type givable struct {
+  roles map[string]bool
+  scopes map[string]bool // By publisher and scope
+}
+
+// Give tells if the shadow or grant of o may be given
+func (g givable) Give(o invitestore.Outcome) bool {
+  switch {
+  case o.Shadow != nil:
+    return g.roles[o.Shadow.Role]
+  case o.Grant != nil:
+    return g.scopes[o.Grant.Publisher + " " + o.Grant.Scope]
+  }
+  return false
+}
+
+// Reads what identity may give now of the roles and publishers of bundle
+func readGivable(aapClient *aap.AapClient, identity string, bundle *invitestore.Bundle) (givable, error) {
+  cfg := config.Get()
+  g := givable{roles: map[string]bool{}, scopes: map[string]bool{}}
+  now := time.Now().Unix()
+  active := func(nbf int64, exp int64) bool {
+    return nbf <= now && (exp == 0 || exp > now)
+  }
+
+  if len(bundle.Shadows) > 0 {
+    status, responses, err := aap.ReadShadows(aapClient, cfg.Aap.Shadows, []aap.ReadShadowsRequest{ {Identity: identity} })
+    if err != nil {
+      return g, err
+    }
+    if status != http.StatusOK {
+      return g, fmt.Errorf("Failed to get 200 from %s", cfg.Aap.Shadows)
+    }
+
+    var own aap.ReadShadowsResponse
+    if len(responses) > 0 {
+      bulky.Unmarshal(0, responses, &own) // Not found gives no roles
+    }
+    for _, s := range own {
+      if active(s.NotBefore, s.Expire) {
+        g.roles[s.Shadow] = true
+      }
+    }
+  }
+
+  if len(bundle.Grants) > 0 {
+    var requests []aap.ReadGrantsRequest
+    publishers := map[string]bool{}
+    for _, grant := range bundle.Grants {
+      if !publishers[grant.Publisher] {
+        publishers[grant.Publisher] = true
+        requests = append(requests, aap.ReadGrantsRequest{Identity: identity, Publisher: grant.Publisher})
+      }
+    }
+
+    status, responses, err := aap.ReadGrants(aapClient, cfg.Aap.Grants, requests)
+    if err != nil {
+      return g, err
+    }
+    if status != http.StatusOK {
+      return g, fmt.Errorf("Failed to get 200 from %s", cfg.Aap.Grants)
+    }
+
+    for _, r := range responses {
+      var own aap.ReadGrantsResponse
+      bulky.Unmarshal(r.Index, responses, &own) // Not found gives no grants
+      for _, grant := range own {
+        if !active(grant.NotBefore, grant.Expire) {
+          continue
+        }
+        for _, scope := range grant.MayGrantScopes {
+          g.scopes[grant.Publisher + " " + scope] = true
+        }
+      }
+    }
+  }
+
+  return g, nil
+}
+
+// Formats a time of the bundle for <input type="datetime-local">
+func bundleDate(unix int64, loc *time.Location) string {
+  if unix == 0 {
+    return ""
+  }
+  return time.Unix(unix, 0).In(loc).Format(validators.DateTimeLayout)
+}
+
+// Parses a date of the bundle form, entered in the timezone of the user. Empty is 0.
+func bundleUnix(value string, loc *time.Location) int64 {
+  if value == "" {
+    return 0
+  }
+  t, _ := validators.ParseDateTime(value, loc) // Validated
+  return t.Unix()
+}
+
+// Input kept from a failed submit replaces the rows, so the user can correct it. Rows are matched by key as the
+// roles and publishes may have changed since the form was shown.
+func populateBundleRows(rows []BundleRowTemplate, values map[string]string, errors map[string]string, list string, keyField string) {
+  byKey := map[string]int{}
+  for i, row := range rows {
+    byKey[row.Key] = i
+  }
+
+  for i := 0; ; i++ {
+    prefix := fmt.Sprintf("%s[%d].", list, i)
+    key, exists := values[prefix + keyField]
+    if !exists {
+      break
+    }
+    j, exists := byKey[key]
+    if !exists {
+      continue
+    }
+    rows[j].Enabled = values[prefix + "Enabled"] == "true"
+    rows[j].StartDate = values[prefix + "StartDate"]
+    rows[j].EndDate = values[prefix + "EndDate"]
+    rows[j].StartDateError = errors[prefix + "StartDate"]
+    rows[j].EndDateError = errors[prefix + "EndDate"]
+  }
+}
+
+func ShowInviteBundle(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "ShowInviteBundle",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    invite, ok := readInvite(env, c, log, c.Query("id"))
+    if !ok {
+      return
+    }
+
+    cfg := config.Get()
+    publisher := c.Query("publisher")
+    loc := i18n.Location(c)
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+    aapClient := app.AapClientUsingAuthorizationCode(env, c)
+
+    bundle := invite.Record.Bundle
+    if bundle == nil {
+      bundle = &invitestore.Bundle{}
+    }
+
+    status, responses, err := idp.ReadRoles(idpClient, cfg.Idp.Roles, nil)
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
+    if status != http.StatusOK {
+      log.Debug("Failed to get 200 from " + cfg.Idp.Roles)
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
+
+    shadows := map[string]invitestore.Shadow{}
+    for _, s := range bundle.Shadows {
+      shadows[s.Role] = s
+    }
+
+    var roles idp.ReadRolesResponse
+    var uiShadows []BundleRowTemplate
+    if len(responses) > 0 {
+      _, restErr := bulky.Unmarshal(0, responses, &roles)
+      if len(restErr) > 0 {
+        app.FlashRestErrors(c, 0, "", restErr, nil)
+      }
+    }
+    for _, role := range roles {
+      s, enabled := shadows[role.Id]
+      uiShadows = append(uiShadows, BundleRowTemplate{
+        Key: role.Id,
+        Name: role.Name,
+        Description: role.Description,
+        Enabled: enabled,
+        StartDate: bundleDate(s.NotBefore, loc),
+        EndDate: bundleDate(s.Expire, loc),
+      })
+    }
+
+    status, responses, err = idp.ReadResourceServers(idpClient, cfg.Idp.ResourceServers, nil)
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
+
+    var resourceServers idp.ReadResourceServersResponse
+    if status == http.StatusOK && len(responses) > 0 {
+      _, restErr := bulky.Unmarshal(0, responses, &resourceServers)
+      if len(restErr) > 0 {
+        app.FlashRestErrors(c, 0, "", restErr, nil)
+      }
+    }
+
+    var uiGrants []BundleRowTemplate
+    if publisher != "" {
+      status, responses, err = aap.ReadPublishes(aapClient, cfg.Aap.Publishes, []aap.ReadPublishesRequest{ {Publisher: publisher} })
+      if err != nil {
+        log.Debug(err.Error())
+        c.AbortWithStatus(http.StatusBadGateway)
+        return
+      }
+
+      grants := map[string]invitestore.Grant{}
+      for _, g := range bundle.Grants {
+        if g.Publisher == publisher {
+          grants[g.Scope] = g
+        }
+      }
+
+      var publishes aap.ReadPublishesResponse
+      if status == http.StatusOK && len(responses) > 0 {
+        _, restErr := bulky.Unmarshal(0, responses, &publishes)
+        if len(restErr) > 0 {
+          app.FlashRestErrors(c, 0, "", restErr, nil)
+        }
+      }
+      for _, p := range publishes {
+        g, enabled := grants[p.Scope]
+        uiGrants = append(uiGrants, BundleRowTemplate{
+          Key: p.Scope,
+          Name: p.Scope,
+          Description: p.Title,
+          Enabled: enabled,
+          StartDate: bundleDate(g.NotBefore, loc),
+          EndDate: bundleDate(g.Expire, loc),
+        })
+      }
+    }
+
+    values, errors := bundleForm.Populate(c, nil)
+    populateBundleRows(uiShadows, values, errors, "Shadows", "Role")
+    populateBundleRows(uiGrants, values, errors, "Grants", "Scope")
+
+    names := map[string]string{}
+    for _, role := range roles {
+      names[role.Id] = role.Name
+    }
+    for _, rs := range resourceServers {
+      names[rs.Id] = rs.Name
+    }
+
+    inviteStatus := invite.Status(time.Now())
+    app.Render(c, http.StatusOK, "invites_bundle.html", gin.H{
+      "title": "Invite Bundle",
+      "invite": invite,
+      "status": inviteStatus,
+      "color": inviteColors[inviteStatus],
+      "editable": isOpen(inviteStatus),
+      "publisher": publisher,
+      "resourceservers": resourceServers,
+      "shadows": uiShadows,
+      "grants": uiGrants,
+      "items": bundleItems(invite, names),
+    })
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// SubmitInviteBundle saves the bundle of an invite that is not yet accepted. The roles replace those of the bundle, the
+// grants replace only those of the chosen publisher. Roles and scopes the author may not give are refused.
+func SubmitInviteBundle(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitInviteBundle",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    var input bundleInput
+    valid, err := bundleForm.Bind(c, &input)
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
+    }
+
+    if !valid {
+      bundleForm.RedirectBack(c)
+      return
+    }
+
+    invite, ok := readInvite(env, c, log, input.Id)
+    if !ok {
+      return
+    }
+
+    lang := i18n.Language(c)
+    status := invite.Status(time.Now())
+    if !isOpen(status) {
+      app.AddFlash(c, app.FlashWarning, i18n.T(lang, "The invite is %s, its bundle can no longer be changed", i18n.T(lang, status)))
+      bundleForm.RedirectBack(c)
+      return
+    }
+
+    loc := i18n.Location(c)
+    bundle := &invitestore.Bundle{UpdatedBy: identity.Id}
+    for _, s := range input.Shadows {
+      if s.Enabled {
+        bundle.Shadows = append(bundle.Shadows, invitestore.Shadow{Role: s.Role, NotBefore: bundleUnix(s.StartDate, loc), Expire: bundleUnix(s.EndDate, loc)})
+      }
+    }
+
+    if previous := invite.Record.Bundle; previous != nil {
+      for _, g := range previous.Grants {
+        if g.Publisher != input.Publisher {
+          bundle.Grants = append(bundle.Grants, g)
+        }
+      }
+    }
+    if input.Publisher != "" {
+      for _, g := range input.Grants {
+        if g.Enabled {
+          bundle.Grants = append(bundle.Grants, invitestore.Grant{Publisher: input.Publisher, Scope: g.Scope, NotBefore: bundleUnix(g.StartDate, loc), Expire: bundleUnix(g.EndDate, loc)})
+        }
+      }
+    }
+
+    // The bundle is provisioned with the client credentials of meui, so the author may only put in it what they may give
+    // themselves. What the bundle held before is kept as it was checked when it was saved.
+    denied, err := deniedItems(env, c, log, identity.Id, invite.Record.Bundle, bundle)
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
+    if len(denied) > 0 {
+      app.AddFlash(c, app.FlashError, i18n.T(lang, "You may not give %s, the bundle is not saved", strings.Join(denied, ", ")))
+      bundleForm.RedirectBack(c)
+      return
+    }
+
+    err = env.Invites.Update(invite.Id, func(r *invitestore.Record) {
+      r.Bundle = nil
+      if !bundle.Empty() {
+        r.Bundle = bundle
+      }
+    })
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusInternalServerError)
+      return
+    }
+
+    invite.Record = env.Invites.Get(invite.Id)
+    app.EmitEvent(env, c, webhooks.InviteUpdated, invite)
+    bundleForm.Clear(c)
+    app.AddFlash(c, app.FlashSuccess, i18n.T(lang, "The bundle of the invite to %s is saved, it is provisioned when the invite is accepted", invite.Email))
+
+    redirectTo, err := inviteUrl("/invites/bundle", invite.Id)
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusInternalServerError)
+      return
+    }
+    if input.Publisher != "" {
+      redirectTo += "&publisher=" + input.Publisher
+    }
+    log.WithFields(logrus.Fields{"redirect_to": redirectTo}).Debug("Redirecting")
+    c.Redirect(http.StatusFound, redirectTo)
+    c.Abort()
+  }
+  return gin.HandlerFunc(fn)
+}
@@ -0,0 +1,59 @@
+package invites
+
+import (
+  "time"
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+)
+
+// ShowInviteDetails shows an invite with its record, its bundle and the outcome of provisioning it
+func ShowInviteDetails(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "ShowInviteDetails",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    invite, ok := readInvite(env, c, log, c.Query("id"))
+    if !ok {
+      return
+    }
+
+    sendUrl, err := inviteUrl(config.Get().Meui.InvitesSend, invite.Id)
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusInternalServerError)
+      return
+    }
+
+    names := readNames(env, c, log)
+
+    status := invite.Status(time.Now())
+    app.Render(c, http.StatusOK, "invites_details.html", gin.H{
+      "title": "Invite",
+      "invite": invite,
+      "status": status,
+      "color": inviteColors[status],
+      "open": isOpen(status),
+      "sendUrl": sendUrl,
+      "items": bundleItems(invite, names),
+      "provisioning": invite.Record.Provisioning,
+      "provisionable": invite.Provisionable(),
+      "provisioned": invite.Record.Provisioning != nil && invite.Record.Provisioning.Skipped == "",
+    })
+  }
+  return gin.HandlerFunc(fn)
+}
@@ -0,0 +1,222 @@
+package invites
+
+import (
+  "io"
+  "bytes"
+  "strings"
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/invitecsv"
+  "github.com/opensentry/meui/ratelimit"
+)
+
+// Largest csv file accepted, well above invites.import.maxRows rows of usual length
+const maxImportBytes = 2 << 20
+
+// Rows of the preview and results pages
+type importRow struct {
+  invitecsv.Row
+  Color string
+}
+
+var statusColors = map[string]string{
+  invitecsv.Valid: "green",
+  invitecsv.Invalid: "red",
+  invitecsv.Created: "teal",
+  invitecsv.Sent: "green",
+  invitecsv.NotSent: "orange",
+  invitecsv.Failed: "red",
+}
+
+func newImportRows(rows []invitecsv.Row) (list []importRow) {
+  for _, row := range rows {
+    list = append(list, importRow{Row: row, Color: statusColors[row.Status]})
+  }
+  return list
+}
+
+func ShowInvitesImport(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "ShowInvitesImport",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    app.Render(c, http.StatusOK, "invites_import.html", gin.H{
+      "title": "Import Invites",
+      "maxRows": config.Get().Invites.ImportMaxRows,
+    })
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// SubmitInvitesImportPreview validates an uploaded or pasted csv file and shows every row with its errors, nothing is created
+func SubmitInvitesImportPreview(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitInvitesImportPreview",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    lang := i18n.Language(c)
+
+    data := c.PostForm("Csv")
+    file, _, err := c.Request.FormFile("File")
+    if err == nil {
+      defer file.Close()
+      // Read no more than the limit, a larger file is refused below without holding all of it
+      var b bytes.Buffer
+      _, err = b.ReadFrom(io.LimitReader(file, maxImportBytes + 1))
+      if err != nil {
+        log.Debug(err.Error())
+        app.AbortWithError(c, http.StatusBadRequest, "The file could not be read.")
+        return
+      }
+      data = b.String()
+    }
+
+    if len(data) > maxImportBytes {
+      app.AddFlash(c, app.FlashError, i18n.T(lang, "The file is larger than %d kB", maxImportBytes >> 10))
+      c.Redirect(http.StatusFound, "/invites/import")
+      c.Abort()
+      return
+    }
+
+    if strings.TrimSpace(data) == "" {
+      app.AddFlash(c, app.FlashWarning, i18n.T(lang, "Choose a csv file or paste its rows."))
+      c.Redirect(http.StatusFound, "/invites/import")
+      c.Abort()
+      return
+    }
+
+    rows, err := invitecsv.Parse(strings.NewReader(data), lang, config.Get().Invites.ImportMaxRows)
+    if err != nil {
+      log.Debug(err.Error())
+      app.AddFlash(c, app.FlashError, i18n.T(lang, "The file could not be read: %s", err.Error()))
+      c.Redirect(http.StatusFound, "/invites/import")
+      c.Abort()
+      return
+    }
+
+    app.Render(c, http.StatusOK, "invites_import_preview.html", gin.H{
+      "title": "Import Invites",
+      "rows": newImportRows(rows),
+      "counts": invitecsv.Count(rows),
+      "csv": data,
+      "send": c.PostForm("Send") != "",
+    })
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// SubmitInvitesImport creates the invites of the valid rows of a previewed csv file and sends them if asked to.
+// Every invite sent counts against the daily quota of invite e-mails, those over it are created but not sent.
+func SubmitInvitesImport(env *environment.State, sendQuota *ratelimit.Quota) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitInvitesImport",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    lang := i18n.Language(c)
+    cfg := config.Get()
+
+    rows, err := invitecsv.Parse(strings.NewReader(c.PostForm("Csv")), lang, cfg.Invites.ImportMaxRows)
+    if err != nil {
+      log.Debug(err.Error())
+      app.AddFlash(c, app.FlashError, i18n.T(lang, "The file could not be read: %s", err.Error()))
+      c.Redirect(http.StatusFound, "/invites/import")
+      c.Abort()
+      return
+    }
+
+    emit := func(eventType string, data interface{}) {
+      app.EmitEvent(env, c, eventType, data)
+    }
+
+    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+    invitecsv.Create(idpClient, cfg.Idp.Invites, rows, cfg.Invites.ImportBatchSize, emit)
+
+    if c.PostForm("Send") != "" {
+      created := invitecsv.Count(rows)[invitecsv.Created]
+      allowed := sendQuota.TakeUpTo(ratelimit.QuotaKey(c), created)
+      invitecsv.Send(idpClient, cfg.Idp.InvitesSend, rows, cfg.Invites.ImportBatchSize, allowed, i18n.T(lang, "The daily quota of invite e-mails is spent"), emit)
+      sendQuota.GiveBack(ratelimit.QuotaKey(c), allowed - invitecsv.Count(rows)[invitecsv.Sent]) // Sends idp failed cost nothing
+    }
+
+    counts := invitecsv.Count(rows)
+    log.WithFields(logrus.Fields{
+      "created": counts[invitecsv.Created] + counts[invitecsv.Sent] + counts[invitecsv.NotSent],
+      "sent": counts[invitecsv.Sent],
+      "failed": counts[invitecsv.Failed],
+      "invalid": counts[invitecsv.Invalid],
+    }).Info("Invites imported")
+
+    var results bytes.Buffer
+    err = invitecsv.WriteResults(&results, rows)
+    if err != nil {
+      log.Debug(err.Error())
+    }
+
+    app.Render(c, http.StatusOK, "invites_import_results.html", gin.H{
+      "title": "Import Invites",
+      "rows": newImportRows(rows),
+      "counts": counts,
+      "results": results.String(),
+    })
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// SubmitInvitesImportResults downloads the results of an import as csv. The results page posts them back, so nothing
+// about an import is kept by meui.
+func SubmitInvitesImportResults(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitInvitesImportResults",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    c.Header("Content-Disposition", `attachment; filename="invites-import-results.csv"`)
+    c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(c.PostForm("Results")))
+  }
+  return gin.HandlerFunc(fn)
+}
@@ -1,30 +1,135 @@
 package invites
 
 import (
+  "time"
+  "strings"
   "net/url"
   "net/http"
-  "time"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  //"github.com/gin-contrib/sessions"
   idp "github.com/opensentry/idp/client"
 
   "github.com/opensentry/meui/app"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
-
-  bulky "github.com/charmixer/bulky/client"
+  "github.com/opensentry/meui/invitestore"
 )
 
 type InviteTemplate struct {
-  IssuedAt string
-  InvitedBy string
-  Expires string
-  Id string
-  Email string
+  invitestore.Invite
+  Status string
+  Color string
+  Open bool // Pending or sent, may be sent, revoked and have its expiry and bundle changed
+  DetailsUrl string
   GrantsUrl string
   SendUrl string
-  SentAt string
+  ExpiryUrl string
+  BundleUrl string
+  RevokeUrl string
+}
+
+type FilterTemplate struct {
+  Status string
+  Count int
+  Url string
+  Active bool
+}
+
+var inviteColors = map[string]string{
+  invitestore.Pending: "blue",
+  invitestore.Sent: "teal",
+  invitestore.Accepted: "green",
+  invitestore.Expired: "orange",
+  invitestore.Revoked: "red",
+}
+
+// Reads invites with their records, aborting with the status the invite pages use if idp fails. nil requests reads all.
+func readInvites(env *environment.State, c *gin.Context, log *logrus.Entry, requests []idp.ReadInvitesRequest) ([]invitestore.Invite, bool) {
+  cfg := config.Get()
+  idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+
+  status, invites, err := env.Invites.Read(idpClient, cfg.Idp.Invites, cfg.Idp.Humans, requests)
+  if err != nil {
+    log.Debug(err.Error())
+    c.AbortWithStatus(http.StatusBadGateway)
+    return nil, false
+  }
+
+  if status == http.StatusForbidden {
+    c.AbortWithStatus(http.StatusForbidden)
+    return nil, false
+  }
+
+  if status != http.StatusOK {
+    log.Debug("Failed to get 200 from " + cfg.Idp.Invites)
+    c.AbortWithStatus(http.StatusBadGateway)
+    return nil, false
+  }
+
+  return invites, true
+}
+
+// Reads the invite given by id, aborting with not found if idp has none
+func readInvite(env *environment.State, c *gin.Context, log *logrus.Entry, id string) (invitestore.Invite, bool) {
+  if id == "" {
+    c.AbortWithStatus(http.StatusNotFound)
+    return invitestore.Invite{}, false
+  }
+
+  invites, ok := readInvites(env, c, log, []idp.ReadInvitesRequest{ {Id: id} })
+  if !ok {
+    return invitestore.Invite{}, false
+  }
+
+  if len(invites) == 0 {
+    c.AbortWithStatus(http.StatusNotFound)
+    return invitestore.Invite{}, false
+  }
+  return invites[0], true
+}
+
+func isOpen(status string) bool {
+  return status == invitestore.Pending || status == invitestore.Sent
+}
+
+// Adds the id of the invite to the query of a page url
+func inviteUrl(page string, id string) (string, error) {
+  u, err := url.Parse(page)
+  if err != nil {
+    return "", err
+  }
+  q := u.Query()
+  q.Add("id", id)
+  u.RawQuery = q.Encode()
+  return u.String(), nil
+}
+
+// The invites page with the filter and search kept by the bulk actions
+func invitesUrl(status string, search string) string {
+  q := url.Values{}
+  if status != "" {
+    q.Set("status", status)
+  }
+  if search != "" {
+    q.Set("q", search)
+  }
+  if len(q) == 0 {
+    return config.Get().Meui.Invites
+  }
+  return config.Get().Meui.Invites + "?" + q.Encode()
+}
+
+func matchesSearch(invite invitestore.Invite, search string) bool {
+  if search == "" {
+    return true
+  }
+  search = strings.ToLower(search)
+  for _, value := range []string{invite.Id, invite.Email, invite.Username} {
+    if strings.Contains(strings.ToLower(value), search) {
+      return true
+    }
+  }
+  return false
 }
 
 func ShowInvites(env *environment.State) gin.HandlerFunc {
@@ -42,89 +147,77 @@ func ShowInvites(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
-
-    callUrl := config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.invites.collection")
-    status, responses, err := idp.ReadInvites(idpClient, callUrl, nil)
-    if err != nil {
-      log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
-    }
-
-    if status == http.StatusForbidden {
-      c.AbortWithStatus(http.StatusForbidden)
-      return
-    }
+    filter := c.Query("status")
+    search := strings.TrimSpace(c.Query("q"))
 
-    if status != 200 {
-      log.Debug("Failed to get 200 from " + callUrl);
-      c.AbortWithStatus(http.StatusInternalServerError)
+    invites, ok := readInvites(env, c, log, nil)
+    if !ok {
       return
     }
 
-    f := "2006-01-02 15:04:05" // Remder time format
-    var uiCreatedInvites []InviteTemplate
-    var uiSentInvites []InviteTemplate
-
-    var invites idp.ReadInvitesResponse
-    status, _ = bulky.Unmarshal(0, responses, &invites)
-    if status == 200 {
-
-      for _, invite := range invites {
-
-        grantsUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.access.grant"))
-        if err != nil {
-          log.Debug(err.Error())
-          c.AbortWithStatus(http.StatusInternalServerError)
-          return
-        }
-        q := grantsUrl.Query()
-        q.Add("receiver", invite.Id)
-        grantsUrl.RawQuery = q.Encode()
-
-        sendUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.invites.send"))
-        if err != nil {
-          log.Debug(err.Error())
-          c.AbortWithStatus(http.StatusInternalServerError)
-          return
-        }
-        q = sendUrl.Query()
-        q.Add("id", invite.Id)
-        sendUrl.RawQuery = q.Encode()
-
-        sat := "n/a"
-        if invite.SentAt > 0 {
-          sat = time.Unix(invite.SentAt, 0).Format(f)
-        }
-
-        uiInvite := InviteTemplate{
-          GrantsUrl: grantsUrl.String(),
-          SendUrl:   sendUrl.String(),
-          Id:        invite.Id,
-          Email:     invite.Email,
-          // InvitedBy: invite.InvitedBy,
-          IssuedAt:  time.Unix(invite.IssuedAt, 0).Format(f),
-          Expires:   time.Unix(invite.ExpiresAt, 0).Format(f),
-          SentAt: sat,
-        }
-        uiCreatedInvites = append(uiCreatedInvites, uiInvite)
+    cfg := config.Get()
+    now := time.Now()
+    counts := map[string]int{}
+    var uiInvites []InviteTemplate
+    for _, invite := range invites {
+      status := invite.Status(now)
+      if !matchesSearch(invite, search) {
+        continue
+      }
+      counts[status]++
+      if filter != "" && status != filter {
+        continue
+      }
 
+      grantsUrl, err := url.Parse(cfg.Meui.AccessGrant)
+      if err != nil {
+        log.Debug(err.Error())
+        c.AbortWithStatus(http.StatusInternalServerError)
+        return
+      }
+      q := grantsUrl.Query()
+      q.Add("receiver", invite.Id)
+      grantsUrl.RawQuery = q.Encode()
+
+      sendUrl, err := inviteUrl(cfg.Meui.InvitesSend, invite.Id)
+      if err != nil {
+        log.Debug(err.Error())
+        c.AbortWithStatus(http.StatusInternalServerError)
+        return
       }
+      detailsUrl, _ := inviteUrl("/invites/details", invite.Id)
+      expiryUrl, _ := inviteUrl("/invites/expiry", invite.Id)
+      bundleUrl, _ := inviteUrl("/invites/bundle", invite.Id)
+      revokeUrl, _ := inviteUrl("/invites/revoke", invite.Id)
+
+      uiInvites = append(uiInvites, InviteTemplate{
+        Invite: invite,
+        Status: status,
+        Color: inviteColors[status],
+        Open: isOpen(status),
+        DetailsUrl: detailsUrl,
+        GrantsUrl: grantsUrl.String(),
+        SendUrl: sendUrl,
+        ExpiryUrl: expiryUrl,
+        BundleUrl: bundleUrl,
+        RevokeUrl: revokeUrl,
+      })
+    }
 
+    total := 0
+    var filters []FilterTemplate
+    for _, status := range invitestore.Statuses {
+      total += counts[status]
+      filters = append(filters, FilterTemplate{Status: status, Count: counts[status], Url: invitesUrl(status, search), Active: filter == status})
     }
+    filters = append([]FilterTemplate{ {Status: "all", Count: total, Url: invitesUrl("", search), Active: filter == ""} }, filters...)
 
-    c.HTML(http.StatusOK, "invites.html", gin.H{
+    app.Render(c, http.StatusOK, "invites.html", gin.H{
       "title": "Invites",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
-      "id": identity.Id,
-      "user": identity.Username,
-      "name": identity.Name,
-      "created": uiCreatedInvites,
-      "sent": uiSentInvites,
+      "invites": uiInvites,
+      "filters": filters,
+      "status": filter,
+      "search": search,
     })
   }
   return gin.HandlerFunc(fn)
@@ -0,0 +1,348 @@
+package invites
+
+import (
+  "time"
+  "net/http"
+  "github.com/gofrs/uuid"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  idp "github.com/opensentry/idp/client"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/forms"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/invitestore"
+  "github.com/opensentry/meui/ratelimit"
+  "github.com/opensentry/meui/validators"
+  "github.com/opensentry/meui/webhooks"
+)
+
+var expiryForm = forms.New("inviteexpiry")
+
+type expiryInput struct {
+  Id string `form:"id" validate:"required,uuid"`
+  ExpiresAt string `validate:"required,datetime,future"`
+}
+
+// Bulk actions of the invites page
+const (
+  bulkResend = "resend"
+  bulkRevoke = "revoke"
+)
+
+// Revokes the invite by identity, returns false and a reason if it may not be revoked
+func revoke(env *environment.State, c *gin.Context, invite invitestore.Invite, identity *idp.Human) (bool, string) {
+  switch invite.Status(time.Now()) {
+  case invitestore.Accepted:
+    return false, invitestore.ErrAccepted.Error()
+  case invitestore.Revoked:
+    return false, invitestore.ErrRevoked.Error()
+  }
+
+  err := env.Invites.Update(invite.Id, func(r *invitestore.Record) {
+    r.RevokedAt = time.Now().Unix()
+    r.RevokedBy = identity.Id
+  })
+  if err != nil {
+    return false, err.Error()
+  }
+
+  invite.Record = env.Invites.Get(invite.Id)
+  app.EmitEvent(env, c, webhooks.InviteRevoked, invite)
+  return true, ""
+}
+
+func ShowInviteRevoke(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "ShowInviteRevoke",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    invite, ok := readInvite(env, c, log, c.Query("id"))
+    if !ok {
+      return
+    }
+
+    status := invite.Status(time.Now())
+    app.Render(c, http.StatusOK, "invites_revoke.html", gin.H{
+      "title": "Revoke Invite",
+      "invite": invite,
+      "status": status,
+      "color": inviteColors[status],
+      "revocable": status != invitestore.Accepted && status != invitestore.Revoked,
+    })
+  }
+  return gin.HandlerFunc(fn)
+}
+
+func SubmitInviteRevoke(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitInviteRevoke",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    invite, ok := readInvite(env, c, log, c.PostForm("id"))
+    if !ok {
+      return
+    }
+
+    lang := i18n.Language(c)
+    revoked, reason := revoke(env, c, invite, identity)
+    if revoked {
+      log.WithFields(logrus.Fields{"id": invite.Id}).Debug("Invite revoked")
+      app.AddFlash(c, app.FlashSuccess, i18n.T(lang, "The invite to %s is revoked", invite.Email))
+    } else {
+      log.WithFields(logrus.Fields{"id": invite.Id, "reason": reason}).Debug("Revoke invite failed")
+      app.AddFlash(c, app.FlashWarning, i18n.T(lang, reason))
+    }
+
+    redirectTo := config.Get().Meui.Invites
+    log.WithFields(logrus.Fields{"redirect_to": redirectTo}).Debug("Redirecting")
+    c.Redirect(http.StatusFound, redirectTo)
+    c.Abort()
+  }
+  return gin.HandlerFunc(fn)
+}
+
+func ShowInviteExpiry(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "ShowInviteExpiry",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    invite, ok := readInvite(env, c, log, c.Query("id"))
+    if !ok {
+      return
+    }
+
+    defaults := map[string]string{}
+    if exp := invite.Expires(); exp > 0 {
+      defaults["ExpiresAt"] = time.Unix(exp, 0).In(i18n.Location(c)).Format(validators.DateLayout)
+    }
+    values, errors := expiryForm.Populate(c, defaults)
+
+    status := invite.Status(time.Now())
+    app.Render(c, http.StatusOK, "invites_expiry.html", gin.H{
+      "title": "Change Expiry",
+      "invite": invite,
+      "status": status,
+      "color": inviteColors[status],
+      "editable": status != invitestore.Accepted && status != invitestore.Revoked,
+      "form": values,
+      "errors": errors,
+    })
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// SubmitInviteExpiry changes the expiry of an invite kept by meui. idp holds on to the expiry the invite was created
+// with, so the expiry may be moved earlier but not past it.
+func SubmitInviteExpiry(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitInviteExpiry",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    var input expiryInput
+    valid, err := expiryForm.Bind(c, &input)
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
+    }
+
+    if !valid {
+      expiryForm.RedirectBack(c)
+      return
+    }
+
+    invite, ok := readInvite(env, c, log, input.Id)
+    if !ok {
+      return
+    }
+
+    lang := i18n.Language(c)
+    status := invite.Status(time.Now())
+    if status == invitestore.Accepted || status == invitestore.Revoked {
+      app.AddFlash(c, app.FlashWarning, i18n.T(lang, "The invite is %s, its expiry can no longer be changed", i18n.T(lang, status)))
+      expiryForm.RedirectBack(c)
+      return
+    }
+
+    expiresAt, _ := validators.ParseDateTime(input.ExpiresAt, i18n.Location(c)) // Validated, entered in the timezone of the user
+    if invite.ExpiresAt > 0 && expiresAt.Unix() > invite.ExpiresAt {
+      expiryForm.AddError(c, "ExpiresAt", i18n.T(lang, "Must be on or before %s, idp keeps the invite until then. Create a new invite to extend it.", i18n.Date(lang, i18n.Location(c), invite.ExpiresAt)))
+      expiryForm.RedirectBack(c)
+      return
+    }
+
+    err = env.Invites.Update(invite.Id, func(r *invitestore.Record) {
+      r.ExpiresAt = expiresAt.Unix()
+    })
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusInternalServerError)
+      return
+    }
+
+    invite.Record = env.Invites.Get(invite.Id)
+    app.EmitEvent(env, c, webhooks.InviteUpdated, invite)
+    expiryForm.Clear(c)
+    app.AddFlash(c, app.FlashSuccess, i18n.T(lang, "The invite to %s now expires %s", invite.Email, i18n.Date(lang, i18n.Location(c), invite.Expires())))
+
+    redirectTo := config.Get().Meui.Invites
+    log.WithFields(logrus.Fields{"redirect_to": redirectTo}).Debug("Redirecting")
+    c.Redirect(http.StatusFound, redirectTo)
+    c.Abort()
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// SubmitInvitesBulk resends or revokes the invites checked on the invites page. Invites that may not be resent or
+// revoked are skipped, and resends beyond the daily quota of invite e-mails are not sent.
+func SubmitInvitesBulk(env *environment.State, sendQuota *ratelimit.Quota) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitInvitesBulk",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    lang := i18n.Language(c)
+    action := c.PostForm("action")
+    redirectTo := invitesUrl(c.PostForm("status"), c.PostForm("q"))
+
+    var requests []idp.ReadInvitesRequest
+    for _, id := range c.PostFormArray("ids") {
+      if _, err := uuid.FromString(id); err == nil {
+        requests = append(requests, idp.ReadInvitesRequest{Id: id})
+      }
+    }
+
+    if len(requests) == 0 || (action != bulkResend && action != bulkRevoke) {
+      app.AddFlash(c, app.FlashWarning, i18n.T(lang, "Check the invites and choose an action"))
+      c.Redirect(http.StatusFound, redirectTo)
+      c.Abort()
+      return
+    }
+
+    invites, ok := readInvites(env, c, log, requests)
+    if !ok {
+      return
+    }
+
+    done := 0
+    skipped := len(requests) - len(invites) // Not found
+    switch action {
+
+    case bulkRevoke:
+      for _, invite := range invites {
+        revoked, reason := revoke(env, c, invite, identity)
+        if !revoked {
+          log.WithFields(logrus.Fields{"id": invite.Id, "reason": reason}).Debug("Revoke invite skipped")
+          skipped++
+          continue
+        }
+        done++
+      }
+      app.AddFlash(c, app.FlashSuccess, i18n.T(lang, "%d invites revoked, %d skipped", done, skipped))
+
+    case bulkResend:
+      now := time.Now()
+      var sendRequests []idp.CreateInvitesSendRequest
+      for _, invite := range invites {
+        if err := invite.CanSend(now, config.Get().Invites.ResendInterval); err != nil {
+          log.WithFields(logrus.Fields{"id": invite.Id, "reason": err.Error()}).Debug("Resend invite skipped")
+          skipped++
+          continue
+        }
+        sendRequests = append(sendRequests, idp.CreateInvitesSendRequest{Id: invite.Id})
+      }
+
+      quotaKey := ratelimit.QuotaKey(c)
+      allowed := sendQuota.TakeUpTo(quotaKey, len(sendRequests))
+      overQuota := len(sendRequests) - allowed
+      sendRequests = sendRequests[:allowed]
+
+      if len(sendRequests) > 0 {
+        idpClient := app.IdpClientUsingAuthorizationCode(env, c)
+        status, responses, err := idp.CreateInvitesSend(idpClient, config.Get().Idp.InvitesSend, sendRequests)
+        if err != nil {
+          log.Debug(err.Error())
+          sendQuota.GiveBack(quotaKey, allowed)
+          c.AbortWithStatus(http.StatusBadGateway)
+          return
+        }
+        if status != http.StatusOK {
+          log.Debug("Failed to get 200 from " + config.Get().Idp.InvitesSend)
+          sendQuota.GiveBack(quotaKey, allowed)
+          c.AbortWithStatus(http.StatusBadGateway)
+          return
+        }
+
+        for _, sent := range sentInvites(responses, len(sendRequests)) {
+          recordSend(env, log, sent.Id)
+          app.EmitEvent(env, c, webhooks.InviteSent, sent)
+          done++
+        }
+        skipped += len(sendRequests) - done
+        sendQuota.GiveBack(quotaKey, allowed - done) // Sends idp failed cost nothing
+      }
+
+      app.AddFlash(c, app.FlashSuccess, i18n.T(lang, "%d invites sent, %d skipped", done, skipped))
+      if overQuota > 0 {
+        app.AddFlash(c, app.FlashWarning, i18n.T(lang, "%d invites were not sent, the daily quota of invite e-mails is spent", overQuota))
+      }
+    }
+
+    log.WithFields(logrus.Fields{"action": action, "done": done, "skipped": skipped}).Debug("Bulk action on invites")
+    c.Redirect(http.StatusFound, redirectTo)
+    c.Abort()
+  }
+  return gin.HandlerFunc(fn)
+}
@@ -0,0 +1,175 @@
+package invites
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  aap "github.com/opensentry/aap/client"
+  idp "github.com/opensentry/idp/client"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/invitestore"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// Provisions the bundle of an accepted invite, see invitestore.Store.Provision. It runs with the client credentials
+// of meui, never the token of whoever looks at the invite, and gives only what the author of the bundle may still
+// give. The invite is given its new record. Returns nil if nothing was provisioned.
+func provision(env *environment.State, log *logrus.Entry, invite *invitestore.Invite, retry bool, actor string) *invitestore.Provisioning {
+  cfg := config.Get()
+  aapClient := aap.NewAapClient(env.AapApiConfig())
+  log = log.WithFields(logrus.Fields{"id": invite.Id})
+
+  record := env.Invites.Get(invite.Id)
+  if record.Bundle.Empty() {
+    return nil
+  }
+
+  // Checked again as the author may have lost rights since the bundle was saved. Bundles without an author give nothing.
+  may := givable{}
+  if record.Bundle.UpdatedBy != "" {
+    var err error
+    may, err = readGivable(aapClient, record.Bundle.UpdatedBy, record.Bundle)
+    if err != nil {
+      log.Error(err.Error())
+      return nil
+    }
+  }
+
+  // Outcomes accepted before are kept by a retry, their events were emitted then
+  given := map[string]bool{}
+  if record.Provisioning != nil {
+    for _, o := range record.Provisioning.Outcomes {
+      if o.Ok() {
+        given[outcomeKey(o)] = true
+      }
+    }
+  }
+
+  p, err := env.Invites.Provision(aapClient, cfg.Aap.Shadows, cfg.Aap.Grants, *invite, retry, may.Give)
+  if err != nil {
+    log.Error(err.Error())
+    return nil
+  }
+  if p == nil {
+    return nil
+  }
+
+  invite.Record = env.Invites.Get(invite.Id)
+  log.WithFields(logrus.Fields{"outcomes": len(p.Outcomes), "failed": p.Failed(), "skipped": p.Skipped}).Info("Invite provisioned")
+
+  for _, o := range p.Outcomes {
+    if !o.Ok() || given[outcomeKey(o)] {
+      continue
+    }
+    switch {
+    case o.Shadow != nil:
+      env.Webhooks.Emit(webhooks.ShadowCreated, cfg.OAuth2.ClientId, aap.Shadow{Identity: invite.Id, Shadow: o.Shadow.Role, NotBefore: o.Shadow.NotBefore, Expire: o.Shadow.Expire})
+    case o.Grant != nil:
+      env.Webhooks.Emit(webhooks.GrantCreated, cfg.OAuth2.ClientId, aap.Grant{Identity: invite.Id, Scope: o.Grant.Scope, Publisher: o.Grant.Publisher, OnBehalfOf: o.Grant.Publisher, NotBefore: o.Grant.NotBefore, Expire: o.Grant.Expire})
+    }
+  }
+  env.Webhooks.Emit(webhooks.InviteProvisioned, actor, invite)
+  return p
+}
+
+// Tells the shadow or grant of an outcome from the others of a bundle
+func outcomeKey(o invitestore.Outcome) string {
+  switch {
+  case o.Shadow != nil:
+    return "shadow " + o.Shadow.Role
+  case o.Grant != nil:
+    return "grant " + o.Grant.Publisher + " " + o.Grant.Scope
+  }
+  return ""
+}
+
+// Invites read per request to idp when looking for accepted ones
+const provisionBatchSize = 100
+
+// ProvisionAccepted provisions the bundles of the invites accepted since it last ran. Only the invites with a bundle
+// not yet provisioned are read from idp. main runs it every invites.provision.interval.
+func ProvisionAccepted(env *environment.State, log *logrus.Entry) {
+  cfg := config.Get()
+  idpClient := idp.NewIdpClient(env.IdpApiConfig())
+
+  ids := env.Invites.Unprovisioned()
+  for start := 0; start < len(ids); start += provisionBatchSize {
+    end := start + provisionBatchSize
+    if end > len(ids) {
+      end = len(ids)
+    }
+
+    var requests []idp.ReadInvitesRequest
+    for _, id := range ids[start:end] {
+      requests = append(requests, idp.ReadInvitesRequest{Id: id})
+    }
+
+    status, invites, err := env.Invites.Read(idpClient, cfg.Idp.Invites, cfg.Idp.Humans, requests)
+    if err != nil {
+      log.Error(err.Error())
+      return
+    }
+    if status != http.StatusOK {
+      log.Error("Failed to get 200 from " + cfg.Idp.Invites)
+      return
+    }
+
+    for i := range invites {
+      if invites[i].Provisionable() {
+        provision(env, log, &invites[i], false, cfg.OAuth2.ClientId)
+      }
+    }
+  }
+}
+
+// SubmitInviteProvision provisions the bundle of an accepted invite now, or again what aap did not accept of it
+func SubmitInviteProvision(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitInviteProvision",
+    })
+
+    identity := app.GetIdentity(c)
+    if identity == nil {
+      log.Debug("Missing Identity")
+      c.AbortWithStatus(http.StatusForbidden)
+      return
+    }
+
+    invite, ok := readInvite(env, c, log, c.PostForm("id"))
+    if !ok {
+      return
+    }
+
+    lang := i18n.Language(c)
+    if !invite.Provisionable() && invite.Record.Provisioning.Failed() == 0 {
+      app.AddFlash(c, app.FlashWarning, i18n.T(lang, "Nothing of the bundle is left to provision"))
+    } else {
+      p := provision(env, log, &invite, true, identity.Id)
+      if p == nil {
+        app.AddFlash(c, app.FlashWarning, i18n.T(lang, "The bundle of the invite to %s could not be provisioned now, try again later", invite.Email))
+      } else if failed := p.Failed(); failed > 0 {
+        app.AddFlash(c, app.FlashWarning, i18n.T(lang, "%d of the bundle of the invite to %s could not be provisioned", failed, invite.Email))
+      } else if p.Skipped == "" {
+        app.AddFlash(c, app.FlashSuccess, i18n.T(lang, "The bundle of the invite to %s is provisioned", invite.Email))
+      }
+    }
+
+    redirectTo, err := inviteUrl("/invites/details", invite.Id)
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusInternalServerError)
+      return
+    }
+    log.WithFields(logrus.Fields{"redirect_to": redirectTo}).Debug("Redirecting")
+    c.Redirect(http.StatusFound, redirectTo)
+    c.Abort()
+  }
+  return gin.HandlerFunc(fn)
+}
@@ -1,16 +1,18 @@
 package invites
 
 import (
+  "time"
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
   idp "github.com/opensentry/idp/client"
 
   "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/webhooks"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
-  "github.com/opensentry/meui/utils"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/ratelimit"
 
   bulky "github.com/charmixer/bulky/client"
 )
@@ -19,6 +21,29 @@ type sendForm struct {
   Id string `form:"id" binding:"required" validate:"required,uuid"`
 }
 
+// Records a send of the invite, so it is not sent again before invites.resend.interval
+func recordSend(env *environment.State, log *logrus.Entry, id string) {
+  err := env.Invites.RecordSend(id, time.Now())
+  if err != nil {
+    log.WithFields(logrus.Fields{"id": id}).Debug(err.Error())
+  }
+}
+
+// Returns the invites idp sent of n requests
+func sentInvites(responses bulky.Responses, n int) (sent []idp.CreateInvitesSendResponse) {
+  for _, r := range responses {
+    if r.Index >= n {
+      continue
+    }
+    var invite idp.CreateInvitesSendResponse
+    status, restErr := bulky.Unmarshal(r.Index, responses, &invite)
+    if status == http.StatusOK && len(restErr) == 0 {
+      sent = append(sent, invite)
+    }
+  }
+  return sent
+}
+
 func ShowInvitesSend(env *environment.State) gin.HandlerFunc {
   fn := func(c *gin.Context) {
 
@@ -34,47 +59,24 @@ func ShowInvitesSend(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    inviteId := c.Query("id")
-    if inviteId == "" {
-      c.AbortWithStatus(http.StatusNotFound)
+    invite, ok := readInvite(env, c, log, c.Query("id"))
+    if !ok {
       return
     }
 
-    idpClient := app.IdpClientUsingAuthorizationCode(env, c)
-
-    inviteRequest := []idp.ReadInvitesRequest{ {Id: inviteId} }
-    status, responses, err := idp.ReadInvites(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.invites.collection"), inviteRequest)
-    if err != nil {
-      log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
-    }
-
-    if status == 200 {
-
-      var resp idp.ReadInvitesResponse
-      status, _ = bulky.Unmarshal(0, responses, &resp)
-      if status == 200 {
-        invite := resp[0]
-        c.HTML(http.StatusOK, "invites_send.html", gin.H{
-          "title": "Send Invite",
-          "links": []map[string]string{
-            {"href": "/public/css/dashboard.css"},
-          },
-          csrf.TemplateTag: csrf.TemplateField(c.Request),
-          "provider": config.GetString("provider.name"),
-          "user": identity.Username,
-          "name": identity.Name,
-          "id": invite.Id,
-          "email": invite.Email,
-        })
-        return
-      }
-
+    reason := ""
+    if err := invite.CanSend(time.Now(), config.Get().Invites.ResendInterval); err != nil {
+      reason = err.Error()
     }
 
-    // Deny by default
-    c.AbortWithStatus(http.StatusNotFound)
+    app.Render(c, http.StatusOK, "invites_send.html", gin.H{
+      "title": "Send Invite",
+      "id": invite.Id,
+      "email": invite.Email,
+      "user": invite.Username,
+      "sentAt": invite.LastSentAt(),
+      "reason": reason,
+    })
   }
   return gin.HandlerFunc(fn)
 }
@@ -102,42 +104,59 @@ func SubmitInvitesSend(env *environment.State) gin.HandlerFunc {
       return
     }
 
+    invite, ok := readInvite(env, c, log, form.Id)
+    if !ok {
+      return
+    }
+
+    redirectTo := config.Get().Meui.Invites
+
+    // Revoked, expired and accepted invites are not sent, nor those sent a moment ago
+    if err := invite.CanSend(time.Now(), config.Get().Invites.ResendInterval); err != nil {
+      log.WithFields(logrus.Fields{"id": invite.Id, "reason": err.Error()}).Debug("Send invite refused")
+      app.AddFlash(c, app.FlashWarning, i18n.T(i18n.Language(c), err.Error()))
+      c.Redirect(http.StatusFound, redirectTo)
+      c.Abort()
+      return
+    }
+
     idpClient := app.IdpClientUsingAuthorizationCode(env, c)
 
     inviteSendRequest := []idp.CreateInvitesSendRequest{ {Id: form.Id} }
-    status, responses, err := idp.CreateInvitesSend(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.invites.send"), inviteSendRequest)
+    status, responses, err := idp.CreateInvitesSend(idpClient, config.Get().Idp.InvitesSend, inviteSendRequest)
     if err != nil {
       log.WithFields(logrus.Fields{ "id":form.Id }).Debug("Send invite failed")
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
     if status == 200 {
+      sent := sentInvites(responses, 1)
+      if len(sent) > 0 {
+        log.WithFields(logrus.Fields{"id": sent[0].Id}).Debug("Send invite")
+        recordSend(env, log, sent[0].Id)
+        ratelimit.Spend(c)
+        app.EmitEvent(env, c, webhooks.InviteSent, sent[0])
 
-      var invite idp.CreateInvitesSendResponse
-      status, _ = bulky.Unmarshal(0, responses, &invite)
-      if status == 200 {
-        log.WithFields(logrus.Fields{"id": invite.Id}).Debug("Send invite")
-
-        redirectTo := config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.invites.collection")
         log.WithFields(logrus.Fields{"redirect_to": redirectTo}).Debug("Redirecting")
         c.Redirect(http.StatusFound, redirectTo)
         c.Abort()
         return
       }
-
     }
 
     // Deny by default.
-    submitUrl, err := utils.FetchSubmitUrlFromRequest(c.Request, nil)
+    log.WithFields(logrus.Fields{"id": form.Id, "status": status}).Debug("Send invite failed")
+    app.AddFlash(c, app.FlashWarning, i18n.T(i18n.Language(c), "The invite could not be sent"))
+    sendUrl, err := inviteUrl(config.Get().Meui.InvitesSend, form.Id)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
       return
     }
-    log.WithFields(logrus.Fields{"redirect_to": submitUrl}).Debug("Redirecting")
-    c.Redirect(http.StatusFound, submitUrl)
+    log.WithFields(logrus.Fields{"redirect_to": sendUrl}).Debug("Redirecting")
+    c.Redirect(http.StatusFound, sendUrl)
     c.Abort()
   }
   return gin.HandlerFunc(fn)
-}
\ No newline at end of file
+}
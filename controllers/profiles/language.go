@@ -0,0 +1,47 @@
+package profiles
+
+import (
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
+)
+
+const languagePreferenceMaxAge = 365 * 24 * 60 * 60 // seconds
+
+type languageForm struct {
+  Lang string `form:"lang" binding:"required"`
+}
+
+// SubmitLanguage remembers the language the user picked in a cookie, so it outlives the session, and sends the user back.
+func SubmitLanguage(env *environment.State) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "SubmitLanguage",
+    })
+
+    var form languageForm
+    err := c.ShouldBind(&form)
+    if err != nil || !i18n.Supported(form.Lang) {
+      log.WithFields(logrus.Fields{"lang": form.Lang}).Debug("Unsupported language")
+      app.AbortWithError(c, http.StatusBadRequest, "The language is not supported.")
+      return
+    }
+
+    cookie := config.Get().Cookie
+    c.SetSameSite(cookie.SameSite)
+    c.SetCookie(i18n.PreferenceCookie, form.Lang, languagePreferenceMaxAge, "/", cookie.Domain, cookie.Secure, true)
+
+    redirectTo := app.BackUrl(c)
+    log.WithFields(logrus.Fields{"redirect_to": redirectTo}).Debug("Redirecting")
+    c.Redirect(http.StatusFound, redirectTo)
+    c.Abort()
+  }
+  return gin.HandlerFunc(fn)
+}
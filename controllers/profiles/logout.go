@@ -26,7 +26,7 @@ func ShowLogout(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    urlLogout := config.GetString("hydra.public.url") + config.GetString("hydra.public.endpoints.logout")
+    urlLogout := config.Get().Hydra.Logout
     if urlLogout == "" {
       log.Debug("Missing config hydra.public.url + hydra.public.endpoints.logout")
       c.AbortWithStatus(http.StatusInternalServerError)
@@ -57,7 +57,7 @@ func ShowLogout(env *environment.State) gin.HandlerFunc {
     q := logoutUrl.Query()
     q.Add("state", state)
     q.Add("id_token_hint", idToken)
-    q.Add("post_logout_redirect_uri", config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.seeyoulater"))
+    q.Add("post_logout_redirect_uri", config.Get().Meui.SeeYouLater)
     logoutUrl.RawQuery = q.Encode()
 
     redirectTo := logoutUrl.String()
@@ -26,7 +26,7 @@ func ShowProfile(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    publicProfileUrl, err := url.Parse(config.GetString("idpui.public.url") + config.GetString("idpui.public.endpoints.profile"))
+    publicProfileUrl, err := url.Parse(config.Get().IdpUi.Profile)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
@@ -37,24 +37,17 @@ func ShowProfile(env *environment.State) gin.HandlerFunc {
     q.Add("id", identity.Id)
     publicProfileUrl.RawQuery = q.Encode()
 
-    c.HTML(http.StatusOK, "profile.html", gin.H{
+    app.Render(c, http.StatusOK, "profile.html", gin.H{
       "title": "Profile",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
-      "id": identity.Id,
-      "user": identity.Username,
       "password": identity.Password,
-      "name": identity.Name,
       "email": identity.Email,
       "totp_required": identity.TotpRequired,
-      "meUiUrl": config.GetString("meui.public.url"),
-      "changeEmailUrl": config.GetString("idpui.public.url") + config.GetString("idpui.public.endpoints.emailchange"),
-      "changePasswordUrl": config.GetString("idpui.public.url") + config.GetString("idpui.public.endpoints.password"),
-      "profileDeleteUrl": config.GetString("idpui.public.url") + config.GetString("idpui.public.endpoints.delete"),
-      "setupTotpUrl": config.GetString("idpui.public.url") + config.GetString("idpui.public.endpoints.totp"),
-      "logoutUrl": config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.logout"),
+      "meUiUrl": config.Get().Meui.Url,
+      "changeEmailUrl": config.Get().IdpUi.EmailChange,
+      "changePasswordUrl": config.Get().IdpUi.Password,
+      "profileDeleteUrl": config.Get().IdpUi.Delete,
+      "setupTotpUrl": config.Get().IdpUi.Totp,
+      "logoutUrl": config.Get().Meui.Logout,
       "publicProfileUrl": publicProfileUrl.String(),
     })
     return
@@ -5,7 +5,6 @@ import (
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
   "github.com/gin-contrib/sessions"
   idp "github.com/opensentry/idp/client"
 
@@ -59,15 +58,9 @@ func ShowProfileDelete(env *environment.State) gin.HandlerFunc {
       }
     }
 
-    c.HTML(http.StatusOK, "profiledelete.html", gin.H{
+    app.Render(c, http.StatusOK, "profiledelete.html", gin.H{
       "title": "Delete profile",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "provider": config.GetString("provider.name"),
       "username": identity.Username,
-      "name": identity.Name,
       "RiskAccepted": riskAccepted,
       "errorRiskAccepted": errorRiskAccepted,
       "profileDeleteUrl": "/me/delete",
@@ -114,16 +107,16 @@ func SubmitProfileDelete(env *environment.State) gin.HandlerFunc {
         idpClient := app.IdpClientUsingAuthorizationCode(env, c)
 
         deleteRequest := []idp.DeleteHumansRequest{ {Id: identity.Id} }
-        _, responses, err := idp.DeleteHumans(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.humans.collection"), deleteRequest)
+        _, responses, err := idp.DeleteHumans(idpClient, config.Get().Idp.Humans, deleteRequest)
         if err != nil {
           log.Debug(err.Error())
-          c.AbortWithStatus(http.StatusInternalServerError)
+          c.AbortWithStatus(http.StatusBadGateway)
           return
         }
 
         if responses == nil {
           log.Debug("Delete failed. Hint: Failed to execute DeleteHumansRequest")
-          c.AbortWithStatus(http.StatusInternalServerError)
+          c.AbortWithStatus(http.StatusBadGateway)
           return
         }
 
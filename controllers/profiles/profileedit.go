@@ -1,30 +1,25 @@
 package profiles
 
 import (
-  "strings"
   "net/http"
-  "reflect"
-  "fmt"
-  "gopkg.in/go-playground/validator.v9"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
-  "github.com/gin-contrib/sessions"
   idp "github.com/opensentry/idp/client"
 
   "github.com/opensentry/meui/app"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
-  "github.com/opensentry/meui/utils"
-  "github.com/opensentry/meui/validators"
+  "github.com/opensentry/meui/forms"
 
   bulky "github.com/charmixer/bulky/client"
 )
 
-type profileEditForm struct {
+type profileEditInput struct {
   Name string `form:"display-name" validate:"required,notblank"`
 }
 
+var profileEditForm = forms.New("profileedit")
+
 func ShowProfileEdit(env *environment.State) gin.HandlerFunc {
   fn := func(c *gin.Context) {
 
@@ -40,53 +35,17 @@ func ShowProfileEdit(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    session := sessions.Default(c)
-
-    // Retain the values that was submittet
-    var submittetName string
-    fau := session.Flashes("profileedit.display-name")
-    if fau != nil {
-      submittetName = fmt.Sprintf("%s", fau[0])
-    }
-
-    errors := session.Flashes("profileedit.errors")
-    err := session.Save() // Remove flashes read, and save submit fields
-    if err != nil {
-      log.Debug(err.Error())
-    }
-
-    // Use submittet value from flash or default from db.
-    var displayName string
-    if submittetName == "" {
-      displayName = identity.Name
-    } else {
-      displayName = submittetName
-    }
-
-    var errorDisplayName string
-
-    if len(errors) > 0 {
-      errorsMap := errors[0].(map[string][]string)
-      for k, v := range errorsMap {
-
-        if k == "display-name" && len(v) > 0 {
-          errorDisplayName = strings.Join(v, ", ")
-        }
-      }
-    }
+    // Use submitted value from the form or default from db.
+    values, errors := profileEditForm.Populate(c, map[string]string{
+      "Name": identity.Name,
+    })
 
-    c.HTML(http.StatusOK, "profileedit.html", gin.H{
+    app.Render(c, http.StatusOK, "profileedit.html", gin.H{
       "title": "Profile",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "profileEditUrl": config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.edit"),
-      "provider": config.GetString("provider.name"),
+      "profileEditUrl": config.Get().Meui.Edit,
       "user": identity.Id,
-      "displayName": displayName,
-      "errorDisplayName": errorDisplayName,
-      "name": identity.Name,
+      "form": values,
+      "errors": errors,
       "registeredDisplayName": identity.Name,
       "registeredEmail": identity.Email,
     })
@@ -102,15 +61,6 @@ func SubmitProfileEdit(env *environment.State) gin.HandlerFunc {
       "func": "SubmitProfileEdit",
     })
 
-    var form profileEditForm
-    err := c.Bind(&form)
-    if err != nil {
-      // Do better error handling in the application.
-      c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-      c.Abort()
-      return
-    }
-
     identity := app.GetIdentity(c)
     if identity == nil {
       log.Debug("Missing Identity")
@@ -118,77 +68,16 @@ func SubmitProfileEdit(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    session := sessions.Default(c)
-
-    // Save values if submit fails
-    session.AddFlash(form.Name, "profileedit.display-name")
-    err = session.Save()
+    var input profileEditInput
+    valid, err := profileEditForm.Bind(c, &input)
     if err != nil {
       log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
     }
 
-    errors := make(map[string][]string)
-    validate := validator.New()
-    validate.RegisterValidation("notblank", validators.NotBlank)
-    err = validate.Struct(form)
-    if err != nil {
-
-      // Validation syntax is invalid
-      if err,ok := err.(*validator.InvalidValidationError); ok{
-        log.Debug(err.Error())
-        c.AbortWithStatus(http.StatusInternalServerError)
-        return
-      }
-
-      reflected := reflect.ValueOf(form) // Use reflector to reverse engineer struct
-      for _, err := range err.(validator.ValidationErrors){
-
-        // Attempt to find field by name and get json tag name
-        field,_ := reflected.Type().FieldByName(err.StructField())
-        var name string
-
-        // If form tag doesn't exist, use lower case of name
-        if name = field.Tag.Get("form"); name == ""{
-          name = strings.ToLower(err.StructField())
-        }
-
-        switch err.Tag() {
-        case "required":
-            errors[name] = append(errors[name], "Field is required")
-            break
-        case "email":
-            errors[name] = append(errors[name], "Field must be a valid email")
-            break
-        case "eqfield":
-            errors[name] = append(errors[name], "Field should be equal to the "+err.Param())
-            break
-        case "notblank":
-          errors[name] = append(errors[name], "Field is not allowed to be blank")
-          break
-        default:
-            errors[name] = append(errors[name], "Field is invalid")
-            break
-        }
-      }
-
-    }
-
-    if len(errors) > 0 {
-      session.AddFlash(errors, "profileedit.errors")
-      err = session.Save()
-      if err != nil {
-        log.Debug(err.Error())
-      }
-
-      submitUrl, err := utils.FetchSubmitUrlFromRequest(c.Request, nil)
-      if err != nil {
-        log.Debug(err.Error())
-        c.AbortWithStatus(http.StatusInternalServerError)
-        return
-      }
-      log.WithFields(logrus.Fields{"redirect_to": submitUrl}).Debug("Redirecting")
-      c.Redirect(http.StatusFound, submitUrl)
-      c.Abort()
+    if !valid {
+      profileEditForm.RedirectBack(c)
       return
     }
 
@@ -196,12 +85,12 @@ func SubmitProfileEdit(env *environment.State) gin.HandlerFunc {
 
     identityRequest := []idp.UpdateHumansRequest{{
       Id: identity.Id,
-      Name: form.Name,
+      Name: input.Name,
     }}
-    status, responses, err := idp.UpdateHumans(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.humans.collection"), identityRequest)
+    status, responses, err := idp.UpdateHumans(idpClient, config.Get().Idp.Humans, identityRequest)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -212,7 +101,7 @@ func SubmitProfileEdit(env *environment.State) gin.HandlerFunc {
 
     if status != http.StatusOK {
       log.Debug("Update failed. Hint: Failed to execute UpdateHumansRequest")
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -222,13 +111,7 @@ func SubmitProfileEdit(env *environment.State) gin.HandlerFunc {
 
       updatedHuman := resp
 
-      // Cleanup session
-      session.Delete("profileedit.display-name")
-      session.Delete("profileedit.errors")
-      err = session.Save()
-      if err != nil {
-        log.Debug(err.Error())
-      }
+      profileEditForm.Clear(c)
 
       if updatedHuman != (idp.UpdateHumansResponse{}) {
         log.WithFields(logrus.Fields{"id": updatedHuman.Id}).Debug("Human updated")
@@ -241,15 +124,7 @@ func SubmitProfileEdit(env *environment.State) gin.HandlerFunc {
     }
 
     // Deny by default. Failed to fill in the form correctly.
-    submitUrl, err := utils.FetchSubmitUrlFromRequest(c.Request, nil)
-    if err != nil {
-      log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
-    }
-    log.WithFields(logrus.Fields{"redirect_to": submitUrl}).Debug("Redirecting")
-    c.Redirect(http.StatusFound, submitUrl)
-    c.Abort()
+    profileEditForm.RedirectBack(c)
   }
   return gin.HandlerFunc(fn)
 }
@@ -6,6 +6,7 @@ import (
   "github.com/gin-gonic/gin"
   "github.com/gin-contrib/sessions"
 
+  "github.com/opensentry/meui/app"
   "github.com/opensentry/meui/environment"
 )
 
@@ -27,11 +28,8 @@ func ShowSeeYouLater(env *environment.State) gin.HandlerFunc {
       sessionCleared = false
     }
 
-    c.HTML(http.StatusOK, "seeyoulater.html", gin.H{
+    app.Render(c, http.StatusOK, "seeyoulater.html", gin.H{
       "title": "See You Later",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
       "sessionCleared": sessionCleared,
     })
   }
@@ -4,7 +4,6 @@ import (
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
   "github.com/gin-contrib/sessions"
   "golang.org/x/oauth2"
   oidc "github.com/coreos/go-oidc/v3/oidc"
@@ -18,16 +17,18 @@ import (
   "github.com/opensentry/meui/environment"
 
   "github.com/opensentry/meui/app"
-  f "github.com/go-playground/form"
+  "github.com/opensentry/meui/forms"
   "fmt"
 )
 
-type publishForm struct {
-  Scope         string
-  Title         string
+type publishInput struct {
+  Scope         string `validate:"required,scope"`
+  Title         string `validate:"required,notblank"`
   Description   string
 }
 
+var publishForm = forms.New("publish")
+
 func ShowPublish(env *environment.State) gin.HandlerFunc {
   fn := func(c *gin.Context) {
 
@@ -49,7 +50,7 @@ func ShowPublish(env *environment.State) gin.HandlerFunc {
 
     if !receiverExists {
       log.Debug("Missing receiver")
-      c.AbortWithStatus(404)
+      app.AbortWithError(c, http.StatusBadRequest, "The link is missing a receiver.")
       return
     }
 
@@ -59,24 +60,22 @@ func ShowPublish(env *environment.State) gin.HandlerFunc {
     var idToken *oidc.IDToken
     idToken = session.Get(environment.SessionIdTokenKey).(*oidc.IDToken)
     if idToken == nil {
-      c.HTML(http.StatusNotFound, "grants.html", gin.H{"error": "Identity not found"})
-      c.Abort()
+      c.AbortWithStatus(http.StatusUnauthorized)
       return
     }
 
     //var accessToken *oauth2.Token
     //accessToken = session.Get(environment.SessionTokenKey).(*oauth2.Token)
-    //aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig, accessToken)
-    //idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig, accessToken)
-
-    c.HTML(200, "publish.html", gin.H{
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
+    //aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig(), accessToken)
+    //idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig(), accessToken)
+
+    values, errors := publishForm.Populate(c, nil)
+
+    app.Render(c, 200, "publish.html", gin.H{
       "title": "Publish scope",
       "receiver": receiver,
+      "form": values,
+      "errors": errors,
     })
 
   }
@@ -95,8 +94,7 @@ func SubmitPublish(env *environment.State) gin.HandlerFunc {
     var idToken *oidc.IDToken
     idToken = session.Get(environment.SessionIdTokenKey).(*oidc.IDToken)
     if idToken == nil {
-      c.HTML(http.StatusNotFound, "publish.html", gin.H{"error": "Identity not found"})
-      c.Abort()
+      c.AbortWithStatus(http.StatusUnauthorized)
       return
     }
 
@@ -106,38 +104,40 @@ func SubmitPublish(env *environment.State) gin.HandlerFunc {
       log.WithFields(logrus.Fields{
         "receiver": receiver,
       }).Debug("receiver must exists")
-      c.AbortWithStatus(404)
+      app.AbortWithError(c, http.StatusBadRequest, "The link is missing a receiver.")
       return
     }
 
-    var form publishForm
-    c.Request.ParseForm()
-
-    decoder := f.NewDecoder()
-    err := decoder.Decode(&form, c.Request.Form)
+    var input publishInput
+    valid, err := publishForm.Bind(c, &input)
     if err != nil {
-      log.Panic(err)
-      c.AbortWithStatus(404)
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
+    }
+
+    if !valid {
+      publishForm.RedirectBack(c)
       return
     }
 
     var accessToken *oauth2.Token
     accessToken = session.Get(environment.SessionTokenKey).(*oauth2.Token)
-    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig, accessToken)
+    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig(), accessToken)
 
     createPublishesRequest := aap.CreatePublishesRequest{
       Publisher: receiver,
-      Scope: form.Scope,
-      Title: form.Title,
-      Description: form.Description,
+      Scope: input.Scope,
+      Title: input.Title,
+      Description: input.Description,
     }
 
-    url := config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.publishes")
+    url := config.Get().Aap.Publishes
 
     createStatus, createResponses, err := aap.CreatePublishes(aapClient, url, []aap.CreatePublishesRequest{createPublishesRequest})
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -145,20 +145,19 @@ func SubmitPublish(env *environment.State) gin.HandlerFunc {
       var createPublishes aap.CreatePublishesResponse
       _, restErr := bulky.Unmarshal(0, createResponses, &createPublishes)
       if restErr != nil {
-        for _,e := range restErr {
-          // TODO show user somehow
-          log.Debug("Rest error: " + e.Error)
-        }
-        c.AbortWithStatus(404)
+        app.FlashRestErrors(c, 0, "", restErr, map[string]string{"Scope": "Scope", "Title": "Title", "Description": "Description"})
+        publishForm.RedirectBack(c)
         return
       }
 
+      publishForm.Clear(c)
+
       c.Redirect(http.StatusFound, fmt.Sprintf("/publishings?receiver=%s", receiver))
       c.Abort()
       return
     }
 
-    c.AbortWithStatus(404)
+    c.AbortWithStatus(http.StatusBadGateway)
   }
   return gin.HandlerFunc(fn)
 }
@@ -4,7 +4,6 @@ import (
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
   "github.com/gin-contrib/sessions"
   "golang.org/x/oauth2"
   oidc "github.com/coreos/go-oidc/v3/oidc"
@@ -49,15 +48,14 @@ func ShowPublishings(env *environment.State) gin.HandlerFunc {
     var idToken *oidc.IDToken
     idToken = session.Get(environment.SessionIdTokenKey).(*oidc.IDToken)
     if idToken == nil {
-      c.HTML(http.StatusNotFound, "grants.html", gin.H{"error": "Identity not found"})
-      c.Abort()
+      c.AbortWithStatus(http.StatusUnauthorized)
       return
     }
 
     var accessToken *oauth2.Token
     accessToken = session.Get(environment.SessionTokenKey).(*oauth2.Token)
-    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig, accessToken)
-    // idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig, accessToken)
+    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig(), accessToken)
+    // idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig(), accessToken)
 
     var url string
     var responses []bulky.Response
@@ -65,13 +63,13 @@ func ShowPublishings(env *environment.State) gin.HandlerFunc {
     var restErr []bulky.ErrorResponse
 
     // fetch publishes
-    url = config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.publishes")
+    url = config.Get().Aap.Publishes
     _, responses, err = aap.ReadPublishes(aapClient, url, []aap.ReadPublishesRequest{
       {Publisher: receiver},
     })
 
     if err != nil {
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       log.Debug(err.Error())
       return
     }
@@ -79,21 +77,10 @@ func ShowPublishings(env *environment.State) gin.HandlerFunc {
     var publishings aap.ReadPublishesResponse
     _, restErr = bulky.Unmarshal(0, responses, &publishings)
     if len(restErr) > 0 {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-
-      c.AbortWithStatus(404)
-      return
+      app.FlashRestErrors(c, 0, "", restErr, nil)
     }
 
-    c.HTML(200, "publishings.html", gin.H{
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
+    app.Render(c, 200, "publishings.html", gin.H{
       "title": "Publishings",
       "receiver": receiver,
       "publishings": publishings,
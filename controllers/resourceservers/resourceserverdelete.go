@@ -5,7 +5,6 @@ import (
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
   "github.com/gin-contrib/sessions"
   idp "github.com/opensentry/idp/client"
 
@@ -50,10 +49,10 @@ func ShowResourceServerDelete(env *environment.State) gin.HandlerFunc {
     var resourceServer *idp.ResourceServer
     idpClient := app.IdpClientUsingAuthorizationCode(env, c)
     readRequest := []idp.ReadResourceServersRequest{ {Id: resourceServerToDeleteId} }
-    status, responses, err := idp.ReadResourceServers(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.resourceservers.collection"), readRequest)
+    status, responses, err := idp.ReadResourceServers(idpClient, config.Get().Idp.ResourceServers, readRequest)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -103,16 +102,10 @@ func ShowResourceServerDelete(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    c.HTML(http.StatusOK, "resourceserverdelete.html", gin.H{
+    app.Render(c, http.StatusOK, "resourceserverdelete.html", gin.H{
       "title": "Delete Resource Server",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "provider": config.GetString("provider.name"),
       "id": resourceServer.Id,
       "username": identity.Username,
-      "name": identity.Name,
       "RiskAccepted": riskAccepted,
       "errorRiskAccepted": errorRiskAccepted,
       "submitUrl": submitUrl,
@@ -161,10 +154,10 @@ func SubmitResourceServerDelete(env *environment.State) gin.HandlerFunc {
         idpClient := app.IdpClientUsingAuthorizationCode(env, c)
 
         deleteRequest := []idp.DeleteResourceServersRequest{ {Id: form.Id} }
-        status, responses, err := idp.DeleteResourceServers(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.resourceservers.collection"), deleteRequest)
+        status, responses, err := idp.DeleteResourceServers(idpClient, config.Get().Idp.ResourceServers, deleteRequest)
         if err != nil {
           log.Debug(err.Error())
-          c.AbortWithStatus(http.StatusInternalServerError)
+          c.AbortWithStatus(http.StatusBadGateway)
           return
         }
 
@@ -182,7 +175,7 @@ func SubmitResourceServerDelete(env *environment.State) gin.HandlerFunc {
               log.Debug(err.Error())
             }
 
-            redirectTo := config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.resourceservers.collection")
+            redirectTo := config.Get().Meui.ResourceServers
             log.WithFields(logrus.Fields{"redirect_to": redirectTo}).Debug("Redirecting");
             c.Redirect(http.StatusFound, redirectTo)
             c.Abort()
@@ -43,10 +43,10 @@ func ShowResourceServers(env *environment.State) gin.HandlerFunc {
 
     idpClient := app.IdpClientUsingAuthorizationCode(env, c)
 
-    status, responses, err := idp.ReadResourceServers(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.resourceservers.collection"), nil)
+    status, responses, err := idp.ReadResourceServers(idpClient, config.Get().Idp.ResourceServers, nil)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -56,14 +56,14 @@ func ShowResourceServers(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    deleteUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.resourceservers.delete"))
+    deleteUrl, err := url.Parse(config.Get().Meui.ResourceServersDelete)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
       return
     }
 
-    publishingsUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.publishings.collection"))
+    publishingsUrl, err := url.Parse(config.Get().Meui.Publishings)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
@@ -106,15 +106,8 @@ func ShowResourceServers(env *environment.State) gin.HandlerFunc {
       return uiCreatedRs[i].Name > uiCreatedRs[j].Name
     })
 
-    c.HTML(http.StatusOK, "resourceservers.html", gin.H{
+    app.Render(c, http.StatusOK, "resourceservers.html", gin.H{
       "title": "Resource Servers",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
-      "id": identity.Id,
-      "user": identity.Username,
-      "name": identity.Name,
       "created": uiCreatedRs,
     })
   }
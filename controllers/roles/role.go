@@ -8,6 +8,7 @@ import (
   idp "github.com/opensentry/idp/client"
 
   "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/webhooks"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
 
@@ -15,7 +16,6 @@ import (
 
   bulky "github.com/charmixer/bulky/client"
 
-  "github.com/gorilla/csrf"
 )
 
 type formInput struct {
@@ -38,16 +38,8 @@ func ShowRole(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    c.HTML(http.StatusOK, "role.html", gin.H{
+    app.Render(c, http.StatusOK, "role.html", gin.H{
       "title": "Create new role",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "provider": config.GetString("provider.name"),
-      "id": identity.Id,
-      "user": identity.Username,
-      "name": identity.Name,
     })
 
   }
@@ -70,8 +62,8 @@ func SubmitRole(env *environment.State) gin.HandlerFunc {
     // must pass a pointer
     err := decoder.Decode(&form, c.Request.Form)
     if err != nil {
-      log.Panic(err)
-      c.AbortWithStatus(404)
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
       return
     }
 
@@ -84,18 +76,18 @@ func SubmitRole(env *environment.State) gin.HandlerFunc {
       Description: form.Description,
     })
 
-    url := config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.roles.collection")
+    url := config.Get().Idp.Roles
     httpStatus, responses, err := idp.CreateRoles(idpClient, url, createRolesRequests)
 
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
     if httpStatus != 200 {
       log.Debug("Failed to get 200 from " + url);
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -103,15 +95,15 @@ func SubmitRole(env *environment.State) gin.HandlerFunc {
     restStatus, restErr := bulky.Unmarshal(0, responses, &createRolesResponse)
 
     if restErr != nil {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-      c.AbortWithStatus(404)
+      app.KeepFormInput(c, "Name", "Description")
+      app.FlashRestErrors(c, 0, "", restErr, map[string]string{"Name": "Name", "Description": "Description"})
+      c.Redirect(http.StatusFound, "/role")
+      c.Abort()
       return
     }
 
     if restStatus == 200 {
+      app.EmitEvent(env, c, webhooks.RoleCreated, createRolesResponse)
       c.Redirect(http.StatusFound, "/roles")
       c.Abort()
       return
@@ -8,6 +8,7 @@ import (
   idp "github.com/opensentry/idp/client"
 
   "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/webhooks"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
   "github.com/opensentry/meui/utils"
@@ -16,7 +17,6 @@ import (
 
   bulky "github.com/charmixer/bulky/client"
 
-  "github.com/gorilla/csrf"
 )
 
 type formRoleDelete struct {
@@ -49,10 +49,10 @@ func ShowRoleDelete(env *environment.State) gin.HandlerFunc {
     var role *idp.Role
     idpClient := app.IdpClientUsingAuthorizationCode(env, c)
     readRequest := []idp.ReadRolesRequest{ {Id: roleToDeleteId} }
-    status, responses, err := idp.ReadRoles(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.roles.collection"), readRequest)
+    status, responses, err := idp.ReadRoles(idpClient, config.Get().Idp.Roles, readRequest)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -80,15 +80,10 @@ func ShowRoleDelete(env *environment.State) gin.HandlerFunc {
     }
 
 
-    c.HTML(http.StatusOK, "roledelete.html", gin.H{
+    app.Render(c, http.StatusOK, "roledelete.html", gin.H{
       "title": "Delete role",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
       "submitUrl": submitUrl,
       "role": role,
-      "provider": config.GetString("provider.name"),
     })
 
   }
@@ -111,8 +106,8 @@ func SubmitRoleDelete(env *environment.State) gin.HandlerFunc {
     // must pass a pointer
     err := decoder.Decode(&form, c.Request.Form)
     if err != nil {
-      log.Panic(err)
-      c.AbortWithStatus(404)
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
       return
     }
 
@@ -130,18 +125,18 @@ func SubmitRoleDelete(env *environment.State) gin.HandlerFunc {
       Id: form.Id,
     })
 
-    url := config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.roles.collection")
+    url := config.Get().Idp.Roles
     httpStatus, responses, err := idp.DeleteRoles(idpClient, url, deleteRolesRequests)
 
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
     if httpStatus != 200 {
       log.Debug("Failed to get 200 from " + url);
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -149,15 +144,14 @@ func SubmitRoleDelete(env *environment.State) gin.HandlerFunc {
     restStatus, restErr := bulky.Unmarshal(0, responses, &deleteRolesResponse)
 
     if restErr != nil {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-      c.AbortWithStatus(404)
+      app.FlashRestErrors(c, 0, "", restErr, nil)
+      c.Redirect(http.StatusFound, "/roles/delete?id="+form.Id)
+      c.Abort()
       return
     }
 
     if restStatus == 200 {
+      app.EmitEvent(env, c, webhooks.RoleDeleted, deleteRolesResponse)
       c.Redirect(http.StatusFound, "/roles")
       c.Abort()
       return
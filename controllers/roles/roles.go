@@ -45,10 +45,10 @@ func ShowRoles(env *environment.State) gin.HandlerFunc {
 
     idpClient := app.IdpClientUsingAuthorizationCode(env, c)
 
-    status, responses, err := idp.ReadRoles(idpClient, config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.roles.collection"), nil)
+    status, responses, err := idp.ReadRoles(idpClient, config.Get().Idp.Roles, nil)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -58,21 +58,21 @@ func ShowRoles(env *environment.State) gin.HandlerFunc {
       return
     }
 
-    grantsUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.access.grant"))
+    grantsUrl, err := url.Parse(config.Get().Meui.AccessGrant)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
       return
     }
 
-    deleteUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.roles.delete"))
+    deleteUrl, err := url.Parse(config.Get().Meui.RolesDelete)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
       return
     }
 
-    shadowsUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.shadows.collection"))
+    shadowsUrl, err := url.Parse(config.Get().Meui.Shadows)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
@@ -120,15 +120,8 @@ func ShowRoles(env *environment.State) gin.HandlerFunc {
       return uiCreatedRoles[i].Name > uiCreatedRoles[j].Name
     })
 
-    c.HTML(http.StatusOK, "roles.html", gin.H{
+    app.Render(c, http.StatusOK, "roles.html", gin.H{
       "title": "Roles",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
-      "id": identity.Id,
-      "user": identity.Username,
-      "name": identity.Name,
       "created": uiCreatedRoles,
     })
   }
@@ -1,9 +1,9 @@
 package shadows
 
 import (
+  "time"
   "net/http"
   "net/url"
-  "time"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
   //"github.com/gin-contrib/sessions"
@@ -11,21 +11,24 @@ import (
   aap "github.com/opensentry/aap/client"
 
   "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/webhooks"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
-
-  f "github.com/go-playground/form"
+  "github.com/opensentry/meui/forms"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/validators"
 
   bulky "github.com/charmixer/bulky/client"
 
-  "github.com/gorilla/csrf"
 )
 
+var shadowForm = forms.New("shadow")
+
 type formInput struct {
-  Identity string
-  Role string
-  StartDate string
-  EndDate string
+  Identity  string `validate:"required,identityid"`
+  Role      string `validate:"required"`
+  StartDate string `validate:"omitempty,datetime"`
+  EndDate   string `validate:"omitempty,datetime,dateafter=StartDate"`
 }
 
 func ShowShadow(env *environment.State) gin.HandlerFunc {
@@ -44,20 +47,17 @@ func ShowShadow(env *environment.State) gin.HandlerFunc {
 
     if !roleExists {
       log.Debug("Missing role in query")
-      c.AbortWithStatus(404)
+      app.AbortWithError(c, http.StatusBadRequest, "The link is missing a role.")
       return
     }
 
-    c.HTML(http.StatusOK, "shadow.html", gin.H{
+    values, errors := shadowForm.Populate(c, nil)
+
+    app.Render(c, http.StatusOK, "shadow.html", gin.H{
       "title": "Create new shadow",
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
       "role": role,
-      "id": identity.Id,
-      "user": identity.Username,
-      "name": identity.Name,
+      "form": values,
+      "errors": errors,
     })
 
   }
@@ -70,15 +70,15 @@ func SubmitShadow(env *environment.State) gin.HandlerFunc {
     log := c.MustGet(environment.LogKey).(*logrus.Entry)
 
     var form formInput
-    c.Request.ParseForm()
-
-    decoder := f.NewDecoder()
-
-    // must pass a pointer
-    err := decoder.Decode(&form, c.Request.Form)
+    valid, err := shadowForm.Bind(c, &form)
     if err != nil {
-      log.Panic(err)
-      c.AbortWithStatus(404)
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
+    }
+
+    if !valid {
+      shadowForm.RedirectBack(c)
       return
     }
 
@@ -86,25 +86,19 @@ func SubmitShadow(env *environment.State) gin.HandlerFunc {
 
     var createShadowsRequests []aap.CreateShadowsRequest
 
-    layout := "2006-01-02"
+    // Dates are entered in the timezone of the user, they are validated so they parse
+    loc := i18n.Location(c)
 
-    var nbf int64
+    // Without a start date the shadow starts now
+    nbf := time.Now().Unix()
     if form.StartDate != "" {
-      nbfTime, err := time.Parse(layout, form.StartDate)
-      if err != nil {
-         panic(err)
-      }
-
+      nbfTime, _ := validators.ParseDateTime(form.StartDate, loc)
       nbf = nbfTime.Unix()
     }
 
     var exp int64
     if form.EndDate != "" {
-      expTime, err := time.Parse(layout, form.EndDate)
-      if err != nil {
-         panic(err)
-      }
-
+      expTime, _ := validators.ParseDateTime(form.EndDate, loc)
       exp = expTime.Unix()
     }
 
@@ -115,18 +109,18 @@ func SubmitShadow(env *environment.State) gin.HandlerFunc {
       Expire: exp,
     })
 
-    callUrl := config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.shadows.collection")
+    callUrl := config.Get().Aap.Shadows
     httpStatus, responses, err := aap.CreateShadows(aapClient, callUrl, createShadowsRequests)
 
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
     if httpStatus != 200 {
       log.Debug("Failed to get 200 from " + callUrl);
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -134,15 +128,12 @@ func SubmitShadow(env *environment.State) gin.HandlerFunc {
     restStatus, restErr := bulky.Unmarshal(0, responses, &createShadowsResponse)
 
     if restErr != nil {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-      c.AbortWithStatus(404)
-      return
+      app.FlashRestErrors(c, 0, "", restErr, map[string]string{"Identity": "Identity", "Shadow": "Role", "NotBefore": "Start date", "Expire": "End date"})
+    } else if restStatus == 200 {
+      app.EmitEvent(env, c, webhooks.ShadowCreated, createShadowsResponse)
     }
 
-    successUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.shadows.collection"))
+    successUrl, err := url.Parse(config.Get().Meui.Shadows)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
@@ -154,13 +145,15 @@ func SubmitShadow(env *environment.State) gin.HandlerFunc {
     q.Add("role", form.Role)
     _successUrl.RawQuery = q.Encode()
 
-    if restStatus == 200 {
+    if restErr == nil && restStatus == 200 {
+      shadowForm.Clear(c)
+
       c.Redirect(http.StatusFound, _successUrl.String())
       c.Abort()
       return
     }
 
-    failureUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.shadow"))
+    failureUrl, err := url.Parse(config.Get().Meui.Shadow)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
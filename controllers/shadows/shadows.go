@@ -5,7 +5,6 @@ import (
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
   "github.com/gin-contrib/sessions"
   "golang.org/x/oauth2"
   oidc "github.com/coreos/go-oidc/v3/oidc"
@@ -17,6 +16,7 @@ import (
 
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
 
   "github.com/opensentry/meui/app"
 )
@@ -54,7 +54,7 @@ func ShowShadows(env *environment.State) gin.HandlerFunc {
     role, roleExists := c.GetQuery("role")
 
     if !roleExists {
-      c.AbortWithStatus(http.StatusNotFound)
+      app.AbortWithError(c, http.StatusBadRequest, "The link is missing a role.")
       log.Debug("Missing role in query")
       return
     }
@@ -65,15 +65,14 @@ func ShowShadows(env *environment.State) gin.HandlerFunc {
     var idToken *oidc.IDToken
     idToken = session.Get(environment.SessionIdTokenKey).(*oidc.IDToken)
     if idToken == nil {
-      c.HTML(http.StatusNotFound, "shadows.html", gin.H{"error": "Identity not found"})
-      c.Abort()
+      c.AbortWithStatus(http.StatusUnauthorized)
       return
     }
 
     var accessToken *oauth2.Token
     accessToken = session.Get(environment.SessionTokenKey).(*oauth2.Token)
-    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig, accessToken)
-    //idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig, accessToken)
+    aapClient := aap.NewAapClientWithUserAccessToken(env.HydraConfig(), accessToken)
+    //idpClient := idp.NewIdpClientWithUserAccessToken(env.HydraConfig(), accessToken)
 
     var responses []bulky.Response
     var err error
@@ -85,13 +84,13 @@ func ShowShadows(env *environment.State) gin.HandlerFunc {
 
     // fetch shadows
 
-    callUrl := config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.shadows.collection")
+    callUrl := config.Get().Aap.Shadows
     status, responses, err = aap.ReadShadows(aapClient, callUrl, []aap.ReadShadowsRequest{
       {Shadow: role},
     })
 
     if err != nil {
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       log.Debug(err.Error())
       return
     }
@@ -103,7 +102,7 @@ func ShowShadows(env *environment.State) gin.HandlerFunc {
     }
 
     if status != http.StatusOK {
-      c.AbortWithStatus(404)
+      c.AbortWithStatus(http.StatusBadGateway)
       log.Debug("Unable to get status 200 from /shadows")
       return
     }
@@ -111,23 +110,17 @@ func ShowShadows(env *environment.State) gin.HandlerFunc {
     var shadows aap.ReadShadowsResponse
     _, restErr = bulky.Unmarshal(0, responses, &shadows)
     if len(restErr) > 0 {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-
-      c.AbortWithStatus(404)
-      return
+      app.FlashRestErrors(c, 0, "", restErr, nil)
     }
 
-    deleteUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.shadows.delete"))
+    deleteUrl, err := url.Parse(config.Get().Meui.ShadowsDelete)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
       return
     }
 
-    createUrl, err := url.Parse(config.GetString("meui.public.url") + config.GetString("meui.public.endpoints.shadow"))
+    createUrl, err := url.Parse(config.Get().Meui.Shadow)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
@@ -155,16 +148,10 @@ func ShowShadows(env *environment.State) gin.HandlerFunc {
       })
     }
 
-    c.HTML(200, "shadows.html", gin.H{
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "title": "Identities shadowing " + ui.Role,
+    app.Render(c, 200, "shadows.html", gin.H{
+      "title": i18n.T(i18n.Language(c), "Identities shadowing %s", ui.Role),
       "created": ui,
       "createUrl": _createUrl.String(),
-      "user": identity.Username,
-      "name": identity.Name,
     })
 
   }
@@ -4,7 +4,6 @@ import (
   "net/http"
   "github.com/sirupsen/logrus"
   "github.com/gin-gonic/gin"
-  "github.com/gorilla/csrf"
 
   bulky "github.com/charmixer/bulky/client"
 
@@ -13,8 +12,10 @@ import (
 
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
 
   "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/webhooks"
   f "github.com/go-playground/form"
   "fmt"
 )
@@ -57,11 +58,11 @@ func ShowSubscriptions(env *environment.State) gin.HandlerFunc {
 
     var readPublishesResponse aap.ReadPublishesResponse
     if publisherExists {
-      url = config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.publishes")
+      url = config.Get().Aap.Publishes
       status, responses, err := aap.ReadPublishes(aapClient, url, []aap.ReadPublishesRequest{ {Publisher: publisher} })
       if err != nil {
         log.Debug(err.Error())
-        c.AbortWithStatus(http.StatusInternalServerError)
+        c.AbortWithStatus(http.StatusBadGateway)
         return
       }
 
@@ -71,28 +72,22 @@ func ShowSubscriptions(env *environment.State) gin.HandlerFunc {
       }
 
       if status != http.StatusOK {
-        log.Debug(err.Error())
-        c.AbortWithStatus(http.StatusInternalServerError)
+        log.Debug("Failed to get 200 from " + url)
+        c.AbortWithStatus(http.StatusBadGateway)
         return
       }
 
       _, restErr = bulky.Unmarshal(0, responses, &readPublishesResponse)
       if len(restErr) > 0 {
-        for _,e := range restErr {
-          // TODO show user somehow
-          log.Debug("Rest error: " + e.Error)
-        }
-
-        c.AbortWithStatus(http.StatusInternalServerError)
-        return
+        app.FlashRestErrors(c, 0, "", restErr, nil)
       }
     }
 
-    url = config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.subscriptions.collection")
+    url = config.Get().Aap.Subscriptions
     status, responses, err := aap.ReadSubscriptions(aapClient, url, []aap.ReadSubscriptionsRequest{ {Subscriber: receiver} })
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -102,21 +97,15 @@ func ShowSubscriptions(env *environment.State) gin.HandlerFunc {
     }
 
     if status != http.StatusOK {
-      log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      log.Debug("Failed to get 200 from " + url)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
     var subscriptions aap.ReadSubscriptionsResponse
     _, restErr = bulky.Unmarshal(0, responses, &subscriptions)
     if len(restErr) > 0 {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
+      app.FlashRestErrors(c, 0, "", restErr, nil)
     }
 
     var hasSubscribedMap = make(map[string]bool, len(subscriptions))
@@ -124,11 +113,11 @@ func ShowSubscriptions(env *environment.State) gin.HandlerFunc {
       hasSubscribedMap[s.Scope] = true
     }
 
-    url = config.GetString("idp.public.url") + config.GetString("idp.public.endpoints.resourceservers.collection")
+    url = config.Get().Idp.ResourceServers
     status, responses, err = idp.ReadResourceServers(idpClient, url, nil)
     if err != nil {
       log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
@@ -138,30 +127,19 @@ func ShowSubscriptions(env *environment.State) gin.HandlerFunc {
     }
 
     if status != http.StatusOK {
-      log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
+      log.Debug("Failed to get 200 from " + url)
+      c.AbortWithStatus(http.StatusBadGateway)
       return
     }
 
     var resourceservers idp.ReadResourceServersResponse
     _, restErr = bulky.Unmarshal(0, responses, &resourceservers)
     if len(restErr) > 0 {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
-      }
-
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
+      app.FlashRestErrors(c, 0, "", restErr, nil)
     }
 
-    c.HTML(200, "subscriptions.html", gin.H{
-      csrf.TemplateTag: csrf.TemplateField(c.Request),
-      "links": []map[string]string{
-        {"href": "/public/css/dashboard.css"},
-      },
-      "provider": config.GetString("provider.name"),
-      "title": "Subscriptions for " + receiver,
+    app.Render(c, 200, "subscriptions.html", gin.H{
+      "title": i18n.T(i18n.Language(c), "Subscriptions for %s", receiver),
       "hasSubscribedMap": hasSubscribedMap,
       "publishes": readPublishesResponse,
       "resourceservers": resourceservers,
@@ -197,7 +175,7 @@ func SubmitSubscriptions(env *environment.State) gin.HandlerFunc {
         "publisher": publisher,
         "receiver": receiver,
       }).Debug("publisher and receiver must exists")
-      c.AbortWithStatus(http.StatusBadRequest)
+      app.AbortWithError(c, http.StatusBadRequest, "The link is missing a publisher or receiver.")
       return
     }
 
@@ -209,15 +187,48 @@ func SubmitSubscriptions(env *environment.State) gin.HandlerFunc {
     // must pass a pointer
     err := decoder.Decode(&form, c.Request.Form)
     if err != nil {
-      log.Panic(err)
-      c.AbortWithStatus(404)
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
+    }
+
+    url := config.Get().Aap.Subscriptions
+
+    // Only what changes is sent, so webhook events are emitted for subscriptions actually created or deleted
+    status, responses, err := aap.ReadSubscriptions(aapClient, url, []aap.ReadSubscriptionsRequest{
+      {Subscriber: receiver, Publisher: publisher},
+    })
+    if err != nil {
+      log.Debug(err.Error())
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
+    if status == http.StatusForbidden {
+      c.AbortWithStatus(http.StatusForbidden)
       return
     }
+    if status != http.StatusOK {
+      log.Debug("Failed to get 200 from " + url)
+      c.AbortWithStatus(http.StatusBadGateway)
+      return
+    }
+
+    var current aap.ReadSubscriptionsResponse
+    if len(responses) > 0 {
+      bulky.Unmarshal(0, responses, &current) // Not found is no subscriptions
+    }
+    subscribed := make(map[string]bool, len(current))
+    for _, s := range current {
+      subscribed[s.Scope] = true
+    }
 
     var createSubscriptionsRequests []aap.CreateSubscriptionsRequest
     var deleteSubscriptionsRequests []aap.DeleteSubscriptionsRequest
     for _,publishing := range form.Publishings {
       if publishing.Subscribed {
+        if subscribed[publishing.Scope] {
+          continue
+        }
         createSubscriptionsRequests = append(createSubscriptionsRequests, aap.CreateSubscriptionsRequest{
           Subscriber: receiver,
           Publisher: publisher,
@@ -226,6 +237,10 @@ func SubmitSubscriptions(env *environment.State) gin.HandlerFunc {
         continue;
       }
 
+      if !subscribed[publishing.Scope] {
+        continue
+      }
+
       // deny by default
       deleteSubscriptionsRequests = append(deleteSubscriptionsRequests, aap.DeleteSubscriptionsRequest{
         Subscriber: receiver,
@@ -234,38 +249,78 @@ func SubmitSubscriptions(env *environment.State) gin.HandlerFunc {
       })
     }
 
-    url := config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.subscriptions.collection")
+    if createSubscriptionsRequests != nil {
+      status, responses, err = aap.CreateSubscriptions(aapClient, url, createSubscriptionsRequests)
+      if err != nil {
+        log.Debug(err.Error())
+        c.AbortWithStatus(http.StatusBadGateway)
+        return
+      }
 
-    status, responses, err := aap.CreateSubscriptions(aapClient, url, createSubscriptionsRequests)
-    if err != nil {
-      log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
-    }
+      if status == http.StatusForbidden {
+        c.AbortWithStatus(http.StatusForbidden)
+        return
+      }
 
-    if status == http.StatusForbidden {
-      c.AbortWithStatus(http.StatusForbidden)
-      return
-    }
+      if status != http.StatusOK {
+        log.Debug("Failed to get 200 from " + url)
+        c.AbortWithStatus(http.StatusBadGateway)
+        return
+      }
 
-    if status != http.StatusOK {
-      log.Debug(err.Error())
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
-    }
+      // Requests are sent in form order, so the response index tells which scope failed
+      for _, r := range responses {
+        var createSubscriptions aap.CreateSubscriptionsResponse
+        _, restErr := bulky.Unmarshal(r.Index, responses, &createSubscriptions)
+        if restErr != nil {
+          var scope string
+          if r.Index < len(createSubscriptionsRequests) {
+            scope = createSubscriptionsRequests[r.Index].Scope
+          }
+          app.FlashRestErrors(c, r.Index, scope, restErr, nil)
+          continue
+        }
 
-    var createSubscriptions aap.CreateSubscriptionsResponse
-    _, restErr := bulky.Unmarshal(0, responses, &createSubscriptions)
-    if restErr != nil {
-      for _,e := range restErr {
-        // TODO show user somehow
-        log.Debug("Rest error: " + e.Error)
+        app.EmitEvent(env, c, webhooks.SubscriptionCreated, createSubscriptions)
       }
-      c.AbortWithStatus(http.StatusInternalServerError)
-      return
     }
 
-    log.Debug(createSubscriptions)
+    if deleteSubscriptionsRequests != nil {
+      status, responses, err = aap.DeleteSubscriptions(aapClient, url, deleteSubscriptionsRequests)
+      if err != nil {
+        log.Debug(err.Error())
+        c.AbortWithStatus(http.StatusBadGateway)
+        return
+      }
+
+      if status == http.StatusForbidden {
+        c.AbortWithStatus(http.StatusForbidden)
+        return
+      }
+
+      if status != http.StatusOK {
+        log.Debug("Failed to get 200 from " + url)
+        c.AbortWithStatus(http.StatusBadGateway)
+        return
+      }
+
+      for _, r := range responses {
+        var deleteSubscriptions aap.DeleteSubscriptionsResponse
+        _, restErr := bulky.Unmarshal(r.Index, responses, &deleteSubscriptions)
+        if restErr != nil {
+          var scope string
+          if r.Index < len(deleteSubscriptionsRequests) {
+            scope = deleteSubscriptionsRequests[r.Index].Scope
+          }
+          app.FlashRestErrors(c, r.Index, scope, restErr, nil)
+          continue
+        }
+
+        if r.Index < len(deleteSubscriptionsRequests) {
+          app.EmitEvent(env, c, webhooks.SubscriptionDeleted, deleteSubscriptionsRequests[r.Index])
+        }
+      }
+    }
 
     c.Redirect(http.StatusFound, fmt.Sprintf("/subscriptions?receiver=%s&publisher=%s", receiver, publisher))
     c.Abort()
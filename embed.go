@@ -0,0 +1,10 @@
+package main
+
+import (
+  "embed"
+)
+
+// Templates and public assets are compiled into the binary, so meui does not depend on its working directory.
+// Set assets.path to override them from disk.
+//go:embed views public
+var embedded embed.FS
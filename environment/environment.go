@@ -1,9 +1,13 @@
 package environment
 
 import (
+  "sync"
   "golang.org/x/oauth2"
   "golang.org/x/oauth2/clientcredentials"
   oidc "github.com/coreos/go-oidc/v3/oidc"
+
+  "github.com/opensentry/meui/invitestore"
+  "github.com/opensentry/meui/webhooks"
 )
 
 type SessionKeys struct {
@@ -19,12 +23,47 @@ const (
   AccessTokenKey string = "access_token"
   IdTokenKey string = "id_token"
   LogKey string = "log"
+  CspNonceKey string = "csp.nonce"
+  LanguageKey string = "language"
+  LocationKey string = "location"
 )
 
 type State struct {
   SessionKeys *SessionKeys
   Provider *oidc.Provider
-  IdpApiConfig *clientcredentials.Config
-  AapApiConfig *clientcredentials.Config
-  HydraConfig *oauth2.Config
+  Webhooks *webhooks.Dispatcher
+  Invites *invitestore.Store
+
+  // The oauth2 configurations hold the client secret, they are replaced when it is rotated. See SetOAuth2Configs.
+  mu sync.RWMutex
+  idpApiConfig *clientcredentials.Config
+  aapApiConfig *clientcredentials.Config
+  hydraConfig *oauth2.Config
+}
+
+func (s *State) HydraConfig() *oauth2.Config {
+  s.mu.RLock()
+  defer s.mu.RUnlock()
+  return s.hydraConfig
+}
+
+func (s *State) IdpApiConfig() *clientcredentials.Config {
+  s.mu.RLock()
+  defer s.mu.RUnlock()
+  return s.idpApiConfig
+}
+
+func (s *State) AapApiConfig() *clientcredentials.Config {
+  s.mu.RLock()
+  defer s.mu.RUnlock()
+  return s.aapApiConfig
+}
+
+// SetOAuth2Configs replaces the oauth2 configurations, requests already holding the old ones finish with them
+func (s *State) SetOAuth2Configs(hydraConfig *oauth2.Config, idpApiConfig *clientcredentials.Config, aapApiConfig *clientcredentials.Config) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.hydraConfig = hydraConfig
+  s.idpApiConfig = idpApiConfig
+  s.aapApiConfig = aapApiConfig
 }
@@ -0,0 +1,318 @@
+package forms
+
+import (
+  "fmt"
+  "errors"
+  "reflect"
+  "strings"
+  "unicode"
+  "net/http"
+  "gopkg.in/go-playground/validator.v9"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+  "github.com/gin-contrib/sessions"
+
+  "github.com/go-playground/form"
+
+  "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/utils"
+  "github.com/opensentry/meui/validators"
+)
+
+// Form binds a posted html form to a struct and keeps the input and validation errors in the session,
+// so the form page can show them again after redirecting back.
+// Fields are decoded by their form tag, or struct field name if none, and validated by their validate tag.
+// Templates find the kept input and errors by struct field name, eg. {{ .form.Email }} and {{ .errors.Email }}, or by path for slices of structs, eg. "Grants[0].StartDate"
+type Form struct {
+  Name string
+
+  decoder *form.Decoder
+  validate *validator.Validate
+}
+
+// New creates a form. name must be unique as it prefixes the session keys, eg. "invite" keeps "invite.fields" and "invite.errors"
+func New(name string) *Form {
+  validate := validator.New()
+  validators.Register(validate)
+
+  return &Form{
+    Name: name,
+    decoder: form.NewDecoder(),
+    validate: validate,
+  }
+}
+
+func (f *Form) fieldsKey() string {
+  return f.Name + ".fields"
+}
+
+func (f *Form) errorsKey() string {
+  return f.Name + ".errors"
+}
+
+// Bind decodes the posted form into v, which must be a pointer to a struct, and validates it.
+// The input is always kept so the form can be shown again if a later step fails, eg. the idp rejecting it. Clear it on success.
+// Returns false if validation failed, the errors are kept with the input. An error is returned only for malformed requests.
+func (f *Form) Bind(c *gin.Context, v interface{}) (bool, error) {
+  err := c.Request.ParseForm()
+  if err != nil {
+    return false, err
+  }
+
+  err = f.decoder.Decode(v, c.Request.PostForm)
+  if err != nil {
+    return false, err
+  }
+
+  session := sessions.Default(c)
+  session.Delete(f.fieldsKey())
+  session.Delete(f.errorsKey())
+  session.AddFlash(fieldValues(v), f.fieldsKey())
+
+  valid := true
+  // Dates are entered in the timezone of the user, the handlers parse them in it as well
+  err = f.validate.StructCtx(validators.WithLocation(c.Request.Context(), i18n.Location(c)), v)
+  if err != nil {
+
+    // Validation syntax is invalid
+    var invalid *validator.InvalidValidationError
+    if errors.As(err, &invalid) {
+      return false, err
+    }
+
+    messages := validationMessages(i18n.Language(c), err.(validator.ValidationErrors))
+    session.AddFlash(messages, f.errorsKey())
+    valid = false
+  }
+
+  err = session.Save()
+  if err != nil {
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log.Debug(err.Error())
+  }
+  return valid, nil
+}
+
+// Validator for json input, fields are named by their json tag
+var jsonValidate = newJsonValidate()
+
+func newJsonValidate() *validator.Validate {
+  validate := validator.New()
+  validators.Register(validate)
+  validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+    name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+    if name == "-" {
+      return ""
+    }
+    return name
+  })
+  return validate
+}
+
+// ValidateJson validates v, a struct decoded from a json body, like Bind validates forms.
+// Returns the messages by json field name, eg. "redirect_uris", nil if v is valid. An error is returned only if the validate tags are invalid.
+func ValidateJson(lang string, v interface{}) (map[string][]string, error) {
+  err := jsonValidate.Struct(v)
+  if err == nil {
+    return nil, nil
+  }
+
+  var invalid *validator.InvalidValidationError
+  if errors.As(err, &invalid) {
+    return nil, err
+  }
+  return validationMessages(lang, err.(validator.ValidationErrors)), nil
+}
+
+func validationMessages(lang string, validationErrors validator.ValidationErrors) map[string][]string {
+  messages := make(map[string][]string)
+  for _, e := range validationErrors {
+    name := e.Namespace()
+    if i := strings.Index(name, "."); i >= 0 {
+      name = name[i+1:] // Drop the struct name, eg. inviteInput.Email
+    }
+    if i := strings.LastIndex(name, "["); i > 0 && strings.HasSuffix(name, "]") {
+      name = name[:i] // Errors for slice elements are shown on the slice, eg. RedirectUri[1]
+    }
+    messages[name] = appendUnique(messages[name], Translate(lang, e.Tag(), e.Param()))
+  }
+  return messages
+}
+
+// RedirectBack sends the user back to the page the form was posted from, query included, where Populate shows the kept input and errors.
+func (f *Form) RedirectBack(c *gin.Context) {
+  log := c.MustGet(environment.LogKey).(*logrus.Entry)
+
+  q := c.Request.URL.Query()
+  submitUrl, err := utils.FetchSubmitUrlFromRequest(c.Request, &q)
+  if err != nil {
+    log.Debug(err.Error())
+    c.AbortWithStatus(http.StatusInternalServerError)
+    return
+  }
+  log.WithFields(logrus.Fields{"redirect_to": submitUrl}).Debug("Redirecting")
+  c.Redirect(http.StatusFound, submitUrl)
+  c.Abort()
+}
+
+// AddError keeps message for field after a successful Bind, for checks validation cannot make, eg. against what idp holds.
+// RedirectBack then shows it like a validation error.
+func (f *Form) AddError(c *gin.Context, field string, message string) {
+  session := sessions.Default(c)
+  session.Delete(f.errorsKey())
+  session.AddFlash(map[string][]string{field: {message}}, f.errorsKey())
+  err := session.Save()
+  if err != nil {
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log.Debug(err.Error())
+  }
+}
+
+// Clear forgets the kept input and errors, call it when the submit succeeded.
+func (f *Form) Clear(c *gin.Context) {
+  session := sessions.Default(c)
+  session.Delete(f.fieldsKey())
+  session.Delete(f.errorsKey())
+  err := session.Save()
+  if err != nil {
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log.Debug(err.Error())
+  }
+}
+
+// Populate returns the kept input, or defaults when nothing was kept, and the error messages per field. Pass them to the page as "form" and "errors".
+func (f *Form) Populate(c *gin.Context, defaults map[string]string) (map[string]string, map[string]string) {
+  session := sessions.Default(c)
+
+  values := make(map[string]string)
+  for k, v := range defaults {
+    values[k] = v
+  }
+
+  fields := session.Flashes(f.fieldsKey())
+  if len(fields) > 0 {
+    if kept, ok := fields[0].(map[string][]string); ok {
+      for k, v := range kept {
+        values[k] = strings.Join(v, ", ")
+      }
+    }
+  }
+
+  messages := make(map[string]string)
+  errs := session.Flashes(f.errorsKey())
+  if len(errs) > 0 {
+    if kept, ok := errs[0].(map[string][]string); ok {
+      for k, v := range kept {
+        messages[k] = strings.Join(v, ", ")
+      }
+    }
+  }
+
+  err := session.Save() // Remove flashes read
+  if err != nil {
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log.Debug(err.Error())
+  }
+
+  return values, messages
+}
+
+// Translate returns the message shown for a failed validation tag in lang
+func Translate(lang string, tag string, param string) string {
+  switch tag {
+  case "required", "required_without":
+    return i18n.T(lang, "Field is required")
+  case "notblank":
+    return i18n.T(lang, "Field is not allowed to be blank")
+  case "email":
+    return i18n.T(lang, "Field must be a valid e-mail")
+  case "url", "uri":
+    return i18n.T(lang, "Field must be a valid url")
+  case "eqfield":
+    return i18n.T(lang, "Field should be equal to the %s", param)
+  case "min", "gte":
+    return i18n.T(lang, "Field is too short or too small")
+  case "max", "lte":
+    return i18n.T(lang, "Field is too long or too large")
+  case "unique":
+    return i18n.T(lang, "Field contains duplicates")
+  case "redirecturi":
+    return i18n.T(lang, "Field must be absolute https urls without a fragment")
+  case "scope":
+    return i18n.T(lang, "Field must be a single scope without spaces, quotes or backslashes")
+  case "audience":
+    return i18n.T(lang, "Field must be an absolute uri, eg. https://api.example.com")
+  case "username":
+    return i18n.T(lang, "Field must be 3 to 32 lowercase letters, digits, dots, dashes or underscores starting with a letter")
+  case "identityid", "uuid":
+    return i18n.T(lang, "Field must be a valid identity id")
+  case "datetime":
+    return i18n.T(lang, "Field must be a valid date and time")
+  case "dateafter":
+    return i18n.T(lang, "Field must be after the %s", i18n.T(lang, fieldLabel(param)))
+  case "future":
+    return i18n.T(lang, "Field must be in the future")
+  default:
+    return i18n.T(lang, "Field is invalid")
+  }
+}
+
+// Name of a struct field as words, eg. "StartDate" is "start date"
+func fieldLabel(name string) string {
+  var words []string
+  start := 0
+  for i, r := range name {
+    if i > 0 && unicode.IsUpper(r) {
+      words = append(words, strings.ToLower(name[start:i]))
+      start = i
+    }
+  }
+  words = append(words, strings.ToLower(name[start:]))
+  return strings.Join(words, " ")
+}
+
+func appendUnique(messages []string, message string) []string {
+  for _, m := range messages {
+    if m == message {
+      return messages
+    }
+  }
+  return append(messages, message)
+}
+
+// Keep values by the path of the struct field, eg. "Email" or "Grants[0].StartDate" for slices of structs.
+// Slices of other kinds are kept as they are, anything else but strings is formatted with fmt.
+func fieldValues(v interface{}) map[string][]string {
+  values := make(map[string][]string)
+  keepFields(values, "", reflect.Indirect(reflect.ValueOf(v)))
+  return values
+}
+
+func keepFields(values map[string][]string, prefix string, rv reflect.Value) {
+  rt := rv.Type()
+  for i := 0; i < rt.NumField(); i++ {
+    field := rt.Field(i)
+    if field.PkgPath != "" {
+      continue // unexported
+    }
+
+    name := prefix + field.Name
+    value := rv.Field(i)
+    switch value.Kind() {
+    case reflect.String:
+      values[name] = []string{value.String()}
+    case reflect.Slice:
+      for j := 0; j < value.Len(); j++ {
+        elem := reflect.Indirect(value.Index(j))
+        if elem.Kind() == reflect.Struct {
+          keepFields(values, fmt.Sprintf("%s[%d].", name, j), elem)
+          continue
+        }
+        values[name] = append(values[name], fmt.Sprint(elem.Interface()))
+      }
+    default:
+      values[name] = []string{fmt.Sprint(value.Interface())}
+    }
+  }
+}
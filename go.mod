@@ -1,10 +1,11 @@
 module github.com/opensentry/meui
 
-go 1.15
+go 1.16
 
 require (
 	github.com/charmixer/bulky v0.0.0-20210207184256-e3c22de48569
 	github.com/coreos/go-oidc/v3 v3.0.0
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/gin-contrib/sessions v0.0.3
 	github.com/gin-gonic/gin v1.6.3
 	github.com/go-playground/form v3.1.4+incompatible
@@ -19,4 +20,5 @@ require (
 	golang.org/x/net v0.0.0-20210119194325-5f4716e94777
 	golang.org/x/oauth2 v0.0.0-20210201163806-010130855d6c
 	gopkg.in/go-playground/validator.v9 v9.31.0
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,206 @@
+package i18n
+
+import (
+  "fmt"
+  "sort"
+  "time"
+  "strings"
+  "strconv"
+  "embed"
+  "encoding/json"
+  "html/template"
+  "github.com/gin-gonic/gin"
+
+  _ "time/tzdata" // Timezones of users, also when built from scratch without zoneinfo
+
+  "github.com/opensentry/meui/environment"
+)
+
+// Language used when nothing better is negotiated, and for messages missing in a catalog
+const DefaultLanguage = "en"
+
+// Cookie holding the language the user picked, it takes precedence over Accept-Language
+const PreferenceCookie = "meui.lang"
+
+// Cookie holding the IANA timezone of the browser, eg. "Europe/Copenhagen". Set by the page script in htmlbegin.
+const TimezoneCookie = "meui.tz"
+
+// Catalog keys that are not english text, the english text itself is the key of every other message
+const (
+  LanguageNameKey = "language.name"
+  DateFormatKey = "format.date"
+  DateTimeFormatKey = "format.datetime"
+)
+
+//go:embed locales/*.json
+var catalogFiles embed.FS
+
+// Messages by language, eg. catalogs["da"]["Sign out"] is "Log ud"
+var catalogs = map[string]map[string]string{}
+
+func init() {
+  entries, err := catalogFiles.ReadDir("locales")
+  if err != nil {
+    panic(err)
+  }
+
+  for _, e := range entries {
+    data, err := catalogFiles.ReadFile("locales/" + e.Name())
+    if err != nil {
+      panic(err)
+    }
+
+    var messages map[string]string
+    err = json.Unmarshal(data, &messages)
+    if err != nil {
+      panic(fmt.Sprintf("locales/%s: %s", e.Name(), err.Error()))
+    }
+    catalogs[strings.TrimSuffix(e.Name(), ".json")] = messages
+  }
+}
+
+// Locale is a language meui has a catalog for, Name is in the language itself, eg. "Dansk"
+type Locale struct {
+  Code string
+  Name string
+}
+
+// Locales returns the languages meui has a catalog for, sorted by code
+func Locales() []Locale {
+  var codes []string
+  for code, _ := range catalogs {
+    codes = append(codes, code)
+  }
+  sort.Strings(codes)
+
+  var locales []Locale
+  for _, code := range codes {
+    locales = append(locales, Locale{Code: code, Name: T(code, LanguageNameKey)})
+  }
+  return locales
+}
+
+func Supported(lang string) bool {
+  _, exists := catalogs[lang]
+  return exists
+}
+
+// T translates message into lang. Messages missing in the catalog fall back to the default language and then to the message itself.
+// With args the translation is used as a fmt format, eg. T("da", "Field should be equal to the %s", "Password")
+func T(lang string, message string, args ...interface{}) string {
+  translation, exists := catalogs[lang][message]
+  if !exists {
+    translation, exists = catalogs[DefaultLanguage][message]
+    if !exists {
+      translation = message
+    }
+  }
+
+  if len(args) > 0 {
+    return fmt.Sprintf(translation, args...)
+  }
+  return translation
+}
+
+// Date formats a unix timestamp as a date in lang and loc. Zero is formatted as empty.
+func Date(lang string, loc *time.Location, unix int64) string {
+  if unix == 0 {
+    return ""
+  }
+  return time.Unix(unix, 0).In(loc).Format(T(lang, DateFormatKey))
+}
+
+// DateTime formats a unix timestamp as date and time in lang and loc. Zero is formatted as empty.
+func DateTime(lang string, loc *time.Location, unix int64) string {
+  if unix == 0 {
+    return ""
+  }
+  return time.Unix(unix, 0).In(loc).Format(T(lang, DateTimeFormatKey))
+}
+
+// FuncMap returns the template functions for translation, eg. {{ t .lang "Sign out" }} and {{ date .lang .tz .NotBefore }}
+func FuncMap() template.FuncMap {
+  return template.FuncMap{
+    "t": T,
+    "date": Date,
+    "datetime": DateTime,
+  }
+}
+
+// Language returns the language negotiated for the request
+func Language(c *gin.Context) string {
+  if lang := c.GetString(environment.LanguageKey); lang != "" {
+    return lang
+  }
+  return DefaultLanguage
+}
+
+// Location returns the timezone of the user, UTC until the browser has told us
+func Location(c *gin.Context) *time.Location {
+  if loc, ok := c.Get(environment.LocationKey); ok {
+    return loc.(*time.Location)
+  }
+  return time.UTC
+}
+
+// Negotiate picks the language for each request, the users preference if set, else the best match of Accept-Language.
+// Handlers find it with Language(c). The timezone of the browser, if known, is found with Location(c).
+func Negotiate() gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    lang := ""
+    if preferred, err := c.Cookie(PreferenceCookie); err == nil && Supported(preferred) {
+      lang = preferred
+    } else {
+      lang = match(c.GetHeader("Accept-Language"))
+    }
+
+    c.Set(environment.LanguageKey, lang)
+
+    loc := time.UTC
+    if tz, err := c.Cookie(TimezoneCookie); err == nil && tz != "" {
+      if l, err := time.LoadLocation(tz); err == nil {
+        loc = l
+      }
+    }
+    c.Set(environment.LocationKey, loc)
+
+    c.Header("Content-Language", lang)
+    c.Writer.Header().Add("Vary", "Accept-Language")
+    c.Next()
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// Best supported language in an Accept-Language header, eg. "da-DK,da;q=0.9,en;q=0.8" is "da"
+func match(acceptLanguage string) string {
+  best := DefaultLanguage
+  bestQ := 0.0
+
+  for _, part := range strings.Split(acceptLanguage, ",") {
+    fields := strings.Split(strings.TrimSpace(part), ";")
+
+    tag := strings.ToLower(strings.TrimSpace(fields[0]))
+    if i := strings.Index(tag, "-"); i > 0 {
+      tag = tag[:i]
+    }
+
+    q := 1.0
+    for _, param := range fields[1:] {
+      param = strings.TrimSpace(param)
+      if strings.HasPrefix(param, "q=") {
+        v, err := strconv.ParseFloat(param[2:], 64)
+        if err == nil {
+          q = v
+        }
+      }
+    }
+
+    if Supported(tag) && q > bestQ {
+      best = tag
+      bestQ = q
+    }
+  }
+
+  return best
+}
@@ -0,0 +1,317 @@
+// Package invitecsv imports invites from csv files of email, username and expiry, used by the invite import page and
+// meui invites import. Rows are validated first, the valid ones are created in batches and optionally sent, and the
+// outcome of every row can be written back as csv.
+package invitecsv
+
+import (
+  "io"
+  "fmt"
+  "errors"
+  "sort"
+  "time"
+  "strings"
+  "net/http"
+  "encoding/csv"
+  bulky "github.com/charmixer/bulky/client"
+  idp "github.com/opensentry/idp/client"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/forms"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/webhooks"
+)
+
+// Status of a row
+const (
+  Valid = "valid" // Not submitted yet
+  Invalid = "invalid"
+  Created = "created"
+  Sent = "sent"
+  NotSent = "not sent" // Created, but sending it failed
+  Failed = "failed"
+)
+
+// Layout of expiry dates, the one of the invite page. RFC3339 is accepted as well.
+const DateLayout = "2006-01-02"
+
+// Columns of the results
+var resultsHeader = []string{"line", "email", "username", "expires", "status", "id", "sent_at", "error"}
+
+// Row of a csv file and its outcome
+type Row struct {
+  Line int `json:"line"` // Number of the record in the file, header included
+  Email string `json:"email"`
+  Username string `json:"username,omitempty"`
+  Expires string `json:"expires,omitempty"` // As written in the file
+  ExpiresAt int64 `json:"exp,omitempty"`
+  Status string `json:"status"`
+  Errors []string `json:"errors,omitempty"` // Why the row is invalid, failed or was not sent
+  Id string `json:"id,omitempty"`
+  SentAt int64 `json:"sent_at,omitempty"`
+}
+
+// Validated like the invite page validates its form
+type rowInput struct {
+  Email string `json:"email" validate:"required,email"`
+  Username string `json:"username" validate:"omitempty,username"`
+}
+
+// Parse reads and validates the rows of a csv file. The columns are email, username and expires, in that order or in
+// the order of a header row naming them. Returns an error if the file is not csv or has more than maxRows rows.
+func Parse(r io.Reader, lang string, maxRows int) ([]Row, error) {
+  reader := csv.NewReader(r)
+  reader.FieldsPerRecord = -1
+  reader.TrimLeadingSpace = true
+
+  records, err := reader.ReadAll()
+  if err != nil {
+    return nil, err
+  }
+
+  columns := map[string]int{"email": 0, "username": 1, "expires": 2}
+  first := 0
+  if len(records) > 0 && isHeader(records[0]) {
+    columns = map[string]int{}
+    for i, name := range records[0] {
+      columns[strings.ToLower(strings.TrimSpace(name))] = i
+    }
+    if _, exists := columns["email"]; !exists {
+      return nil, errors.New(i18n.T(lang, "The header row has no email column"))
+    }
+    first = 1
+  }
+
+  value := func(record []string, column string) string {
+    i, exists := columns[column]
+    if !exists || i >= len(record) {
+      return ""
+    }
+    return strings.TrimSpace(record[i])
+  }
+
+  var rows []Row
+  emails := map[string]int{}
+  now := time.Now()
+  for i, record := range records[first:] {
+    if isBlank(record) {
+      continue
+    }
+    if len(rows) == maxRows {
+      return nil, errors.New(i18n.T(lang, "The file has more than %d rows", maxRows))
+    }
+
+    row := Row{Line: i + first + 1, Email: value(record, "email"), Username: value(record, "username"), Expires: value(record, "expires")}
+
+    messages, err := forms.ValidateJson(lang, rowInput{Email: row.Email, Username: row.Username})
+    if err != nil {
+      return nil, err
+    }
+    for _, field := range []string{"email", "username"} {
+      for _, message := range messages[field] {
+        row.Errors = append(row.Errors, field + ": " + message)
+      }
+    }
+
+    if row.Expires != "" {
+      expiresAt, ok := parseDate(row.Expires)
+      switch {
+      case !ok:
+        row.Errors = append(row.Errors, "expires: " + forms.Translate(lang, "datetime", ""))
+      case expiresAt <= now.Unix():
+        row.Errors = append(row.Errors, "expires: " + i18n.T(lang, "Field must be in the future"))
+      default:
+        row.ExpiresAt = expiresAt
+      }
+    }
+
+    email := strings.ToLower(row.Email)
+    if previous, exists := emails[email]; exists && email != "" {
+      row.Errors = append(row.Errors, "email: " + i18n.T(lang, "Already on line %d", previous))
+    } else {
+      emails[email] = row.Line
+    }
+
+    row.Status = Valid
+    if len(row.Errors) > 0 {
+      row.Status = Invalid
+    }
+    rows = append(rows, row)
+  }
+  return rows, nil
+}
+
+func isHeader(record []string) bool {
+  for _, name := range record {
+    if strings.ToLower(strings.TrimSpace(name)) == "email" {
+      return true
+    }
+  }
+  return false
+}
+
+func isBlank(record []string) bool {
+  for _, value := range record {
+    if strings.TrimSpace(value) != "" {
+      return false
+    }
+  }
+  return true
+}
+
+// Dates are midnight UTC, like those of the invite page
+func parseDate(value string) (int64, bool) {
+  t, err := time.Parse(DateLayout, value)
+  if err == nil {
+    return t.Unix(), true
+  }
+  t, err = time.Parse(time.RFC3339, value)
+  if err == nil {
+    return t.Unix(), true
+  }
+  return 0, false
+}
+
+// Count returns the number of rows by status
+func Count(rows []Row) map[string]int {
+  counts := map[string]int{}
+  for _, row := range rows {
+    counts[row.Status]++
+  }
+  return counts
+}
+
+// Create creates the invites of the valid rows in batches of batchSize. Rows become Created or Failed.
+// emit is called with webhooks.InviteCreated for every invite created.
+func Create(client *idp.IdpClient, url string, rows []Row, batchSize int, emit func(eventType string, data interface{})) {
+  var pending []int
+  for i, row := range rows {
+    if row.Status == Valid {
+      pending = append(pending, i)
+    }
+  }
+
+  for _, batch := range batches(pending, batchSize) {
+    var requests []idp.CreateInvitesRequest
+    for _, i := range batch {
+      requests = append(requests, idp.CreateInvitesRequest{Email: rows[i].Email, Username: rows[i].Username, ExpiresAt: rows[i].ExpiresAt})
+    }
+
+    status, responses, err := idp.CreateInvites(client, url, requests)
+    for n, i := range batch {
+      var invite idp.CreateInvitesResponse
+      message, ok := outcome(n, status, responses, err, &invite)
+      if !ok {
+        rows[i].Status = Failed
+        rows[i].Errors = append(rows[i].Errors, message)
+        continue
+      }
+      rows[i].Status = Created
+      rows[i].Id = invite.Id
+      rows[i].ExpiresAt = invite.ExpiresAt
+      emit(webhooks.InviteCreated, invite)
+    }
+  }
+}
+
+// Send sends the invites of the first limit Created rows in batches of batchSize, a negative limit sends them all.
+// Rows become Sent or NotSent, those over the limit NotSent with reason. emit is called with webhooks.InviteSent for
+// every invite sent.
+func Send(client *idp.IdpClient, url string, rows []Row, batchSize int, limit int, reason string, emit func(eventType string, data interface{})) {
+  var pending []int
+  for i, row := range rows {
+    if row.Status != Created {
+      continue
+    }
+    if limit >= 0 && len(pending) == limit {
+      rows[i].Status = NotSent
+      rows[i].Errors = append(rows[i].Errors, reason)
+      continue
+    }
+    pending = append(pending, i)
+  }
+
+  for _, batch := range batches(pending, batchSize) {
+    var requests []idp.CreateInvitesSendRequest
+    for _, i := range batch {
+      requests = append(requests, idp.CreateInvitesSendRequest{Id: rows[i].Id})
+    }
+
+    status, responses, err := idp.CreateInvitesSend(client, url, requests)
+    for n, i := range batch {
+      var invite idp.CreateInvitesSendResponse
+      message, ok := outcome(n, status, responses, err, &invite)
+      if !ok {
+        rows[i].Status = NotSent
+        rows[i].Errors = append(rows[i].Errors, message)
+        continue
+      }
+      rows[i].Status = Sent
+      rows[i].SentAt = invite.SentAt
+      emit(webhooks.InviteSent, invite)
+    }
+  }
+}
+
+func batches(indexes []int, size int) (batches [][]int) {
+  if size < 1 {
+    size = 1
+  }
+  for len(indexes) > size {
+    batches = append(batches, indexes[:size])
+    indexes = indexes[size:]
+  }
+  if len(indexes) > 0 {
+    batches = append(batches, indexes)
+  }
+  return batches
+}
+
+// Reads item n of a bulk response into v, or returns why the item failed
+func outcome(n int, status int, responses bulky.Responses, err error, v interface{}) (string, bool) {
+  if err != nil {
+    return err.Error(), false
+  }
+  if status != http.StatusOK {
+    return fmt.Sprintf("Got status %d from idp", status), false
+  }
+
+  for _, r := range responses {
+    if r.Index != n { // bulky.Unmarshal panics if the index is missing
+      continue
+    }
+    status, restErr := bulky.Unmarshal(n, responses, v)
+    if status == http.StatusOK && len(restErr) == 0 {
+      return "", true
+    }
+    messages := app.RestErrorMessages("en", restErr)
+    if len(messages) == 0 {
+      messages = []string{fmt.Sprintf("Got status %d from idp", status)}
+    }
+    return strings.Join(messages, ", "), false
+  }
+  return "Missing from the response of idp", false
+}
+
+// WriteResults writes the rows and their outcome as csv
+func WriteResults(w io.Writer, rows []Row) error {
+  writer := csv.NewWriter(w)
+  err := writer.Write(resultsHeader)
+  if err != nil {
+    return err
+  }
+
+  sorted := append([]Row{}, rows...)
+  sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Line < sorted[j].Line })
+  for _, row := range sorted {
+    sentAt := ""
+    if row.SentAt > 0 {
+      sentAt = time.Unix(row.SentAt, 0).UTC().Format(time.RFC3339)
+    }
+    err = writer.Write([]string{fmt.Sprint(row.Line), row.Email, row.Username, row.Expires, row.Status, row.Id, sentAt, strings.Join(row.Errors, "; ")})
+    if err != nil {
+      return err
+    }
+  }
+  writer.Flush()
+  return writer.Error()
+}
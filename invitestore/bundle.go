@@ -0,0 +1,247 @@
+package invitestore
+
+import (
+  "sort"
+  "time"
+  "errors"
+  "strings"
+  "net/http"
+  bulky "github.com/charmixer/bulky/client"
+  aap "github.com/opensentry/aap/client"
+)
+
+// Bundle is what the human claiming an invite is given: roles to shadow and grants of publishers. A NotBefore of 0
+// starts when the bundle is provisioned.
+type Bundle struct {
+  Shadows []Shadow `json:"shadows,omitempty"`
+  Grants []Grant `json:"grants,omitempty"`
+  UpdatedBy string `json:"updated_by,omitempty"`
+}
+
+type Shadow struct {
+  Role string `json:"role_id"`
+  NotBefore int64 `json:"nbf,omitempty"`
+  Expire int64 `json:"exp,omitempty"`
+}
+
+type Grant struct {
+  Publisher string `json:"publisher_id"`
+  Scope string `json:"scope"`
+  NotBefore int64 `json:"nbf,omitempty"`
+  Expire int64 `json:"exp,omitempty"`
+}
+
+func (b *Bundle) Empty() bool {
+  return b == nil || (len(b.Shadows) == 0 && len(b.Grants) == 0)
+}
+
+// Outcome of provisioning one shadow or grant of a bundle, Status is the status aap gave it
+type Outcome struct {
+  Shadow *Shadow `json:"shadow,omitempty"`
+  Grant *Grant `json:"grant,omitempty"`
+  Status int `json:"status"`
+  Error string `json:"error,omitempty"`
+}
+
+func (o Outcome) Ok() bool {
+  return o.Status == http.StatusOK
+}
+
+// Provisioning records the bundle given to the human who claimed the invite. Skipped tells why it was not given.
+type Provisioning struct {
+  At int64 `json:"at"`
+  Identity string `json:"identity_id"`
+  Skipped string `json:"skipped,omitempty"`
+  Outcomes []Outcome `json:"outcomes,omitempty"`
+}
+
+// Failed counts the outcomes aap did not accept
+func (p *Provisioning) Failed() (n int) {
+  if p == nil {
+    return 0
+  }
+  for _, o := range p.Outcomes {
+    if !o.Ok() {
+      n++
+    }
+  }
+  return n
+}
+
+// ErrNotGivable is the outcome of what the author of the bundle could no longer give when it was provisioned
+var ErrNotGivable = errors.New("The author of the bundle may no longer give it")
+
+// Provisionable tells if the invite is accepted and its bundle not yet provisioned
+func (i Invite) Provisionable() bool {
+  return i.Accepted && !i.Record.Bundle.Empty() && i.Record.Provisioning == nil
+}
+
+// Unprovisioned returns the ids of the invites with a bundle not yet provisioned, accepted or not
+func (s *Store) Unprovisioned() (ids []string) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+
+  for id, r := range s.records {
+    if !r.Bundle.Empty() && r.Provisioning == nil {
+      ids = append(ids, id)
+    }
+  }
+  sort.Strings(ids)
+  return ids
+}
+
+// Provision gives the bundle of an accepted invite to the human with its id, the first time it is called for the
+// invite. With retry it gives again only what aap did not accept before. What givable refuses is not given and
+// fails with ErrNotGivable. The bundle of a revoked invite is skipped, idp lets it be claimed but meui does not
+// provision it. Returns nil if there is nothing to provision, eg. another request is provisioning the invite.
+func (s *Store) Provision(client *aap.AapClient, shadowsUrl string, grantsUrl string, invite Invite, retry bool, givable func(o Outcome) bool) (*Provisioning, error) {
+  s.mu.Lock()
+  record := s.records[invite.Id]
+  if !invite.Accepted || record.Bundle.Empty() || s.provisioning[invite.Id] || (record.Provisioning != nil && (!retry || record.Provisioning.Failed() == 0)) {
+    s.mu.Unlock()
+    return nil, nil
+  }
+  s.provisioning[invite.Id] = true
+  s.mu.Unlock()
+
+  defer func() {
+    s.mu.Lock()
+    delete(s.provisioning, invite.Id)
+    s.mu.Unlock()
+  }()
+
+  now := time.Now()
+  p := &Provisioning{At: now.Unix(), Identity: invite.Id}
+
+  // Given again is only what failed, the outcomes aap accepted are kept
+  var shadows []Shadow
+  var grants []Grant
+  switch {
+  case record.Provisioning == nil && record.RevokedAt > 0:
+    p.Skipped = ErrRevoked.Error()
+  case record.Provisioning == nil:
+    shadows = record.Bundle.Shadows
+    grants = record.Bundle.Grants
+  default:
+    for _, o := range record.Provisioning.Outcomes {
+      switch {
+      case o.Ok():
+        p.Outcomes = append(p.Outcomes, o)
+      case o.Shadow != nil:
+        shadows = append(shadows, *o.Shadow)
+      case o.Grant != nil:
+        grants = append(grants, *o.Grant)
+      }
+    }
+  }
+
+  var givenShadows []Shadow
+  for _, shadow := range shadows {
+    shadow := shadow
+    if givable(Outcome{Shadow: &shadow}) {
+      givenShadows = append(givenShadows, shadow)
+    } else {
+      p.Outcomes = append(p.Outcomes, Outcome{Shadow: &shadow, Status: http.StatusForbidden, Error: ErrNotGivable.Error()})
+    }
+  }
+  var givenGrants []Grant
+  for _, grant := range grants {
+    grant := grant
+    if givable(Outcome{Grant: &grant}) {
+      givenGrants = append(givenGrants, grant)
+    } else {
+      p.Outcomes = append(p.Outcomes, Outcome{Grant: &grant, Status: http.StatusForbidden, Error: ErrNotGivable.Error()})
+    }
+  }
+
+  p.Outcomes = append(p.Outcomes, createShadows(client, shadowsUrl, invite.Id, givenShadows, now)...)
+  p.Outcomes = append(p.Outcomes, createGrants(client, grantsUrl, invite.Id, givenGrants, now)...)
+
+  err := s.Update(invite.Id, func(r *Record) {
+    r.Provisioning = p
+  })
+  if err != nil {
+    return nil, err
+  }
+  return p, nil
+}
+
+func createShadows(client *aap.AapClient, url string, identity string, shadows []Shadow, now time.Time) []Outcome {
+  if len(shadows) == 0 {
+    return nil
+  }
+
+  var requests []aap.CreateShadowsRequest
+  outcomes := make([]Outcome, len(shadows))
+  for i, shadow := range shadows {
+    shadow := shadow
+    if shadow.NotBefore == 0 {
+      shadow.NotBefore = now.Unix()
+    }
+    outcomes[i].Shadow = &shadow
+    requests = append(requests, aap.CreateShadowsRequest{Identity: identity, Shadow: shadow.Role, NotBefore: shadow.NotBefore, Expire: shadow.Expire})
+  }
+
+  status, responses, err := aap.CreateShadows(client, url, requests)
+  return fillOutcomes(outcomes, status, responses, err)
+}
+
+func createGrants(client *aap.AapClient, url string, identity string, grants []Grant, now time.Time) []Outcome {
+  if len(grants) == 0 {
+    return nil
+  }
+
+  var requests []aap.CreateGrantsRequest
+  outcomes := make([]Outcome, len(grants))
+  for i, grant := range grants {
+    grant := grant
+    if grant.NotBefore == 0 {
+      grant.NotBefore = now.Unix()
+    }
+    outcomes[i].Grant = &grant
+    requests = append(requests, aap.CreateGrantsRequest{
+      Identity: identity,
+      Scope: grant.Scope,
+      Publisher: grant.Publisher,
+      OnBehalfOf: grant.Publisher,
+      NotBefore: grant.NotBefore,
+      Expire: grant.Expire,
+    })
+  }
+
+  status, responses, err := aap.CreateGrants(client, url, requests)
+  return fillOutcomes(outcomes, status, responses, err)
+}
+
+// Sets the status and error of each outcome from the response of aap with its index. A failed call fails them all.
+func fillOutcomes(outcomes []Outcome, status int, responses bulky.Responses, err error) []Outcome {
+  if err != nil || status != http.StatusOK {
+    message := http.StatusText(status)
+    if err != nil {
+      status = http.StatusBadGateway
+      message = err.Error()
+    }
+    for i := range outcomes {
+      outcomes[i].Status = status
+      outcomes[i].Error = message
+    }
+    return outcomes
+  }
+
+  for i := range outcomes {
+    outcomes[i].Status = http.StatusBadGateway
+    outcomes[i].Error = "No response"
+  }
+  for _, r := range responses {
+    if r.Index < 0 || r.Index >= len(outcomes) {
+      continue
+    }
+    var messages []string
+    for _, e := range r.Errors {
+      messages = append(messages, e.Error)
+    }
+    outcomes[r.Index].Status = r.Status
+    outcomes[r.Index].Error = strings.Join(messages, ", ")
+  }
+  return outcomes
+}
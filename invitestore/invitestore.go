@@ -0,0 +1,181 @@
+// Package invitestore keeps what meui knows of invites beyond what idp holds. idp creates, reads and sends invites but
+// cannot change or delete them, so revocations, expiry changes and resends are recorded here and applied by meui:
+// revoked and expired invites are not sent again and are shown as such. idp still lets an invite be claimed until its
+// own expiry. An invite may carry a bundle of shadows and grants, provisioned with the client credentials of meui after
+// the invite is accepted, every invites.provision.interval or when asked to on the invite details page. The records
+// are saved to invites.store.path, which belongs to one meui instance.
+package invitestore
+
+import (
+  "os"
+  "sync"
+  "time"
+  "io/ioutil"
+  "encoding/json"
+  "path/filepath"
+  idp "github.com/opensentry/idp/client"
+)
+
+// Status of an invite
+const (
+  Pending = "pending" // Created, not sent yet
+  Sent = "sent"
+  Accepted = "accepted" // Claimed, a human has the id of the invite
+  Expired = "expired"
+  Revoked = "revoked"
+)
+
+// Statuses in the order shown by the filters
+var Statuses = []string{Pending, Sent, Accepted, Expired, Revoked}
+
+// Record is what meui knows of an invite beyond idp
+type Record struct {
+  RevokedAt int64 `json:"revoked_at,omitempty"`
+  RevokedBy string `json:"revoked_by,omitempty"`
+  ExpiresAt int64 `json:"exp,omitempty"` // Replaces the expiry of idp, never later than it
+  SentAt int64 `json:"sent_at,omitempty"` // Last send through meui
+  Sends int `json:"sends,omitempty"`
+  Bundle *Bundle `json:"bundle,omitempty"`
+  Provisioning *Provisioning `json:"provisioning,omitempty"`
+}
+
+// Invite is an invite of idp with its record
+type Invite struct {
+  idp.Invite
+  Record Record `json:"record"`
+  Accepted bool `json:"accepted"`
+}
+
+// Expiry of the invite, 0 if it never expires
+func (i Invite) Expires() int64 {
+  if i.Record.ExpiresAt > 0 && (i.ExpiresAt == 0 || i.Record.ExpiresAt < i.ExpiresAt) {
+    return i.Record.ExpiresAt
+  }
+  return i.ExpiresAt
+}
+
+// LastSentAt is the last time the invite was sent, by meui or as told by idp
+func (i Invite) LastSentAt() int64 {
+  if i.Record.SentAt > i.SentAt {
+    return i.Record.SentAt
+  }
+  return i.SentAt
+}
+
+func (i Invite) Status(now time.Time) string {
+  exp := i.Expires()
+  switch {
+  case i.Accepted:
+    return Accepted
+  case i.Record.RevokedAt > 0:
+    return Revoked
+  case exp > 0 && exp <= now.Unix():
+    return Expired
+  case i.LastSentAt() > 0:
+    return Sent
+  }
+  return Pending
+}
+
+// Store holds the records by invite id
+type Store struct {
+  path string
+
+  mu sync.Mutex
+  records map[string]Record
+  provisioning map[string]bool // Invites being provisioned
+}
+
+// Open reads the records saved to path. A missing file is an empty store, an empty path keeps the records in memory.
+func Open(path string) (*Store, error) {
+  s := &Store{path: path, records: map[string]Record{}, provisioning: map[string]bool{}}
+  if path == "" {
+    return s, nil
+  }
+
+  data, err := ioutil.ReadFile(path)
+  if os.IsNotExist(err) {
+    return s, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  err = json.Unmarshal(data, &s.records)
+  if err != nil {
+    return nil, err
+  }
+  return s, nil
+}
+
+// Get returns the record of the invite, empty if there is none
+func (s *Store) Get(id string) Record {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return s.records[id]
+}
+
+// Join returns the invites with their records
+func (s *Store) Join(invites []idp.Invite, accepted map[string]bool) []Invite {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+
+  var joined []Invite
+  for _, invite := range invites {
+    joined = append(joined, Invite{Invite: invite, Record: s.records[invite.Id], Accepted: accepted[invite.Id]})
+  }
+  return joined
+}
+
+// Update changes the record of the invite with fn and saves the store. The change is undone if saving fails.
+func (s *Store) Update(id string, fn func(r *Record)) error {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+
+  previous, existed := s.records[id]
+  record := previous
+  fn(&record)
+  s.records[id] = record
+
+  err := s.save()
+  if err != nil {
+    if existed {
+      s.records[id] = previous
+    } else {
+      delete(s.records, id)
+    }
+  }
+  return err
+}
+
+// RecordSend records that the invite was sent at, see Invite.CanSend
+func (s *Store) RecordSend(id string, at time.Time) error {
+  return s.Update(id, func(r *Record) {
+    r.SentAt = at.Unix()
+    r.Sends++
+  })
+}
+
+// Written next to the file and renamed, so an interrupted write keeps the previous records. The caller holds mu.
+func (s *Store) save() error {
+  if s.path == "" {
+    return nil
+  }
+
+  data, err := json.MarshalIndent(s.records, "", "  ")
+  if err != nil {
+    return err
+  }
+
+  err = os.MkdirAll(filepath.Dir(s.path), 0700)
+  if err != nil {
+    return err
+  }
+
+  tmp := s.path + ".tmp"
+  err = ioutil.WriteFile(tmp, append(data, '\n'), 0600)
+  if err != nil {
+    return err
+  }
+  return os.Rename(tmp, s.path)
+}
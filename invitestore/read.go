@@ -0,0 +1,89 @@
+package invitestore
+
+import (
+  "fmt"
+  "time"
+  "errors"
+  "net/http"
+  bulky "github.com/charmixer/bulky/client"
+  idp "github.com/opensentry/idp/client"
+)
+
+// Why an invite may not be sent, messages are translated by the caller
+var (
+  ErrAccepted = errors.New("The invite is accepted")
+  ErrRevoked = errors.New("The invite is revoked")
+  ErrExpired = errors.New("The invite is expired")
+  ErrSentRecently = errors.New("The invite was sent recently, wait before sending it again")
+)
+
+// Humans read per request to idp when telling which invites are accepted
+const humansBatchSize = 100
+
+// CanSend returns why the invite may not be sent now, nil if it may. An invite is sent again no sooner than interval after the last send.
+func (i Invite) CanSend(now time.Time, interval time.Duration) error {
+  switch i.Status(now) {
+  case Accepted:
+    return ErrAccepted
+  case Revoked:
+    return ErrRevoked
+  case Expired:
+    return ErrExpired
+  }
+  if last := i.LastSentAt(); last > 0 && now.Before(time.Unix(last, 0).Add(interval)) {
+    return ErrSentRecently
+  }
+  return nil
+}
+
+// Read reads invites from idp, all of them if requests is nil, with their records. An invite is accepted once idp has a
+// human with its id. Returns the status of idp if it is not 200.
+func (s *Store) Read(client *idp.IdpClient, invitesUrl string, humansUrl string, requests []idp.ReadInvitesRequest) (int, []Invite, error) {
+  status, responses, err := idp.ReadInvites(client, invitesUrl, requests)
+  if err != nil || status != http.StatusOK {
+    return status, nil, err
+  }
+
+  var invites []idp.Invite
+  for _, r := range responses {
+    var list idp.ReadInvitesResponse
+    status, restErr := bulky.Unmarshal(r.Index, responses, &list)
+    if status == http.StatusNotFound {
+      continue
+    }
+    if status != http.StatusOK || len(restErr) > 0 {
+      return status, nil, fmt.Errorf("Got status %d for item %d from %s", status, r.Index, invitesUrl)
+    }
+    invites = append(invites, list...)
+  }
+
+  accepted := map[string]bool{}
+  for start := 0; start < len(invites); start += humansBatchSize {
+    end := start + humansBatchSize
+    if end > len(invites) {
+      end = len(invites)
+    }
+
+    var humanRequests []idp.ReadHumansRequest
+    for _, invite := range invites[start:end] {
+      humanRequests = append(humanRequests, idp.ReadHumansRequest{Id: invite.Id})
+    }
+    status, responses, err = idp.ReadHumans(client, humansUrl, humanRequests)
+    if err != nil || status != http.StatusOK {
+      return status, nil, err
+    }
+
+    for _, r := range responses {
+      var humans idp.ReadHumansResponse
+      status, _ := bulky.Unmarshal(r.Index, responses, &humans)
+      if status != http.StatusOK {
+        continue // Not found, not accepted
+      }
+      for _, human := range humans {
+        accepted[human.Id] = true
+      }
+    }
+  }
+
+  return http.StatusOK, s.Join(invites, accepted), nil
+}
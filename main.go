@@ -2,6 +2,7 @@ package main
 
 import (
   "strings"
+  "expvar"
   "net/url"
   "net/http"
   "encoding/gob"
@@ -26,10 +27,18 @@ import (
   "github.com/opensentry/meui/app"
   "github.com/opensentry/meui/config"
   "github.com/opensentry/meui/environment"
+  "github.com/opensentry/meui/i18n"
+  "github.com/opensentry/meui/invitestore"
   "github.com/opensentry/meui/utils"
+  "github.com/opensentry/meui/server"
+  "github.com/opensentry/meui/ratelimit"
+  "github.com/opensentry/meui/security"
+  "github.com/opensentry/meui/assets"
+  "github.com/opensentry/meui/webhooks"
   "github.com/opensentry/meui/controllers/callbacks"
   "github.com/opensentry/meui/controllers/profiles"
   "github.com/opensentry/meui/controllers/invites"
+  "github.com/opensentry/meui/controllers/deliveries"
   "github.com/opensentry/meui/controllers/clients"
   "github.com/opensentry/meui/controllers/resourceservers"
   "github.com/opensentry/meui/controllers/access"
@@ -40,12 +49,13 @@ import (
   "github.com/opensentry/meui/controllers/shadows"
   "github.com/opensentry/meui/controllers/ajax"
   "github.com/opensentry/meui/controllers/consents"
+  "github.com/opensentry/meui/controllers/api"
 )
 
 const appName = "meui"
 
 var (
-  logDebug int // Set to 1 to enable debug
+  logDebug bool
   logFormat string // Current only supports default and json
 
   log *logrus.Logger
@@ -57,140 +67,316 @@ var (
 func init() {
   log = logrus.New();
 
+  sessionKeys = environment.SessionKeys{
+    SessionAppStore: appName,
+  }
+
+  gob.Register(&oauth2.Token{}) // This is required to make session in meui able to persist tokens.
+  gob.Register(&oidc.IDToken{})
+  //gob.Register(&idp.Profile{})
+  gob.Register(make(map[string][]string))
+  gob.Register(app.Flash{})
+}
+
+// Load the configuration and set up logging by it
+func initConfigurations() {
+
+  // Lists every problem in the configuration, refuse to start until all are fixed
   err := config.InitConfigurations()
   if err != nil {
-    log.Panic(err.Error())
+    log.Fatal(err.Error())
     return
   }
 
-  logDebug = config.GetInt("log.debug")
-  logFormat = config.GetString("log.format")
+  logDebug = config.Get().Log.Debug
+  logFormat = config.Get().Log.Format
+  setupLogging(config.Get().Log)
+
+  appFields = logrus.Fields{
+    "appname": appName,
+    "log.debug": logDebug,
+    "log.format": logFormat,
+  }
+}
 
+// Set the log level and format, also when the configuration is reloaded
+func setupLogging(cfg config.LogConfig) {
   log.SetReportCaller(true)
-  log.Formatter = &logrus.TextFormatter{
-    CallerPrettyfier: func(f *runtime.Frame) (string, string) {
-      filename := path.Base(f.File)
-      return "", fmt.Sprintf("%s:%d", filename, f.Line)
-    },
+  if cfg.Format == "json" {
+    log.SetFormatter(&logrus.JSONFormatter{})
+  } else {
+    log.SetFormatter(&logrus.TextFormatter{
+      CallerPrettyfier: func(f *runtime.Frame) (string, string) {
+        filename := path.Base(f.File)
+        return "", fmt.Sprintf("%s:%d", filename, f.Line)
+      },
+    })
   }
 
   // We only have 2 log levels. Things developers care about (debug) and things the user of the app cares about (info)
-  if logDebug == 1 {
+  if cfg.Debug {
     log.SetLevel(logrus.DebugLevel)
   } else {
     log.SetLevel(logrus.InfoLevel)
   }
-  if logFormat == "json" {
-    log.SetFormatter(&logrus.JSONFormatter{})
-  }
+}
 
-  appFields = logrus.Fields{
-    "appname": appName,
-    "log.debug": logDebug,
-    "log.format": logFormat,
+func main() {
+
+  optServe := getopt.BoolLong("serve", 0, "Serve application")
+  optHelp := getopt.BoolLong("help", 0, "Help")
+  getopt.SetParameters("[config validate|print|keys] [login|logout] [apply|export] [<resource> <command>]")
+  getopt.Parse()
+
+  if *optHelp {
+    getopt.Usage()
+    os.Exit(0)
   }
 
-  sessionKeys = environment.SessionKeys{
-    SessionAppStore: appName,
+  args := getopt.Args()
+  if len(args) > 0 && args[0] == "config" {
+    os.Exit(configCommand(args[1:]))
+  }
+  if len(args) > 0 && args[0] == "login" {
+    os.Exit(loginCommand(args[1:]))
+  }
+  if len(args) > 0 && args[0] == "logout" {
+    os.Exit(logoutCommand(args[1:]))
+  }
+  if len(args) > 0 && isAdminCommand(args[0]) {
+    os.Exit(adminCommandLine(args))
   }
 
-  gob.Register(&oauth2.Token{}) // This is required to make session in meui able to persist tokens.
-  gob.Register(&oidc.IDToken{})
-  //gob.Register(&idp.Profile{})
-  gob.Register(make(map[string][]string))
-}
+  if !*optServe {
+    getopt.Usage()
+    os.Exit(0)
+  }
 
-func main() {
+  initConfigurations()
+  cfg := config.Get()
 
-  provider, err := oidc.NewProvider(context.Background(), config.GetString("hydra.public.url") + "/")
+  provider, err := oidc.NewProvider(context.Background(), cfg.Hydra.Url + "/")
   if err != nil {
     logrus.WithFields(appFields).Panic("oidc.NewProvider" + err.Error())
     return
   }
 
-  endpoint := provider.Endpoint()
-  endpoint.AuthStyle = 2 // Force basic secret, so token exchange does not auto to post which we did not allow.
+  inviteStore, err := invitestore.Open(cfg.Invites.StorePath)
+  if err != nil {
+    log.WithFields(appFields).Panic("invitestore.Open: " + err.Error())
+    return
+  }
+
+  // Setup app state variables. Can be used in handler functions by doing closures see exchangeAuthorizationCodeCallback
+  env := &environment.State{
+    SessionKeys: &sessionKeys,
+    Provider: provider,
+    Webhooks: webhooks.NewDispatcher(log.WithFields(appFields)),
+    Invites: inviteStore,
+  }
+  setOAuth2Configs(env)
+  env.Webhooks.Start(4)
 
+  if cfg.Secrets.ReloadInterval > 0 {
+    go refreshSecrets(env, cfg.Secrets.ReloadInterval)
+  }
+
+  if cfg.Invites.ProvisionInterval > 0 {
+    go provisionInvites(env, cfg.Invites.ProvisionInterval)
+  }
+
+  serve(env)
+}
+
+// Build the oauth2 configurations from the current client secret
+func setOAuth2Configs(env *environment.State) {
+  cfg := config.Get()
+
+  endpoint := env.Provider.Endpoint()
+  endpoint.AuthStyle = 2 // Force basic secret, so token exchange does not auto to post which we did not allow.
 
   // IdpApi needs to be able to act as an App using its client_id to bootstrap Authorization Code flow
   // Eg. Users accessing /me directly from browser.
   hydraConfig := &oauth2.Config{
-    ClientID:     config.GetString("oauth2.client.id"),
-    ClientSecret: config.GetString("oauth2.client.secret"),
+    ClientID:     cfg.OAuth2.ClientId,
+    ClientSecret: cfg.OAuth2.ClientSecret,
     Endpoint:     endpoint,
-    RedirectURL:  config.GetString("oauth2.callback"),
-    Scopes:       config.GetStringSlice("oauth2.scopes.required"),
+    RedirectURL:  cfg.OAuth2.Callback,
+    Scopes:       cfg.OAuth2.Scopes,
   }
 
   // IdpFe needs to be able as an App using client_id to access idp endpoints. Using client credentials flow
   idpConfig := &clientcredentials.Config{
-    ClientID:  config.GetString("oauth2.client.id"),
-    ClientSecret: config.GetString("oauth2.client.secret"),
-    TokenURL: provider.Endpoint().TokenURL,
-    Scopes: config.GetStringSlice("oauth2.scopes.required"),
+    ClientID:  cfg.OAuth2.ClientId,
+    ClientSecret: cfg.OAuth2.ClientSecret,
+    TokenURL: endpoint.TokenURL,
+    Scopes: cfg.OAuth2.Scopes,
     EndpointParams: url.Values{"audience": {"idp"}},
     AuthStyle: 2, // https://godoc.org/golang.org/x/oauth2#AuthStyle
   }
 
   aapConfig := &clientcredentials.Config{
-    ClientID:  config.GetString("oauth2.client.id"),
-    ClientSecret: config.GetString("oauth2.client.secret"),
-    TokenURL: provider.Endpoint().TokenURL,
-    Scopes: config.GetStringSlice("oauth2.scopes.required"),
+    ClientID:  cfg.OAuth2.ClientId,
+    ClientSecret: cfg.OAuth2.ClientSecret,
+    TokenURL: endpoint.TokenURL,
+    Scopes: cfg.OAuth2.Scopes,
     EndpointParams: url.Values{"audience": {"aap"}},
     AuthStyle: 2, // https://godoc.org/golang.org/x/oauth2#AuthStyle
   }
 
-  // Setup app state variables. Can be used in handler functions by doing closures see exchangeAuthorizationCodeCallback
-  env := &environment.State{
-    SessionKeys: &sessionKeys,
-    Provider: provider,
-    HydraConfig: hydraConfig,
-    IdpApiConfig: idpConfig,
-    AapApiConfig: aapConfig,
-  }
+  env.SetOAuth2Configs(hydraConfig, idpConfig, aapConfig)
+}
 
-  optServe := getopt.BoolLong("serve", 0, "Serve application")
-  optHelp := getopt.BoolLong("help", 0, "Help")
-  getopt.Parse()
+// Read secret files and references again every interval. A rotated client secret is used from the next request,
+// the session and csrf keys sign cookies of every running request so they are only picked up by a restart.
+func refreshSecrets(env *environment.State, interval time.Duration) {
+  for range time.Tick(interval) {
+    changed, err := config.RefreshSecrets()
+    if err != nil {
+      log.WithFields(appFields).Error(err.Error())
+      continue
+    }
 
-  if *optHelp {
-    getopt.Usage()
-    os.Exit(0)
+    for _, key := range changed {
+      switch key {
+      case "oauth2.client.secret":
+        setOAuth2Configs(env)
+        log.WithFields(appFields).Info("Rotated " + key)
+      default:
+        log.WithFields(appFields).Warn(key + " changed, restart meui to use it")
+      }
+    }
   }
+}
 
-  if *optServe {
-    serve(env)
-  } else {
-    getopt.Usage()
-    os.Exit(0)
+// Provision the bundles of accepted invites every interval, with the client credentials of meui
+func provisionInvites(env *environment.State, interval time.Duration) {
+  for range time.Tick(interval) {
+    invites.ProvisionAccepted(env, log.WithFields(appFields))
   }
-
 }
 
 func serve(env *environment.State) {
+  cfg := config.Get()
+
   r := gin.New() // Clean gin to take control with logging.
   r.Use(gin.Recovery())
 
   r.Use(requestId())
   r.Use(RequestLogger(env))
 
-  store := cookie.NewStore([]byte(config.GetString("session.authKey")))
+  cspReportUri := ""
+  if cfg.Csp.ReportEnabled {
+    cspReportUri = "/csp-report"
+  }
+  r.Use(security.Headers(security.Policy{
+    Directives: cfg.Csp.Directives,
+    ReportOnly: cfg.Csp.ReportOnly,
+    ReportUri: cspReportUri,
+    ReferrerPolicy: cfg.Headers.ReferrerPolicy,
+    HstsMaxAge: cfg.Headers.HstsMaxAge,
+    HstsIncludeSubDomains: cfg.Headers.HstsIncludeSubDomains,
+  }))
+
+  // Pick the language of every page, error pages included
+  r.Use(i18n.Negotiate())
+
+  // Cookies are Secure by default. Only turn it off when the browser talks plain http to meui, eg. local development.
+  // Behind a tls terminating proxy the browser still sees https, so keep it on.
+  cookieSecure := cfg.Cookie.Secure
+  cookieDomain := cfg.Cookie.Domain
+  cookieSameSite := cfg.Cookie.SameSite
+
+  store := cookie.NewStore(cfg.Session.AuthKey)
   // Ref: https://godoc.org/github.com/gin-gonic/contrib/sessions#Options
   store.Options(sessions.Options{
     MaxAge: 86400,
     Path: "/",
-    Secure: true,
+    Domain: cookieDomain,
+    Secure: cookieSecure,
     HttpOnly: true,
+    SameSite: cookieSameSite,
   })
   r.Use(sessions.Sessions(env.SessionKeys.SessionAppStore, store))
 
+  // Render empty error responses as pages, needs the session for the page context
+  r.Use(app.ErrorPages())
+
   // Use CSRF on all meui forms.
-  adapterCSRF := adapter.Wrap(csrf.Protect([]byte(config.GetString("csrf.authKey")), csrf.Secure(true)))
+  csrfOptions := []csrf.Option{
+    csrf.Secure(cookieSecure),
+    csrf.Path("/"),
+    csrf.SameSite(csrfSameSite(cookieSameSite)),
+    csrf.ErrorHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+      w.WriteHeader(http.StatusForbidden) // Rendered by app.ErrorPages
+    })),
+  }
+  if cookieDomain != "" {
+    csrfOptions = append(csrfOptions, csrf.Domain(cookieDomain))
+  }
+  adapterCSRF := adapter.Wrap(csrf.Protect(cfg.Csrf.AuthKey, csrfOptions...))
   // r.Use(adapterCSRF) // Do not use this as it will make csrf tokens for public files aswell which is just extra data going over the wire, no need for that.
 
-  r.Static("/public", "public")
-  r.LoadHTMLGlob("views/*")
+  webAssets := assets.New(embedded, cfg.Assets.Path, cfg.Assets.Reload)
+  err := webAssets.LoadTemplates(i18n.FuncMap())
+  if err != nil {
+    log.WithFields(appFields).Panic(err.Error())
+    return
+  }
+  r.HTMLRender = webAssets
+  r.GET(assets.PublicPrefix + "*filepath", webAssets.Serve())
+  r.HEAD(assets.PublicPrefix + "*filepath", webAssets.Serve())
+
+  if cfg.Serve.DebugVars {
+    r.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+  }
+
+  invitesLimiter := ratelimit.NewLimiter("invites", cfg.RateLimit.Invites.PerMinute, cfg.RateLimit.Invites.Burst)
+  clientsLimiter := ratelimit.NewLimiter("clients", cfg.RateLimit.Clients.PerMinute, cfg.RateLimit.Clients.Burst)
+  ajaxLimiter := ratelimit.NewLimiter("ajax", cfg.RateLimit.Ajax.PerMinute, cfg.RateLimit.Ajax.Burst)
+  apiLimiter := ratelimit.NewLimiter("api", cfg.RateLimit.Api.PerMinute, cfg.RateLimit.Api.Burst)
+  defaultLimiter := ratelimit.NewLimiter("default", cfg.RateLimit.Default.PerMinute, cfg.RateLimit.Default.Burst)
+  invitesSendQuota := ratelimit.NewQuota("invites.send.daily", cfg.RateLimit.InvitesSendDaily)
+
+  // Apply changes of app.yml and discovery.yml without a restart where possible. Endpoints, branding and the like are read on every request.
+  err = config.Watch(func(changes config.Changes, err error) {
+    if err != nil {
+      log.WithFields(appFields).Error("Configuration not reloaded: " + err.Error())
+      return
+    }
+
+    cfg := config.Get()
+    for _, key := range changes.Applied {
+      switch {
+      case strings.HasPrefix(key, "log."):
+        setupLogging(cfg.Log)
+      case strings.HasPrefix(key, "ratelimit."):
+        invitesLimiter.SetLimit(cfg.RateLimit.Invites.PerMinute, cfg.RateLimit.Invites.Burst)
+        clientsLimiter.SetLimit(cfg.RateLimit.Clients.PerMinute, cfg.RateLimit.Clients.Burst)
+        ajaxLimiter.SetLimit(cfg.RateLimit.Ajax.PerMinute, cfg.RateLimit.Ajax.Burst)
+        apiLimiter.SetLimit(cfg.RateLimit.Api.PerMinute, cfg.RateLimit.Api.Burst)
+        defaultLimiter.SetLimit(cfg.RateLimit.Default.PerMinute, cfg.RateLimit.Default.Burst)
+        invitesSendQuota.SetLimit(cfg.RateLimit.InvitesSendDaily)
+      case strings.HasPrefix(key, "oauth2."):
+        setOAuth2Configs(env)
+      }
+    }
+
+    if len(changes.Applied) > 0 {
+      log.WithFields(appFields).WithFields(logrus.Fields{"keys": changes.Applied}).Info("Configuration reloaded")
+    }
+    if len(changes.Restart) > 0 {
+      log.WithFields(appFields).WithFields(logrus.Fields{"keys": changes.Restart}).Warn("Configuration changed, restart meui to use it")
+    }
+  })
+  if err != nil {
+    log.WithFields(appFields).Error("Unable to watch the configuration files: " + err.Error())
+  }
+
+  // Browsers post csp violation reports without csrf tokens or session
+  if cspReportUri != "" {
+    r.POST(cspReportUri, ratelimit.Limit(defaultLimiter), security.CollectReports())
+  }
 
   // Public endpoints
   ep := r.Group("/")
@@ -201,6 +387,9 @@ func serve(env *environment.State) {
     ep.GET("/callback", callbacks.ExchangeAuthorizationCodeCallback(env) )
 
     ep.GET("/seeyoulater", profiles.ShowSeeYouLater(env) )
+
+    // Language preference, also offered to signed out users
+    ep.POST("/language", ratelimit.Limit(defaultLimiter), profiles.SubmitLanguage(env) )
   }
 
   // Endpoints that require Authentication and Authorization
@@ -212,69 +401,135 @@ func serve(env *environment.State) {
     // Profile
     ep.GET(  "/",                       profiles.ShowProfile(env))
     ep.GET(  "/profile/edit",           profiles.ShowProfileEdit(env))
-    ep.POST( "/profile/edit",           profiles.SubmitProfileEdit(env))
+    ep.POST( "/profile/edit",           ratelimit.Limit(defaultLimiter), profiles.SubmitProfileEdit(env))
 
     ep.GET(  "/logout",                 profiles.ShowLogout(env))
 
     // Invites
     ep.GET(  "/invites",                invites.ShowInvites(env))
     ep.GET(  "/invites/send",           invites.ShowInvitesSend(env))
-    ep.POST( "/invites/send",           invites.SubmitInvitesSend(env))
+    ep.POST( "/invites/send",           ratelimit.Limit(invitesLimiter), ratelimit.DailyQuota(invitesSendQuota), invites.SubmitInvitesSend(env))
+    ep.POST( "/invites/bulk",           ratelimit.Limit(invitesLimiter), invites.SubmitInvitesBulk(env, invitesSendQuota))
+    ep.GET(  "/invites/revoke",         invites.ShowInviteRevoke(env))
+    ep.POST( "/invites/revoke",         ratelimit.Limit(invitesLimiter), invites.SubmitInviteRevoke(env))
+    ep.GET(  "/invites/expiry",         invites.ShowInviteExpiry(env))
+    ep.POST( "/invites/expiry",         ratelimit.Limit(invitesLimiter), invites.SubmitInviteExpiry(env))
+    ep.GET(  "/invites/details",        invites.ShowInviteDetails(env))
+    ep.GET(  "/invites/bundle",         invites.ShowInviteBundle(env))
+    ep.POST( "/invites/bundle",         ratelimit.Limit(invitesLimiter), invites.SubmitInviteBundle(env))
+    ep.POST( "/invites/provision",      ratelimit.Limit(invitesLimiter), invites.SubmitInviteProvision(env))
     ep.GET(  "/invite",                 invites.ShowInvite(env))
-    ep.POST( "/invite",                 invites.SubmitInvite(env))
+    ep.POST( "/invite",                 ratelimit.Limit(invitesLimiter), invites.SubmitInvite(env))
+    ep.GET(  "/invites/import",         invites.ShowInvitesImport(env))
+    ep.POST( "/invites/import/preview", ratelimit.Limit(invitesLimiter), invites.SubmitInvitesImportPreview(env))
+    ep.POST( "/invites/import",         ratelimit.Limit(invitesLimiter), invites.SubmitInvitesImport(env, invitesSendQuota))
+    ep.POST( "/invites/import/results", invites.SubmitInvitesImportResults(env))
 
     // Clients
     ep.GET(  "/clients",                clients.ShowClients(env))
     ep.GET(  "/clients/delete",         clients.ShowClientDelete(env))
-    ep.POST( "/clients/delete",         clients.SubmitClientDelete(env))
+    ep.POST( "/clients/delete",         ratelimit.Limit(clientsLimiter), clients.SubmitClientDelete(env))
     ep.GET(  "/client",                 clients.ShowClient(env))
-    ep.POST( "/client",                 clients.SubmitClient(env))
+    ep.POST( "/client",                 ratelimit.Limit(clientsLimiter), clients.SubmitClient(env))
 
     // Resource servers
     ep.GET(  "/resourceservers",        resourceservers.ShowResourceServers(env))
     ep.GET(  "/resourceservers/delete", resourceservers.ShowResourceServerDelete(env))
-    ep.POST( "/resourceservers/delete", resourceservers.SubmitResourceServerDelete(env))
+    ep.POST( "/resourceservers/delete", ratelimit.Limit(defaultLimiter), resourceservers.SubmitResourceServerDelete(env))
     ep.GET(  "/resourceserver",         resourceservers.ShowResourceServer(env))
-    ep.POST( "/resourceserver",         resourceservers.SubmitResourceServer(env))
+    ep.POST( "/resourceserver",         ratelimit.Limit(defaultLimiter), resourceservers.SubmitResourceServer(env))
 
     // Access
     ep.GET(  "/access",                 access.ShowAccess(env))
     ep.GET(  "/access/grant",           grant.ShowGrants(env))
-    ep.POST( "/access/grant",           grant.SubmitGrants(env))
+    ep.POST( "/access/grant",           ratelimit.Limit(defaultLimiter), grant.SubmitGrants(env))
     ep.GET(  "/access/new",             access.ShowAccessNew(env))
-    ep.POST( "/access/new",             access.SubmitAccessNew(env))
+    ep.POST( "/access/new",             ratelimit.Limit(defaultLimiter), access.SubmitAccessNew(env))
 
     // Consents
     ep.GET(  "/consents",               consents.ShowConsents(env))
-    ep.POST( "/consents",               consents.SubmitConsents(env))
+    ep.POST( "/consents",               ratelimit.Limit(defaultLimiter), consents.SubmitConsents(env))
 
     // Subscriptions
     ep.GET(  "/subscriptions",          subscriptions.ShowSubscriptions(env))
-    ep.POST( "/subscriptions",          subscriptions.SubmitSubscriptions(env))
+    ep.POST( "/subscriptions",          ratelimit.Limit(defaultLimiter), subscriptions.SubmitSubscriptions(env))
 
     // Publishings
     ep.GET(  "/publishings",            publishings.ShowPublishings(env))
     ep.GET(  "/publishings/publish",    publishings.ShowPublish(env))
-    ep.POST( "/publishings/publish",    publishings.SubmitPublish(env))
+    ep.POST( "/publishings/publish",    ratelimit.Limit(defaultLimiter), publishings.SubmitPublish(env))
 
     // Roles
     ep.GET(  "/roles",                  roles.ShowRoles(env))
     ep.GET(  "/roles/delete",           roles.ShowRoleDelete(env))
-    ep.POST( "/roles/delete",           roles.SubmitRoleDelete(env))
+    ep.POST( "/roles/delete",           ratelimit.Limit(defaultLimiter), roles.SubmitRoleDelete(env))
     ep.GET(  "/role",                   roles.ShowRole(env))
-    ep.POST( "/role",                   roles.SubmitRole(env))
+    ep.POST( "/role",                   ratelimit.Limit(defaultLimiter), roles.SubmitRole(env))
 
     // Shadows
     ep.GET(  "/shadows",                shadows.ShowShadows(env))
     ep.GET(  "/shadow",                 shadows.ShowShadow(env))
-    ep.POST( "/shadow",                 shadows.SubmitShadow(env))
+    ep.POST( "/shadow",                 ratelimit.Limit(defaultLimiter), shadows.SubmitShadow(env))
+
+    // Webhooks
+    ep.GET(  "/webhooks",               app.RequireOperator(), deliveries.ShowDeliveries(env))
+    ep.POST( "/webhooks/retry",         app.RequireOperator(), ratelimit.Limit(defaultLimiter), deliveries.SubmitRetry(env))
 
     // Shadows
-    ep.GET(  "/ajax/identities",        ajax.GetIdentities(env))
+    ep.GET(  "/ajax/identities",        ratelimit.Limit(ajaxLimiter), ajax.GetIdentities(env))
+
+  }
 
+  // Json api for scripts and services. Authenticated by bearer token instead of session, so there is no csrf.
+  // Routes are registered from the route table, which the openapi document is generated from as well.
+  writeLimits := map[string][]gin.HandlerFunc{
+    api.LimitDefault: {ratelimit.Limit(defaultLimiter)},
+    api.LimitInvites: {ratelimit.Limit(invitesLimiter)},
+    api.LimitInvitesSend: {ratelimit.Limit(invitesLimiter), ratelimit.DailyQuota(invitesSendQuota)},
+    api.LimitClients: {ratelimit.Limit(clientsLimiter)},
+  }
+  ep = r.Group(api.Prefix)
+  ep.Use( app.RequireBearerIdentity(env) )
+  ep.Use( ratelimit.Limit(apiLimiter) )
+  {
+    for _, route := range api.Routes {
+      handlers := append(append([]gin.HandlerFunc{}, writeLimits[route.Limit]...), route.Handler(env))
+      ep.Handle(route.Method, route.Path, handlers...)
+    }
   }
 
-  r.RunTLS(":" + config.GetString("serve.public.port"), config.GetString("serve.tls.cert.path"), config.GetString("serve.tls.key.path"))
+  // The contract of the api, public so clients can be generated from it
+  r.GET("/api/openapi.json", ratelimit.Limit(apiLimiter), api.GetOpenApi(env))
+  ep = r.Group("/api")
+  ep.Use(adapterCSRF)
+  {
+    ep.GET("/docs", ratelimit.Limit(apiLimiter), api.ShowDocs(env))
+  }
+
+  err = server.ListenAndServe(r, server.Options{
+    Mode: cfg.Serve.Mode,
+    Address: fmt.Sprintf(":%d", cfg.Serve.Port),
+    Socket: cfg.Serve.Socket,
+    CertPath: cfg.Serve.CertPath,
+    KeyPath: cfg.Serve.KeyPath,
+    CertReloadInterval: cfg.Serve.CertReloadInterval,
+    ShutdownTimeout: cfg.Serve.ShutdownTimeout,
+  }, log.WithFields(appFields))
+  if err != nil {
+    log.WithFields(appFields).Fatal(err.Error())
+  }
+}
+
+func csrfSameSite(sameSite http.SameSite) csrf.SameSiteMode {
+  switch sameSite {
+  case http.SameSiteLaxMode:
+    return csrf.SameSiteLaxMode
+  case http.SameSiteStrictMode:
+    return csrf.SameSiteStrictMode
+  case http.SameSiteNoneMode:
+    return csrf.SameSiteNoneMode
+  }
+  return csrf.SameSiteDefaultMode
 }
 
 func RequestLogger(env *environment.State) gin.HandlerFunc {
@@ -367,7 +622,7 @@ func AuthenticationRequired(env *environment.State) gin.HandlerFunc {
     // Authenticate by looking for valid access token
     var token *oauth2.Token
 
-    token = authenticateWithBearer(c.Request)
+    token = app.BearerToken(c.Request)
     if token != nil {
       log = log.WithFields(logrus.Fields{"authorization": "bearer"})
       log.Debug("Access token found")
@@ -383,7 +638,7 @@ func AuthenticationRequired(env *environment.State) gin.HandlerFunc {
 
     if token != nil {
 
-      tokenSource := env.HydraConfig.TokenSource(oauth2.NoContext, token)
+      tokenSource := env.HydraConfig().TokenSource(oauth2.NoContext, token)
       newToken, err := tokenSource.Token()
       if err != nil {
         log.Debug(err.Error())
@@ -449,10 +704,10 @@ func AuthenticationRequired(env *environment.State) gin.HandlerFunc {
 
     judgeRequest := []aap.ReadEntitiesJudgeRequest{ {
       Publisher: "a73b547b-f26d-487b-9e3a-2574fe3403fe", // Resource Server. For IdpUI this is IDP, FIXME: We should be able to specify audience instead of id (as thirdparty might not know id)
-      Owners: []string{config.GetString("oauth2.client.id")}, // meui client
+      Owners: []string{cfg.OAuth2.ClientId}, // meui client
       Scopes: requiredScopes,
     }}
-    status, responses, err := aap.ReadEntitiesJudge(aapClient, config.GetString("aap.public.url") + config.GetString("aap.public.endpoints.entities.judge"), judgeRequest)
+    status, responses, err := aap.ReadEntitiesJudge(aapClient, config.Get().Aap.EntitiesJudge, judgeRequest)
     if err != nil {
       log.Debug(err.Error())
       c.AbortWithStatus(http.StatusInternalServerError)
@@ -492,19 +747,6 @@ func AuthenticationRequired(env *environment.State) gin.HandlerFunc {
   return gin.HandlerFunc(fn)
 }*/
 
-func authenticateWithBearer(req *http.Request) (*oauth2.Token) {
-  auth := req.Header.Get("Authorization")
-  split := strings.SplitN(auth, " ", 2)
-  if len(split) == 2 || strings.EqualFold(split[0], "bearer") {
-    token := &oauth2.Token{
-      AccessToken: split[1],
-      TokenType: split[0],
-    }
-    return token
-  }
-  return nil
-}
-
 func authenticateWithSession(session sessions.Session, tokenKey string) (*oauth2.Token) {
   v := session.Get(tokenKey)
   if v != nil {
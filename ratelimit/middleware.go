@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+  "fmt"
+  "math"
+  "time"
+  "expvar"
+  "net"
+  "strings"
+  "net/http"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+
+  "github.com/opensentry/meui/app"
+  "github.com/opensentry/meui/config"
+  "github.com/opensentry/meui/environment"
+)
+
+// Number of requests denied, by limiter or quota name. Exposed by expvar.
+var Tripped = expvar.NewMap("meui.ratelimit.tripped")
+
+// Limit denies requests with 429 Too Many Requests when either the identity or the client ip runs out of tokens.
+// Use it after app.RequireIdentity so requests are keyed by identity as well as ip.
+func Limit(limiter *Limiter) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    // The identity is checked first, so its denied requests do not drain the bucket shared by everyone behind the ip
+    var keys []string
+    identity := app.GetIdentity(c)
+    if identity != nil {
+      keys = append(keys, "identity:" + identity.Id)
+    }
+    keys = append(keys, "ip:" + clientIp(c.Request))
+
+    for _, key := range keys {
+      allowed, retryAfter := limiter.Allow(key)
+      if !allowed {
+        deny(c, limiter.Name, key, retryAfter)
+        return
+      }
+    }
+
+    c.Next()
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// Set by handlers behind DailyQuota when the request did what the quota counts
+const quotaSpentKey = "ratelimit.quota.spent"
+
+// DailyQuota denies requests with 429 Too Many Requests when the identity has spent its quota for the day.
+// The quota is held while the handler runs and given back unless the handler calls Spend, so failed requests cost
+// nothing.
+func DailyQuota(quota *Quota) gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    key := QuotaKey(c)
+    allowed, retryAfter := quota.Take(key)
+    if !allowed {
+      deny(c, quota.Name, key, retryAfter)
+      return
+    }
+
+    c.Next()
+
+    if !c.GetBool(quotaSpentKey) {
+      quota.GiveBack(key, 1)
+    }
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// Spend tells DailyQuota that the request did what its quota counts, eg. an invite was sent
+func Spend(c *gin.Context) {
+  c.Set(quotaSpentKey, true)
+}
+
+// QuotaKey is the key quotas count a request by, the identity or else the client ip
+func QuotaKey(c *gin.Context) string {
+  identity := app.GetIdentity(c)
+  if identity != nil {
+    return "identity:" + identity.Id
+  }
+  return "ip:" + clientIp(c.Request)
+}
+
+func deny(c *gin.Context, name string, key string, retryAfter time.Duration) {
+  seconds := int(math.Ceil(retryAfter.Seconds()))
+  if seconds < 1 {
+    seconds = 1
+  }
+
+  Tripped.Add(name, 1)
+
+  log := c.MustGet(environment.LogKey).(*logrus.Entry)
+  log.WithFields(logrus.Fields{
+    "audit": "ratelimit.tripped",
+    "ratelimit": name,
+    "key": key,
+    "path": c.Request.URL.Path,
+    "retry_after": seconds,
+  }).Info("Rate limit exceeded")
+
+  c.Header("Retry-After", fmt.Sprintf("%d", seconds))
+  c.AbortWithStatus(http.StatusTooManyRequests)
+}
+
+// The ip the request is limited by. It is the peer address, unless the peer is a trusted proxy, see
+// ratelimit.trustedProxies, in which case X-Forwarded-For is read from the right and the first hop that is not a
+// trusted proxy is the client. Peers on the unix socket are the ingress in front of meui and trusted as well.
+// Left of the first untrusted hop anything may be claimed, so it is never used.
+func clientIp(r *http.Request) string {
+  trusted := config.Get().RateLimit.TrustedProxies
+
+  ip, _, err := net.SplitHostPort(r.RemoteAddr)
+  if err == nil && !isTrustedProxy(net.ParseIP(ip), trusted) {
+    return ip
+  }
+
+  var hops []string
+  for _, header := range r.Header.Values("X-Forwarded-For") {
+    for _, hop := range strings.Split(header, ",") {
+      hops = append(hops, strings.TrimSpace(hop))
+    }
+  }
+  for i := len(hops) - 1; i >= 0; i-- {
+    hopIp := net.ParseIP(hops[i])
+    if hopIp == nil {
+      break // A malformed hop ends what can be told
+    }
+    ip = hopIp.String()
+    if !isTrustedProxy(hopIp, trusted) {
+      break
+    }
+  }
+  return ip
+}
+
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+  if ip == nil {
+    return false
+  }
+  for _, ipNet := range trusted {
+    if ipNet.Contains(ip) {
+      return true
+    }
+  }
+  return false
+}
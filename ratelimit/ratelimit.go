@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+  "math"
+  "sync"
+  "time"
+)
+
+type bucket struct {
+  tokens float64
+  last time.Time
+}
+
+// Limiter is a token bucket per key. Each key may do burst requests at once and regains rate tokens per second.
+type Limiter struct {
+  Name string
+
+  mu sync.Mutex
+  rate float64
+  burst float64
+  buckets map[string]*bucket
+  sweptAt time.Time
+}
+
+// NewLimiter creates a limiter allowing perMinute requests per minute per key with bursts of up to burst requests.
+func NewLimiter(name string, perMinute int, burst int) *Limiter {
+  l := &Limiter{
+    Name: name,
+    buckets: make(map[string]*bucket),
+    sweptAt: time.Now(),
+  }
+  l.SetLimit(perMinute, burst)
+  return l
+}
+
+// SetLimit changes the limit of a running limiter. Existing buckets keep their tokens.
+func (l *Limiter) SetLimit(perMinute int, burst int) {
+  if burst < 1 {
+    burst = 1
+  }
+
+  l.mu.Lock()
+  l.rate = float64(perMinute) / 60
+  l.burst = float64(burst)
+  l.mu.Unlock()
+}
+
+// Allow takes a token for key. If none is left it returns false and how long to wait for the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+  now := time.Now()
+
+  l.mu.Lock()
+  defer l.mu.Unlock()
+
+  l.sweep(now)
+
+  b, exists := l.buckets[key]
+  if !exists {
+    b = &bucket{tokens: l.burst, last: now}
+    l.buckets[key] = b
+  }
+
+  b.tokens = math.Min(l.burst, b.tokens + now.Sub(b.last).Seconds() * l.rate)
+  b.last = now
+
+  if b.tokens >= 1 {
+    b.tokens--
+    return true, 0
+  }
+
+  if l.rate <= 0 {
+    return false, time.Hour
+  }
+
+  wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+  return false, wait
+}
+
+// Remove buckets that would be full by now, they carry no state worth keeping.
+func (l *Limiter) sweep(now time.Time) {
+  if now.Sub(l.sweptAt) < time.Minute {
+    return
+  }
+  l.sweptAt = now
+
+  for key, b := range l.buckets {
+    if b.tokens + now.Sub(b.last).Seconds() * l.rate >= l.burst {
+      delete(l.buckets, key)
+    }
+  }
+}
+
+// Quota counts usage per key per UTC day.
+type Quota struct {
+  Name string
+
+  mu sync.Mutex
+  limit int
+  day string
+  counts map[string]int
+}
+
+func NewQuota(name string, perDay int) *Quota {
+  return &Quota{
+    Name: name,
+    limit: perDay,
+    counts: make(map[string]int),
+  }
+}
+
+func (q *Quota) SetLimit(perDay int) {
+  q.mu.Lock()
+  q.limit = perDay
+  q.mu.Unlock()
+}
+
+// Take uses one of key's daily quota. If the quota is spent it returns false and the time left until it resets.
+func (q *Quota) Take(key string) (bool, time.Duration) {
+  now := time.Now().UTC()
+  day := now.Format("2006-01-02")
+
+  q.mu.Lock()
+  defer q.mu.Unlock()
+
+  if day != q.day {
+    q.day = day
+    q.counts = make(map[string]int)
+  }
+
+  if q.counts[key] >= q.limit {
+    tomorrow := time.Date(now.Year(), now.Month(), now.Day() + 1, 0, 0, 0, 0, time.UTC)
+    return false, tomorrow.Sub(now)
+  }
+
+  q.counts[key]++
+  return true, 0
+}
+
+// TakeUpTo uses up to n of key's daily quota and returns how many it got, 0 if the quota is spent.
+func (q *Quota) TakeUpTo(key string, n int) int {
+  day := time.Now().UTC().Format("2006-01-02")
+
+  q.mu.Lock()
+  defer q.mu.Unlock()
+
+  if day != q.day {
+    q.day = day
+    q.counts = make(map[string]int)
+  }
+
+  left := q.limit - q.counts[key]
+  if left < n {
+    n = left
+  }
+  if n < 0 {
+    n = 0
+  }
+  q.counts[key] += n
+  return n
+}
+
+// GiveBack returns n of key's daily quota taken for requests that failed. Quota taken on a previous day is not given back.
+func (q *Quota) GiveBack(key string, n int) {
+  day := time.Now().UTC().Format("2006-01-02")
+
+  q.mu.Lock()
+  defer q.mu.Unlock()
+
+  if day != q.day || n <= 0 {
+    return
+  }
+
+  q.counts[key] -= n
+  if q.counts[key] <= 0 {
+    delete(q.counts, key)
+  }
+}
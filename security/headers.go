@@ -0,0 +1,143 @@
+package security
+
+import (
+  "sort"
+  "strings"
+  "fmt"
+  "net/http"
+  "crypto/rand"
+  "encoding/base64"
+  "github.com/sirupsen/logrus"
+  "github.com/gin-gonic/gin"
+
+  "github.com/opensentry/meui/environment"
+)
+
+// Placeholder in csp directive values that is replaced with the per request nonce
+const NoncePlaceholder = "{nonce}"
+
+type Policy struct {
+  // Content-Security-Policy directives, eg. "script-src": {"'self'", "'nonce-{nonce}'"}
+  Directives map[string][]string
+
+  // Send Content-Security-Policy-Report-Only instead of enforcing the policy
+  ReportOnly bool
+
+  // Where browsers should post violation reports. Empty disables reporting.
+  ReportUri string
+
+  ReferrerPolicy string
+
+  // Strict-Transport-Security max-age in seconds. Zero disables the header.
+  HstsMaxAge int
+  HstsIncludeSubDomains bool
+}
+
+// Headers adds security headers to every response and exposes a fresh csp nonce to handlers under environment.CspNonceKey.
+// Templates must put the nonce on inline scripts, eg. <script nonce="{{ .cspNonce }}">
+func Headers(policy Policy) gin.HandlerFunc {
+
+  // Build the static part once, only the nonce changes per request.
+  var names []string
+  for name, _ := range policy.Directives {
+    names = append(names, strings.ToLower(name))
+  }
+  sort.Strings(names)
+
+  var directives []string
+  for _, name := range names {
+    directives = append(directives, strings.TrimSpace(name + " " + strings.Join(policy.Directives[name], " ")))
+  }
+  if policy.ReportUri != "" {
+    directives = append(directives, "report-uri " + policy.ReportUri)
+  }
+  csp := strings.Join(directives, "; ")
+
+  cspHeader := "Content-Security-Policy"
+  if policy.ReportOnly {
+    cspHeader = "Content-Security-Policy-Report-Only"
+  }
+
+  var hsts string
+  if policy.HstsMaxAge > 0 {
+    hsts = fmt.Sprintf("max-age=%d", policy.HstsMaxAge)
+    if policy.HstsIncludeSubDomains {
+      hsts = hsts + "; includeSubDomains"
+    }
+  }
+
+  fn := func(c *gin.Context) {
+
+    nonce, err := createNonce()
+    if err != nil {
+      c.AbortWithStatus(http.StatusInternalServerError)
+      return
+    }
+    c.Set(environment.CspNonceKey, nonce)
+
+    h := c.Writer.Header()
+    if csp != "" {
+      h.Set(cspHeader, strings.ReplaceAll(csp, NoncePlaceholder, nonce))
+    }
+    h.Set("X-Content-Type-Options", "nosniff")
+    h.Set("X-Frame-Options", "DENY") // Legacy browsers without frame-ancestors support
+    if policy.ReferrerPolicy != "" {
+      h.Set("Referrer-Policy", policy.ReferrerPolicy)
+    }
+
+    // Only over https, browsers ignore it on plain http anyway. Behind a tls terminating proxy trust X-Forwarded-Proto.
+    if hsts != "" && (c.Request.TLS != nil || c.Request.Header.Get("X-Forwarded-Proto") == "https") {
+      h.Set("Strict-Transport-Security", hsts)
+    }
+
+    c.Next()
+  }
+  return gin.HandlerFunc(fn)
+}
+
+// CollectReports receives csp violation reports posted by browsers and logs them, so a policy can be tightened in report-only mode first.
+func CollectReports() gin.HandlerFunc {
+  fn := func(c *gin.Context) {
+
+    log := c.MustGet(environment.LogKey).(*logrus.Entry)
+    log = log.WithFields(logrus.Fields{
+      "func": "CollectReports",
+    })
+
+    // Ref: https://www.w3.org/TR/CSP2/#violation-reports
+    var report struct {
+      Body map[string]interface{} `json:"csp-report"`
+    }
+
+    // Browsers send application/csp-report which gin does not know, so decode the body as json directly.
+    c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 64 * 1024)
+    err := c.ShouldBindJSON(&report)
+    if err != nil || report.Body == nil {
+      log.Debug("Invalid csp report")
+      c.AbortWithStatus(http.StatusBadRequest)
+      return
+    }
+
+    log.WithFields(logrus.Fields{
+      "audit": "csp.violation",
+      "csp.document_uri": report.Body["document-uri"],
+      "csp.violated_directive": report.Body["violated-directive"],
+      "csp.blocked_uri": report.Body["blocked-uri"],
+      "csp.source_file": report.Body["source-file"],
+      "csp.line_number": report.Body["line-number"],
+      "csp.disposition": report.Body["disposition"],
+    }).Info("Content security policy violation")
+
+    c.Status(http.StatusNoContent)
+  }
+  return gin.HandlerFunc(fn)
+}
+
+func createNonce() (string, error) {
+  b := make([]byte, 16)
+  _, err := rand.Read(b)
+  if err != nil {
+    return "", err
+  }
+  return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+  "os"
+  "sync"
+  "time"
+  "crypto/tls"
+  "github.com/sirupsen/logrus"
+)
+
+// CertificateReloader serves a tls certificate loaded from disk and reloads it when the cert or key file changes.
+// Files are checked at most once every interval, so handshakes do not stat the filesystem on every connection.
+type CertificateReloader struct {
+  certPath string
+  keyPath string
+  interval time.Duration
+  log *logrus.Entry
+
+  mu sync.RWMutex
+  certificate *tls.Certificate
+  modTime time.Time
+  checkedAt time.Time
+}
+
+func NewCertificateReloader(certPath string, keyPath string, interval time.Duration, log *logrus.Entry) (*CertificateReloader, error) {
+  r := &CertificateReloader{
+    certPath: certPath,
+    keyPath: keyPath,
+    interval: interval,
+    log: log,
+  }
+
+  err := r.load()
+  if err != nil {
+    return nil, err
+  }
+
+  return r, nil
+}
+
+// GetCertificate is meant to be used as tls.Config.GetCertificate
+func (r *CertificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+  r.mu.RLock()
+  due := time.Since(r.checkedAt) >= r.interval
+  certificate := r.certificate
+  r.mu.RUnlock()
+
+  if due {
+    err := r.reloadIfChanged()
+    if err != nil {
+      // Keep serving the last good certificate, a rotation might be halfway done.
+      r.log.WithFields(logrus.Fields{"func": "GetCertificate"}).Debug(err.Error())
+    }
+
+    r.mu.RLock()
+    certificate = r.certificate
+    r.mu.RUnlock()
+  }
+
+  return certificate, nil
+}
+
+func (r *CertificateReloader) reloadIfChanged() error {
+  modTime, err := r.latestModTime()
+
+  r.mu.Lock()
+  r.checkedAt = time.Now()
+  changed := err == nil && modTime.After(r.modTime)
+  r.mu.Unlock()
+
+  if err != nil {
+    return err
+  }
+
+  if changed {
+    err = r.load()
+    if err != nil {
+      return err
+    }
+    r.log.WithFields(logrus.Fields{"cert": r.certPath, "key": r.keyPath}).Info("TLS certificate reloaded")
+  }
+
+  return nil
+}
+
+func (r *CertificateReloader) load() error {
+  modTime, err := r.latestModTime()
+  if err != nil {
+    return err
+  }
+
+  certificate, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+  if err != nil {
+    return err
+  }
+
+  r.mu.Lock()
+  r.certificate = &certificate
+  r.modTime = modTime
+  r.checkedAt = time.Now()
+  r.mu.Unlock()
+  return nil
+}
+
+func (r *CertificateReloader) latestModTime() (time.Time, error) {
+  var latest time.Time
+  for _, path := range []string{r.certPath, r.keyPath} {
+    fi, err := os.Stat(path)
+    if err != nil {
+      return time.Time{}, err
+    }
+
+    if fi.ModTime().After(latest) {
+      latest = fi.ModTime()
+    }
+  }
+  return latest, nil
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+  "os"
+  "os/signal"
+  "syscall"
+  "fmt"
+  "net"
+  "net/http"
+  "time"
+  "context"
+  "crypto/tls"
+  "github.com/sirupsen/logrus"
+)
+
+// Listen modes supported by serve.listen.mode
+const (
+  ListenModeTls string = "tls" // Terminate tls in meui
+  ListenModeHttp string = "http" // Plain http, eg. behind a tls terminating proxy
+  ListenModeUnix string = "unix" // Plain http on a unix socket
+)
+
+type Options struct {
+  Mode string
+  Address string
+  Socket string
+  CertPath string
+  KeyPath string
+  CertReloadInterval time.Duration
+  ShutdownTimeout time.Duration
+}
+
+// ListenAndServe serves handler using the listener described by opts and blocks until the server fails or
+// a SIGTERM/SIGINT is received. On a signal, in-flight requests are drained for up to opts.ShutdownTimeout.
+func ListenAndServe(handler http.Handler, opts Options, log *logrus.Entry) error {
+  log = log.WithFields(logrus.Fields{
+    "func": "ListenAndServe",
+    "serve.listen.mode": opts.Mode,
+  })
+
+  srv := &http.Server{
+    Handler: handler,
+  }
+
+  listener, err := listen(srv, opts, log)
+  if err != nil {
+    return err
+  }
+
+  serveErrors := make(chan error, 1)
+  go func() {
+    serveErrors <- srv.Serve(listener)
+  }()
+
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+  defer signal.Stop(signals)
+
+  log.WithFields(logrus.Fields{"address": listener.Addr().String()}).Info("Listening")
+
+  select {
+  case err := <-serveErrors:
+    return err
+
+  case sig := <-signals:
+    log.WithFields(logrus.Fields{"signal": sig.String(), "timeout": opts.ShutdownTimeout}).Info("Shutting down, draining in-flight requests")
+
+    ctx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+    defer cancel()
+
+    err := srv.Shutdown(ctx)
+    if err != nil {
+      return err
+    }
+
+    log.Info("Shutdown complete")
+    return nil
+  }
+}
+
+func listen(srv *http.Server, opts Options, log *logrus.Entry) (net.Listener, error) {
+  switch opts.Mode {
+
+  case ListenModeTls:
+    reloader, err := NewCertificateReloader(opts.CertPath, opts.KeyPath, opts.CertReloadInterval, log)
+    if err != nil {
+      return nil, err
+    }
+
+    srv.TLSConfig = &tls.Config{
+      MinVersion: tls.VersionTLS12,
+      GetCertificate: reloader.GetCertificate,
+      // The listener is built by hand so ServeTLS does not add these, without them clients never negotiate HTTP/2
+      NextProtos: []string{"h2", "http/1.1"},
+    }
+
+    ln, err := net.Listen("tcp", opts.Address)
+    if err != nil {
+      return nil, err
+    }
+    return tls.NewListener(ln, srv.TLSConfig), nil
+
+  case ListenModeHttp:
+    return net.Listen("tcp", opts.Address)
+
+  case ListenModeUnix:
+    if opts.Socket == "" {
+      return nil, fmt.Errorf("Missing socket path for listen mode %s", opts.Mode)
+    }
+
+    // Remove stale socket left behind by an unclean exit
+    err := os.Remove(opts.Socket)
+    if err != nil && !os.IsNotExist(err) {
+      return nil, err
+    }
+    return net.Listen("unix", opts.Socket)
+
+  }
+
+  return nil, fmt.Errorf("Unknown listen mode %s. Hint: Use one of %s, %s or %s", opts.Mode, ListenModeTls, ListenModeHttp, ListenModeUnix)
+}
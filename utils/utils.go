@@ -2,6 +2,7 @@ package utils
 
 import (
   "bytes"
+  "fmt"
   "strings"
   "net"
   "net/http"
@@ -124,3 +125,17 @@ func FetchSubmitUrlFromRequest(req *http.Request, q *url.Values) (string, error)
 
   return u.String(), nil
 }
+
+func ParseSameSite(sameSite string) (http.SameSite, error) {
+  switch strings.ToLower(sameSite) {
+  case "", "default":
+    return http.SameSiteDefaultMode, nil
+  case "lax":
+    return http.SameSiteLaxMode, nil
+  case "strict":
+    return http.SameSiteStrictMode, nil
+  case "none":
+    return http.SameSiteNoneMode, nil
+  }
+  return http.SameSiteDefaultMode, fmt.Errorf("Unknown SameSite mode %s. Hint: Use one of default, lax, strict or none", sameSite)
+}
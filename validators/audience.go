@@ -0,0 +1,15 @@
+package validators
+
+import (
+  "net/url"
+  "gopkg.in/go-playground/validator.v9"
+)
+
+// Audience requires an absolute uri without a fragment identifying a resource server, eg. "https://api.example.com" or "urn:example:api"
+func Audience(fl validator.FieldLevel) bool {
+  u, err := url.Parse(fl.Field().String())
+  if err != nil || !u.IsAbs() || u.Fragment != "" {
+    return false
+  }
+  return u.Host != "" || u.Opaque != ""
+}
@@ -0,0 +1,35 @@
+package validators
+
+import (
+  "context"
+  "gopkg.in/go-playground/validator.v9"
+)
+
+const DateLayout = "2006-01-02"
+
+// DateAfter requires the date to be after the date in the field named by the param, eg. `validate:"dateafter=StartDate"`
+// Dates are parsed by ParseDateTime in the location of ctx, see WithLocation, like the handler parses them.
+// Empty dates are left for required to check, dates that do not parse fail.
+func DateAfter(ctx context.Context, fl validator.FieldLevel) bool {
+  value := fl.Field().String()
+  if value == "" {
+    return true
+  }
+
+  date, err := ParseDateTime(value, Location(ctx))
+  if err != nil {
+    return false
+  }
+
+  other, _, ok := fl.GetStructFieldOK()
+  if !ok || other.String() == "" {
+    return true
+  }
+
+  otherDate, err := ParseDateTime(other.String(), Location(ctx))
+  if err != nil {
+    return true // Reported by the other field
+  }
+
+  return date.After(otherDate)
+}
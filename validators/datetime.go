@@ -0,0 +1,50 @@
+package validators
+
+import (
+  "time"
+  "context"
+  "gopkg.in/go-playground/validator.v9"
+)
+
+type locationKey struct{}
+
+// WithLocation returns ctx carrying the timezone the user enters dates in. Validate with StructCtx and the context so
+// datetime, dateafter and future parse dates in it, without a location they are parsed as UTC.
+func WithLocation(ctx context.Context, loc *time.Location) context.Context {
+  return context.WithValue(ctx, locationKey{}, loc)
+}
+
+// Location returns the timezone carried by ctx, UTC if there is none
+func Location(ctx context.Context) *time.Location {
+  if loc, ok := ctx.Value(locationKey{}).(*time.Location); ok && loc != nil {
+    return loc
+  }
+  return time.UTC
+}
+
+// Layout of <input type="datetime-local">, some browsers add seconds
+const DateTimeLayout = "2006-01-02T15:04"
+
+var dateTimeLayouts = []string{DateTimeLayout, "2006-01-02T15:04:05", DateLayout}
+
+// ParseDateTime parses a date and time, or a date at midnight, as entered by the user in loc, eg. "2021-03-01T08:30" or "2021-03-01"
+func ParseDateTime(value string, loc *time.Location) (t time.Time, err error) {
+  for _, layout := range dateTimeLayouts {
+    t, err = time.ParseInLocation(layout, value, loc)
+    if err == nil {
+      return t, nil
+    }
+  }
+  return t, err
+}
+
+// DateTime requires a date and time, or a date, that ParseDateTime understands. Empty values are left for required to check.
+func DateTime(ctx context.Context, fl validator.FieldLevel) bool {
+  value := fl.Field().String()
+  if value == "" {
+    return true
+  }
+
+  _, err := ParseDateTime(value, Location(ctx))
+  return err == nil
+}
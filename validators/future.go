@@ -0,0 +1,23 @@
+package validators
+
+import (
+  "time"
+  "context"
+  "gopkg.in/go-playground/validator.v9"
+)
+
+// Future requires a date and time, or a date, after now. Dates are parsed by ParseDateTime in the location of ctx, see
+// WithLocation, like the handler parses them. Empty values are left for required to check and values that do not
+// parse for datetime.
+func Future(ctx context.Context, fl validator.FieldLevel) bool {
+  value := fl.Field().String()
+  if value == "" {
+    return true
+  }
+
+  date, err := ParseDateTime(value, Location(ctx))
+  if err != nil {
+    return true
+  }
+  return date.After(time.Now())
+}
@@ -0,0 +1,13 @@
+package validators
+
+import (
+  "regexp"
+  "gopkg.in/go-playground/validator.v9"
+)
+
+var uuid = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IdentityId requires the id of an identity, humans, clients and roles alike. Identities are identified by uuid.
+func IdentityId(fl validator.FieldLevel) bool {
+  return uuid.MatchString(fl.Field().String())
+}
@@ -0,0 +1,36 @@
+package validators
+
+import (
+  "net"
+  "net/url"
+  "gopkg.in/go-playground/validator.v9"
+
+  "github.com/opensentry/meui/config"
+)
+
+// RedirectUri requires an absolute https uri without a fragment, see RFC 6749 section 3.1.2.
+// Plain http is allowed for localhost when validation.redirectUris.allowLocalhost is set, eg. for native and development clients.
+func RedirectUri(fl validator.FieldLevel) bool {
+  u, err := url.Parse(fl.Field().String())
+  if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+    return false
+  }
+
+  if u.Scheme == "https" {
+    return true
+  }
+
+  if u.Scheme == "http" && config.Get().Validation.RedirectUrisAllowLocalhost {
+    return isLocalhost(u.Hostname())
+  }
+
+  return false
+}
+
+func isLocalhost(host string) bool {
+  if host == "localhost" {
+    return true
+  }
+  ip := net.ParseIP(host)
+  return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,14 @@
+package validators
+
+import (
+  "regexp"
+  "gopkg.in/go-playground/validator.v9"
+)
+
+// A scope-token from RFC 6749 section 3.3, any printable ascii except space, double quote and backslash.
+var scopeName = regexp.MustCompile(`^[\x21\x23-\x5B\x5D-\x7E]+$`)
+
+// ScopeName requires a single scope, eg. "openid" or "idp:read:humans"
+func ScopeName(fl validator.FieldLevel) bool {
+  return scopeName.MatchString(fl.Field().String())
+}
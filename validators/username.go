@@ -0,0 +1,14 @@
+package validators
+
+import (
+  "regexp"
+  "gopkg.in/go-playground/validator.v9"
+)
+
+// 3 to 32 lowercase letters, digits, dots, dashes or underscores. Starting with a letter and ending with a letter or digit.
+var username = regexp.MustCompile(`^[a-z][a-z0-9._-]{1,30}[a-z0-9]$`)
+
+// Username requires a username following the username policy
+func Username(fl validator.FieldLevel) bool {
+  return username.MatchString(fl.Field().String())
+}
@@ -0,0 +1,18 @@
+package validators
+
+import (
+  "gopkg.in/go-playground/validator.v9"
+)
+
+// Register adds the meui validators to validate by tag, eg. `validate:"required,notblank"`
+func Register(validate *validator.Validate) {
+  validate.RegisterValidation("notblank", NotBlank)
+  validate.RegisterValidation("redirecturi", RedirectUri)
+  validate.RegisterValidation("scope", ScopeName)
+  validate.RegisterValidation("audience", Audience)
+  validate.RegisterValidation("username", Username)
+  validate.RegisterValidation("identityid", IdentityId)
+  validate.RegisterValidationCtx("datetime", DateTime)
+  validate.RegisterValidationCtx("dateafter", DateAfter)
+  validate.RegisterValidationCtx("future", Future)
+}
@@ -0,0 +1,247 @@
+package validators
+
+import (
+  "context"
+  "strings"
+  "testing"
+  "time"
+  "gopkg.in/go-playground/validator.v9"
+
+  "github.com/opensentry/meui/config"
+)
+
+func newValidate() *validator.Validate {
+  validate := validator.New()
+  Register(validate)
+  return validate
+}
+
+type varTest struct {
+  value string
+  valid bool
+}
+
+func testVar(t *testing.T, tag string, tests []varTest) {
+  t.Helper()
+  validate := newValidate()
+  for _, test := range tests {
+    err := validate.Var(test.value, tag)
+    if valid := err == nil; valid != test.valid {
+      t.Errorf("%s %q: got valid %v, want %v", tag, test.value, valid, test.valid)
+    }
+  }
+}
+
+func TestRedirectUri(t *testing.T) {
+  tests := []struct {
+    value string
+    allowLocalhost bool
+    valid bool
+  }{
+    {"https://app.example.com/callback", false, true},
+    {"https://app.example.com/callback?state=1", false, true},
+    {"https://localhost:8080/callback", false, true},
+    {"https://app.example.com/callback#fragment", false, false},
+    {"http://app.example.com/callback", false, false},
+    {"http://app.example.com/callback", true, false},
+    {"http://localhost:8080/callback", false, false},
+    {"http://localhost:8080/callback", true, true},
+    {"http://127.0.0.1/callback", true, true},
+    {"http://[::1]:8080/callback", true, true},
+    {"http://127.0.0.1.example.com/callback", true, false},
+    {"/callback", true, false},
+    {"app.example.com/callback", false, false},
+    {"https://", false, false},
+    {"ftp://app.example.com/callback", true, false},
+    {"", false, false},
+  }
+
+  defer config.Set(config.Get())
+  validate := newValidate()
+  for _, test := range tests {
+    config.Set(&config.Config{Validation: config.ValidationConfig{RedirectUrisAllowLocalhost: test.allowLocalhost}})
+    err := validate.Var(test.value, "redirecturi")
+    if valid := err == nil; valid != test.valid {
+      t.Errorf("redirecturi %q with allowLocalhost %v: got valid %v, want %v", test.value, test.allowLocalhost, valid, test.valid)
+    }
+  }
+}
+
+func TestScopeName(t *testing.T) {
+  testVar(t, "scope", []varTest{
+    {"openid", true},
+    {"idp:read:humans", true},
+    {"https://api.example.com/read", true},
+    {"a", true},
+    {"", false},
+    {"read write", false},
+    {`say"hi`, false},
+    {`back\slash`, false},
+    {"tab\tscope", false},
+    {"æøå", false},
+  })
+}
+
+func TestAudience(t *testing.T) {
+  testVar(t, "audience", []varTest{
+    {"https://api.example.com", true},
+    {"https://api.example.com/v1", true},
+    {"urn:example:api", true},
+    {"api.example.com", false},
+    {"/api", false},
+    {"https://api.example.com#part", false},
+    {"https://", false},
+    {"", false},
+  })
+}
+
+func TestUsername(t *testing.T) {
+  testVar(t, "username", []varTest{
+    {"jane", true},
+    {"abc", true},
+    {"jane.doe-2_x", true},
+    {"j" + strings.Repeat("a", 30) + "1", true}, // 32 characters
+    {"j" + strings.Repeat("a", 31) + "1", false},
+    {"ab", false},
+    {"Jane", false},
+    {"2jane", false},
+    {"jane.", false},
+    {"jane doe", false},
+    {"", false},
+  })
+}
+
+func TestIdentityId(t *testing.T) {
+  testVar(t, "identityid", []varTest{
+    {"00000000-0000-0000-0000-0000000000d1", true},
+    {"9F1B2C3D-4E5F-4a6b-8c9d-0e1f2a3b4c5d", true},
+    {"00000000000000000000000000000000", false},
+    {"00000000-0000-0000-0000-0000000000d", false},
+    {"00000000-0000-0000-0000-0000000000g1", false},
+    {" 00000000-0000-0000-0000-0000000000d1", false},
+    {"", false},
+  })
+}
+
+func TestDateTime(t *testing.T) {
+  testVar(t, "datetime", []varTest{
+    {"2021-03-01T08:30", true},
+    {"2021-03-01T08:30:15", true},
+    {"2021-03-01", true},
+    {"", true},
+    {"2021-03-01 08:30", false},
+    {"2021-13-01", false},
+    {"01-03-2021", false},
+    {"tomorrow", false},
+  })
+}
+
+func TestDateAfter(t *testing.T) {
+  type dates struct {
+    StartDate string
+    EndDate string `validate:"dateafter=StartDate"`
+  }
+
+  tests := []struct {
+    dates dates
+    valid bool
+  }{
+    {dates{"2021-03-01T08:30", "2021-03-01T08:31"}, true},
+    {dates{"2021-03-01", "2021-03-02"}, true},
+    {dates{"2021-03-01T08:30", "2021-03-01T08:30"}, false},
+    {dates{"2021-03-02", "2021-03-01T23:59"}, false},
+    {dates{"", "2021-03-01"}, true},
+    {dates{"2021-03-01", ""}, true},
+    {dates{"not a date", "2021-03-01"}, true},
+    {dates{"2021-03-01", "not a date"}, false},
+  }
+
+  validate := newValidate()
+  for _, test := range tests {
+    err := validate.Struct(test.dates)
+    if valid := err == nil; valid != test.valid {
+      t.Errorf("dateafter %+v: got valid %v, want %v", test.dates, valid, test.valid)
+    }
+  }
+}
+
+func TestFuture(t *testing.T) {
+  now := time.Now().UTC()
+  testVar(t, "future", []varTest{
+    {now.Add(2 * time.Minute).Format(DateTimeLayout), true},
+    {now.AddDate(0, 0, 2).Format(DateLayout), true},
+    {now.AddDate(1, 0, 0).Format("2006-01-02T15:04:05"), true},
+    {now.Add(-2 * time.Minute).Format(DateTimeLayout), false},
+    {now.AddDate(0, 0, -1).Format(DateLayout), false},
+    {"2000-01-01", false},
+    {"", true},
+    {"not a date", true}, // Reported by datetime
+  })
+}
+
+func TestFutureInLocation(t *testing.T) {
+  copenhagen, err := time.LoadLocation("Europe/Copenhagen")
+  if err != nil {
+    t.Skip("no time zone database: " + err.Error())
+  }
+  newYork, err := time.LoadLocation("America/New_York")
+  if err != nil {
+    t.Skip("no time zone database: " + err.Error())
+  }
+
+  // Wall clock times that are future in one timezone and past in the other
+  now := time.Now()
+  tests := []struct {
+    value string
+    loc *time.Location
+    valid bool
+  }{
+    {now.In(newYork).Add(30 * time.Minute).Format(DateTimeLayout), newYork, true},
+    {now.In(newYork).Add(30 * time.Minute).Format(DateTimeLayout), time.UTC, false},
+    {now.In(copenhagen).Add(-30 * time.Minute).Format(DateTimeLayout), copenhagen, false},
+    {now.In(copenhagen).Add(-30 * time.Minute).Format(DateTimeLayout), time.UTC, true},
+  }
+
+  validate := newValidate()
+  for _, test := range tests {
+    err := validate.VarCtx(WithLocation(context.Background(), test.loc), test.value, "future")
+    if valid := err == nil; valid != test.valid {
+      t.Errorf("future %q in %s: got valid %v, want %v", test.value, test.loc, valid, test.valid)
+    }
+  }
+}
+
+func TestParseDateTime(t *testing.T) {
+  copenhagen, err := time.LoadLocation("Europe/Copenhagen")
+  if err != nil {
+    t.Skip("no time zone database: " + err.Error())
+  }
+
+  tests := []struct {
+    value string
+    loc *time.Location
+    want time.Time
+    err bool
+  }{
+    {"2021-03-01T08:30", time.UTC, time.Date(2021, 3, 1, 8, 30, 0, 0, time.UTC), false},
+    {"2021-03-01T08:30:15", time.UTC, time.Date(2021, 3, 1, 8, 30, 15, 0, time.UTC), false},
+    {"2021-03-01", time.UTC, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), false},
+    {"2021-03-01T08:30", copenhagen, time.Date(2021, 3, 1, 7, 30, 0, 0, time.UTC), false}, // CET
+    {"2021-07-01T08:30", copenhagen, time.Date(2021, 7, 1, 6, 30, 0, 0, time.UTC), false}, // CEST
+    {"2021-03-01", copenhagen, time.Date(2021, 2, 28, 23, 0, 0, 0, time.UTC), false},
+    {"2021-03-01T08:30Z", time.UTC, time.Time{}, true},
+    {"2021-02-30", time.UTC, time.Time{}, true},
+    {"", time.UTC, time.Time{}, true},
+  }
+
+  for _, test := range tests {
+    got, err := ParseDateTime(test.value, test.loc)
+    if (err != nil) != test.err {
+      t.Errorf("ParseDateTime(%q, %s): got error %v, want error %v", test.value, test.loc, err, test.err)
+      continue
+    }
+    if !test.err && !got.Equal(test.want) {
+      t.Errorf("ParseDateTime(%q, %s): got %s, want %s", test.value, test.loc, got.UTC(), test.want)
+    }
+  }
+}
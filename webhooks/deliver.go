@@ -0,0 +1,150 @@
+package webhooks
+
+import (
+  "io"
+  "fmt"
+  "time"
+  "bytes"
+  "strconv"
+  "net/http"
+  "io/ioutil"
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "github.com/sirupsen/logrus"
+
+  "github.com/opensentry/meui/config"
+)
+
+// Headers of a delivery. Receivers check the signature, and the timestamp to refuse replays.
+const (
+  EventHeader = "X-Meui-Event"
+  DeliveryHeader = "X-Meui-Delivery"
+  TimestampHeader = "X-Meui-Timestamp"
+  SignatureHeader = "X-Meui-Signature"
+)
+
+// Longest wait between retries, however many retries are configured
+const maxBackoff = time.Hour
+
+// Start runs workers delivering queued events in the background
+func (d *Dispatcher) Start(workers int) {
+  for i := 0; i < workers; i++ {
+    go func() {
+      for delivery := range d.queue {
+        d.attempt(delivery)
+      }
+    }()
+  }
+}
+
+// Sign is the signature of a delivery, sha256= followed by the hex encoded HMAC-SHA256 of timestamp, a dot and the body, keyed by the secret of the endpoint
+func Sign(secret string, timestamp string, body []byte) string {
+  mac := hmac.New(sha256.New, []byte(secret))
+  mac.Write([]byte(timestamp + "."))
+  mac.Write(body)
+  return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) attempt(delivery *Delivery) {
+  cfg := config.Get().Webhooks
+  log := d.log.WithFields(logrus.Fields{"delivery": delivery.Id, "event": delivery.Event.Type, "endpoint": delivery.Endpoint})
+
+  var endpoint *config.WebhookEndpoint
+  for i := range cfg.Endpoints {
+    if cfg.Endpoints[i].Name == delivery.Endpoint {
+      endpoint = &cfg.Endpoints[i]
+    }
+  }
+  if endpoint == nil {
+    log.Warn("Webhook endpoint no longer configured, delivery moved to the dead letters")
+    d.finish(delivery, Dead, 0, "endpoint no longer configured")
+    Deliveries.Add("dead", 1)
+    return
+  }
+
+  status, err := post(endpoint, delivery, cfg.Timeout)
+
+  d.mu.Lock()
+  delivery.Attempts++
+  delivery.LastAttemptAt = time.Now()
+  attempts := delivery.Attempts
+  d.mu.Unlock()
+
+  if err == nil {
+    log.WithFields(logrus.Fields{"status": status, "attempts": attempts}).Debug("Webhook delivered")
+    d.finish(delivery, Delivered, status, "")
+    Deliveries.Add("delivered", 1)
+    return
+  }
+
+  if attempts > cfg.Retries {
+    log.WithFields(logrus.Fields{"status": status, "attempts": attempts}).Warn("Webhook failed every attempt, delivery moved to the dead letters: " + err.Error())
+    d.finish(delivery, Dead, status, err.Error())
+    Deliveries.Add("dead", 1)
+    return
+  }
+
+  wait := maxBackoff
+  if attempts < 32 && cfg.Backoff << uint(attempts - 1) < maxBackoff {
+    wait = cfg.Backoff << uint(attempts - 1)
+  }
+
+  d.mu.Lock()
+  delivery.Status = status
+  delivery.Error = err.Error()
+  delivery.NextAttemptAt = time.Now().Add(wait)
+  d.mu.Unlock()
+
+  log.WithFields(logrus.Fields{"status": status, "attempts": attempts, "retry_in": wait.String()}).Debug("Webhook failed: " + err.Error())
+  Deliveries.Add("retried", 1)
+
+  time.AfterFunc(wait, func() {
+    select {
+    case d.queue <- delivery:
+    default:
+      d.finish(delivery, Dead, status, "queue full")
+      Deliveries.Add("dropped", 1)
+    }
+  })
+}
+
+// Posts the event signed with the current secret of the endpoint, so a rotated secret file is used from the next attempt on
+func post(endpoint *config.WebhookEndpoint, delivery *Delivery, timeout time.Duration) (int, error) {
+  body, err := json.Marshal(delivery.Event)
+  if err != nil {
+    return 0, err
+  }
+
+  secret, err := config.Secret("webhooks.endpoints." + endpoint.Name + ".secret")
+  if err != nil {
+    return 0, err
+  }
+
+  req, err := http.NewRequest(http.MethodPost, endpoint.Url, bytes.NewReader(body))
+  if err != nil {
+    return 0, err
+  }
+
+  timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+  req.Header.Set("Content-Type", "application/json")
+  req.Header.Set("User-Agent", "meui-webhooks")
+  req.Header.Set(EventHeader, delivery.Event.Type)
+  req.Header.Set(DeliveryHeader, delivery.Id)
+  req.Header.Set(TimestampHeader, timestamp)
+  req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+  client := &http.Client{Timeout: timeout}
+  res, err := client.Do(req)
+  if err != nil {
+    return 0, err
+  }
+  defer res.Body.Close()
+  io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64 * 1024)) // Lets the connection be reused
+
+  if res.StatusCode < 200 || res.StatusCode > 299 {
+    return res.StatusCode, fmt.Errorf("endpoint answered %s", res.Status)
+  }
+  return res.StatusCode, nil
+}
@@ -0,0 +1,253 @@
+// Package webhooks tells downstream systems about changes made through meui, eg. grants created or clients deleted.
+// Events are posted as signed json to the endpoints configured under webhooks.endpoints whose event filters match.
+// Failed deliveries are retried with exponential backoff and end in the dead letters, which operators can retry.
+// Deliveries are kept in memory, so the delivery log and the dead letters start empty after a restart.
+package webhooks
+
+import (
+  "time"
+  "sync"
+  "expvar"
+  "strings"
+  "github.com/gofrs/uuid"
+  "github.com/sirupsen/logrus"
+  idp "github.com/opensentry/idp/client"
+
+  "github.com/opensentry/meui/config"
+)
+
+// Events, config.WebhookEvents lists them for validation of the configuration
+const (
+  GrantCreated = "grant.created"
+  GrantDeleted = "grant.deleted"
+  SubscriptionCreated = "subscription.created"
+  SubscriptionDeleted = "subscription.deleted"
+  ShadowCreated = "shadow.created"
+  ShadowDeleted = "shadow.deleted"
+  ClientCreated = "client.created"
+  ClientDeleted = "client.deleted"
+  RoleCreated = "role.created"
+  RoleDeleted = "role.deleted"
+  InviteCreated = "invite.created"
+  InviteSent = "invite.sent"
+  InviteRevoked = "invite.revoked"
+  InviteUpdated = "invite.updated"
+  InviteProvisioned = "invite.provisioned"
+)
+
+// States of a delivery
+const (
+  Pending = "pending"
+  Delivered = "delivered"
+  Dead = "dead"
+)
+
+// Number of deliveries by outcome: delivered, retried, dead and dropped. Exposed by expvar.
+var Deliveries = expvar.NewMap("meui.webhooks.deliveries")
+
+// Event is the payload posted to endpoints. Data is the item idp or aap answered with, for deletes the request identifying it.
+type Event struct {
+  Id string `json:"id"`
+  Type string `json:"type"`
+  CreatedAt int64 `json:"created_at"`
+  Actor string `json:"actor_id"` // Identity making the change
+  Data interface{} `json:"data"`
+}
+
+// Delivery is an event on its way to one endpoint
+type Delivery struct {
+  Id string
+  Endpoint string
+  Event Event
+  State string
+  Attempts int
+  Status int // Http status of the last attempt, 0 if the endpoint did not answer
+  Error string
+  LastAttemptAt time.Time
+  NextAttemptAt time.Time
+}
+
+// Dispatcher queues events and delivers them in the background, see Start
+type Dispatcher struct {
+  log *logrus.Entry
+  queue chan *Delivery
+
+  mu sync.Mutex
+  deliveries []*Delivery // Newest first, pending, delivered and dead
+  dead []*Delivery // Newest first
+}
+
+// Deliveries waiting for a worker, events emitted while the queue is full are dropped into the dead letters
+const queueSize = 1000
+
+func NewDispatcher(log *logrus.Entry) *Dispatcher {
+  return &Dispatcher{log: log, queue: make(chan *Delivery, queueSize)}
+}
+
+// Emit queues a delivery of the event to every endpoint whose filter matches. Never blocks the request emitting it.
+// A nil dispatcher emits nothing, so commands without webhooks can share the controllers.
+func (d *Dispatcher) Emit(eventType string, actor string, data interface{}) {
+  if d == nil {
+    return
+  }
+
+  id, err := uuid.NewV4()
+  if err != nil {
+    d.log.WithFields(logrus.Fields{"event": eventType}).Error("Unable to create webhook event id: " + err.Error())
+    return
+  }
+  event := Event{Id: id.String(), Type: eventType, CreatedAt: time.Now().Unix(), Actor: actor, Data: data}
+
+  for _, endpoint := range config.Get().Webhooks.Endpoints {
+    if !Matches(endpoint.Events, eventType) {
+      continue
+    }
+
+    id, err := uuid.NewV4()
+    if err != nil {
+      continue
+    }
+    delivery := &Delivery{Id: id.String(), Endpoint: endpoint.Name, Event: event, State: Pending, NextAttemptAt: time.Now()}
+    d.record(delivery)
+
+    select {
+    case d.queue <- delivery:
+    default:
+      d.finish(delivery, Dead, 0, "queue full")
+      Deliveries.Add("dropped", 1)
+    }
+  }
+}
+
+// Matches tells if an event passes the filters of an endpoint: the event name, a pattern like grant.* or * for all
+func Matches(filters []string, eventType string) bool {
+  for _, filter := range filters {
+    if filter == "*" || filter == eventType {
+      return true
+    }
+    if strings.HasSuffix(filter, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(filter, "*")) {
+      return true
+    }
+  }
+  return false
+}
+
+// ClientData is a client as sent in events, the secret never leaves meui
+func ClientData(client idp.Client) idp.Client {
+  client.Secret = ""
+  return client
+}
+
+// Log returns copies of the deliveries kept, newest first
+func (d *Dispatcher) Log() []Delivery {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  return copies(d.deliveries)
+}
+
+// DeadLetters returns copies of the deliveries that failed every attempt, newest first
+func (d *Dispatcher) DeadLetters() []Delivery {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  return copies(d.dead)
+}
+
+// Retry queues a dead letter again with fresh attempts. Returns false if id is not among the dead letters.
+func (d *Dispatcher) Retry(id string) bool {
+  d.mu.Lock()
+  var delivery *Delivery
+  for i, dl := range d.dead {
+    if dl.Id == id {
+      delivery = dl
+      d.dead = append(d.dead[:i], d.dead[i+1:]...)
+      break
+    }
+  }
+  if delivery != nil {
+    delivery.State = Pending
+    delivery.Attempts = 0
+    delivery.NextAttemptAt = time.Now()
+
+    // Back on top of the delivery log, it may have dropped out of it since
+    for i, dl := range d.deliveries {
+      if dl == delivery {
+        d.deliveries = append(d.deliveries[:i], d.deliveries[i+1:]...)
+        break
+      }
+    }
+    d.deliveries = prepend(d.deliveries, delivery, config.Get().Webhooks.LogSize)
+  }
+  d.mu.Unlock()
+
+  if delivery == nil {
+    return false
+  }
+
+  select {
+  case d.queue <- delivery:
+  default:
+    d.finish(delivery, Dead, 0, "queue full")
+    Deliveries.Add("dropped", 1)
+  }
+  return true
+}
+
+// Wait blocks until no delivery is pending or timeout has passed, so commands that exit right after emitting get their events out.
+// Returns the number of deliveries still pending.
+func (d *Dispatcher) Wait(timeout time.Duration) int {
+  if d == nil {
+    return 0
+  }
+
+  deadline := time.Now().Add(timeout)
+  for {
+    d.mu.Lock()
+    pending := 0
+    for _, delivery := range d.deliveries {
+      if delivery.State == Pending {
+        pending++
+      }
+    }
+    d.mu.Unlock()
+
+    if pending == 0 || time.Now().After(deadline) {
+      return pending
+    }
+    time.Sleep(100 * time.Millisecond)
+  }
+}
+
+func (d *Dispatcher) record(delivery *Delivery) {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  d.deliveries = prepend(d.deliveries, delivery, config.Get().Webhooks.LogSize)
+}
+
+// Marks the delivery delivered or dead, dead ones are kept in the dead letters as well
+func (d *Dispatcher) finish(delivery *Delivery, state string, status int, reason string) {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  delivery.State = state
+  delivery.Status = status
+  delivery.Error = reason
+  delivery.NextAttemptAt = time.Time{}
+  if state == Dead {
+    d.dead = prepend(d.dead, delivery, config.Get().Webhooks.LogSize)
+  }
+}
+
+func prepend(deliveries []*Delivery, delivery *Delivery, size int) []*Delivery {
+  deliveries = append([]*Delivery{delivery}, deliveries...)
+  if len(deliveries) > size {
+    deliveries = deliveries[:size]
+  }
+  return deliveries
+}
+
+func copies(deliveries []*Delivery) []Delivery {
+  list := make([]Delivery, len(deliveries))
+  for i, delivery := range deliveries {
+    list[i] = *delivery
+  }
+  return list
+}